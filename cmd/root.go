@@ -12,7 +12,6 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
-	"strconv"
 	"time"
 
 	"github.com/NYTimes/logrotate"
@@ -319,7 +318,7 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 	// Create a new HTTP server instance to handle inbound requests from the Panel
 	// and external clients.
 	s := &http.Server{
-		Addr:      api.Host + ":" + strconv.Itoa(api.Port),
+		Addr:      api.ListenAddress(),
 		Handler:   router.Configure(manager, pclient),
 		TLSConfig: config.DefaultTLSConfig,
 	}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
 	"os/user"
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -70,6 +72,11 @@ type SftpConfiguration struct {
 	ReadOnly bool `default:"false" yaml:"read_only"`
 }
 
+// ListenAddress returns the address that the SFTP server should listen on.
+func (c SftpConfiguration) ListenAddress() string {
+	return joinHostPort(c.Address, c.Port)
+}
+
 // ApiConfiguration defines the configuration for the internal API that is
 // exposed by the Wings webserver.
 type ApiConfiguration struct {
@@ -96,6 +103,33 @@ type ApiConfiguration struct {
 
 	// A list of IP address of proxies that may send a X-Forwarded-For header to set the true clients IP
 	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies"`
+
+	// Websocket defines the keepalive settings for server console websocket connections.
+	Websocket struct {
+		// PingInterval is the number of seconds between pings sent to each connected client.
+		// A client that does not respond to two consecutive pings is considered dead and the
+		// connection is closed. Set to 0 to disable pings.
+		PingInterval int `default:"30" json:"ping_interval" yaml:"ping_interval"`
+
+		// IdleTimeout is the number of seconds a client can go without sending any messages
+		// before the connection is closed. Responding to pings does not count as activity.
+		// Set to 0 to allow connections to stay idle indefinitely.
+		IdleTimeout int `default:"0" json:"idle_timeout" yaml:"idle_timeout"`
+	} `json:"websocket" yaml:"websocket"`
+}
+
+// ListenAddress returns the address that the internal webserver should listen
+// on. Every endpoint, including websockets and streamed responses, is served
+// from this single listener.
+func (c ApiConfiguration) ListenAddress() string {
+	return joinHostPort(c.Host, c.Port)
+}
+
+// joinHostPort combines a bind address and port into an address that can be
+// listened on. IPv6 addresses are wrapped in brackets as needed, so both "::"
+// and "[::]" can be used in the configuration to bind to every IPv6 interface.
+func joinHostPort(host string, port int) string {
+	return net.JoinHostPort(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), strconv.Itoa(port))
 }
 
 // RemoteQueryConfiguration defines the configuration settings for remote requests
@@ -231,6 +265,15 @@ type SystemConfiguration struct {
 
 	Transfers Transfers `yaml:"transfers"`
 
+	LogBundle LogBundle `yaml:"log_bundle"`
+
+	// RedactPatterns are regular expressions matching secrets, such as passwords or
+	// API tokens, that are replaced with "****" before file contents are shared,
+	// such as in log bundles and search snippets or previews, in addition to
+	// built-in patterns for common formats such as "password=...".
+	// If a pattern has a capture group only the text it matches is replaced.
+	RedactPatterns []string `yaml:"redact_patterns"`
+
 	OpenatMode string `default:"auto" yaml:"openat_mode"`
 }
 
@@ -257,9 +300,19 @@ type Backups struct {
 	// If the value is less than 1, the write speed is unlimited,
 	// if the value is greater than 0, the write speed is the value in MiB/s.
 	//
+	// This can be overridden for individual servers by the Panel.
+	//
 	// Defaults to 0 (unlimited)
 	WriteLimit int `default:"0" yaml:"write_limit"`
 
+	// MaxConcurrent is the most backups that can be generated at once across every
+	// server on the node. Any further backups are queued until one finishes, in the
+	// order that they were started, so that backups scheduled for the same time do
+	// not all compete for the disk and network at once.
+	//
+	// Defaults to 0 (unlimited)
+	MaxConcurrent int `default:"0" yaml:"max_concurrent"`
+
 	// CompressionLevel determines how much backups created by wings should be compressed.
 	//
 	// "none" -> no compression will be applied
@@ -280,6 +333,171 @@ type Transfers struct {
 	DownloadLimit int `default:"0" yaml:"download_limit"`
 }
 
+// LogBundle controls the bundles of logs and configuration files that can be
+// downloaded for a server to attach to support requests.
+type LogBundle struct {
+	// MaxSize is the largest that a bundle can be, in MiB, before any remaining
+	// files are cut short or left out.
+	MaxSize int `default:"10" yaml:"max_size"`
+
+	// LogLines is the number of lines of recent console output that are included.
+	LogLines int `default:"1000" yaml:"log_lines"`
+}
+
+// FilesystemConfiguration defines settings for the file operations that Wings
+// performs against server data directories.
+type FilesystemConfiguration struct {
+	// MaxSearchWorkers limits the total number of search workers that can be running
+	// at once across every server on this node. Each search request will use up to 8
+	// workers, and will wait for at least one to become available before it starts.
+	//
+	// Set to 0 to disable the limit. Changes require Wings to be restarted.
+	MaxSearchWorkers int `default:"0" json:"max_search_workers" yaml:"max_search_workers"`
+
+	// MaxPreviewBytes is the largest content preview that can be requested for each
+	// file returned by a search. Requests for larger previews are reduced to this size.
+	MaxPreviewBytes int `default:"4096" json:"max_preview_bytes" yaml:"max_preview_bytes"`
+
+	// MaxSearchMatches is the default number of results in a single search that can
+	// include content from the file they matched, such as a preview. Files are still
+	// matched and returned once this is reached, just without the extra content.
+	MaxSearchMatches int `default:"1000" json:"max_search_matches" yaml:"max_search_matches"`
+
+	// MaxSearchLimit is the largest number of results that a single search can ask
+	// for. Searches that ask for more are rejected. Set to 0 to disable the limit.
+	MaxSearchLimit int `default:"10000" json:"max_search_limit" yaml:"max_search_limit"`
+
+	// AllowContentSearch controls whether searches can look through the contents
+	// of files. Searching contents reads every file that is searched, which can
+	// thrash the disks of busy nodes, so it can be turned off to only allow
+	// searching file names. Searches that ask to include contents are rejected.
+	AllowContentSearch bool `default:"true" json:"allow_content_search" yaml:"allow_content_search"`
+
+	// MaxSearchFileSize is the largest size, in MiB, that a search can ask to read
+	// from each file when searching their contents. Searches that ask for more are
+	// rejected. Set to 0 to disable the limit.
+	MaxSearchFileSize int64 `default:"100" json:"max_search_file_size" yaml:"max_search_file_size"`
+
+	// MaxServerOperations limits how many heavy filesystem operations (searching,
+	// compressing, and copying files) a single server can be running at once. Any
+	// further requests are rejected until one of the running operations finishes.
+	//
+	// Set to 0 to disable the limit.
+	MaxServerOperations int `default:"4" json:"max_server_operations" yaml:"max_server_operations"`
+
+	// CancelOperationsOnStop cancels any background file operations running for a
+	// server, such as searches or deleting files, when the server is stopped or
+	// killed. Operations are always canceled when a server is deleted.
+	CancelOperationsOnStop bool `default:"true" json:"cancel_operations_on_stop" yaml:"cancel_operations_on_stop"`
+
+	// SymlinkPolicy controls how symlinks within server directories are handled when
+	// reading, searching, and compressing files. "reject" returns an error when a
+	// symlink is accessed, "follow" resolves symlinks with a relative target that stays
+	// within the server directory, and "ignore" treats symlinks as if they do not exist.
+	// Changes require Wings to be restarted.
+	SymlinkPolicy string `default:"reject" json:"symlink_policy" yaml:"symlink_policy"`
+
+	// SearchIndexMaxEntries enables an in-memory index of the files in each server
+	// directory that is built the first time a server is searched and kept up to date
+	// by watching for changes, so that later searches do not need to walk the disk.
+	// Servers with more files than this are not indexed.
+	//
+	// Set to 0 to disable the index.
+	SearchIndexMaxEntries int `default:"0" json:"search_index_max_entries" yaml:"search_index_max_entries"`
+
+	// MaxArchiveDownloadSize is the largest total size, in MiB, of the files that can be
+	// selected when downloading them as a single archive. Set to 0 to disable the limit.
+	MaxArchiveDownloadSize int64 `default:"2048" json:"max_archive_download_size" yaml:"max_archive_download_size"`
+
+	// MaxArchiveDownloadEntries is the largest number of files that can be selected
+	// when downloading them as a single archive. Set to 0 to disable the limit.
+	MaxArchiveDownloadEntries int `default:"50000" json:"max_archive_download_entries" yaml:"max_archive_download_entries"`
+
+	// MaxOpenFiles limits how many files can be held open at once by operations that
+	// open files concurrently, such as searching, copying, and generating backups.
+	// Operations wait for a file to be closed once the limit is reached, rather than
+	// failing with "too many open files".
+	//
+	// Set to 0 to use half of the open file limit for the Wings process, or to -1 to
+	// disable the limit. Changes require Wings to be restarted.
+	MaxOpenFiles int `default:"0" json:"max_open_files" yaml:"max_open_files"`
+
+	// MaxPathDepth is the deepest that a file or directory can be nested within the
+	// server directory, counting the number of directories above it. Creating a file
+	// or directory any deeper, whether by writing, moving, or extracting an archive,
+	// fails. This keeps recursive operations such as searching and backups safe from
+	// maliciously deep directory trees. Set to 0 to disable the limit.
+	MaxPathDepth int `default:"256" json:"max_path_depth" yaml:"max_path_depth"`
+
+	// MimeOverrides maps file extensions, such as ".yml", to the mimetype reported for
+	// files with that extension when their contents cannot be classified and would
+	// otherwise be reported as "application/octet-stream". These are merged over the
+	// built-in defaults for common game server configuration formats, an empty value
+	// removes a default.
+	MimeOverrides map[string]string `json:"mime_overrides" yaml:"mime_overrides"`
+
+	// TransientRetries is the number of times that stating, opening, or reading a file
+	// is retried while searching when it fails with an error that is likely to be
+	// temporary, such as an I/O error on network backed storage. Each retry waits
+	// twice as long as the last, starting from 25ms. Errors such as a file not existing
+	// are never retried.
+	//
+	// Set to 0 to disable retries.
+	TransientRetries int `default:"2" json:"transient_retries" yaml:"transient_retries"`
+
+	// SearchDisallowedPaths are directories within every server directory, such as
+	// "secrets" or ".config/keys", that can never be searched. Searches are rejected
+	// if their root is within one of these directories, and they are skipped by any
+	// search that would otherwise enter them. Paths are relative to the root of the
+	// server directory.
+	SearchDisallowedPaths []string `json:"search_disallowed_paths" yaml:"search_disallowed_paths"`
+
+	// SearchContentExtensions are the only file extensions, such as ".yml" or
+	// ".properties", whose contents are searched when a search includes them. Files
+	// with any other extension, or none at all, are not opened. Leave empty to allow
+	// every extension. Names of files are matched regardless of their extension.
+	SearchContentExtensions []string `json:"search_content_extensions" yaml:"search_content_extensions"`
+
+	// SearchSkipContentExtensions are file extensions whose contents are never
+	// searched, since they are almost always binary. Files with these extensions are
+	// not opened at all, which saves reading the start of every region file and
+	// texture in a world just to find out that it cannot be searched. Extensions
+	// may have more than one part, such as ".tar.gz". Set to an empty list to open
+	// every file.
+	SearchSkipContentExtensions []string `default:"[\".jar\",\".zip\",\".7z\",\".rar\",\".mca\",\".mcr\",\".png\",\".jpg\",\".jpeg\",\".gif\",\".webp\",\".ogg\",\".mp3\",\".class\",\".so\",\".dll\",\".exe\"]" json:"search_skip_content_extensions" yaml:"search_skip_content_extensions"`
+
+	// FileChangeDebounce is the window, in milliseconds, that changes to files seen by
+	// the file index watcher are collected over before being sent to websocket
+	// clients. Every change to the same path within the window is sent as a single
+	// change, so saving a file in an editor does not send a storm of events.
+	//
+	// Set to 0 to send every change as soon as it is seen.
+	FileChangeDebounce int `default:"250" json:"file_change_debounce" yaml:"file_change_debounce"`
+
+	// SearchReadLimit imposes a Disk I/O read limit, in MiB/s, on reading the contents
+	// of files while searching a server. The limit is shared between every worker of a
+	// single search. This can be overridden for individual servers by the Panel.
+	//
+	// Set to 0 to disable the limit.
+	SearchReadLimit int `default:"0" json:"search_read_limit" yaml:"search_read_limit"`
+
+	// SearchLowMemory runs every search in low memory mode, for nodes with very
+	// little RAM. Searches use fewer workers and smaller buffers, never include
+	// content previews or snippets, and stream their results to the client rather
+	// than collecting them first, which also means the results are not sorted.
+	// Searches can also ask for this mode themselves when it is not enabled here.
+	SearchLowMemory bool `default:"false" json:"search_low_memory" yaml:"search_low_memory"`
+
+	// MaxAsyncSearches is the most searches that can be running in the background
+	// for a single server at once, further background searches are rejected until
+	// one of them finishes. Set to 0 to disable background searches.
+	MaxAsyncSearches int `default:"2" json:"max_async_searches" yaml:"max_async_searches"`
+
+	// AsyncSearchTTL is how long, in seconds, the results of a background search are
+	// kept once it has finished for the client to collect them.
+	AsyncSearchTTL int `default:"600" json:"async_search_ttl" yaml:"async_search_ttl"`
+}
+
 type ConsoleThrottles struct {
 	// Whether or not the throttler is enabled for this instance.
 	Enabled bool `json:"enabled" yaml:"enabled" default:"true"`
@@ -319,6 +537,8 @@ type Configuration struct {
 	System SystemConfiguration `json:"system" yaml:"system"`
 	Docker DockerConfiguration `json:"docker" yaml:"docker"`
 
+	Filesystem FilesystemConfiguration `json:"filesystem" yaml:"filesystem"`
+
 	// Defines internal throttling configurations for server processes to prevent
 	// someone from running an endless loop that spams data to logs.
 	Throttles ConsoleThrottles
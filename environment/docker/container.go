@@ -337,6 +337,49 @@ func (e *Environment) Readlog(lines int) ([]string, error) {
 	return out, nil
 }
 
+// ReadlogSince reads the lines of the log written after the given time with
+// the time that each was written, see environment.ProcessEnvironment. Docker
+// includes lines written at exactly the given time, those are skipped since the
+// client has already seen them.
+func (e *Environment) ReadlogSince(since time.Time, lines int) ([]environment.LogLine, bool, error) {
+	opts := container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+	}
+	if since.IsZero() {
+		opts.Tail = strconv.Itoa(lines)
+	} else {
+		opts.Since = fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond())
+	}
+	r, err := e.client.ContainerLogs(context.Background(), e.Id, opts)
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+	defer r.Close()
+
+	out := make([]environment.LogLine, 0, lines)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		ts, line, _ := strings.Cut(scanner.Text(), " ")
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			continue
+		}
+		if !since.IsZero() && !t.After(since) {
+			continue
+		}
+		// Stop reading as soon as there is one line more than can be returned, the
+		// client can continue from the last line that was.
+		if len(out) == lines {
+			return out, true, nil
+		}
+		out = append(out, environment.LogLine{Time: t, Line: line})
+	}
+
+	return out, false, nil
+}
+
 // Pulls the image from Docker. If there is an error while pulling the image
 // from the source but the image already exists locally, we will report that
 // error to the logger but continue with the process.
@@ -0,0 +1,111 @@
+package docker
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/kristiangarcia/wings/environment"
+)
+
+// Processes returns every process that is currently running within the
+// container.
+func (e *Environment) Processes(ctx context.Context) ([]environment.Process, error) {
+	top, err := e.client.ContainerTop(ctx, e.Id, []string{"-eo", "pid,ppid,pcpu,pmem,args"})
+	if err != nil {
+		return nil, errors.WrapIf(err, "environment/docker: failed to list container processes")
+	}
+
+	// Docker returns each process as a list of columns matching the titles, the
+	// order is not guaranteed so look up each column by name.
+	columns := make(map[string]int, len(top.Titles))
+	for i, t := range top.Titles {
+		columns[t] = i
+	}
+	column := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	out := make([]environment.Process, 0, len(top.Processes))
+	for _, row := range top.Processes {
+		pid, err := strconv.Atoi(column(row, "PID"))
+		if err != nil {
+			continue
+		}
+		ppid, _ := strconv.Atoi(column(row, "PPID"))
+		cpu, _ := strconv.ParseFloat(column(row, "%CPU"), 64)
+		mem, _ := strconv.ParseFloat(column(row, "%MEM"), 64)
+		out = append(out, environment.Process{
+			Pid:     pid,
+			Ppid:    ppid,
+			Cpu:     cpu,
+			Memory:  mem,
+			Command: column(row, "COMMAND"),
+		})
+	}
+	return out, nil
+}
+
+// SignalProcess sends the given signal to a single process running within the
+// container. The process ID is the one seen by the host system, as returned by
+// Processes, and must belong to this container.
+//
+// The process is opened as a pidfd before checking that it is within the
+// container, and the signal is sent through that descriptor. If the process
+// exits and its ID is reused by another process at any point, the descriptor
+// still refers to the original process, so the signal can never reach a
+// process outside of the container.
+func (e *Environment) SignalProcess(ctx context.Context, pid int, signal string) error {
+	sig, err := parseSignal(signal)
+	if err != nil {
+		return err
+	}
+
+	fd, err := unix.PidfdOpen(pid, 0)
+	if err != nil {
+		if errors.Is(err, unix.ESRCH) {
+			return errors.WithStack(environment.ErrProcessNotFound)
+		}
+		return errors.Wrap(err, "environment/docker: failed to open process")
+	}
+	defer unix.Close(fd)
+
+	processes, err := e.Processes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range processes {
+		if p.Pid != pid {
+			continue
+		}
+		if err := unix.PidfdSendSignal(fd, sig, nil, 0); err != nil {
+			if errors.Is(err, unix.ESRCH) {
+				return errors.WithStack(environment.ErrProcessNotFound)
+			}
+			return errors.Wrap(err, "environment/docker: failed to signal process")
+		}
+		return nil
+	}
+	return errors.WithStack(environment.ErrProcessNotFound)
+}
+
+// parseSignal returns the signal with the given number, or name with or without
+// the "SIG" prefix.
+func parseSignal(signal string) (unix.Signal, error) {
+	var sig unix.Signal
+	if n, err := strconv.Atoi(signal); err == nil {
+		sig = unix.Signal(n)
+	} else if sig = unix.SignalNum(strings.ToUpper(signal)); sig == 0 {
+		sig = unix.SignalNum("SIG" + strings.ToUpper(signal))
+	}
+	if sig <= 0 || unix.SignalName(sig) == "" {
+		return 0, errors.WithStack(environment.ErrUnknownSignal)
+	}
+	return sig, nil
+}
@@ -0,0 +1,32 @@
+package docker
+
+import (
+	"testing"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+	"golang.org/x/sys/unix"
+
+	"github.com/kristiangarcia/wings/environment"
+)
+
+func TestParseSignal(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("parseSignal", func() {
+		g.It("accepts signals by number and by name", func() {
+			for _, s := range []string{"15", "SIGTERM", "sigterm", "TERM", "term"} {
+				sig, err := parseSignal(s)
+				g.Assert(err).IsNil()
+				g.Assert(sig).Equal(unix.SIGTERM)
+			}
+		})
+
+		g.It("rejects unknown signals", func() {
+			for _, s := range []string{"", "0", "-9", "1000", "SIGNOPE", "kill -9"} {
+				_, err := parseSignal(s)
+				g.Assert(errors.Is(err, environment.ErrUnknownSignal)).IsTrue(s)
+			}
+		})
+	})
+}
@@ -22,6 +22,13 @@ const (
 	ProcessStoppingState = "stopping"
 )
 
+// LogLine is a single line of output from the process and the time that it was
+// written.
+type LogLine struct {
+	Time time.Time `json:"time"`
+	Line string    `json:"line"`
+}
+
 // Defines the basic interface that all environments need to implement so that
 // a server can be properly controlled.
 type ProcessEnvironment interface {
@@ -98,6 +105,13 @@ type ProcessEnvironment interface {
 	// number of lines is met.
 	Readlog(int) ([]string, error)
 
+	// Reads up to the provided number of lines from the log that were written after
+	// the given time, oldest first, so that a client that has already seen the log
+	// up to that time can continue from where it left off. If the time is zero the
+	// most recent lines are returned instead. Also returns whether there were more
+	// lines after the time than could be returned.
+	ReadlogSince(since time.Time, lines int) ([]LogLine, bool, error)
+
 	// Returns the current state of the environment.
 	State() string
 
@@ -112,4 +126,12 @@ type ProcessEnvironment interface {
 
 	// SetLogCallback sets the callback that the container's log output will be passed to.
 	SetLogCallback(func([]byte))
+
+	// Processes returns every process that is currently running within the
+	// environment.
+	Processes(ctx context.Context) ([]Process, error)
+
+	// SignalProcess sends a signal to a single process running within the
+	// environment, returning an error if the process is not part of it.
+	SignalProcess(ctx context.Context, pid int, signal string) error
 }
@@ -0,0 +1,23 @@
+package environment
+
+import "emperror.dev/errors"
+
+// ErrProcessNotFound is returned when attempting to signal a process that is
+// not running within the environment.
+var ErrProcessNotFound = errors.Sentinel("environment: process is not running in environment")
+
+// ErrUnknownSignal is returned when the signal provided is not recognized.
+var ErrUnknownSignal = errors.Sentinel("environment: unknown signal")
+
+// Process is a single process running within a server environment.
+type Process struct {
+	// The ID of the process as seen by the host system.
+	Pid int `json:"pid"`
+	// The ID of the parent process as seen by the host system.
+	Ppid int `json:"ppid"`
+	// The percentage of a single CPU core that the process is using.
+	Cpu float64 `json:"cpu"`
+	// The percentage of the system memory that the process is using.
+	Memory  float64 `json:"memory"`
+	Command string  `json:"command"`
+}
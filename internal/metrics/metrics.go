@@ -0,0 +1,180 @@
+// Package metrics defines the Prometheus collectors used to report on the
+// operations Wings performs.
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "wings"
+
+var (
+	// SearchDuration tracks how long file searches take to complete, split by
+	// whether file contents were also searched.
+	SearchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "filesystem",
+		Name:      "search_duration_seconds",
+		Help:      "The time taken to complete a file search.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"content"})
+
+	// Searches counts the number of file searches performed for each server.
+	Searches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "filesystem",
+		Name:      "searches_total",
+		Help:      "The number of file searches performed.",
+	}, []string{"server"})
+
+	// SearchBytesRead counts the number of bytes read from files while searching
+	// their contents.
+	SearchBytesRead = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "filesystem",
+		Name:      "search_bytes_read_total",
+		Help:      "The number of bytes read while searching file contents.",
+	})
+
+	// ActiveOperations tracks the number of file operations that are currently
+	// being performed, split by the type of operation.
+	ActiveOperations = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "filesystem",
+		Name:      "active_operations",
+		Help:      "The number of file operations currently in progress.",
+	}, []string{"operation"})
+
+	// Backups counts the number of backups that have been generated, split by
+	// whether they were successful or not.
+	Backups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "backup",
+		Name:      "generated_total",
+		Help:      "The number of server backups generated.",
+	}, []string{"status"})
+
+	// BackupDuration tracks how long it takes to generate a backup.
+	BackupDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "backup",
+		Name:      "duration_seconds",
+		Help:      "The time taken to generate a server backup.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+	}, []string{"status"})
+)
+
+var diskUsageDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "server", "disk_usage_bytes"),
+	"The amount of disk space used by a server.",
+	[]string{"server"},
+	nil,
+)
+
+// diskUsageCollector reports the disk usage of every server on the node each
+// time the metrics are scraped.
+type diskUsageCollector struct {
+	usage atomic.Pointer[func() map[string]int64]
+}
+
+var (
+	diskUsage         = &diskUsageCollector{}
+	registerDiskUsage sync.Once
+)
+
+func (c *diskUsageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- diskUsageDesc
+}
+
+func (c *diskUsageCollector) Collect(ch chan<- prometheus.Metric) {
+	usage := c.usage.Load()
+	if usage == nil {
+		return
+	}
+	for id, bytes := range (*usage)() {
+		ch <- prometheus.MustNewConstMetric(diskUsageDesc, prometheus.GaugeValue, float64(bytes), id)
+	}
+}
+
+// RegisterDiskUsage registers a collector that reports the disk usage returned
+// by the provided function, keyed by server ID. The function is called every
+// time the metrics are scraped so it should not perform any expensive lookups.
+// The collector is only registered once, calling this again replaces the
+// function it reports the usage from.
+func RegisterDiskUsage(usage func() map[string]int64) {
+	diskUsage.usage.Store(&usage)
+	registerDiskUsage.Do(func() {
+		prometheus.MustRegister(diskUsage)
+	})
+}
+
+// ServerStats is a snapshot of the resources being used by a single server.
+type ServerStats struct {
+	Server           string
+	CpuAbsolute      float64
+	MemoryBytes      uint64
+	MemoryLimitBytes uint64
+	DiskBytes        int64
+	DiskLimitBytes   int64
+	NetworkRxBytes   uint64
+	NetworkTxBytes   uint64
+	// The uptime of the server process in milliseconds.
+	Uptime int64
+}
+
+func serverDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "server", name), help, []string{"server"}, nil)
+}
+
+var (
+	serverCpuDesc         = serverDesc("cpu_absolute", "The CPU usage of a server relative to the entire node, as a percentage.")
+	serverMemoryDesc      = serverDesc("memory_bytes", "The amount of memory used by a server.")
+	serverMemoryLimitDesc = serverDesc("memory_limit_bytes", "The amount of memory a server is allowed to use.")
+	serverDiskLimitDesc   = serverDesc("disk_limit_bytes", "The amount of disk space a server is allowed to use, 0 if it is unlimited.")
+	serverRxDesc          = serverDesc("network_receive_bytes_total", "The number of bytes received by a server.")
+	serverTxDesc          = serverDesc("network_transmit_bytes_total", "The number of bytes transmitted by a server.")
+	serverUptimeDesc      = serverDesc("uptime_seconds", "How long the server process has been running.")
+)
+
+// serverStatsCollector reports a single snapshot of the resource usage of a
+// server.
+type serverStatsCollector struct {
+	stats ServerStats
+}
+
+func (c *serverStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		serverCpuDesc, serverMemoryDesc, serverMemoryLimitDesc, diskUsageDesc,
+		serverDiskLimitDesc, serverRxDesc, serverTxDesc, serverUptimeDesc,
+	} {
+		ch <- d
+	}
+}
+
+func (c *serverStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, s.Server)
+	}
+	gauge(serverCpuDesc, s.CpuAbsolute)
+	gauge(serverMemoryDesc, float64(s.MemoryBytes))
+	gauge(serverMemoryLimitDesc, float64(s.MemoryLimitBytes))
+	gauge(diskUsageDesc, float64(s.DiskBytes))
+	gauge(serverDiskLimitDesc, float64(s.DiskLimitBytes))
+	gauge(serverUptimeDesc, float64(s.Uptime)/1000)
+	ch <- prometheus.MustNewConstMetric(serverRxDesc, prometheus.CounterValue, float64(s.NetworkRxBytes), s.Server)
+	ch <- prometheus.MustNewConstMetric(serverTxDesc, prometheus.CounterValue, float64(s.NetworkTxBytes), s.Server)
+}
+
+// NewServerStatsRegistry returns a registry that only contains the given resource
+// usage of a server, so that it can be scraped on its own rather than as part of
+// the metrics for the whole node.
+func NewServerStatsRegistry(stats ServerStats) *prometheus.Registry {
+	r := prometheus.NewRegistry()
+	r.MustRegister(&serverStatsCollector{stats: stats})
+	return r
+}
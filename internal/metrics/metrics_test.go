@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRegisterDiskUsage(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("RegisterDiskUsage", func() {
+		g.It("can be called more than once", func() {
+			RegisterDiskUsage(func() map[string]int64 { return map[string]int64{"a": 1} })
+			RegisterDiskUsage(func() map[string]int64 { return map[string]int64{"b": 2} })
+
+			families, err := prometheus.DefaultGatherer.Gather()
+			g.Assert(err).IsNil()
+			var servers []string
+			for _, f := range families {
+				if f.GetName() != "wings_server_disk_usage_bytes" {
+					continue
+				}
+				for _, m := range f.GetMetric() {
+					for _, l := range m.GetLabel() {
+						servers = append(servers, l.GetValue())
+					}
+				}
+			}
+			g.Assert(servers).Equal([]string{"b"})
+		})
+	})
+}
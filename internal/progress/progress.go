@@ -15,6 +15,10 @@ type Progress struct {
 	written uint64
 	// Total is the total size of the archive in bytes.
 	total uint64
+	// files is the number of files that have been processed.
+	files uint64
+	// current is the path of the file currently being processed.
+	current atomic.Pointer[string]
 
 	// Writer .
 	Writer io.Writer
@@ -44,6 +48,27 @@ func (p *Progress) SetTotal(total uint64) {
 	atomic.StoreUint64(&p.total, total)
 }
 
+// AddFile records that the file at the given path is being processed, which
+// increments the number of files processed and updates the current path.
+func (p *Progress) AddFile(name string) {
+	atomic.AddUint64(&p.files, 1)
+	p.current.Store(&name)
+}
+
+// Files returns the number of files that have been processed.
+func (p *Progress) Files() uint64 {
+	return atomic.LoadUint64(&p.files)
+}
+
+// Current returns the path of the file currently being processed, or an empty
+// string if no files have been processed yet.
+func (p *Progress) Current() string {
+	if v := p.current.Load(); v != nil {
+		return *v
+	}
+	return ""
+}
+
 // Write totals the number of bytes that have been written to the writer.
 func (p *Progress) Write(v []byte) (int, error) {
 	n := len(v)
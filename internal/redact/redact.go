@@ -0,0 +1,73 @@
+// Package redact removes secrets, such as passwords and API tokens, from text
+// before it is shown to someone who may not be allowed to see them.
+package redact
+
+import (
+	"regexp"
+	"slices"
+
+	"emperror.dev/errors"
+)
+
+// Placeholder is what every redacted secret is replaced with.
+const Placeholder = "****"
+
+// DefaultPatterns match the most common ways that secrets appear in
+// configuration files and logs, such as "password=hunter2" or "token: abc". Only
+// the value is redacted, the name is kept so that it is clear what was removed.
+var DefaultPatterns = []string{
+	`(?i)(?:password|passwd|pass|secret|token|api[_-]?key|access[_-]?key|private[_-]?key)["']?\s*[:=]\s*["']?([^\s"',;]+)`,
+	`(?i)\bbearer\s+([a-z0-9._~+/=-]+)`,
+}
+
+// Redactor replaces the parts of text matched by its patterns. If a pattern has
+// a capture group only the text matched by the first group is replaced,
+// otherwise the entire match is.
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// New returns a redactor for the default patterns along with the given ones. Any
+// pattern that is not a valid regular expression is left out and returned in
+// the error, the redactor is always usable.
+func New(patterns []string) (*Redactor, error) {
+	r := &Redactor{}
+	var errs []error
+	for _, p := range slices.Concat(DefaultPatterns, patterns) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "redact: invalid pattern %q", p))
+			continue
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, errors.Combine(errs...)
+}
+
+// Redact returns b with every secret replaced by the placeholder.
+func (r *Redactor) Redact(b []byte) []byte {
+	for _, re := range r.patterns {
+		matches := re.FindAllSubmatchIndex(b, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		out := make([]byte, 0, len(b))
+		var last int
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			out = append(out, b[last:start]...)
+			out = append(out, Placeholder...)
+			last = end
+		}
+		b = append(out, b[last:]...)
+	}
+	return b
+}
+
+// RedactString is Redact for a string.
+func (r *Redactor) RedactString(s string) string {
+	return string(r.Redact([]byte(s)))
+}
@@ -0,0 +1,35 @@
+package redact_test
+
+import (
+	"testing"
+
+	"github.com/franela/goblin"
+
+	"github.com/kristiangarcia/wings/internal/redact"
+)
+
+func TestRedactor(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	g.Describe("Redactor", func() {
+		g.It("redacts only the value of common secrets", func() {
+			r, err := redact.New(nil)
+			g.Assert(err).IsNil()
+			g.Assert(r.RedactString("rcon.password=hunter2\nmotd=hello")).Equal("rcon.password=****\nmotd=hello")
+			g.Assert(r.RedactString(`"api_key": "abc123",`)).Equal(`"api_key": "****",`)
+			g.Assert(r.RedactString("Authorization: Bearer abc.def")).Equal("Authorization: Bearer ****")
+		})
+
+		g.It("redacts the whole match of a pattern without a group", func() {
+			r, err := redact.New([]string{`sk_live_[a-z0-9]+`})
+			g.Assert(err).IsNil()
+			g.Assert(r.RedactString("key sk_live_abc123 and sk_live_def")).Equal("key **** and ****")
+		})
+
+		g.It("skips invalid patterns but still redacts", func() {
+			r, err := redact.New([]string{`(`})
+			g.Assert(err).IsNotNil()
+			g.Assert(r.RedactString("password=hunter2")).Equal("password=****")
+		})
+	})
+}
@@ -24,6 +24,9 @@ var (
 	// ErrNotRegular is an error for when an operation that operates only on
 	// regular files is passed something other than a regular file.
 	ErrNotRegular = errors.New("not a regular file")
+	// ErrPathTooDeep is an error for when creating an entry would exceed the
+	// maximum path depth of a sand-boxed filesystem.
+	ErrPathTooDeep = errors.New("path is nested too deeply")
 
 	// ErrClosed is an error for when an entry was accessed after being closed.
 	ErrClosed = iofs.ErrClosed
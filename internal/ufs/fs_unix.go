@@ -28,6 +28,10 @@ type UnixFS struct {
 	// useOpenat2 controls whether the `openat2` syscall is used instead of the
 	// older `openat` syscall.
 	useOpenat2 bool
+
+	// maxDepth is the maximum number of elements in the path of a newly created
+	// entry, or 0 if there is no limit.
+	maxDepth int
 }
 
 // NewUnixFS creates a new sandboxed unix filesystem. BasePath is used as the
@@ -43,6 +47,54 @@ func NewUnixFS(basePath string, useOpenat2 bool) (*UnixFS, error) {
 	return fs, nil
 }
 
+// SetMaxDepth sets the maximum number of elements in the path of any file,
+// directory, or symlink that is created, including by a rename. Going any deeper
+// fails with ErrPathTooDeep. A value of 0 removes the limit.
+//
+// This should be called before the filesystem is used.
+func (fs *UnixFS) SetMaxDepth(n int) {
+	fs.maxDepth = max(n, 0)
+}
+
+// checkDepth returns an error if the path is deeper than the maximum depth of
+// the filesystem.
+func (fs *UnixFS) checkDepth(op, path string) error {
+	if fs.maxDepth == 0 {
+		return nil
+	}
+	name, err := fs.unsafePath(path)
+	if err != nil {
+		return err
+	}
+	if name != "." && strings.Count(name, "/")+1 > fs.maxDepth {
+		return &PathError{Op: op, Path: path, Err: ErrPathTooDeep}
+	}
+	return nil
+}
+
+// checkTreeDepth returns an error if moving the directory opened relative to
+// dirfd to the given path would leave anything within it deeper than the
+// maximum depth of the filesystem.
+func (fs *UnixFS) checkTreeDepth(op string, dirfd int, name, path string) error {
+	if fs.maxDepth == 0 {
+		return nil
+	}
+	newname, err := fs.unsafePath(path)
+	if err != nil {
+		return err
+	}
+	depth := strings.Count(newname, "/") + 1
+	return fs.WalkDirat(dirfd, name, func(_ int, _, relative string, _ DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if relative != "." && depth+strings.Count(relative, "/")+1 > fs.maxDepth {
+			return &PathError{Op: op, Path: path, Err: ErrPathTooDeep}
+		}
+		return nil
+	})
+}
+
 // BasePath returns the base path of the UnixFS sandbox, file operations
 // pointing outside this path are prohibited and will be blocked by all
 // operations implemented by UnixFS.
@@ -150,6 +202,31 @@ func (fs *UnixFS) Chtimes(name string, atime, mtime time.Time) error {
 // Chtimesat is like Chtimes but allows passing an existing directory file
 // descriptor rather than needing to resolve one.
 func (fs *UnixFS) Chtimesat(dirfd int, name string, atime, mtime time.Time) error {
+	return fs.chtimesat(dirfd, name, atime, mtime, 0)
+}
+
+// Lchtimes changes the access and modification times of the named file.
+//
+// If the file is a symbolic link, it changes the times of the link itself.
+// If there is an error, it will be of type *PathError.
+func (fs *UnixFS) Lchtimes(name string, atime, mtime time.Time) error {
+	dirfd, name, closeFd, err := fs.safePath(name)
+	defer closeFd()
+	if err != nil {
+		return err
+	}
+	return fs.Lchtimesat(dirfd, name, atime, mtime)
+}
+
+// Lchtimesat is like Lchtimes but allows passing an existing directory file
+// descriptor rather than needing to resolve one.
+func (fs *UnixFS) Lchtimesat(dirfd int, name string, atime, mtime time.Time) error {
+	return fs.chtimesat(dirfd, name, atime, mtime, AT_SYMLINK_NOFOLLOW)
+}
+
+// chtimesat is a re-usable UtimesNanoAt syscall used by Chtimesat and
+// Lchtimesat. A zero time leaves that time unchanged.
+func (fs *UnixFS) chtimesat(dirfd int, name string, atime, mtime time.Time, flags int) error {
 	var utimes [2]unix.Timespec
 	set := func(i int, t time.Time) {
 		if t.IsZero() {
@@ -160,8 +237,7 @@ func (fs *UnixFS) Chtimesat(dirfd int, name string, atime, mtime time.Time) erro
 	}
 	set(0, atime)
 	set(1, mtime)
-	// This does support `AT_SYMLINK_NOFOLLOW` as well if needed.
-	if err := unix.UtimesNanoAt(dirfd, name, utimes[0:], 0); err != nil {
+	if err := unix.UtimesNanoAt(dirfd, name, utimes[0:], flags); err != nil {
 		return convertErrorType(&PathError{Op: "chtimes", Path: name, Err: err})
 	}
 	return nil
@@ -183,6 +259,9 @@ func (fs *UnixFS) Create(name string) (File, error) {
 //
 // If there is an error, it will be of type *PathError.
 func (fs *UnixFS) Mkdir(name string, mode FileMode) error {
+	if err := fs.checkDepth("mkdir", name); err != nil {
+		return err
+	}
 	dirfd, name, closeFd, err := fs.safePath(name)
 	defer closeFd()
 	if err != nil {
@@ -242,6 +321,11 @@ func (fs *UnixFS) OpenFile(name string, flag int, mode FileMode) (File, error) {
 }
 
 func (fs *UnixFS) openFile(name string, flag int, mode FileMode) (int, error) {
+	if flag&O_CREATE != 0 {
+		if err := fs.checkDepth("open", name); err != nil {
+			return 0, err
+		}
+	}
 	dirfd, name, closeFd, err := fs.safePath(name)
 	defer closeFd()
 	if err != nil {
@@ -408,6 +492,11 @@ func (fs *UnixFS) Rename(oldpath, newpath string) error {
 	if oldpath == newpath {
 		return nil
 	}
+	// The depth of anything nested within a directory that is being moved is
+	// checked once the old path has been opened.
+	if err := fs.checkDepth("rename", newpath); err != nil {
+		return err
+	}
 
 	olddirfd, oldname, closeFd, err := fs.safePath(oldpath)
 	defer closeFd()
@@ -425,9 +514,15 @@ func (fs *UnixFS) Rename(oldpath, newpath string) error {
 		})
 	}
 	// Stat the old target to return proper errors.
-	if _, err := fs.Lstatat(olddirfd, oldname); err != nil {
+	st, err := fs.Lstatat(olddirfd, oldname)
+	if err != nil {
 		return err
 	}
+	if st.IsDir() {
+		if err := fs.checkTreeDepth("rename", olddirfd, oldname, newpath); err != nil {
+			return err
+		}
+	}
 
 	newdirfd, newname, closeFd2, err := fs.safePath(newpath)
 	if err != nil {
@@ -540,6 +635,9 @@ func (fs *UnixFS) _fstatat(op string, dirfd int, name string, flags int) (FileIn
 //
 // If there is an error, it will be of type *LinkError.
 func (fs *UnixFS) Symlink(oldpath, newpath string) error {
+	if err := fs.checkDepth("symlink", newpath); err != nil {
+		return err
+	}
 	dirfd, newpath, closeFd, err := fs.safePath(newpath)
 	defer closeFd()
 	if err != nil {
@@ -563,6 +661,9 @@ func (fs *UnixFS) Touch(path string, flag int, mode FileMode) (File, error) {
 	if flag&O_CREATE == 0 {
 		flag |= O_CREATE
 	}
+	if err := fs.checkDepth("touch", path); err != nil {
+		return nil, err
+	}
 	dirfd, name, closeFd, err := fs.safePath(path)
 	defer closeFd()
 	if err == nil {
@@ -13,6 +13,7 @@ import (
 	"slices"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/kristiangarcia/wings/internal/ufs"
 )
@@ -291,6 +292,54 @@ func TestUnixFS_Chtimes(t *testing.T) {
 	// TODO: implement
 }
 
+func TestUnixFS_Lchtimes(t *testing.T) {
+	t.Parallel()
+	fs, err := newTestUnixFS()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer fs.Cleanup()
+
+	outside := filepath.Join(fs.TmpDir, "outside")
+	if err := os.WriteFile(outside, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+		return
+	}
+	before, err := os.Stat(outside)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err := os.Symlink(outside, filepath.Join(fs.Root, "link")); err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mtime := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	if err := fs.Lchtimes("link", time.Time{}, mtime); err != nil {
+		t.Error(err)
+		return
+	}
+
+	st, err := os.Lstat(filepath.Join(fs.Root, "link"))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if !st.ModTime().Equal(mtime) {
+		t.Errorf("expected the link to have a modification time of %s, got %s", mtime, st.ModTime())
+	}
+	after, err := os.Stat(outside)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if !after.ModTime().Equal(before.ModTime()) {
+		t.Error("expected the target of the link to be left unchanged")
+	}
+}
+
 func TestUnixFS_Create(t *testing.T) {
 	t.Parallel()
 	fs, err := newTestUnixFS()
@@ -332,6 +381,75 @@ func TestUnixFS_MkdirAll(t *testing.T) {
 	// TODO: stat sanity check
 }
 
+func TestUnixFS_MaxDepth(t *testing.T) {
+	t.Parallel()
+	fs, err := newTestUnixFS()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer fs.Cleanup()
+	fs.SetMaxDepth(3)
+
+	t.Run("within the limit", func(t *testing.T) {
+		if err := fs.MkdirAll("a/b/c", 0o755); err != nil {
+			t.Errorf("expected directory to be created: %v", err)
+			return
+		}
+		f, err := fs.Touch("a/b/file", ufs.O_RDWR, 0o644)
+		if err != nil {
+			t.Errorf("expected file to be created: %v", err)
+			return
+		}
+		_ = f.Close()
+	})
+
+	t.Run("beyond the limit", func(t *testing.T) {
+		if err := fs.MkdirAll("a/b/c/d", 0o755); !errors.Is(err, ufs.ErrPathTooDeep) {
+			t.Errorf("expected MkdirAll to fail with ErrPathTooDeep, got %v", err)
+		}
+		if _, err := fs.Touch("a/b/c/file", ufs.O_RDWR, 0o644); !errors.Is(err, ufs.ErrPathTooDeep) {
+			t.Errorf("expected Touch to fail with ErrPathTooDeep, got %v", err)
+		}
+		if _, err := fs.OpenFile("a/b/c/file", ufs.O_CREATE|ufs.O_WRONLY, 0o644); !errors.Is(err, ufs.ErrPathTooDeep) {
+			t.Errorf("expected OpenFile to fail with ErrPathTooDeep, got %v", err)
+		}
+		if err := fs.Rename("a/b/file", "a/b/c/file"); !errors.Is(err, ufs.ErrPathTooDeep) {
+			t.Errorf("expected Rename to fail with ErrPathTooDeep, got %v", err)
+		}
+		if err := fs.Symlink("../file", "a/b/c/link"); !errors.Is(err, ufs.ErrPathTooDeep) {
+			t.Errorf("expected Symlink to fail with ErrPathTooDeep, got %v", err)
+		}
+	})
+
+	t.Run("directories cannot be moved if anything within them would be too deep", func(t *testing.T) {
+		if err := fs.MkdirAll("x/y", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Rename("x", "a/b/x"); !errors.Is(err, ufs.ErrPathTooDeep) {
+			t.Errorf("expected Rename to fail with ErrPathTooDeep, got %v", err)
+		}
+		if _, err := fs.Lstat("x/y"); err != nil {
+			t.Errorf("expected directory to be left in place: %v", err)
+		}
+		if err := fs.Rename("x", "a/x"); err != nil {
+			t.Errorf("expected directory to be moved: %v", err)
+		}
+	})
+
+	t.Run("existing files can still be opened", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(fs.Root, "a/b/c/existing"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := fs.Open("a/b/c/existing")
+		if err != nil {
+			t.Errorf("expected existing file to be opened: %v", err)
+			return
+		}
+		_ = f.Close()
+	})
+}
+
 func TestUnixFS_Open(t *testing.T) {
 	t.Parallel()
 	fs, err := newTestUnixFS()
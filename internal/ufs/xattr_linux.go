@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package ufs
+
+import (
+	"bytes"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// Xattrs returns the extended attributes of the named file, keyed by their name.
+// Symbolic links are not followed, the attributes of the link itself are
+// returned. If the underlying filesystem does not support extended attributes
+// an empty map is returned.
+func (fs *UnixFS) Xattrs(name string) (map[string][]byte, error) {
+	dirfd, file, closeFd, err := fs.safePath(name)
+	defer closeFd()
+	if err != nil {
+		return nil, err
+	}
+	// An O_PATH descriptor is used so that opening the file never has any side
+	// effects, such as blocking on a named pipe.
+	fd, err := fs.openat(dirfd, file, unix.O_PATH, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+
+	// The attributes cannot be read through an O_PATH descriptor directly, but
+	// they can through its link in /proc which always refers to the opened file.
+	p := "/proc/self/fd/" + strconv.Itoa(fd)
+	names, err := readXattr(func(b []byte) (int, error) { return unix.Listxattr(p, b) })
+	if err != nil {
+		if err == unix.ENOTSUP {
+			return map[string][]byte{}, nil
+		}
+		return nil, convertErrorType(&PathError{Op: "listxattr", Path: name, Err: err})
+	}
+
+	out := make(map[string][]byte)
+	for _, n := range bytes.Split(names, []byte{0}) {
+		if len(n) == 0 {
+			continue
+		}
+		attr := string(n)
+		v, err := readXattr(func(b []byte) (int, error) { return unix.Getxattr(p, attr, b) })
+		if err != nil {
+			// The attribute was removed after the names were listed.
+			if err == unix.ENODATA {
+				continue
+			}
+			return nil, convertErrorType(&PathError{Op: "getxattr", Path: name, Err: err})
+		}
+		out[attr] = v
+	}
+	return out, nil
+}
+
+// readXattr calls fn, which is either listxattr or getxattr, with a buffer that
+// is large enough to hold the result, growing it if the attributes change
+// between asking for their size and reading them.
+func readXattr(fn func([]byte) (int, error)) ([]byte, error) {
+	for {
+		n, err := fn(nil)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, nil
+		}
+		b := make([]byte, n)
+		n, err = fn(b)
+		if err == unix.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
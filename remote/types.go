@@ -98,6 +98,20 @@ type OutputLineMatcher struct {
 	reg *regexp.Regexp
 }
 
+// NewOutputLineMatcher returns a matcher for the given raw string, returning an
+// error if it is prefixed with `regex:` and the expression is not valid.
+func NewOutputLineMatcher(raw string) (*OutputLineMatcher, error) {
+	olm := &OutputLineMatcher{raw: []byte(raw)}
+	if strings.HasPrefix(raw, "regex:") && len(raw) > 6 {
+		r, err := regexp.Compile(strings.TrimPrefix(raw, "regex:"))
+		if err != nil {
+			return olm, err
+		}
+		olm.reg = r
+	}
+	return olm, nil
+}
+
 // Matches determines if the provided byte string matches the given regex or
 // raw string provided to the matcher.
 func (olm *OutputLineMatcher) Matches(s []byte) bool {
@@ -107,6 +121,24 @@ func (olm *OutputLineMatcher) Matches(s []byte) bool {
 	return olm.reg.Match(s)
 }
 
+// Groups returns the groups captured by the regex when it matches the provided
+// byte string. Nil is returned if the matcher is not a regex or it does not
+// match.
+func (olm *OutputLineMatcher) Groups(s []byte) []string {
+	if olm.reg == nil {
+		return nil
+	}
+	m := olm.reg.FindSubmatch(s)
+	if m == nil {
+		return nil
+	}
+	groups := make([]string, len(m)-1)
+	for i, g := range m[1:] {
+		groups[i] = string(g)
+	}
+	return groups
+}
+
 // String returns the matcher's raw comparison string.
 func (olm *OutputLineMatcher) String() string {
 	return string(olm.raw)
@@ -120,14 +152,11 @@ func (olm *OutputLineMatcher) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	olm.raw = []byte(r)
-	if bytes.HasPrefix(olm.raw, []byte("regex:")) && len(olm.raw) > 6 {
-		r, err := regexp.Compile(strings.TrimPrefix(string(olm.raw), "regex:"))
-		if err != nil {
-			log.WithField("error", err).WithField("raw", string(olm.raw)).Warn("failed to compile output line marked as being regex")
-		}
-		olm.reg = r
+	m, err := NewOutputLineMatcher(r)
+	if err != nil {
+		log.WithField("error", err).WithField("raw", r).Warn("failed to compile output line marked as being regex")
 	}
+	*olm = *m
 
 	return nil
 }
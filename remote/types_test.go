@@ -0,0 +1,23 @@
+package remote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputLineMatcher(t *testing.T) {
+	m, err := NewOutputLineMatcher("Done (")
+	assert.NoError(t, err)
+	assert.True(t, m.Matches([]byte(`[12:00:00] Done (4.2s)! For help, type "help"`)))
+	assert.Nil(t, m.Groups([]byte("Done (4.2s)")))
+
+	m, err = NewOutputLineMatcher(`regex:Done \(([\d.]+)s\)`)
+	assert.NoError(t, err)
+	assert.True(t, m.Matches([]byte("Done (4.2s)")))
+	assert.Equal(t, []string{"4.2"}, m.Groups([]byte("Done (4.2s)")))
+	assert.False(t, m.Matches([]byte("Starting server")))
+
+	_, err = NewOutputLineMatcher("regex:Done (")
+	assert.Error(t, err)
+}
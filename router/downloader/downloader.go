@@ -18,6 +18,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/kristiangarcia/wings/server"
+	"github.com/kristiangarcia/wings/server/filesystem"
 )
 
 var client *http.Client
@@ -233,6 +234,7 @@ func (dl *Download) Execute() error {
 	if err := dl.server.Filesystem().Write(p, r, res.ContentLength, 0o644); err != nil {
 		return errors.WrapIf(err, "downloader: failed to write file to server directory")
 	}
+	dl.server.Filesystem().RecordOp(filesystem.RecentOpPull, p)
 	return nil
 }
 
@@ -12,7 +12,9 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/internal/metrics"
 	"github.com/kristiangarcia/wings/remote"
+	"github.com/kristiangarcia/wings/router/tokens"
 	"github.com/kristiangarcia/wings/server"
 )
 
@@ -28,7 +30,9 @@ func AttachRequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := uuid.New().String()
 		c.Set("request_id", id)
-		c.Set("logger", log.WithField("request_id", id))
+		// The client IP only comes from the X-Forwarded-For header when the request
+		// was made through one of the configured trusted proxies.
+		c.Set("logger", log.WithFields(log.Fields{"request_id": id, "client_ip": c.ClientIP()}))
 		c.Header("X-Request-Id", id)
 		c.Next()
 	}
@@ -80,8 +84,8 @@ func CaptureErrors() gin.HandlerFunc {
 			return
 		}
 		captured := NewError(err.Err)
-		if status, msg := captured.asFilesystemError(); msg != "" {
-			c.AbortWithStatusJSON(status, gin.H{"error": msg, "request_id": c.Writer.Header().Get("X-Request-Id")})
+		if status, code, msg := captured.asFilesystemError(); msg != "" {
+			c.AbortWithStatusJSON(status, gin.H{"error": msg, "code": code, "request_id": c.Writer.Header().Get("X-Request-Id")})
 			return
 		}
 		captured.Abort(c, status)
@@ -187,6 +191,45 @@ func RequireAuthorization() gin.HandlerFunc {
 	}
 }
 
+// RequireScopedPermission checks the optional scope token sent along with the
+// request in the "X-Scope-Token" header. Requests without one are treated as
+// coming from the Panel itself and are allowed through. If a token is present
+// it must be valid for the server in the request context and grant the given
+// permission, otherwise the request is aborted with a 403 error. The parsed
+// token is stored on the context so that handlers can also check that any paths
+// they act on are within the token's root.
+func RequireScopedPermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.GetHeader("X-Scope-Token")
+		if raw == "" {
+			c.Next()
+			return
+		}
+		scope := tokens.ScopePayload{}
+		if err := tokens.ParseToken([]byte(raw), &scope); err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "The scope token provided with this request is not valid."})
+			return
+		}
+		if scope.ServerUuid != ExtractServer(c).ID() || !scope.HasPermission(permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to perform this action."})
+			return
+		}
+		c.Set("scope", &scope)
+		c.Next()
+	}
+}
+
+// TrackOperation records the request as an in-progress file operation of the
+// given type for as long as it is being handled.
+func TrackOperation(operation string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		g := metrics.ActiveOperations.WithLabelValues(operation)
+		g.Inc()
+		defer g.Dec()
+		c.Next()
+	}
+}
+
 // RemoteDownloadEnabled checks if remote downloads are enabled for this instance
 // and if not aborts the request.
 func RemoteDownloadEnabled() gin.HandlerFunc {
@@ -221,6 +264,59 @@ func ExtractServer(c *gin.Context) *server.Server {
 	return v.(*server.Server)
 }
 
+// LimitServerOperations prevents a single server from running more than the
+// configured number of heavy filesystem operations at once. Requests over the
+// limit are rejected with a 429 so that one server cannot exhaust the resources
+// shared by the node. The slot is released once the request finishes, even if
+// the handler panics or the request is canceled.
+func LimitServerOperations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		release, ok := ExtractServer(c).AcquireOperation()
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "This server is already running the maximum number of file operations, please try again shortly.",
+			})
+			return
+		}
+		defer release()
+		c.Next()
+	}
+}
+
+// RequireNotSuspended rejects requests that would modify the files of a server
+// while it is suspended. Requests that only read files are still allowed, and
+// should not use this middleware.
+func RequireNotSuspended() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if AbortIfSuspended(c, ExtractServer(c)) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// AbortIfSuspended aborts the request with a 409 error if the given server is
+// suspended, returning true if the request was aborted. This is used directly by
+// handlers that locate the server themselves rather than through ServerExists.
+func AbortIfSuspended(c *gin.Context, s *server.Server) bool {
+	if !s.IsSuspended() {
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+		"error": "This server is suspended, its files cannot be modified.",
+	})
+	return true
+}
+
+// ExtractScope returns the scope token attached to the request by the
+// RequireScopedPermission middleware, or nil if the request was not scoped.
+func ExtractScope(c *gin.Context) *tokens.ScopePayload {
+	if v, ok := c.Get("scope"); ok {
+		return v.(*tokens.ScopePayload)
+	}
+	return nil
+}
+
 // ExtractApiClient returns the API client defined for the routes.
 func ExtractApiClient(c *gin.Context) remote.Client {
 	if v, ok := c.Get("api_client"); ok {
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/router/tokens"
+	"github.com/kristiangarcia/wings/server"
+)
+
+func TestRequireNotSuspended(t *testing.T) {
+	g := Goblin(t)
+	gin.SetMode(gin.TestMode)
+
+	g.Describe("RequireNotSuspended", func() {
+		var s *server.Server
+
+		// request runs a request through the middleware and returns the response
+		// along with whether the handler was reached.
+		request := func() (*httptest.ResponseRecorder, bool) {
+			var called bool
+			w := httptest.NewRecorder()
+			_, r := gin.CreateTestContext(w)
+			r.POST("/write", func(c *gin.Context) {
+				c.Set("server", s)
+				c.Next()
+			}, RequireNotSuspended(), func(c *gin.Context) {
+				called = true
+				c.Status(http.StatusNoContent)
+			})
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/write", nil))
+			return w, called
+		}
+
+		g.BeforeEach(func() {
+			var err error
+			s, err = server.New(nil)
+			g.Assert(err).IsNil()
+		})
+
+		g.It("allows writes to a server that is not suspended", func() {
+			w, called := request()
+			g.Assert(called).IsTrue()
+			g.Assert(w.Code).Equal(http.StatusNoContent)
+		})
+
+		g.It("refuses writes to a suspended server", func() {
+			s.Config().SetSuspended(true)
+
+			w, called := request()
+			g.Assert(called).IsFalse()
+			g.Assert(w.Code).Equal(http.StatusConflict)
+		})
+	})
+}
+
+func TestRequireScopedPermission(t *testing.T) {
+	g := Goblin(t)
+	gin.SetMode(gin.TestMode)
+
+	g.Describe("RequireScopedPermission", func() {
+		var s *server.Server
+
+		// request runs a request with the given scope token through the middleware
+		// and returns the response along with the scope seen by the handler, which
+		// is only set if the handler was reached.
+		request := func(token string) (*httptest.ResponseRecorder, *tokens.ScopePayload, bool) {
+			var called bool
+			var scope *tokens.ScopePayload
+			w := httptest.NewRecorder()
+			_, r := gin.CreateTestContext(w)
+			r.GET("/files", func(c *gin.Context) {
+				c.Set("server", s)
+				c.Next()
+			}, RequireScopedPermission("file.read"), func(c *gin.Context) {
+				called = true
+				scope = ExtractScope(c)
+				c.Status(http.StatusNoContent)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/files", nil)
+			if token != "" {
+				req.Header.Set("X-Scope-Token", token)
+			}
+			r.ServeHTTP(w, req)
+			return w, scope, called
+		}
+
+		sign := func(p tokens.ScopePayload) string {
+			if p.ExpirationTime == nil {
+				p.ExpirationTime = jwt.NumericDate(time.Now().Add(time.Minute))
+			}
+			b, err := jwt.Sign(p, config.GetJwtAlgorithm())
+			g.Assert(err).IsNil()
+			return string(b)
+		}
+
+		g.BeforeEach(func() {
+			config.Set(&config.Configuration{
+				AuthenticationToken: "abc",
+				System: config.SystemConfiguration{
+					RootDirectory:     "/server",
+					DiskCheckInterval: 150,
+				},
+			})
+			var err error
+			s, err = server.New(nil)
+			g.Assert(err).IsNil()
+			s.Config().Uuid = "server-uuid"
+		})
+
+		g.It("allows requests without a scope token", func() {
+			w, scope, called := request("")
+			g.Assert(called).IsTrue()
+			g.Assert(w.Code).Equal(http.StatusNoContent)
+			g.Assert(scope == nil).IsTrue()
+		})
+
+		g.It("allows requests with the permission and attaches the scope", func() {
+			for _, permissions := range [][]string{{"file.read"}, {"*"}, {"file.update", "file.read"}} {
+				w, scope, called := request(sign(tokens.ScopePayload{ServerUuid: "server-uuid", Permissions: permissions, Root: "/plugins"}))
+				g.Assert(called).IsTrue()
+				g.Assert(w.Code).Equal(http.StatusNoContent)
+				g.Assert(scope.Root).Equal("/plugins")
+			}
+		})
+
+		g.It("refuses requests without the permission", func() {
+			for _, permissions := range [][]string{nil, {"file.update"}, {"file"}} {
+				w, _, called := request(sign(tokens.ScopePayload{ServerUuid: "server-uuid", Permissions: permissions}))
+				g.Assert(called).IsFalse()
+				g.Assert(w.Code).Equal(http.StatusForbidden)
+			}
+		})
+
+		g.It("refuses requests with a token for another server", func() {
+			for _, uuid := range []string{"", "other-uuid"} {
+				w, _, called := request(sign(tokens.ScopePayload{ServerUuid: uuid, Permissions: []string{"*"}}))
+				g.Assert(called).IsFalse()
+				g.Assert(w.Code).Equal(http.StatusForbidden)
+			}
+		})
+
+		g.It("refuses requests with an invalid or expired token", func() {
+			expired := tokens.ScopePayload{ServerUuid: "server-uuid", Permissions: []string{"*"}}
+			expired.ExpirationTime = jwt.NumericDate(time.Now().Add(-time.Minute))
+			for _, token := range []string{"invalid", sign(expired)} {
+				w, _, called := request(token)
+				g.Assert(called).IsFalse()
+				g.Assert(w.Code).Equal(http.StatusForbidden)
+			}
+
+			other, err := jwt.Sign(tokens.ScopePayload{ServerUuid: "server-uuid", Permissions: []string{"*"}}, jwt.NewHS256([]byte("other")))
+			g.Assert(err).IsNil()
+			w, _, called := request(string(other))
+			g.Assert(called).IsFalse()
+			g.Assert(w.Code).Equal(http.StatusForbidden)
+		})
+	})
+}
@@ -103,8 +103,35 @@ func (re *RequestError) Error() string {
 	return re.err.Error()
 }
 
+// filesystemErrorResponse is the status and message returned for a filesystem
+// error code.
+type filesystemErrorResponse struct {
+	status int
+	msg    string
+}
+
+// filesystemErrors maps each filesystem error code to the response returned
+// for it. The code itself is also included in the response so that clients can
+// handle specific failures without matching on the message.
+var filesystemErrors = map[filesystem.ErrorCode]filesystemErrorResponse{
+	filesystem.ErrNotExist:           {http.StatusNotFound, "The requested resources was not found on the system."},
+	filesystem.ErrCodePathResolution: {http.StatusNotFound, "The requested resources was not found on the system."},
+	filesystem.ErrCodeDenylistFile:   {http.StatusForbidden, "This file cannot be modified: present in egg denylist."},
+	filesystem.ErrCodePermission:     {http.StatusForbidden, "Cannot perform that action: permission denied."},
+	filesystem.ErrCodeSymlink:        {http.StatusBadRequest, "Cannot perform that action: symlinks are not allowed on this system."},
+	filesystem.ErrCodeIsDirectory:    {http.StatusBadRequest, "Cannot perform that action: file is a directory."},
+	filesystem.ErrCodeNotDirectory:   {http.StatusBadRequest, "Cannot perform that action: file is not a directory."},
+	filesystem.ErrCodeDiskSpace:      {http.StatusBadRequest, "There is not enough disk space available to perform that action."},
+	filesystem.ErrCodeNameTooLong:    {http.StatusBadRequest, "Cannot perform that action: file name is too long."},
+	filesystem.ErrCodePathTooDeep:    {http.StatusBadRequest, "Cannot perform that action: the path is nested too deeply."},
+	filesystem.ErrCodeUnknownArchive: {http.StatusBadRequest, "Cannot perform that action: the archive is not in a supported format."},
+	filesystem.ErrCodeExist:          {http.StatusConflict, "Cannot perform that action: a file or directory already exists at that path."},
+	filesystem.ErrCodeTooLarge:       {http.StatusRequestEntityTooLarge, "Cannot perform that action: the file is too large."},
+}
+
 // Looks at the given RequestError and determines if it is a specific filesystem
-// error that we can process and return differently for the user.
+// error that we can process and return differently for the user, returning the
+// HTTP status, error code, and message to respond with.
 //
 // Some external things end up calling fmt.Errorf() on our filesystem errors
 // which ends up just unleashing chaos on the system. For the sake of this,
@@ -112,30 +139,32 @@ func (re *RequestError) Error() string {
 //
 // If the error passed into this call is nil or does not match empty values will
 // be returned to the caller.
-func (re *RequestError) asFilesystemError() (int, string) {
+func (re *RequestError) asFilesystemError() (int, filesystem.ErrorCode, string) {
 	err := re.Cause()
 	if err == nil {
-		return 0, ""
+		return 0, "", ""
 	}
-	if filesystem.IsErrorCode(err, filesystem.ErrNotExist) ||
-		filesystem.IsErrorCode(err, filesystem.ErrCodePathResolution) ||
-		strings.Contains(err.Error(), "resolves to a location outside the server root") {
-		return http.StatusNotFound, "The requested resources was not found on the system."
-	}
-	if filesystem.IsErrorCode(err, filesystem.ErrCodeDenylistFile) || strings.Contains(err.Error(), "filesystem: file access prohibited") {
-		return http.StatusForbidden, "This file cannot be modified: present in egg denylist."
-	}
-	if filesystem.IsErrorCode(err, filesystem.ErrCodeIsDirectory) || strings.Contains(err.Error(), "filesystem: is a directory") {
-		return http.StatusBadRequest, "Cannot perform that action: file is a directory."
-	}
-	if filesystem.IsErrorCode(err, filesystem.ErrCodeDiskSpace) || strings.Contains(err.Error(), "filesystem: not enough disk space") {
-		return http.StatusBadRequest, "There is not enough disk space available to perform that action."
-	}
-	if strings.HasSuffix(err.Error(), "file name too long") {
-		return http.StatusBadRequest, "Cannot perform that action: file name is too long."
+	code := filesystem.ErrorCodeOf(err)
+	if code == "" {
+		switch msg := err.Error(); {
+		case strings.Contains(msg, "resolves to a location outside the server root"):
+			code = filesystem.ErrCodePathResolution
+		case strings.Contains(msg, "filesystem: file access prohibited"):
+			code = filesystem.ErrCodeDenylistFile
+		case strings.Contains(msg, "filesystem: is a directory"):
+			code = filesystem.ErrCodeIsDirectory
+		case strings.Contains(msg, "filesystem: not enough disk space"):
+			code = filesystem.ErrCodeDiskSpace
+		case strings.HasSuffix(msg, "file name too long"):
+			code = filesystem.ErrCodeNameTooLong
+		}
 	}
 	if e, ok := err.(*os.SyscallError); ok && e.Syscall == "readdirent" {
-		return http.StatusNotFound, "The requested directory does not exist."
+		return http.StatusNotFound, filesystem.ErrNotExist, "The requested directory does not exist."
+	}
+	r, ok := filesystemErrors[code]
+	if !ok {
+		return 0, "", ""
 	}
-	return 0, ""
+	return r.status, code, r.msg
 }
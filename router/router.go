@@ -4,8 +4,10 @@ import (
 	"emperror.dev/errors"
 	"github.com/apex/log"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/internal/metrics"
 	"github.com/kristiangarcia/wings/remote"
 	"github.com/kristiangarcia/wings/router/middleware"
 	wserver "github.com/kristiangarcia/wings/server"
@@ -23,6 +25,13 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 	}
 	router.Use(middleware.AttachRequestID(), middleware.CaptureErrors(), middleware.SetAccessControlHeaders())
 	router.Use(middleware.AttachServerManager(m), middleware.AttachApiClient(client))
+	metrics.RegisterDiskUsage(func() map[string]int64 {
+		usage := make(map[string]int64)
+		for _, s := range m.All() {
+			usage[s.ID()] = s.Filesystem().CachedUsage()
+		}
+		return usage
+	})
 	// @todo log this into a different file so you can setup IP blocking for abusive requests and such.
 	// This should still dump requests in debug mode since it does help with understanding the request
 	// lifecycle and quickly seeing what was called leading to the logs. However, it isn't feasible to mix
@@ -59,6 +68,7 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 	protected := router.Use(middleware.RequireAuthorization())
 	protected.POST("/api/update", postUpdateConfiguration)
 	protected.GET("/api/system", getSystemInformation)
+	protected.GET("/metrics", gin.WrapH(promhttp.Handler()))
 	protected.GET("/api/servers", getAllServers)
 	protected.POST("/api/servers", postCreateServer)
 	protected.DELETE("/api/transfers/:server", deleteTransfer)
@@ -72,12 +82,20 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 		server.DELETE("", deleteServer)
 
 		server.GET("/logs", getServerLogs)
+		server.GET("/logs/bundle", getServerLogBundle)
+		server.GET("/crash", getServerCrashStatus)
+		server.GET("/stats", getServerStats)
 		server.POST("/power", postServerPower)
 		server.POST("/commands", postServerCommands)
 		server.POST("/install", postServerInstall)
 		server.POST("/reinstall", postServerReinstall)
 		server.POST("/sync", postServerSync)
+		server.POST("/startup/test", postServerTestStartup)
+		server.GET("/processes", getServerProcesses)
+		server.POST("/processes/:pid/signal", postServerSignalProcess)
 		server.POST("/ws/deny", postServerDenyWSTokens)
+		server.GET("/operations", getServerOperations)
+		server.DELETE("/operations/:operation", deleteServerOperation)
 
 		server.GET("/version", getInstalledVersion)
 
@@ -89,20 +107,49 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 		files := server.Group("/files")
 		{
 			files.GET("/contents", getServerFileContents)
+			files.GET("/read", getServerFileWindow)
+			files.GET("/tail/json", getServerFileTailJSON)
+			files.GET("/thumbnail", middleware.TrackOperation("thumbnail"), middleware.LimitServerOperations(), getServerFileThumbnail)
 			files.GET("/list-directory", getServerListDirectory)
-			files.PUT("/rename", putServerRenameFiles)
-			files.POST("/search", postServerSearchFiles)
-			files.POST("/copy", postServerCopyFile)
-			files.POST("/write", postServerWriteFile)
-			files.POST("/create-directory", postServerCreateDirectory)
-			files.POST("/delete", postServerDeleteFiles)
-			files.POST("/compress", postServerCompressFiles)
-			files.POST("/decompress", postServerDecompressFiles)
-			files.POST("/chmod", postServerChmodFile)
+			files.GET("/autocomplete", getServerAutocompletePath)
+			files.GET("/check", getServerCheckFile)
+			files.GET("/xattrs", getServerFileXattrs)
+			files.POST("/exists", middleware.RequireScopedPermission("files.read"), postServerFilesExist)
+			files.GET("/recent", getServerRecentOps)
+			files.GET("/locks", getServerFileLocks)
+			files.POST("/lock", middleware.RequireNotSuspended(), postServerLockFile)
+			files.POST("/unlock", postServerUnlockFile)
+			files.PUT("/rename", middleware.RequireNotSuspended(), middleware.TrackOperation("rename"), putServerRenameFiles)
+			files.POST("/move-batch", middleware.RequireNotSuspended(), middleware.TrackOperation("rename"), postServerMoveBatch)
+			files.POST("/search", middleware.TrackOperation("search"), middleware.LimitServerOperations(), middleware.RequireScopedPermission("files.read"), postServerSearchFiles)
+			files.GET("/search/capabilities", getServerSearchCapabilities)
+			files.GET("/search/:handle", middleware.RequireScopedPermission("files.read"), getServerAsyncSearch)
+			files.POST("/duplicates", middleware.TrackOperation("duplicates"), middleware.LimitServerOperations(), middleware.RequireScopedPermission("files.read"), postServerFindDuplicates)
+			files.POST("/tree-hash", middleware.TrackOperation("tree-hash"), middleware.LimitServerOperations(), middleware.RequireScopedPermission("files.read"), postServerHashTree)
+			files.POST("/copy", middleware.RequireNotSuspended(), middleware.TrackOperation("copy"), middleware.LimitServerOperations(), postServerCopyFile)
+			files.POST("/write", middleware.RequireNotSuspended(), middleware.TrackOperation("write"), postServerWriteFile)
+			files.POST("/replace", middleware.RequireNotSuspended(), middleware.TrackOperation("replace"), postServerReplaceInFile)
+			files.POST("/line-endings", middleware.RequireNotSuspended(), middleware.TrackOperation("line-endings"), postServerLineEndings)
+			files.POST("/create-directory", middleware.RequireNotSuspended(), postServerCreateDirectory)
+			files.POST("/delete", middleware.RequireNotSuspended(), middleware.TrackOperation("delete"), postServerDeleteFiles)
+			files.POST("/delete-recursive", middleware.RequireNotSuspended(), postServerDeleteRecursive)
+			files.GET("/ownership", getServerOwnership)
+			files.POST("/fix-permissions", middleware.RequireNotSuspended(), postServerFixPermissions)
+			files.POST("/compress", middleware.RequireNotSuspended(), middleware.TrackOperation("compress"), middleware.LimitServerOperations(), postServerCompressFiles)
+			files.POST("/download-archive", middleware.TrackOperation("download-archive"), middleware.LimitServerOperations(), middleware.RequireScopedPermission("files.read"), postServerDownloadArchive)
+			files.POST("/decompress", middleware.RequireNotSuspended(), middleware.TrackOperation("decompress"), postServerDecompressFiles)
+			files.POST("/benchmark", middleware.RequireNotSuspended(), middleware.TrackOperation("benchmark"), middleware.LimitServerOperations(), postServerBenchmarkDisk)
+			files.GET("/config-bundle", getServerConfigBundle)
+			files.POST("/config-bundle", middleware.RequireNotSuspended(), postServerConfigBundle)
+			files.POST("/staging", middleware.RequireNotSuspended(), postServerStageUpload)
+			files.POST("/staging/:upload/install", middleware.RequireNotSuspended(), postServerInstallStagedUpload)
+			files.DELETE("/staging/:upload", deleteServerStagedUpload)
+			files.POST("/chmod", middleware.RequireNotSuspended(), middleware.TrackOperation("chmod"), postServerChmodFile)
+			files.POST("/utimes", middleware.RequireNotSuspended(), middleware.TrackOperation("utimes"), postServerUtimesFile)
 
 			files.GET("/pull", middleware.RemoteDownloadEnabled(), getServerPullingFiles)
-			files.POST("/pull", middleware.RemoteDownloadEnabled(), postServerPullRemoteFile)
-			files.DELETE("/pull/:download", middleware.RemoteDownloadEnabled(), deleteServerPullRemoteFile)
+			files.POST("/pull", middleware.RemoteDownloadEnabled(), middleware.RequireNotSuspended(), postServerPullRemoteFile)
+			files.DELETE("/pull/:download", middleware.RemoteDownloadEnabled(), middleware.RequireNotSuspended(), deleteServerPullRemoteFile)
 		}
 
 		backup := server.Group("/backup")
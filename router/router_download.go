@@ -3,9 +3,13 @@ package router
 import (
 	"bufio"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -66,7 +70,7 @@ func getDownloadBackup(c *gin.Context) {
 	defer f.Close()
 
 	c.Header("Content-Length", strconv.Itoa(int(st.Size())))
-	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(st.Name()))
+	c.Header("Content-Disposition", contentDisposition("attachment", st.Name()))
 	c.Header("Content-Type", "application/octet-stream")
 
 	_, _ = bufio.NewReader(f).WriteTo(c.Writer)
@@ -102,9 +106,54 @@ func getDownloadFile(c *gin.Context) {
 		return
 	}
 
-	c.Header("Content-Length", strconv.Itoa(int(st.Size())))
-	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(st.Name()))
-	c.Header("Content-Type", "application/octet-stream")
+	// Only types that a browser cannot run anything from are shown inline, every
+	// other file is always downloaded.
+	disposition := "attachment"
+	if c.Query("inline") != "" && isInlineMimetype(st.Mimetype) {
+		disposition = "inline"
+	}
+	c.Header("Content-Length", strconv.FormatInt(st.Size(), 10))
+	c.Header("Content-Disposition", contentDisposition(disposition, st.Name()))
+	c.Header("Content-Type", st.Mimetype)
+	c.Header("X-Content-Type-Options", "nosniff")
 
-	_, _ = bufio.NewReader(f).WriteTo(c.Writer)
+	// The size is fixed when the file is opened, see getServerFileContents.
+	_, _ = bufio.NewReader(io.LimitReader(f, st.Size())).WriteTo(c.Writer)
+}
+
+// inlineMimetypes are the types of file that can be shown in the browser rather
+// than downloaded. Anything that can contain scripts, such as HTML or SVG, is
+// left out.
+var inlineMimetypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain", "audio/mpeg", "audio/ogg", "video/mp4", "video/webm"}
+
+func isInlineMimetype(m string) bool {
+	m, _, _ = strings.Cut(m, ";")
+	return slices.Contains(inlineMimetypes, strings.TrimSpace(m))
+}
+
+// contentDisposition returns the value of a Content-Disposition header for a
+// file with the given name. Names that are not plain ASCII are included in the
+// filename* parameter in UTF-8, as described by RFC 6266, along with an ASCII
+// fallback for clients that do not support it.
+func contentDisposition(disposition, name string) string {
+	var fallback, encoded strings.Builder
+	for _, r := range name {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == '%' {
+			fallback.WriteByte('_')
+		} else {
+			fallback.WriteRune(r)
+		}
+	}
+	for _, b := range []byte(name) {
+		if b < 0x80 && (b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || strings.IndexByte("!#$&+-.^_`|~", b) >= 0) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	out := disposition + `; filename="` + fallback.String() + `"`
+	if fallback.String() != name {
+		out += "; filename*=UTF-8''" + encoded.String()
+	}
+	return out
 }
@@ -1,15 +1,19 @@
 package router
 
 import (
+	"bytes"
 	"context"
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
 	"github.com/gin-gonic/gin"
 
+	"github.com/kristiangarcia/wings/environment"
+	"github.com/kristiangarcia/wings/remote"
 	"github.com/kristiangarcia/wings/router/downloader"
 	"github.com/kristiangarcia/wings/router/middleware"
 	"github.com/kristiangarcia/wings/router/tokens"
@@ -22,6 +26,12 @@ func getServer(c *gin.Context) {
 	c.JSON(http.StatusOK, ExtractServer(c).ToAPIResponse())
 }
 
+// Returns the restart policy for a server along with how many times it has
+// been restarted after crashing and how the process last exited.
+func getServerCrashStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, ExtractServer(c).CrashStatus())
+}
+
 // Returns the logs for a given server instance.
 func getServerLogs(c *gin.Context) {
 	s := ExtractServer(c)
@@ -33,13 +43,54 @@ func getServerLogs(c *gin.Context) {
 		l = 100
 	}
 
-	out, err := s.ReadLogfile(l)
+	// If a time is given only the lines written after it are returned, oldest
+	// first, so that a client reconnecting after losing the websocket can pass the
+	// cursor from its last request and continue without any gaps or duplicates.
+	var since time.Time
+	if v := c.Query("since"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The since parameter must be an RFC 3339 timestamp.",
+			})
+			return
+		}
+		since = t
+	}
+
+	lines, more, err := s.ReadLogfileSince(since, l)
 	if err != nil {
 		middleware.CaptureAndAbort(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": out})
+	out := make([]string, len(lines))
+	cursor := since
+	for i, line := range lines {
+		out[i] = line.Line
+		cursor = line.Time
+	}
+	res := gin.H{"data": out, "more": more}
+	if !cursor.IsZero() {
+		res["cursor"] = cursor.Format(time.RFC3339Nano)
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// Returns a zip archive of the recent logs and configuration files of a server,
+// with any secrets redacted, that can be attached to a support request.
+func getServerLogBundle(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var buf bytes.Buffer
+	if _, err := s.ExportLogBundle(&buf); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", contentDisposition("attachment", s.ID()+"-logs.zip"))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
 }
 
 // Handles a request to control the power state of a server. If the action being passed
@@ -135,6 +186,125 @@ func postServerCommands(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// postServerTestStartup checks a sample line of console output against a
+// startup detection pattern, so that the pattern can be validated without
+// needing to restart the server. If no pattern is provided the line is checked
+// against the patterns currently configured for the server.
+func postServerTestStartup(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		Line string `binding:"required" json:"line"`
+		// The pattern to test, using the same format as the startup configuration so
+		// it must be prefixed with "regex:" to be treated as a regular expression.
+		Pattern   string `json:"pattern"`
+		StripAnsi *bool  `json:"strip_ansi"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	cfg := s.ProcessConfiguration()
+	matchers := cfg.Startup.Done
+	stripAnsi := cfg.Startup.StripAnsi
+	if data.Pattern != "" {
+		m, err := remote.NewOutputLineMatcher(data.Pattern)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The pattern provided is not a valid regular expression: " + err.Error(),
+			})
+			return
+		}
+		matchers = []*remote.OutputLineMatcher{m}
+	}
+	if data.StripAnsi != nil {
+		stripAnsi = *data.StripAnsi
+	}
+
+	line := []byte(data.Line)
+	if stripAnsi {
+		line = server.StripAnsi(line)
+	}
+	for _, m := range matchers {
+		if m.Matches(line) {
+			c.JSON(http.StatusOK, gin.H{
+				"matches": true,
+				"pattern": m.String(),
+				"groups":  m.Groups(line),
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"matches": false})
+}
+
+// getServerProcesses returns the processes running within the server's
+// container, which is useful for finding stray child processes that prevent
+// a server from stopping.
+func getServerProcesses(c *gin.Context) {
+	s := ExtractServer(c)
+
+	if running, err := s.Environment.IsRunning(c.Request.Context()); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	} else if !running {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "Cannot list the processes of a stopped server instance.",
+		})
+		return
+	}
+
+	processes, err := s.Environment.Processes(c.Request.Context())
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"processes": processes})
+}
+
+// postServerSignalProcess sends a signal to a single process running within
+// the server's container.
+func postServerSignalProcess(c *gin.Context) {
+	s := ExtractServer(c)
+
+	pid, err := strconv.Atoi(c.Param("pid"))
+	if err != nil || pid <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The process ID provided is not valid.",
+		})
+		return
+	}
+
+	var data struct {
+		Signal string `binding:"required" json:"signal"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if err := s.Environment.SignalProcess(c.Request.Context(), pid, data.Signal); err != nil {
+		if errors.Is(err, environment.ErrUnknownSignal) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The signal provided is not valid.",
+			})
+			return
+		}
+		if errors.Is(err, environment.ErrProcessNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "The requested process is not running within this server.",
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	s.Log().WithFields(log.Fields{"pid": pid, "signal": data.Signal}).Info("sent signal to process running in server container")
+	c.Status(http.StatusNoContent)
+}
+
 // postServerSync will accept a POST request and trigger a re-sync of the given
 // server against the Panel. This can be manually triggered when needed by an
 // external system, or triggered by the Panel itself when modifications are made
@@ -76,6 +76,12 @@ func postServerRestoreBackup(c *gin.Context) {
 		// A UUID is always required for this endpoint, however the download URL
 		// is only present when the given adapter type is s3.
 		DownloadUrl string `json:"download_url"`
+		// The ID of the server the backup was created from, when it is being restored
+		// into a different server to clone it.
+		SourceServer string `json:"source_server"`
+		// Overwrite allows a backup from another server to be restored on top of
+		// any files that already exist for this server.
+		Overwrite bool `json:"overwrite"`
 	}
 	if err := c.BindJSON(&data); err != nil {
 		return
@@ -85,6 +91,22 @@ func postServerRestoreBackup(c *gin.Context) {
 		return
 	}
 
+	// Restoring another server's backup is only allowed into an empty server, unless
+	// the existing files are going to be removed or overwritten.
+	if data.SourceServer != "" && data.SourceServer != s.ID() && !data.TruncateDirectory && !data.Overwrite {
+		empty, err := s.Filesystem().IsEmpty()
+		if err != nil {
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
+		if !empty {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "Cannot restore a backup from another server into a server that already has files unless overwrite or truncate_directory is set.",
+			})
+			return
+		}
+	}
+
 	s.SetRestoring(true)
 	hasError := true
 	defer func() {
@@ -114,7 +136,7 @@ func postServerRestoreBackup(c *gin.Context) {
 		}
 		go func(s *server.Server, b backup.BackupInterface, logger *log.Entry) {
 			logger.Info("starting restoration process for server backup using local driver")
-			if err := s.RestoreBackup(b, nil); err != nil {
+			if err := s.RestoreBackup(b, nil, data.SourceServer); err != nil {
 				logger.WithField("error", err).Error("failed to restore local backup to server")
 			}
 			s.Events().Publish(server.DaemonMessageEvent, "Completed server restoration from local backup.")
@@ -158,7 +180,7 @@ func postServerRestoreBackup(c *gin.Context) {
 
 	go func(s *server.Server, uuid string, logger *log.Entry) {
 		logger.Info("starting restoration process for server backup using S3 driver")
-		if err := s.RestoreBackup(backup.NewS3(client, uuid, ""), res.Body); err != nil {
+		if err := s.RestoreBackup(backup.NewS3(client, uuid, ""), res.Body, data.SourceServer); err != nil {
 			logger.WithField("error", errors.WithStack(err)).Error("failed to restore remote S3 backup to server")
 		}
 		s.Events().Publish(server.DaemonMessageEvent, "Completed server restoration from S3 backup.")
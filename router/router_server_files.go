@@ -2,7 +2,9 @@ package router
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -10,8 +12,11 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
@@ -60,7 +65,7 @@ func getServerFileContents(c *gin.Context) {
 	// If a download parameter is included in the URL go ahead and attach the necessary headers
 	// so that the file can be downloaded.
 	if c.Query("download") != "" {
-		c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(st.Name()))
+		c.Header("Content-Disposition", contentDisposition("attachment", st.Name()))
 		c.Header("Content-Type", "application/octet-stream")
 	}
 	defer c.Writer.Flush()
@@ -82,6 +87,204 @@ func getServerFileContents(c *gin.Context) {
 	}
 }
 
+// The largest size that a thumbnail can be requested at, and the size used if
+// none is provided.
+const (
+	maxThumbnailSize     = 1024
+	defaultThumbnailSize = 256
+)
+
+// getServerFileThumbnail returns a PNG thumbnail of an image file on the server,
+// scaled down to the requested size so that previews can be shown without
+// downloading the full image. Thumbnails are cached until the image changes.
+func getServerFileThumbnail(c *gin.Context) {
+	s := ExtractServer(c)
+	p := strings.TrimLeft(c.Query("file"), "/")
+	size := defaultThumbnailSize
+	if v := c.Query("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxThumbnailSize {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The size must be a number between 1 and " + strconv.Itoa(maxThumbnailSize) + ".",
+			})
+			return
+		}
+		size = n
+	}
+
+	b, err := s.Filesystem().Thumbnail(c.Request.Context(), p, size)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "The requested file was not found on the server.",
+			})
+		case errors.Is(err, filesystem.ErrNotImage):
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{
+				"error": "Thumbnails can only be generated for PNG, JPEG and GIF images.",
+			})
+		case errors.Is(err, filesystem.ErrImageTooLarge):
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": "The image is too large to generate a thumbnail for.",
+			})
+		default:
+			middleware.CaptureAndAbort(c, err)
+		}
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, "image/png", b)
+}
+
+// The maximum number of bytes that can be returned by a single request to read
+// part of a file, and the amount returned if no length is provided.
+const (
+	maxFileWindowLength     = 4 * 1024 * 1024
+	defaultFileWindowLength = 64 * 1024
+)
+
+// getServerFileWindow returns a single window of bytes from a file, allowing very
+// large files such as logs to be viewed without downloading the entire file. The
+// total size of the file and the offset the window starts at are returned as headers.
+//
+// If "snap" is set the offset is moved back to the start of the line that it falls
+// on, so that the window never begins partway through a line.
+func getServerFileWindow(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The offset must be a positive integer.",
+		})
+		return
+	}
+	length, err := strconv.ParseInt(c.DefaultQuery("length", strconv.Itoa(defaultFileWindowLength)), 10, 64)
+	if err != nil || length <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The length must be a positive integer.",
+		})
+		return
+	}
+	length = min(length, maxFileWindowLength)
+
+	f, st, err := s.Filesystem().File(strings.TrimLeft(c.Query("file"), "/"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "The requested file was not found on the server.",
+			})
+			return
+		}
+
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	defer f.Close()
+	if !st.Mode().IsRegular() {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Cannot open files of this type.",
+		})
+		return
+	}
+
+	offset = min(offset, st.Size())
+	if c.Query("snap") != "" && offset > 0 {
+		if offset, err = lineStart(f, offset); err != nil {
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	// The length is limited to the size of the file when it was opened, in the
+	// same way as when returning the entire file.
+	length = min(length, st.Size()-offset)
+	c.Header("X-Mime-Type", st.Mimetype)
+	c.Header("X-Total-Size", strconv.FormatInt(st.Size(), 10))
+	c.Header("X-Offset", strconv.FormatInt(offset, 10))
+	c.Header("Content-Length", strconv.FormatInt(length, 10))
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, io.LimitReader(f, length)); err != nil {
+		middleware.CaptureAndAbort(c, err)
+	}
+}
+
+// The most lines and bytes per line that can be returned when tailing a file as
+// JSON, and the amounts returned if they are not provided.
+const (
+	maxTailLines         = 1000
+	defaultTailLines     = 100
+	maxTailLineBytes     = 64 * 1024
+	defaultTailLineBytes = 16 * 1024
+)
+
+// getServerFileTailJSON returns the last lines of a file that contains JSON
+// logs, with each line parsed into an object. Lines that are not JSON are
+// returned as raw strings. If "field" is set only the lines where that field is
+// equal to "value" are returned.
+func getServerFileTailJSON(c *gin.Context) {
+	s := middleware.ExtractServer(c)
+	lines, err := strconv.Atoi(c.DefaultQuery("lines", strconv.Itoa(defaultTailLines)))
+	if err != nil || lines <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The number of lines must be a positive integer.",
+		})
+		return
+	}
+	lineBytes, err := strconv.Atoi(c.DefaultQuery("max_line_bytes", strconv.Itoa(defaultTailLineBytes)))
+	if err != nil || lineBytes <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The maximum line length must be a positive integer.",
+		})
+		return
+	}
+
+	opts := filesystem.TailOptions{Lines: min(lines, maxTailLines), MaxLineBytes: min(lineBytes, maxTailLineBytes)}
+	out, truncated, err := s.Filesystem().TailJSON(strings.TrimLeft(c.Query("file"), "/"), opts, c.Query("field"), c.Query("value"))
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "The requested file was not found on the server.",
+			})
+		case errors.Is(err, filesystem.ErrNotRegularFile):
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Cannot open files of this type.",
+			})
+		default:
+			middleware.CaptureAndAbort(c, err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lines": out, "truncated": truncated})
+}
+
+// lineStart returns the offset of the start of the line that the given offset
+// falls within. If no line break is found within the maximum window length before
+// the offset, the original offset is returned.
+func lineStart(f io.ReaderAt, offset int64) (int64, error) {
+	buf := make([]byte, 4096)
+	for end := offset; end > 0 && offset-end < maxFileWindowLength; {
+		start := max(0, end-int64(len(buf)))
+		n, err := f.ReadAt(buf[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+		end = start
+	}
+	if offset <= maxFileWindowLength {
+		// Reached the start of the file, which is also the start of the first line.
+		return 0, nil
+	}
+	return offset, nil
+}
+
 // Returns the contents of a directory for a server.
 func getServerListDirectory(c *gin.Context) {
 	s := ExtractServer(c)
@@ -101,6 +304,39 @@ func getServerListDirectory(c *gin.Context) {
 	}
 }
 
+// The number of suggestions returned by the autocomplete endpoint when no limit
+// is given, and the most that can be requested.
+const (
+	defaultAutocompleteLimit = 25
+	maxAutocompleteLimit     = 100
+)
+
+// Returns the names in a directory that complete a partial path, for use by
+// "go to path" inputs in the file manager.
+func getServerAutocompletePath(c *gin.Context) {
+	s := ExtractServer(c)
+
+	limit := defaultAutocompleteLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The limit must be a positive number.",
+			})
+			return
+		}
+		limit = min(n, maxAutocompleteLimit)
+	}
+
+	suggestions, err := s.Filesystem().Autocomplete(c.Query("path"), limit)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
+
 type renameFile struct {
 	To   string `json:"to"`
 	From string `json:"from"`
@@ -113,6 +349,9 @@ func putServerRenameFiles(c *gin.Context) {
 	var data struct {
 		Root  string       `json:"root"`
 		Files []renameFile `json:"files"`
+		// Conflict determines what happens when a file already exists at the
+		// destination, defaults to returning an error.
+		Conflict string `json:"conflict"`
 	}
 	// BindJSON sends 400 if the request fails, all we need to do is return
 	if err := c.BindJSON(&data); err != nil {
@@ -126,9 +365,19 @@ func putServerRenameFiles(c *gin.Context) {
 		return
 	}
 
+	switch data.Conflict {
+	case "", filesystem.RenameConflictError, filesystem.RenameConflictOverwrite, filesystem.RenameConflictRename:
+	default:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The conflict option must be one of \"error\", \"overwrite\", or \"rename\".",
+		})
+		return
+	}
+
+	renamed := make([]renameFile, len(data.Files))
 	g, ctx := errgroup.WithContext(c.Request.Context())
 	// Loop over the array of files passed in and perform the move or rename action against each.
-	for _, p := range data.Files {
+	for i, p := range data.Files {
 		pf := path.Join(data.Root, p.From)
 		pt := path.Join(data.Root, p.To)
 
@@ -143,7 +392,8 @@ func putServerRenameFiles(c *gin.Context) {
 				if err := fs.IsIgnored(pf, pt); err != nil {
 					return err
 				}
-				if err := fs.Rename(pf, pt); err != nil {
+				final, err := fs.RenameWithConflict(pf, pt, data.Conflict)
+				if err != nil {
 					// Return nil if the error is an is not exists.
 					if errors.Is(err, os.ErrNotExist) {
 						s.Log().WithField("error", err).
@@ -154,6 +404,10 @@ func putServerRenameFiles(c *gin.Context) {
 					}
 					return err
 				}
+				// A different name may have been picked if the destination already existed, so
+				// the final name is returned for the Panel to display.
+				renamed[i] = renameFile{From: p.From, To: path.Join(path.Dir(p.To), path.Base(final))}
+				fs.RecordOp(filesystem.RecentOpRename, final)
 				return nil
 			}
 		})
@@ -171,7 +425,198 @@ func putServerRenameFiles(c *gin.Context) {
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	// Files that did not exist were skipped, so they are not included in the response.
+	renamed = slices.DeleteFunc(renamed, func(f renameFile) bool { return f.To == "" })
+	c.JSON(http.StatusOK, gin.H{"files": renamed})
+}
+
+// maxMoveBatchSize is the most files that can be moved by a single request to
+// postServerMoveBatch.
+const maxMoveBatchSize = 1000
+
+// Moves many files at once, either each to its own destination or all of them
+// into a single directory. Every move is checked before anything is moved, and
+// then the outcome of each move is returned so the Panel can show which of them
+// failed.
+func postServerMoveBatch(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		Root string `json:"root"`
+		// Each file to move along with where to move it to.
+		Moves []filesystem.Move `json:"moves"`
+		// Alternatively, the files to move into the destination directory keeping
+		// their names.
+		Files       []string `json:"files"`
+		Destination string   `json:"destination"`
+		// Conflict determines what happens when a file already exists at the
+		// destination, defaults to leaving that file where it is.
+		Conflict string `json:"conflict"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if (len(data.Moves) > 0) == (data.Destination != "") {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Either a list of moves or a destination directory must be provided.",
+		})
+		return
+	}
+	for _, f := range data.Files {
+		data.Moves = append(data.Moves, filesystem.Move{From: f, To: path.Join(data.Destination, path.Base(f))})
+	}
+	if len(data.Moves) == 0 {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "No files to move were provided.",
+		})
+		return
+	}
+	if len(data.Moves) > maxMoveBatchSize {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "No more than " + strconv.Itoa(maxMoveBatchSize) + " files can be moved at once.",
+		})
+		return
+	}
+	switch data.Conflict {
+	case "", filesystem.RenameConflictError, filesystem.RenameConflictOverwrite, filesystem.RenameConflictRename:
+	default:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The conflict option must be one of \"error\", \"overwrite\", or \"rename\".",
+		})
+		return
+	}
+
+	for i, m := range data.Moves {
+		data.Moves[i] = filesystem.Move{From: path.Join(data.Root, m.From), To: path.Join(data.Root, m.To)}
+	}
+	results, err := s.Filesystem().MoveBatch(data.Moves, data.Conflict)
+	if err != nil {
+		if errors.Is(err, filesystem.ErrInvalidMove) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The files could not be moved, nothing was changed: " + err.Error(),
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	var moved int
+	for _, r := range results {
+		if r.Moved {
+			moved++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"moved":  moved,
+		"failed": len(results) - moved,
+		"files":  results,
+	})
+}
+
+// Returns whether an operation on a file is permitted by the server's file
+// denylist, allowing the Panel to disable actions that would otherwise fail.
+func getServerCheckFile(c *gin.Context) {
+	s := ExtractServer(c)
+
+	permitted, err := s.Filesystem().IsPermitted(c.Query("operation"), "/"+strings.TrimLeft(c.Query("file"), "/"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The operation must be one of \"read\", \"write\", \"rename\", \"copy\", or \"delete\".",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"permitted": permitted})
+}
+
+const (
+	// maxExistsFiles is the most paths that can be checked in a single request.
+	maxExistsFiles = 1000
+	// existsConcurrency is the most paths that are checked at the same time.
+	existsConcurrency = 16
+)
+
+// existsFile is whether a single path checked by postServerFilesExist exists,
+// and the type of the file if it does. If the path could not be checked the
+// code of the error is returned instead, and exists is always false.
+type existsFile struct {
+	Exists bool                 `json:"exists"`
+	Type   string               `json:"type,omitempty"`
+	Error  filesystem.ErrorCode `json:"error,omitempty"`
+}
+
+// Returns whether each of the given paths exist on the server, without the cost
+// of fully stating them. Symlinks are not followed, they are reported with the
+// type "symlink" regardless of what they point to. A path that cannot be checked
+// is reported with an error rather than failing the whole request.
+func postServerFilesExist(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		Root  string   `json:"root"`
+		Files []string `json:"files"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if len(data.Files) == 0 {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "No files to check were provided.",
+		})
+		return
+	}
+	if len(data.Files) > maxExistsFiles {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "No more than " + strconv.Itoa(maxExistsFiles) + " files can be checked at once.",
+		})
+		return
+	}
+	if scope := middleware.ExtractScope(c); scope != nil {
+		for _, f := range data.Files {
+			if !scope.AllowsPath(path.Join(data.Root, f)) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": "You do not have permission to access files within that directory.",
+				})
+				return
+			}
+		}
+	}
+
+	out := make([]existsFile, len(data.Files))
+	g, ctx := errgroup.WithContext(c.Request.Context())
+	g.SetLimit(existsConcurrency)
+	for i, f := range data.Files {
+		g.Go(func() error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			t, err := s.Filesystem().FileType(path.Join(data.Root, f))
+			if err != nil {
+				code := filesystem.ErrorCodeOf(err)
+				if code == "" {
+					code = filesystem.ErrCodeUnknownError
+				}
+				out[i].Error = code
+				return nil
+			}
+			out[i].Exists = t != ""
+			out[i].Type = t
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	files := make(map[string]existsFile, len(data.Files))
+	for i, f := range data.Files {
+		files[f] = out[i]
+	}
+	c.JSON(http.StatusOK, gin.H{"files": files})
 }
 
 // Copies a server file.
@@ -230,7 +675,11 @@ func postServerDeleteFiles(c *gin.Context) {
 			case <-ctx.Done():
 				return ctx.Err()
 			default:
-				return s.Filesystem().Delete(pi)
+				if err := s.Filesystem().Delete(pi); err != nil {
+					return err
+				}
+				s.Filesystem().RecordOp(filesystem.RecentOpDelete, pi)
+				return nil
 			}
 		})
 	}
@@ -263,6 +712,19 @@ func postServerWriteFile(c *gin.Context) {
 		return
 	}
 
+	// Reject writes to a file that someone else is editing, unless the caller has
+	// chosen to overwrite their changes anyway.
+	if c.Query("force") != "true" {
+		if lock, err := s.Filesystem().CheckFileLock(f, c.Query("lock_owner")); err != nil {
+			c.AbortWithStatusJSON(http.StatusLocked, gin.H{
+				"error":     "This file is currently being edited by another user.",
+				"locked_by": lock.Owner,
+				"expires":   lock.Expires,
+			})
+			return
+		}
+	}
+
 	if err := s.Filesystem().Write(f, c.Request.Body, c.Request.ContentLength, 0o644); err != nil {
 		if filesystem.IsErrorCode(err, filesystem.ErrCodeIsDirectory) {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
@@ -274,6 +736,212 @@ func postServerWriteFile(c *gin.Context) {
 		middleware.CaptureAndAbort(c, err)
 		return
 	}
+	s.Filesystem().RecordOp(filesystem.RecentOpWrite, f)
+
+	c.Status(http.StatusNoContent)
+}
+
+// Returns the extended attributes of a file or directory.
+func getServerFileXattrs(c *gin.Context) {
+	s := ExtractServer(c)
+
+	p := "/" + strings.TrimLeft(c.Query("file"), "/")
+	if err := s.Filesystem().IsIgnored(p); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	attrs, err := s.Filesystem().Xattrs(p)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"xattrs": attrs})
+}
+
+// Replaces one or every match of some text within a single file, for making a
+// small edit without sending the entire file. The number of matches found, the
+// number replaced, and a diff of the changes are returned. If the expected
+// number of matches is given and the file does not contain exactly that many
+// nothing is changed and a 409 is returned, as the file has most likely been
+// changed since it was last read.
+func postServerReplaceInFile(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		File    string `binding:"required" json:"file"`
+		Search  string `binding:"required" json:"search"`
+		Replace string `json:"replace"`
+		// If true, search is a regular expression and replace may refer to its
+		// groups such as "$1".
+		Regex bool `json:"regex"`
+		// Which match to replace, starting from 1. If not set every match is replaced.
+		Occurrence         int    `json:"occurrence"`
+		ExpectedMatchCount *int   `json:"expected_match_count"`
+		LockOwner          string `json:"lock_owner"`
+		Force              bool   `json:"force"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	f := "/" + strings.TrimLeft(data.File, "/")
+	if err := s.Filesystem().IsIgnored(f); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	if data.Occurrence < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The occurrence must not be negative.",
+		})
+		return
+	}
+	if data.Regex {
+		if _, err := regexp.Compile(data.Search); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The search is not a valid regular expression.",
+			})
+			return
+		}
+	}
+	if !data.Force {
+		if lock, err := s.Filesystem().CheckFileLock(f, data.LockOwner); err != nil {
+			c.AbortWithStatusJSON(http.StatusLocked, gin.H{
+				"error":     "This file is currently being edited by another user.",
+				"locked_by": lock.Owner,
+				"expires":   lock.Expires,
+			})
+			return
+		}
+	}
+
+	res, err := s.Filesystem().ReplaceInFile(f, filesystem.ReplaceOptions{
+		Search:          data.Search,
+		Replace:         data.Replace,
+		Regex:           data.Regex,
+		Occurrence:      data.Occurrence,
+		ExpectedMatches: data.ExpectedMatchCount,
+	})
+	if err != nil {
+		if errors.Is(err, filesystem.ErrReplaceMismatch) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error":   "The file does not contain the expected number of matches, it may have been changed.",
+				"matches": res.Matches,
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	if res.Replacements > 0 {
+		s.Filesystem().RecordOp(filesystem.RecentOpWrite, f)
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// Returns the files that were most recently changed through Wings, most recent
+// first. The log only covers changes made since Wings was last started.
+func getServerRecentOps(c *gin.Context) {
+	s := ExtractServer(c)
+
+	c.JSON(http.StatusOK, gin.H{"operations": s.Filesystem().RecentOps()})
+}
+
+// The number of seconds a file lock is held for when no duration is given, and
+// the longest that a lock can be held before it must be renewed.
+const (
+	defaultFileLockDuration = 5 * 60
+	maxFileLockDuration     = 60 * 60
+)
+
+// Returns the advisory locks currently held on the files of a server.
+func getServerFileLocks(c *gin.Context) {
+	s := ExtractServer(c)
+
+	c.JSON(http.StatusOK, gin.H{"locks": s.Filesystem().FileLocks()})
+}
+
+// Acquires or extends an advisory lock on a file so that others editing it are
+// told their changes would overwrite someone else's. If the file is already
+// locked by another owner the existing lock is returned with a 423.
+func postServerLockFile(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		File  string `binding:"required" json:"file"`
+		Owner string `binding:"required" json:"owner"`
+		// The number of seconds until the lock expires, defaults to five minutes.
+		Duration int `json:"duration"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	f := "/" + strings.TrimLeft(data.File, "/")
+	if err := s.Filesystem().IsIgnored(f); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	if data.Duration < 0 || data.Duration > maxFileLockDuration {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("The lock duration must be between 0 and %d seconds.", maxFileLockDuration),
+		})
+		return
+	}
+	if data.Duration == 0 {
+		data.Duration = defaultFileLockDuration
+	}
+	st, err := s.Filesystem().Stat(f)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "The requested file was not found on the server.",
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	if st.IsDir() {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Only files can be locked.",
+		})
+		return
+	}
+
+	lock, err := s.Filesystem().LockFile(f, data.Owner, time.Duration(data.Duration)*time.Second)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusLocked, gin.H{
+			"error": "This file is currently being edited by another user.",
+			"lock":  lock,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, lock)
+}
+
+// Releases an advisory lock on a file. Only the owner of the lock can release
+// it, unless it is forced.
+func postServerUnlockFile(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		File  string `binding:"required" json:"file"`
+		Owner string `json:"owner"`
+		Force bool   `json:"force"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if err := s.Filesystem().UnlockFile(data.File, data.Owner, data.Force); err != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "This file is locked by another user.",
+		})
+		return
+	}
 
 	c.Status(http.StatusNoContent)
 }
@@ -407,6 +1075,7 @@ func postServerCreateDirectory(c *gin.Context) {
 		middleware.CaptureAndAbort(c, err)
 		return
 	}
+	s.Filesystem().RecordOp(filesystem.RecentOpCreateDirectory, path.Join(data.Path, data.Name))
 
 	c.Status(http.StatusNoContent)
 }
@@ -456,6 +1125,11 @@ func postServerDecompressFiles(c *gin.Context) {
 	var data struct {
 		RootPath string `json:"root"`
 		File     string `json:"file"`
+		// DryRun returns the contents of the archive without extracting anything.
+		DryRun bool `json:"dry_run"`
+		// Resume skips files that were already extracted by a previous attempt
+		// that failed part way through, and returns how many were skipped.
+		Resume bool `json:"resume"`
 	}
 	if err := c.BindJSON(&data); err != nil {
 		return
@@ -463,6 +1137,20 @@ func postServerDecompressFiles(c *gin.Context) {
 
 	s := middleware.ExtractServer(c)
 	lg := middleware.ExtractLogger(c).WithFields(log.Fields{"root_path": data.RootPath, "file": data.File})
+	if data.DryRun {
+		listing, err := s.Filesystem().ListArchive(c.Request.Context(), data.RootPath, data.File)
+		if err != nil {
+			if filesystem.IsErrorCode(err, filesystem.ErrCodeUnknownArchive) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "The archive provided is in a format Wings does not understand."})
+				return
+			}
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, listing)
+		return
+	}
+
 	lg.Debug("checking if space is available for file decompression")
 	err := s.Filesystem().SpaceAvailableForDecompression(context.Background(), data.RootPath, data.File)
 	if err != nil {
@@ -475,8 +1163,9 @@ func postServerDecompressFiles(c *gin.Context) {
 		return
 	}
 
-	lg.Info("starting file decompression")
-	if err := s.Filesystem().DecompressFile(context.Background(), data.RootPath, data.File); err != nil {
+	lg.WithField("resume", data.Resume).Info("starting file decompression")
+	res, err := s.Filesystem().DecompressFileWithOptions(context.Background(), data.RootPath, data.File, filesystem.DecompressOptions{Resume: data.Resume})
+	if err != nil {
 		// If the file is busy for some reason just return a nicer error to the user since there is not
 		// much we specifically can do. They'll need to stop the running server process in order to overwrite
 		// a file like this.
@@ -490,9 +1179,145 @@ func postServerDecompressFiles(c *gin.Context) {
 		middleware.CaptureAndAbort(c, err)
 		return
 	}
+	if data.Resume {
+		lg.WithFields(log.Fields{"written": res.Written, "skipped": res.Skipped}).Info("resumed file decompression")
+		c.JSON(http.StatusOK, res)
+		return
+	}
 	c.Status(http.StatusNoContent)
 }
 
+// lineEndingsSkip is a file that was not converted by postServerLineEndings.
+type lineEndingsSkip struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+}
+
+// Converts the line endings of the given text files to either LF or CRLF,
+// reporting how many of them were changed. Files that cannot be converted, such
+// as binary files or those being edited by another user, are skipped.
+func postServerLineEndings(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		Root  string   `json:"root"`
+		Files []string `json:"files"`
+		// Either "lf" or "crlf".
+		To string `binding:"required" json:"to"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if len(data.Files) == 0 {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "No files to convert were provided.",
+		})
+		return
+	}
+	if data.To != filesystem.LineEndingLF && data.To != filesystem.LineEndingCRLF {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The line ending must be one of \"lf\" or \"crlf\".",
+		})
+		return
+	}
+	files := make([]string, len(data.Files))
+	for i, f := range data.Files {
+		files[i] = path.Join("/", data.Root, f)
+		if err := s.Filesystem().IsIgnored(files[i]); err != nil {
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
+	}
+
+	var changed, unchanged int
+	skipped := []lineEndingsSkip{}
+	for i, f := range files {
+		if _, err := s.Filesystem().CheckFileLock(f, ""); err != nil {
+			skipped = append(skipped, lineEndingsSkip{File: data.Files[i], Reason: "locked"})
+			continue
+		}
+		ok, err := s.Filesystem().NormalizeLineEndings(f, data.To)
+		if err != nil {
+			var reason string
+			switch {
+			case errors.Is(err, filesystem.ErrBinaryFile):
+				reason = "binary"
+			case errors.Is(err, os.ErrNotExist):
+				reason = "not_found"
+			case filesystem.IsErrorCode(err, filesystem.ErrCodeTooLarge):
+				reason = "too_large"
+			case filesystem.IsErrorCode(err, filesystem.ErrCodeIsDirectory), filesystem.IsErrorCode(err, filesystem.ErrCodeSymlink):
+				reason = "not_a_file"
+			default:
+				middleware.CaptureAndAbort(c, err)
+				return
+			}
+			skipped = append(skipped, lineEndingsSkip{File: data.Files[i], Reason: reason})
+			continue
+		}
+		if ok {
+			changed++
+		} else {
+			unchanged++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"changed":   changed,
+		"unchanged": unchanged,
+		"skipped":   skipped,
+	})
+}
+
+// Sets the access and modification times of a file to the given values, which
+// are left unchanged if they are not provided.
+func postServerUtimesFile(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		File  string     `binding:"required" json:"file"`
+		Atime *time.Time `json:"atime"`
+		Mtime *time.Time `json:"mtime"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if data.Atime == nil && data.Mtime == nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "At least one of atime or mtime must be provided.",
+		})
+		return
+	}
+	f := "/" + strings.TrimLeft(data.File, "/")
+	if err := s.Filesystem().IsIgnored(f); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	var atime, mtime time.Time
+	if data.Atime != nil {
+		atime = *data.Atime
+	}
+	if data.Mtime != nil {
+		mtime = *data.Mtime
+	}
+	st, err := s.Filesystem().Utimes(f, atime, mtime)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "The requested file was not found on the server.",
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, &st)
+}
+
 type chmodFile struct {
 	File string `json:"file"`
 	Mode string `json:"mode"`
@@ -580,6 +1405,9 @@ func postServerUploadFiles(c *gin.Context) {
 		})
 		return
 	}
+	if middleware.AbortIfSuspended(c, s) {
+		return
+	}
 
 	form, err := c.MultipartForm()
 	if err != nil {
@@ -641,5 +1469,6 @@ func handleFileUpload(p string, s *server.Server, header *multipart.FileHeader)
 	if err := s.Filesystem().Write(p, file, header.Size, 0o644); err != nil {
 		return err
 	}
+	s.Filesystem().RecordOp(filesystem.RecentOpUpload, p)
 	return nil
 }
@@ -0,0 +1,88 @@
+package router
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/router/middleware"
+	"github.com/kristiangarcia/wings/server/filesystem"
+)
+
+// postServerDownloadArchive streams an archive of the selected files directly
+// to the response, so that they can be downloaded together without creating an
+// archive in the server directory first.
+func postServerDownloadArchive(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		RootPath string   `json:"root"`
+		Files    []string `json:"files"`
+		Format   string   `json:"format"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+	if len(data.Files) == 0 {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "No files were passed through to be downloaded.",
+		})
+		return
+	}
+
+	var contentType string
+	switch data.Format {
+	case "", filesystem.ArchiveFormatTarGz:
+		data.Format = filesystem.ArchiveFormatTarGz
+		contentType = "application/gzip"
+	case filesystem.ArchiveFormatZip:
+		contentType = "application/zip"
+	default:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The format must be one of \"tar.gz\" or \"zip\".",
+		})
+		return
+	}
+
+	if scope := middleware.ExtractScope(c); scope != nil {
+		for _, f := range data.Files {
+			if !scope.AllowsPath(path.Join(data.RootPath, f)) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": "You do not have permission to download files within that directory.",
+				})
+				return
+			}
+		}
+	}
+
+	// The limits are checked before anything is written, since an error cannot be
+	// returned once the archive has started streaming.
+	cfg := config.Get().Filesystem
+	_, _, err := s.Filesystem().ArchiveSize(c.Request.Context(), data.RootPath, data.Files, cfg.MaxArchiveDownloadEntries, cfg.MaxArchiveDownloadSize*1024*1024)
+	if err != nil {
+		if errors.Is(err, filesystem.ErrArchiveTooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": "The selected files are too large to be downloaded as a single archive.",
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	name := "archive-" + strings.ReplaceAll(time.Now().Format(time.RFC3339), ":", "") + "." + data.Format
+	c.Header("Content-Disposition", contentDisposition("attachment", name))
+	c.Header("Content-Type", contentType)
+	c.Status(http.StatusOK)
+
+	if err := s.Filesystem().StreamArchive(c.Request.Context(), c.Writer, data.RootPath, data.Files, data.Format); err != nil {
+		// The response has already started so the error cannot be returned to the
+		// client, the archive will simply be incomplete.
+		middleware.ExtractLogger(c).WithField("error", err).Warn("failed to stream archive of server files")
+	}
+}
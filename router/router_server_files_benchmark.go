@@ -0,0 +1,49 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristiangarcia/wings/router/middleware"
+)
+
+// The default and largest size, in MiB, of the file written when benchmarking
+// the disk of a server.
+const (
+	defaultBenchmarkSize = 64
+	maxBenchmarkSize     = 1024
+)
+
+// Benchmarks the disk that a server's files are stored on by writing and then
+// reading back a temporary file within the server directory, reporting the
+// throughput and latency of each. This helps to diagnose a server that is slow
+// because of its storage without needing shell access to the node.
+func postServerBenchmarkDisk(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		// The size of the file to write in MiB, defaults to 64.
+		Size int64 `json:"size"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+	if data.Size < 0 || data.Size > maxBenchmarkSize {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The size must be between 1 and 1024 MiB.",
+		})
+		return
+	}
+	if data.Size == 0 {
+		data.Size = defaultBenchmarkSize
+	}
+
+	res, err := s.Filesystem().Benchmark(c.Request.Context(), data.Size*1024*1024)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
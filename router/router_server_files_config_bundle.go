@@ -0,0 +1,74 @@
+package router
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"emperror.dev/errors"
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/router/middleware"
+	"github.com/kristiangarcia/wings/server"
+)
+
+// Returns every configuration file declared by the egg of the server as a
+// single JSON Lines bundle, which can be imported into this or another server
+// using the same egg.
+func getServerConfigBundle(c *gin.Context) {
+	s := ExtractServer(c)
+
+	// The bundle is built in memory so that a failure while reading one of the
+	// files can still be returned as an error.
+	var buf bytes.Buffer
+	files, err := s.ExportConfigBundle(&buf)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", contentDisposition("attachment", s.ID()+"-config.jsonl"))
+	c.Header("X-Config-Files", strconv.Itoa(len(files)))
+	c.Data(http.StatusOK, "application/x-ndjson", buf.Bytes())
+}
+
+// Writes the configuration files in a JSON Lines bundle created by
+// getServerConfigBundle to the server. Nothing is written if any file in the
+// bundle is not a configuration file declared by the egg, or cannot be
+// modified.
+func postServerConfigBundle(c *gin.Context) {
+	s := ExtractServer(c)
+
+	limit := config.Get().Api.UploadLimit
+	if c.Request.ContentLength > limit*1024*1024 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The bundle is larger than the maximum file upload size of " + strconv.FormatInt(limit, 10) + " MB.",
+		})
+		return
+	}
+
+	files, err := s.ImportConfigBundle(http.MaxBytesReader(c.Writer, c.Request.Body, limit*1024*1024))
+	if err != nil {
+		var be *server.ConfigBundleError
+		if errors.As(err, &be) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The bundle could not be imported, line " + strconv.Itoa(be.Line) + ": " + be.Reason + ".",
+				"line":  be.Line,
+				"path":  be.Path,
+			})
+			return
+		}
+		var me *http.MaxBytesError
+		if errors.As(err, &me) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": "The bundle is larger than the maximum file upload size of " + strconv.FormatInt(limit, 10) + " MB.",
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"files": files})
+}
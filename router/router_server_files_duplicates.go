@@ -0,0 +1,59 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristiangarcia/wings/router/middleware"
+	"github.com/kristiangarcia/wings/server/filesystem"
+)
+
+// The default and maximum number of files that will be scanned when looking for
+// duplicate files.
+const (
+	defaultDuplicateMaxFiles = 50_000
+	maxDuplicateMaxFiles     = 250_000
+)
+
+// postServerFindDuplicates returns clusters of files with identical contents
+// within the given directory, so that users are able to clean up copies of
+// files that are taking up space.
+func postServerFindDuplicates(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		RootPath string `json:"root"`
+		MinSize  int64  `json:"min_size"`
+		MaxSize  int64  `json:"max_size"`
+		MaxFiles int    `json:"max_files"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if scope := middleware.ExtractScope(c); scope != nil && !scope.AllowsPath(data.RootPath) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "You do not have permission to search within that directory.",
+		})
+		return
+	}
+
+	if data.MaxFiles <= 0 {
+		data.MaxFiles = defaultDuplicateMaxFiles
+	}
+	data.MaxFiles = min(data.MaxFiles, maxDuplicateMaxFiles)
+
+	res, err := s.Filesystem().FindDuplicates(c.Request.Context(), filesystem.DuplicateOptions{
+		Root:     data.RootPath,
+		MinSize:  data.MinSize,
+		MaxSize:  data.MaxSize,
+		MaxFiles: data.MaxFiles,
+	})
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
@@ -1,278 +1,704 @@
-package router
-
-import (
-	"bytes"
-	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"slices"
-	"strings"
-	"sync"
-	"sync/atomic"
-	"time"
-
-	"github.com/gabriel-vasile/mimetype"
-	"github.com/gin-gonic/gin"
-
-	"github.com/kristiangarcia/wings/router/middleware"
-	"github.com/kristiangarcia/wings/server/filesystem"
-)
-
-func postServerSearchFiles(c *gin.Context) {
-	s := ExtractServer(c)
-
-	var data struct {
-		RootPath       string `json:"root"`
-		Query          string `json:"query"`
-		IncludeContent bool   `json:"include_content"`
-		Limit          int    `json:"limit,omitempty"`
-		MaxSize        int64  `json:"max_size,omitempty"`
-	}
-
-	if err := c.BindJSON(&data); err != nil {
-		return
-	}
-
-	if data.Query == "" {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "A query parameter must be provided.",
-		})
-		return
-	}
-
-	if data.Limit <= 0 {
-		data.Limit = 100
-	}
-
-	if data.MaxSize <= 0 {
-		data.MaxSize = 1024 * 1024 // 1MB default
-	}
-
-	type StatResult struct {
-		Name      string    `json:"name"`
-		Created   time.Time `json:"created"`
-		Modified  time.Time `json:"modified"`
-		Mode      string    `json:"mode"`
-		ModeBits  string    `json:"mode_bits"`
-		Size      int64     `json:"size"`
-		Directory bool      `json:"directory"`
-		File      bool      `json:"file"`
-		Symlink   bool      `json:"symlink"`
-		Mime      string    `json:"mime"`
-	}
-
-	results := make([]StatResult, 0, min(50, data.Limit))
-	resultsMux := sync.Mutex{}
-	queryLower := strings.ToLower(data.Query)
-	resultCount := atomic.Int32{}
-
-	pending := make(chan string, 1000)
-	var wg sync.WaitGroup
-
-	for i := 0; i < 8; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			buf := make([]byte, 8192)
-
-			for path := range pending {
-				if resultCount.Load() >= int32(data.Limit) {
-					continue
-				}
-
-				info, err := s.Filesystem().UnixFS().Stat(path)
-				if err != nil {
-					continue
-				}
-
-				// Skip large files for content search
-				if info.Size() > data.MaxSize {
-					if strings.Contains(strings.ToLower(path), queryLower) {
-						if stat, err := statFromPath(s.Filesystem(), path); err == nil {
-							resultsMux.Lock()
-							if len(results) < data.Limit {
-								results = append(results, StatResult{
-									Name:      strings.TrimPrefix(strings.TrimPrefix(path, data.RootPath), "/"),
-									Created:   stat.CTime(),
-									Modified:  stat.ModTime(),
-									Mode:      stat.Mode().String(),
-									ModeBits:  fmt.Sprintf("%o", stat.Mode().Perm()),
-									Size:      stat.Size(),
-									Directory: stat.IsDir(),
-									File:      stat.Mode().IsRegular(),
-									Symlink:   stat.Mode()&os.ModeSymlink != 0,
-									Mime:      stat.Mimetype,
-								})
-								resultCount.Add(1)
-							}
-							resultsMux.Unlock()
-						}
-					}
-					continue
-				}
-
-				if strings.Contains(strings.ToLower(path), queryLower) {
-					if stat, err := statFromPath(s.Filesystem(), path); err == nil {
-						resultsMux.Lock()
-						if len(results) < data.Limit {
-							results = append(results, StatResult{
-								Name:      strings.TrimPrefix(strings.TrimPrefix(path, data.RootPath), "/"),
-								Created:   stat.CTime(),
-								Modified:  stat.ModTime(),
-								Mode:      stat.Mode().String(),
-								ModeBits:  fmt.Sprintf("%o", stat.Mode().Perm()),
-								Size:      stat.Size(),
-								Directory: stat.IsDir(),
-								File:      stat.Mode().IsRegular(),
-								Symlink:   stat.Mode()&os.ModeSymlink != 0,
-								Mime:      stat.Mimetype,
-							})
-							resultCount.Add(1)
-						}
-						resultsMux.Unlock()
-					}
-					continue
-				}
-
-				if !data.IncludeContent {
-					continue
-				}
-
-				file, err := s.Filesystem().UnixFS().Open(path)
-				if err != nil {
-					continue
-				}
-
-				n, err := file.Read(buf[:512])
-				if err != nil || (n > 0 && bytes.Contains(buf[:n], []byte{0})) {
-					file.Close()
-					continue
-				}
-
-				// Reset to start of file after binary check
-				if _, err := file.Seek(0, 0); err != nil {
-					file.Close()
-					continue
-				}
-
-				found := false
-				var lastChunk []byte
-				for !found {
-					n, err := file.Read(buf)
-					if n <= 0 {
-						break
-					}
-					
-					// Combine with previous chunk's remainder to handle split matches
-					searchChunk := append(lastChunk, buf[:n]...)
-					if strings.Contains(strings.ToLower(string(searchChunk)), queryLower) {
-						if stat, err := statFromPath(s.Filesystem(), path); err == nil {
-							resultsMux.Lock()
-							if len(results) < data.Limit {
-								results = append(results, StatResult{
-									Name:      strings.TrimPrefix(strings.TrimPrefix(path, data.RootPath), "/"),
-									Created:   stat.CTime(),
-									Modified:  stat.ModTime(),
-									Mode:      stat.Mode().String(),
-									ModeBits:  fmt.Sprintf("%o", stat.Mode().Perm()),
-									Size:      stat.Size(),
-									Directory: stat.IsDir(),
-									File:      stat.Mode().IsRegular(),
-									Symlink:   stat.Mode()&os.ModeSymlink != 0,
-									Mime:      stat.Mimetype,
-								})
-								resultCount.Add(1)
-							}
-							resultsMux.Unlock()
-						}
-						found = true
-					}
-
-					// Keep last portion that's the length of query for next chunk
-					if n >= len(queryLower) {
-						lastChunk = buf[n-len(queryLower):n]
-					}
-					
-					if err == io.EOF || int64(len(searchChunk)) > data.MaxSize {
-						break
-					}
-				}
-				file.Close()
-			}
-		}()
-	}
-
-	err := s.Filesystem().UnixFS().WalkDir(data.RootPath, func(path string, d os.DirEntry, err error) error {
-		if err != nil || d.IsDir() {
-			return err
-		}
-		if resultCount.Load() >= int32(data.Limit) {
-			return io.EOF
-		}
-		pending <- path
-		return nil
-	})
-
-	close(pending)
-	wg.Wait()
-
-	if err != nil && err != io.EOF {
-		middleware.CaptureAndAbort(c, err)
-		return
-	}
-
-	// Sort results
-	slices.SortStableFunc(results, func(a, b StatResult) int {
-		switch {
-		case a.Name == b.Name:
-			return 0
-		case a.Name > b.Name:
-			return 1
-		default:
-			return -1
-		}
-	})
-
-	slices.SortStableFunc(results, func(a, b StatResult) int {
-		switch {
-		case a.Directory && b.Directory:
-			return 0
-		case a.Directory:
-			return -1
-		default:
-			return 1
-		}
-	})
-
-	c.JSON(http.StatusOK, gin.H{
-		"results": results,
-	})
-}
-
-func statFromPath(fs *filesystem.Filesystem, path string) (filesystem.Stat, error) {
-	info, err := fs.UnixFS().Stat(path)
-	if err != nil {
-		return filesystem.Stat{}, err
-	}
-
-	var mt string
-	if info.IsDir() {
-		mt = "inode/directory"
-	} else {
-		mt = "application/octet-stream"
-		if info.Mode().IsRegular() {
-			file, err := fs.UnixFS().Open(path)
-			if err != nil {
-				return filesystem.Stat{}, err
-			}
-			m, err := mimetype.DetectReader(file)
-			if err == nil {
-				mt = m.String()
-			}
-			file.Close()
-		}
-	}
-
-	return filesystem.Stat{FileInfo: info, Mimetype: mt}, nil
-}
\ No newline at end of file
+package router
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/internal/metrics"
+	"github.com/kristiangarcia/wings/router/middleware"
+	"github.com/kristiangarcia/wings/server"
+	"github.com/kristiangarcia/wings/server/filesystem"
+)
+
+// defaultSearchLimit and defaultSearchMaxSize are the number of results returned
+// and the largest file whose contents are searched when a search does not ask
+// for anything else.
+const (
+	defaultSearchLimit   = 100
+	defaultSearchMaxSize = 1024 * 1024
+)
+
+// maxSearchLineBytes is the most bytes of a line that can be requested in each
+// search snippet.
+const maxSearchLineBytes = 64 * 1024
+
+// maxSearchCount is the most occurrences of the queries that can be counted in
+// each file.
+const maxSearchCount = 100000
+
+// searchParams are the parameters that a search was actually performed with once
+// all of the defaults and limits were applied, returned when explain is set.
+type searchParams struct {
+	Root             string                   `json:"root"`
+	Queries          []string                 `json:"queries"`
+	Paths            []string                 `json:"paths,omitempty"`
+	IncludeContent   bool                     `json:"include_content"`
+	Limit            int                      `json:"limit"`
+	MaxSize          int64                    `json:"max_size"`
+	PreviewBytes     int                      `json:"preview_bytes"`
+	MaxMatches       int                      `json:"max_matches"`
+	FirstPerDir      bool                     `json:"first_per_dir"`
+	Fields           []string                 `json:"fields,omitempty"`
+	IgnoreComments   bool                     `json:"ignore_comments"`
+	BreadthFirst     bool                     `json:"breadth_first"`
+	RecentOpsOnly    bool                     `json:"recent_ops_only"`
+	Glob             bool                     `json:"glob"`
+	Hash             bool                     `json:"hash"`
+	Size             int64                    `json:"size,omitempty"`
+	Snippets         bool                     `json:"snippets"`
+	MaxLineBytes     int                      `json:"max_line_bytes,omitempty"`
+	RawSnippets      bool                     `json:"raw_snippets"`
+	BrokenSymlinks   bool                     `json:"broken_symlinks"`
+	SymlinkTargets   bool                     `json:"symlink_targets"`
+	OneFilesystem    bool                     `json:"one_filesystem"`
+	Excludes         []string                 `json:"excludes"`
+	CountMatches     bool                     `json:"count_matches"`
+	MaxCount         int                      `json:"max_count,omitempty"`
+	Sort             string                   `json:"sort"`
+	ReadLimit        int64                    `json:"read_limit"`
+	Xattrs           []filesystem.XattrFilter `json:"xattrs,omitempty"`
+	Executable       bool                     `json:"executable"`
+	LowMemory        bool                     `json:"low_memory"`
+	PathStyle        string                   `json:"path_style"`
+	NormalizeUnicode bool                     `json:"normalize_unicode"`
+	SkipOpen         bool                     `json:"skip_open"`
+	Dedupe           bool                     `json:"dedupe"`
+	Export           string                   `json:"export,omitempty"`
+	Workers          int                      `json:"workers"`
+	Indexed          bool                     `json:"indexed"`
+}
+
+// searchRequest is the body of a request to search the files of a server.
+type searchRequest struct {
+	RootPath       string   `json:"root"`
+	Query          string   `json:"query"`
+	Queries        []string `json:"queries"`
+	Paths          []string `json:"paths"`
+	IncludeContent bool     `json:"include_content"`
+	Limit          int      `json:"limit,omitempty"`
+	MaxSize        int64    `json:"max_size,omitempty"`
+	PreviewBytes   int      `json:"preview_bytes,omitempty"`
+	MaxMatches     int      `json:"max_matches,omitempty"`
+	// If true, only the first match within each directory is returned.
+	FirstPerDir bool `json:"first_per_dir"`
+	// The fields to include in each result, if empty every field is included.
+	Fields []string `json:"fields"`
+	// If true, comments in common configuration and source file formats are not
+	// searched. This is experimental and only a best-effort heuristic.
+	IgnoreComments bool `json:"ignore_comments"`
+	// If true, files closer to the root are searched first, which is useful with
+	// a low limit.
+	BreadthFirst bool `json:"breadth_first"`
+	// If true, only files recently changed through Wings are searched.
+	RecentOpsOnly bool `json:"recent_ops_only"`
+	// If set, the results are written to this file within the server directory
+	// rather than being returned in the response.
+	Export string `json:"export"`
+	// If true, the parameters used for the search are included in the response.
+	Explain bool `json:"explain"`
+	// Queries are always matched as literal text, so any special characters in
+	// them have no meaning. Literal is accepted so that clients can be explicit
+	// about this, and Regex is rejected since it is not supported.
+	Literal bool `json:"literal"`
+	Regex   bool `json:"regex"`
+	// If true, the queries are glob patterns such as "*.properties" or
+	// "plugins/**/config.yml" that are matched against the path of each file.
+	Glob bool `json:"glob"`
+	// If true, the queries are SHA-256 hashes and the files whose contents hash
+	// to any of them are returned, for finding copies of a known file. Files
+	// larger than max_size are not hashed.
+	Hash bool `json:"hash"`
+	// If set along with hash, only files of exactly this size are hashed.
+	Size int64 `json:"size"`
+	// If true, results matched by their contents include the line the match was
+	// found on, cut down to at most max_line_bytes around the match.
+	Snippets     bool `json:"snippets"`
+	MaxLineBytes int  `json:"max_line_bytes"`
+	// If true along with snippets, each snippet also includes the exact bytes of
+	// the line encoded as base64, for files that are not entirely valid UTF-8.
+	RawSnippets bool `json:"raw_snippets"`
+	// If true, only symlinks that point to something that no longer exists are
+	// returned. A query is optional in this mode.
+	BrokenSymlinks bool `json:"broken_symlinks"`
+	// If true, symlinks are matched by where they point as well as by their name,
+	// and are returned with their target rather than being followed.
+	SymlinkTargets bool `json:"symlink_targets"`
+	// If true, directories that are mounted from another filesystem within the
+	// root are not searched.
+	OneFilesystem bool `json:"one_filesystem"`
+	// Glob patterns for files and directories to skip, relative to the server
+	// root. These are added to the default excludes for the server and its egg
+	// unless override_excludes is set, in which case only these are used.
+	Excludes         []string `json:"excludes"`
+	OverrideExcludes bool     `json:"override_excludes"`
+	// If true, the number of times the queries appear in each file matched by its
+	// contents is counted, up to max_count, rather than stopping at the first.
+	CountMatches bool `json:"count_matches"`
+	MaxCount     int  `json:"max_count"`
+	// The order to return the results in, either "name", "matches", or "none" to
+	// return them in the order they were found. That order is different every
+	// time since files are searched by many workers at once, but the results are
+	// streamed as they are found rather than all being returned at the end.
+	Sort string `json:"sort"`
+	// If true, the server is searched even while it is being installed or a
+	// backup is being restored, when files may still be appearing.
+	AllowBusy bool `json:"allow_busy"`
+	// If set, only files with extended attributes matching every filter are
+	// returned. A filter without a value matches any file with the attribute.
+	Xattrs []filesystem.XattrFilter `json:"xattrs"`
+	// If true, only files with an execute bit set in their mode are returned.
+	Executable bool `json:"executable"`
+	// If true, the search uses as little memory as possible. Previews and
+	// snippets are not included and the results are streamed without being
+	// sorted. This is always the case if the node is configured for it.
+	LowMemory bool `json:"low_memory"`
+	// How the name of each result is written, either "relative" to the root of
+	// the search or "rooted" to include the root of the search in it.
+	PathStyle string `json:"path_style"`
+	// If true, the queries and file names are converted to the NFC Unicode
+	// normal form before they are matched, and the names of results are returned
+	// in it, so that accented names from macOS are found.
+	NormalizeUnicode bool `json:"normalize_unicode"`
+	// If true, files that the server process currently has open, such as a
+	// world lock or the log being written to, are left out of the results. This
+	// is only supported on Linux and is ignored where /proc is not available.
+	SkipOpen bool `json:"skip_open"`
+	// If true, matched files with exactly the same contents are collapsed into a
+	// single result listing the names of the others as its copies. Cannot be
+	// used when the results are streamed, exported or searched in the background.
+	Dedupe bool `json:"dedupe"`
+	// If true, the search runs in the background and a handle is returned right
+	// away, which is used to read the results as they are found. Results of a
+	// background search are never sorted.
+	Async bool `json:"async"`
+}
+
+func postServerSearchFiles(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data searchRequest
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if scope := middleware.ExtractScope(c); scope != nil {
+		allowed := scope.AllowsPath(data.RootPath)
+		for _, p := range data.Paths {
+			allowed = allowed && scope.AllowsPath(path.Join(data.RootPath, p))
+		}
+		if data.Export != "" {
+			allowed = allowed && scope.HasPermission("files.create") && scope.AllowsPath(data.Export)
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "You do not have permission to search within that directory.",
+			})
+			return
+		}
+	}
+
+	if data.Async && data.Export != "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "A background search cannot be exported.",
+		})
+		return
+	}
+
+	// Files are still being created while a server is installing or restoring a
+	// backup, so searching it would give confusing results and compete with the
+	// process writing them for the disk.
+	if (s.IsInstalling() || s.IsRestoring()) && !data.AllowBusy {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "This server is currently being installed or restored, please try searching again once it has finished.",
+		})
+		return
+	}
+
+	// The single query field is still supported for older versions of the Panel,
+	// it is simply treated as one more query to match against.
+	if data.Query != "" {
+		data.Queries = append([]string{data.Query}, data.Queries...)
+	}
+	if (len(data.Queries) == 0 && !data.BrokenSymlinks) || slices.Contains(data.Queries, "") {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "A query parameter must be provided.",
+		})
+		return
+	}
+
+	if data.Regex {
+		msg := "Searching with regular expressions is not supported."
+		if data.Literal {
+			msg = "The regex and literal options cannot both be set."
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	// Matching by hash and collapsing duplicates both read the contents of the
+	// files that are searched, the same as searching their contents does.
+	if (data.IncludeContent || data.Hash || data.Dedupe) && !config.Get().Filesystem.AllowContentSearch {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "Searching the contents of files is disabled on this node, only file names can be searched.",
+		})
+		return
+	}
+
+	if data.Glob {
+		var msg string
+		switch {
+		case data.Literal:
+			msg = "The glob and literal options cannot both be set."
+		case data.IncludeContent:
+			msg = "File contents cannot be searched with a glob pattern."
+		}
+		for _, q := range data.Queries {
+			if msg == "" && filesystem.ValidateGlob(q) != nil {
+				msg = "The glob pattern \"" + q + "\" is not valid."
+			}
+		}
+		if msg != "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+
+	if data.Hash {
+		var msg string
+		switch {
+		case data.Glob:
+			msg = "The hash and glob options cannot both be set."
+		case data.IncludeContent:
+			msg = "File contents cannot be searched when searching by hash."
+		case data.SymlinkTargets:
+			msg = "Symlink targets cannot be matched when searching by hash."
+		case data.Size < 0:
+			msg = "The size must not be negative."
+		}
+		for i, q := range data.Queries {
+			data.Queries[i] = strings.ToLower(q)
+			if msg == "" && filesystem.ValidateHash(data.Queries[i]) != nil {
+				msg = "The hash \"" + q + "\" is not a valid SHA-256 hash."
+			}
+		}
+		if msg != "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+
+	if data.BrokenSymlinks {
+		var msg string
+		switch {
+		case data.IncludeContent:
+			msg = "File contents cannot be searched when looking for broken symlinks."
+		case data.Glob, data.Hash:
+			msg = "The broken_symlinks option cannot be combined with glob or hash."
+		case data.SymlinkTargets:
+			msg = "The broken_symlinks and symlink_targets options cannot both be set."
+		}
+		if msg != "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+
+	for _, f := range data.Xattrs {
+		if f.Name == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Every extended attribute filter must have a name.",
+			})
+			return
+		}
+	}
+
+	if data.CountMatches && !data.IncludeContent {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Matches can only be counted when searching file contents.",
+		})
+		return
+	}
+	// The sort that was asked for, before the default is filled in.
+	requestedSort := data.Sort
+	switch data.Sort {
+	case "name":
+		data.Sort = filesystem.SearchSortName
+	case filesystem.SearchSortName, filesystem.SearchSortNone:
+	case filesystem.SearchSortMatches:
+		if !data.CountMatches {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Results can only be sorted by matches when counting matches.",
+			})
+			return
+		}
+	default:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The sort must be one of \"name\", \"matches\", or \"none\".",
+		})
+		return
+	}
+	switch data.PathStyle {
+	case "relative":
+		data.PathStyle = filesystem.SearchPathRelative
+	case filesystem.SearchPathRelative, filesystem.SearchPathRooted:
+	default:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The path_style must be one of \"relative\" or \"rooted\".",
+		})
+		return
+	}
+	if data.MaxCount > maxSearchCount {
+		data.MaxCount = maxSearchCount
+	}
+
+	for _, e := range data.Excludes {
+		if filesystem.ValidateGlob(e) != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The exclude pattern \"" + e + "\" is not valid.",
+			})
+			return
+		}
+	}
+	if !data.OverrideExcludes {
+		data.Excludes = append(s.SearchExcludes(), data.Excludes...)
+	}
+
+	cfg := config.Get().Filesystem
+	if data.Limit < 0 || data.MaxSize < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The limit and max_size must not be negative.",
+		})
+		return
+	}
+	if cfg.MaxSearchLimit > 0 && data.Limit > cfg.MaxSearchLimit {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The limit must not be greater than " + strconv.Itoa(cfg.MaxSearchLimit) + ".",
+		})
+		return
+	}
+	if cfg.MaxSearchFileSize > 0 && data.MaxSize > cfg.MaxSearchFileSize*1024*1024 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The max_size must not be greater than " + strconv.FormatInt(cfg.MaxSearchFileSize*1024*1024, 10) + " bytes.",
+		})
+		return
+	}
+
+	if data.Limit == 0 {
+		data.Limit = defaultSearchLimit
+	}
+
+	if data.MaxSize == 0 {
+		data.MaxSize = defaultSearchMaxSize
+	}
+
+	if ceiling := cfg.MaxPreviewBytes; data.PreviewBytes > ceiling {
+		data.PreviewBytes = ceiling
+	}
+
+	for _, f := range data.Fields {
+		if !slices.Contains(filesystem.SearchFields, f) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The field \"" + f + "\" cannot be requested.",
+			})
+			return
+		}
+	}
+
+	if data.MaxLineBytes > maxSearchLineBytes {
+		data.MaxLineBytes = maxSearchLineBytes
+	}
+
+	if data.MaxMatches <= 0 {
+		data.MaxMatches = cfg.MaxSearchMatches
+	}
+
+	if cfg.SearchLowMemory {
+		data.LowMemory = true
+	}
+	if data.LowMemory {
+		// Sorting needs every result to be collected first, which is what low
+		// memory mode avoids by streaming them as they are found.
+		if requestedSort != filesystem.SearchSortName && requestedSort != filesystem.SearchSortNone {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Results cannot be sorted when searching in low memory mode.",
+			})
+			return
+		}
+		data.Sort = filesystem.SearchSortNone
+		data.PreviewBytes = 0
+		data.Snippets = false
+		data.RawSnippets = false
+	}
+	// Every result needs to be known before duplicates can be collapsed, which is
+	// never the case when they are written out as they are found.
+	if data.Dedupe && (data.LowMemory || data.Async || data.Export != "" || data.Sort == filesystem.SearchSortNone) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Duplicates cannot be collapsed when the results are streamed, exported, or searched in the background.",
+		})
+		return
+	}
+
+	opts := filesystem.SearchOptions{
+		Root:             data.RootPath,
+		Queries:          data.Queries,
+		Paths:            data.Paths,
+		IncludeContent:   data.IncludeContent,
+		Limit:            data.Limit,
+		MaxSize:          data.MaxSize,
+		PreviewBytes:     data.PreviewBytes,
+		MaxMatches:       data.MaxMatches,
+		FirstPerDir:      data.FirstPerDir,
+		Fields:           data.Fields,
+		IgnoreComments:   data.IgnoreComments,
+		BreadthFirst:     data.BreadthFirst,
+		RecentOpsOnly:    data.RecentOpsOnly,
+		Glob:             data.Glob,
+		Hash:             data.Hash,
+		Size:             data.Size,
+		Snippets:         data.Snippets,
+		MaxLineBytes:     data.MaxLineBytes,
+		RawSnippets:      data.RawSnippets,
+		BrokenSymlinks:   data.BrokenSymlinks,
+		SymlinkTargets:   data.SymlinkTargets,
+		OneFilesystem:    data.OneFilesystem,
+		Excludes:         data.Excludes,
+		Protected:        s.ProtectedFiles(),
+		CountMatches:     data.CountMatches,
+		MaxCount:         data.MaxCount,
+		Sort:             data.Sort,
+		ReadLimit:        s.SearchReadLimit(),
+		Xattrs:           data.Xattrs,
+		Executable:       data.Executable,
+		LowMemory:        data.LowMemory,
+		PathStyle:        data.PathStyle,
+		NormalizeUnicode: data.NormalizeUnicode,
+		Dedupe:           data.Dedupe,
+	}
+	if data.SkipOpen {
+		ids, err := s.OpenFiles(c.Request.Context())
+		if err != nil {
+			// Leaving open files in the results is better than failing the search.
+			middleware.ExtractLogger(c).WithField("error", err).Warn("failed to find files open by server, they will not be skipped")
+		}
+		opts.SkipFiles = ids
+	}
+
+	if data.Async {
+		as, err := s.StartAsyncSearch(opts)
+		if err != nil {
+			if errors.Is(err, server.ErrTooManyAsyncSearches) {
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+					"error": "This server is already running the maximum number of background searches, please try again once one has finished.",
+				})
+				return
+			}
+			middleware.CaptureAndAbort(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"handle": as.ID})
+		return
+	}
+
+	var results *filesystem.SearchResults
+	var count int
+	var err error
+	// When streaming, the start of the response is only written once the search
+	// has started, so any error before then can still be returned as normal.
+	streamed := data.Export == "" && (data.LowMemory || data.Sort == filesystem.SearchSortNone)
+	pw := &prefixWriter{w: c.Writer, prefix: []byte(`{"results":`)}
+	if data.Export != "" {
+		results, count, err = s.Filesystem().SearchExport(c.Request.Context(), opts, data.Export)
+	} else if streamed {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		results, count, err = s.Filesystem().SearchStream(c.Request.Context(), opts, pw)
+	} else {
+		results, err = s.Filesystem().Search(c.Request.Context(), opts)
+		if results != nil {
+			count = len(results.Results)
+		}
+	}
+	if err != nil && pw.written {
+		// The status and the start of the results have already been sent, so the
+		// error can only be reported by ending the object with it.
+		middleware.ExtractLogger(c).WithField("error", err).Warn("streamed search failed after results were written")
+		b, _ := json.Marshal(gin.H{
+			"complete":  false,
+			"truncated": true,
+			"error":     "The search failed before it finished, the results are incomplete.",
+		})
+		_, _ = c.Writer.Write(append([]byte(","), b[1:]...))
+		return
+	}
+	if err != nil {
+		if errors.Is(err, filesystem.ErrSearchDisallowed) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Searching within that directory is not allowed on this system.",
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	// The query itself is intentionally not logged since it may contain information
+	// the user would not want showing up in the node logs.
+	var queryLength int
+	for _, q := range data.Queries {
+		queryLength += len(q)
+	}
+	middleware.ExtractLogger(c).WithFields(log.Fields{
+		"queries":         len(data.Queries),
+		"query_length":    queryLength,
+		"root":            data.RootPath,
+		"include_content": data.IncludeContent,
+		"files_visited":   results.Stats.FilesVisited,
+		"bytes_read":      results.Stats.BytesRead,
+		"duration":        results.Stats.Duration,
+		"results":         count,
+		"export":          data.Export != "",
+	}).Info("completed search of server files")
+
+	metrics.Searches.WithLabelValues(s.ID()).Inc()
+	metrics.SearchBytesRead.Add(float64(results.Stats.BytesRead))
+	metrics.SearchDuration.WithLabelValues(strconv.FormatBool(data.IncludeContent)).Observe(results.Stats.Duration.Seconds())
+
+	var params *searchParams
+	if data.Explain {
+		params = &searchParams{
+			Root:             data.RootPath,
+			Queries:          data.Queries,
+			Paths:            data.Paths,
+			IncludeContent:   data.IncludeContent,
+			Limit:            data.Limit,
+			MaxSize:          data.MaxSize,
+			PreviewBytes:     data.PreviewBytes,
+			MaxMatches:       data.MaxMatches,
+			FirstPerDir:      data.FirstPerDir,
+			Fields:           data.Fields,
+			IgnoreComments:   data.IgnoreComments,
+			BreadthFirst:     data.BreadthFirst,
+			RecentOpsOnly:    data.RecentOpsOnly,
+			Glob:             data.Glob,
+			Hash:             data.Hash,
+			Size:             data.Size,
+			Snippets:         data.Snippets,
+			MaxLineBytes:     data.MaxLineBytes,
+			RawSnippets:      data.RawSnippets,
+			BrokenSymlinks:   data.BrokenSymlinks,
+			SymlinkTargets:   data.SymlinkTargets,
+			OneFilesystem:    data.OneFilesystem,
+			Excludes:         data.Excludes,
+			CountMatches:     data.CountMatches,
+			MaxCount:         data.MaxCount,
+			Sort:             data.Sort,
+			ReadLimit:        opts.ReadLimit,
+			Xattrs:           data.Xattrs,
+			Executable:       data.Executable,
+			LowMemory:        data.LowMemory,
+			PathStyle:        data.PathStyle,
+			NormalizeUnicode: data.NormalizeUnicode,
+			SkipOpen:         data.SkipOpen,
+			Dedupe:           data.Dedupe,
+			Export:           data.Export,
+			Workers:          results.Stats.Workers,
+			Indexed:          results.Stats.Indexed,
+		}
+	}
+
+	if data.Export != "" {
+		res := gin.H{
+			"path":           data.Export,
+			"count":          count,
+			"complete":       results.Complete,
+			"reason":         results.Reason,
+			"warning":        results.Warning,
+			"matches_capped": results.MatchesCapped,
+		}
+		if params != nil {
+			res["params"] = params
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	if streamed {
+		// The results have already been written, so the rest of the fields are added
+		// after them to finish the object.
+		b, err := json.Marshal(struct {
+			Complete      bool          `json:"complete"`
+			Reason        string        `json:"reason,omitempty"`
+			Warning       string        `json:"warning,omitempty"`
+			MatchesCapped bool          `json:"matches_capped"`
+			Params        *searchParams `json:"params,omitempty"`
+		}{results.Complete, results.Reason, results.Warning, results.MatchesCapped, params})
+		if err != nil {
+			middleware.ExtractLogger(c).WithField("error", err).Warn("failed to encode end of streamed search results")
+			return
+		}
+		_, _ = c.Writer.Write(append([]byte(","), b[1:]...))
+		return
+	}
+
+	c.JSON(http.StatusOK, struct {
+		*filesystem.SearchResults
+		Params *searchParams `json:"params,omitempty"`
+	}{results, params})
+}
+
+// prefixWriter writes a prefix before the first bytes written to it, so that
+// nothing at all is written if there is never anything else to write.
+type prefixWriter struct {
+	w       io.Writer
+	prefix  []byte
+	written bool
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	if !p.written {
+		p.written = true
+		if _, err := p.w.Write(p.prefix); err != nil {
+			return 0, err
+		}
+	}
+	return p.w.Write(b)
+}
+
+// Returns the state of a background search along with the results it has found
+// since the given offset, so the results can be collected as the search runs by
+// passing the count from the previous response as the next offset.
+func getServerAsyncSearch(c *gin.Context) {
+	s := ExtractServer(c)
+
+	as, ok := s.AsyncSearch(c.Param("handle"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "The requested search was not found, it may have expired.",
+		})
+		return
+	}
+	if scope := middleware.ExtractScope(c); scope != nil && !scope.AllowsPath(as.Root) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "You do not have permission to search within that directory.",
+		})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The offset must be a number that is not negative.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, as.Snapshot(offset))
+}
@@ -0,0 +1,77 @@
+package router
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/server/filesystem"
+)
+
+// searchCapabilitiesVersion is increased whenever the search endpoint changes in
+// a way that clients may need to know about beyond the options it accepts.
+const searchCapabilitiesVersion = 1
+
+// searchOptions are the names of every option accepted in a search request,
+// taken from the request itself so that they can never fall out of date.
+// Regex is accepted only so that it can be rejected, so it is not included.
+var searchOptions = func() []string {
+	var out []string
+	t := reflect.TypeOf(searchRequest{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" && name != "regex" {
+			out = append(out, name)
+		}
+	}
+	return out
+}()
+
+// Returns what searching the files of the server supports on this version of
+// Wings, along with the limits that searches are held to, so that clients can
+// hide anything that is not available rather than sending a search that fails.
+func getServerSearchCapabilities(c *gin.Context) {
+	s := ExtractServer(c)
+	cfg := config.Get().Filesystem
+
+	var maxSize int64
+	if cfg.MaxSearchFileSize > 0 {
+		maxSize = cfg.MaxSearchFileSize * 1024 * 1024
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"version": searchCapabilitiesVersion,
+		"options": searchOptions,
+		"fields":  filesystem.SearchFields,
+		"sorts":   []string{"name", filesystem.SearchSortMatches, filesystem.SearchSortNone},
+		"modes": gin.H{
+			"content":         cfg.AllowContentSearch,
+			"regex":           false,
+			"glob":            true,
+			"hash":            cfg.AllowContentSearch,
+			"dedupe":          cfg.AllowContentSearch,
+			"broken_symlinks": true,
+			"xattrs":          true,
+			// Gzip compressed files, such as rotated logs, have their contents searched.
+			"compressed": true,
+			"export":     true,
+		},
+		"path_styles": []string{"relative", filesystem.SearchPathRooted},
+		// A value of 0 means there is no limit.
+		"limits": gin.H{
+			"default_limit":     defaultSearchLimit,
+			"max_limit":         cfg.MaxSearchLimit,
+			"default_max_size":  defaultSearchMaxSize,
+			"max_size":          maxSize,
+			"max_preview_bytes": cfg.MaxPreviewBytes,
+			"max_matches":       cfg.MaxSearchMatches,
+			"max_line_bytes":    maxSearchLineBytes,
+			"max_count":         maxSearchCount,
+			"read_limit":        s.SearchReadLimit(),
+		},
+		"low_memory": cfg.SearchLowMemory,
+		"indexed":    cfg.SearchIndexMaxEntries > 0,
+	})
+}
@@ -0,0 +1,167 @@
+package router
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/internal/ufs"
+	"github.com/kristiangarcia/wings/router/middleware"
+	"github.com/kristiangarcia/wings/server"
+	"github.com/kristiangarcia/wings/server/filesystem"
+)
+
+// Stores the request body as a staged upload outside of the server directory,
+// returning its ID. The upload is then installed into the server directory in a
+// separate request, so that an upload that fails part way through never leaves
+// a partially written file among the server files.
+func postServerStageUpload(c *gin.Context) {
+	s := ExtractServer(c)
+
+	if c.Request.ContentLength == -1 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Missing Content-Length",
+		})
+		return
+	}
+	limit := config.Get().Api.UploadLimit
+	if c.Request.ContentLength > limit*1024*1024 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The file is larger than the maximum file upload size of " + strconv.FormatInt(limit, 10) + " MB.",
+		})
+		return
+	}
+
+	id, err := s.StageUpload(c.Request.Body, c.Request.ContentLength)
+	if err != nil {
+		if errors.Is(err, server.ErrTooManyStagedUploads) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The server already has the maximum number of staged uploads, install or discard some before staging more.",
+			})
+			return
+		}
+		if filesystem.IsErrorCode(err, filesystem.ErrCodeDiskSpace) {
+			c.AbortWithStatusJSON(http.StatusInsufficientStorage, gin.H{
+				"error": "There is not enough disk space available to stage that upload.",
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": id, "size": c.Request.ContentLength})
+}
+
+// Discards a staged upload without installing it.
+func deleteServerStagedUpload(c *gin.Context) {
+	s := ExtractServer(c)
+
+	if err := s.RemoveStagedUpload(c.Param("upload")); err != nil {
+		abortStagedUploadError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Moves a staged upload into place within the server directory, returning the
+// stat information for the installed file. The staged upload is removed once it
+// has been installed.
+func postServerInstallStagedUpload(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		// The path within the server directory to install the file to.
+		File string `binding:"required" json:"file"`
+		// The octal mode of the installed file, defaults to 0644.
+		Mode              string   `json:"mode"`
+		AllowedExtensions []string `json:"allowed_extensions"`
+		AllowedMimetypes  []string `json:"allowed_mimetypes"`
+		Overwrite         bool     `json:"overwrite"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	p := "/" + strings.TrimLeft(data.File, "/")
+	if err := s.Filesystem().IsIgnored(p); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	var mode ufs.FileMode
+	if data.Mode != "" {
+		m, err := strconv.ParseUint(data.Mode, 8, 32)
+		if err != nil || m > 0o777 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid file mode.",
+			})
+			return
+		}
+		mode = ufs.FileMode(m)
+	}
+	if lock, err := s.Filesystem().CheckFileLock(p, c.Query("lock_owner")); err != nil {
+		c.AbortWithStatusJSON(http.StatusLocked, gin.H{
+			"error":     "This file is currently being edited by another user.",
+			"locked_by": lock.Owner,
+			"expires":   lock.Expires,
+		})
+		return
+	}
+
+	f, size, err := s.OpenStagedUpload(c.Param("upload"))
+	if err != nil {
+		abortStagedUploadError(c, err)
+		return
+	}
+	defer f.Close()
+
+	st, err := s.Filesystem().InstallFile(p, f, size, filesystem.InstallOptions{
+		Mode:              mode,
+		AllowedExtensions: data.AllowedExtensions,
+		AllowedMimetypes:  data.AllowedMimetypes,
+		Overwrite:         data.Overwrite,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, filesystem.ErrInstallNotAllowed):
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The type of the uploaded file is not allowed at that location.",
+			})
+		case errors.Is(err, ufs.ErrExist):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "A file already exists at that location.",
+			})
+		case errors.Is(err, os.ErrNotExist):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "The directory to install the file to could not be found.",
+			})
+		default:
+			middleware.CaptureAndAbort(c, err)
+		}
+		return
+	}
+	s.Filesystem().RecordOp(filesystem.RecentOpUpload, p)
+	if err := s.RemoveStagedUpload(c.Param("upload")); err != nil {
+		s.Log().WithField("upload", c.Param("upload")).WithField("error", err).Warn("failed to remove staged upload after installing it")
+	}
+
+	c.JSON(http.StatusOK, &st)
+}
+
+// abortStagedUploadError responds with a 404 if the staged upload could not be
+// found, or a generic error otherwise.
+func abortStagedUploadError(c *gin.Context, err error) {
+	if errors.Is(err, server.ErrStagedUploadNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "The requested staged upload was not found.",
+		})
+		return
+	}
+	middleware.CaptureAndAbort(c, err)
+}
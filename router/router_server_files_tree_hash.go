@@ -0,0 +1,59 @@
+package router
+
+import (
+	"net/http"
+
+	"emperror.dev/errors"
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristiangarcia/wings/router/middleware"
+	"github.com/kristiangarcia/wings/server/filesystem"
+)
+
+// The default and maximum number of files, directories and symlinks that can be
+// hashed when hashing a directory.
+const (
+	defaultTreeHashMaxFiles = 50_000
+	maxTreeHashMaxFiles     = 250_000
+)
+
+// postServerHashTree returns a single hash over everything within the given
+// directory, so that the Panel can check whether the files of cloned servers
+// are still in sync by comparing a hash from each of them.
+func postServerHashTree(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		RootPath string `json:"root"`
+		MaxFiles int    `json:"max_files"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if scope := middleware.ExtractScope(c); scope != nil && !scope.AllowsPath(data.RootPath) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "You do not have permission to read that directory.",
+		})
+		return
+	}
+
+	if data.MaxFiles <= 0 {
+		data.MaxFiles = defaultTreeHashMaxFiles
+	}
+	data.MaxFiles = min(data.MaxFiles, maxTreeHashMaxFiles)
+
+	res, err := s.Filesystem().HashTree(c.Request.Context(), data.RootPath, data.MaxFiles)
+	if err != nil {
+		if errors.Is(err, filesystem.ErrTreeTooLarge) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The directory has too many files to be hashed.",
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,187 @@
+package router
+
+import (
+	"net/http"
+	"os"
+	"path"
+
+	"emperror.dev/errors"
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/router/middleware"
+	"github.com/kristiangarcia/wings/server/filesystem"
+)
+
+// maxDeleteRate is the highest number of entries per second that a recursive
+// delete can be throttled to, anything higher is treated as no limit.
+const maxDeleteRate = 10000
+
+// Returns the background operations currently running for a server.
+func getServerOperations(c *gin.Context) {
+	s := ExtractServer(c)
+
+	c.JSON(http.StatusOK, gin.H{"operations": s.Operations()})
+}
+
+// Cancels a running background operation for a server.
+func deleteServerOperation(c *gin.Context) {
+	s := ExtractServer(c)
+
+	if !s.CancelOperation(c.Param("operation")) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "The requested operation is not running for this server.",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Starts permanently deleting a file or directory and everything within it in
+// the background. The ID of the operation is returned so that it can be
+// canceled, and progress is published over the websocket.
+func postServerDeleteRecursive(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		Root string `json:"root"`
+		File string `json:"file"`
+		// The maximum number of files and directories to remove each second, if not
+		// set they are removed as quickly as possible.
+		Rate int `json:"rate"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	p := path.Join("/", data.Root, data.File)
+	if p == "/" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The server root directory cannot be deleted.",
+		})
+		return
+	}
+	if data.Rate < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The rate must not be negative.",
+		})
+		return
+	}
+	if data.Rate > maxDeleteRate {
+		data.Rate = 0
+	}
+	if err := s.Filesystem().IsIgnored(p); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	// Check that the path exists up front, rather than only reporting it once the
+	// operation has already been started.
+	if _, err := s.Filesystem().UnixFS().Lstat(p); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "The requested file or directory was not found on the server.",
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	release, ok := s.AcquireOperation()
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"error": "This server is already running the maximum number of file operations, please try again shortly.",
+		})
+		return
+	}
+
+	op, ctx := s.StartOperation("delete")
+	go func() {
+		defer release()
+		defer s.FinishOperation(op.ID)
+		// Any error is logged and published over the websocket as the result of the
+		// operation, there is no one left to return it to.
+		_, _ = s.DeleteRecursive(ctx, op, p, data.Rate)
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{"operation": op.ID})
+}
+
+// Returns the user that the files of a server are owned by on the node, along
+// with the user that the server process runs as within its container.
+func getServerOwnership(c *gin.Context) {
+	cfg := config.Get().System.User
+
+	res := gin.H{
+		"uid":           cfg.Uid,
+		"gid":           cfg.Gid,
+		"rootless":      cfg.Rootless.Enabled,
+		"container_uid": cfg.Uid,
+		"container_gid": cfg.Gid,
+	}
+	if cfg.Rootless.Enabled {
+		res["container_uid"] = cfg.Rootless.ContainerUID
+		res["container_gid"] = cfg.Rootless.ContainerGID
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// Starts changing the owner of every file within a directory of the server, or
+// the whole server directory, back to the configured user in the background.
+// The ID of the operation is returned so that it can be canceled, and progress
+// is published over the websocket.
+func postServerFixPermissions(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		Root string `json:"root"`
+		File string `json:"file"`
+		// If set, only files currently owned by this user or group are changed.
+		OnlyUid *int `json:"only_uid"`
+		OnlyGid *int `json:"only_gid"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if (data.OnlyUid != nil && *data.OnlyUid < 0) || (data.OnlyGid != nil && *data.OnlyGid < 0) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The only_uid and only_gid must not be negative.",
+		})
+		return
+	}
+	p := path.Join("/", data.Root, data.File)
+	if _, err := s.Filesystem().UnixFS().Lstat(p); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "The requested file or directory was not found on the server.",
+			})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	release, ok := s.AcquireOperation()
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"error": "This server is already running the maximum number of file operations, please try again shortly.",
+		})
+		return
+	}
+
+	op, ctx := s.StartOperation("permissions")
+	go func() {
+		defer release()
+		defer s.FinishOperation(op.ID)
+		// Any error is logged and published over the websocket as the result of the
+		// operation, there is no one left to return it to.
+		_, _ = s.FixPermissions(ctx, op, p, filesystem.FixPermissionsOptions{
+			OnlyUid: data.OnlyUid,
+			OnlyGid: data.OnlyGid,
+		})
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{"operation": op.ID})
+}
@@ -0,0 +1,67 @@
+package router
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/kristiangarcia/wings/internal/metrics"
+)
+
+// Returns the current resource usage of a server in a plain text format that
+// external monitoring can scrape, rather than the JSON sent over the websocket.
+// The format is chosen with the "format" query parameter, either "prometheus"
+// (the default) or "csv".
+func getServerStats(c *gin.Context) {
+	s := ExtractServer(c)
+
+	format := c.DefaultQuery("format", "prometheus")
+	if format != "prometheus" && format != "csv" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "The format must be either \"prometheus\" or \"csv\".",
+		})
+		return
+	}
+
+	proc := s.Proc()
+	stats := metrics.ServerStats{
+		Server:           s.ID(),
+		CpuAbsolute:      proc.CpuAbsolute,
+		MemoryBytes:      proc.Memory,
+		MemoryLimitBytes: proc.MemoryLimit,
+		DiskBytes:        proc.Disk,
+		DiskLimitBytes:   s.DiskSpace(),
+		NetworkRxBytes:   proc.Network.RxBytes,
+		NetworkTxBytes:   proc.Network.TxBytes,
+		Uptime:           proc.Uptime,
+	}
+
+	if format == "prometheus" {
+		promhttp.HandlerFor(metrics.NewServerStatsRegistry(stats), promhttp.HandlerOpts{}).ServeHTTP(c.Writer, c.Request)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{
+		"server", "state", "cpu_absolute", "memory_bytes", "memory_limit_bytes", "disk_bytes",
+		"disk_limit_bytes", "network_rx_bytes", "network_tx_bytes", "uptime",
+	})
+	_ = w.Write([]string{
+		stats.Server,
+		s.Environment.State(),
+		strconv.FormatFloat(stats.CpuAbsolute, 'f', -1, 64),
+		strconv.FormatUint(stats.MemoryBytes, 10),
+		strconv.FormatUint(stats.MemoryLimitBytes, 10),
+		strconv.FormatInt(stats.DiskBytes, 10),
+		strconv.FormatInt(stats.DiskLimitBytes, 10),
+		strconv.FormatUint(stats.NetworkRxBytes, 10),
+		strconv.FormatUint(stats.NetworkTxBytes, 10),
+		strconv.FormatInt(stats.Uptime, 10),
+	})
+	w.Flush()
+}
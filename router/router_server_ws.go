@@ -63,6 +63,10 @@ func getServerWebsocket(c *gin.Context) {
 		}
 	}()
 
+	// Ping the client periodically so that dead connections are cleaned up, and close
+	// the connection if the client has been idle for too long.
+	handler.Keepalive(ctx)
+
 	for {
 		j := websocket.Message{}
 
@@ -73,6 +77,7 @@ func getServerWebsocket(c *gin.Context) {
 			}
 			break
 		}
+		handler.MarkActive()
 
 		// Discard and JSON parse errors into the void and don't continue processing this
 		// specific socket request. If we did a break here the client would get disconnected
@@ -0,0 +1,50 @@
+package tokens
+
+import (
+	"path"
+	"strings"
+
+	"github.com/gbrlsnchs/jwt/v3"
+)
+
+// ScopePayload defines the JWT payload for a request that the Panel is making
+// on behalf of a specific user. The node authentication token grants access to
+// everything, so this token is used to narrow a request down to the permissions
+// the user actually holds and, optionally, to a single directory of the server.
+type ScopePayload struct {
+	jwt.Payload
+
+	ServerUuid  string   `json:"server_uuid"`
+	UserUuid    string   `json:"user_uuid"`
+	Permissions []string `json:"permissions"`
+	// The directory within the server that the token is limited to. An empty
+	// value allows access to the entire server root.
+	Root string `json:"root"`
+}
+
+// Returns the JWT payload.
+func (p *ScopePayload) GetPayload() *jwt.Payload {
+	return &p.Payload
+}
+
+// HasPermission checks if the given token payload has a permission string,
+// following the same wildcard rules as the websocket tokens.
+func (p *ScopePayload) HasPermission(permission string) bool {
+	for _, k := range p.Permissions {
+		if k == permission || (!strings.HasPrefix(permission, "admin") && k == "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsPath checks if the given server path falls within the root directory
+// that this token has been limited to.
+func (p *ScopePayload) AllowsPath(name string) bool {
+	root := path.Clean("/" + p.Root)
+	if root == "/" {
+		return true
+	}
+	name = path.Clean("/" + name)
+	return name == root || strings.HasPrefix(name, root+"/")
+}
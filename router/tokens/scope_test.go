@@ -0,0 +1,98 @@
+package tokens
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestScopePayload(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("ScopePayload#HasPermission", func() {
+		g.It("matches permissions exactly", func() {
+			p := &ScopePayload{Permissions: []string{"file.read", "file.update"}}
+			for permission, want := range map[string]bool{
+				"file.read":    true,
+				"file.update":  true,
+				"file.delete":  false,
+				"file":         false,
+				"file.read.me": false,
+				"":             false,
+			} {
+				g.Assert(p.HasPermission(permission)).Equal(want, permission)
+			}
+		})
+
+		g.It("grants everything but admin permissions with a wildcard", func() {
+			p := &ScopePayload{Permissions: []string{"*"}}
+			for permission, want := range map[string]bool{
+				"file.read":         true,
+				"control.console":   true,
+				"admin.websocket":   false,
+				"admin":             false,
+				"administer.server": false,
+			} {
+				g.Assert(p.HasPermission(permission)).Equal(want, permission)
+			}
+		})
+
+		g.It("grants admin permissions that are given explicitly", func() {
+			p := &ScopePayload{Permissions: []string{"*", "admin.websocket.errors"}}
+			g.Assert(p.HasPermission("admin.websocket.errors")).IsTrue()
+			g.Assert(p.HasPermission("admin.websocket.install")).IsFalse()
+		})
+
+		g.It("grants nothing without any permissions", func() {
+			p := &ScopePayload{}
+			for _, permission := range []string{"file.read", "*", ""} {
+				g.Assert(p.HasPermission(permission)).IsFalse(permission)
+			}
+		})
+	})
+
+	g.Describe("ScopePayload#AllowsPath", func() {
+		g.It("allows every path without a root", func() {
+			for _, root := range []string{"", "/", ".", "//", "/.."} {
+				p := &ScopePayload{Root: root}
+				for _, name := range []string{"", "/", "server.properties", "/a/b/c", "../../etc/passwd"} {
+					g.Assert(p.AllowsPath(name)).IsTrue(root + " " + name)
+				}
+			}
+		})
+
+		g.It("only allows paths within the root", func() {
+			for _, root := range []string{"a", "/a", "/a/", "a/./", "/b/../a"} {
+				p := &ScopePayload{Root: root}
+				for name, want := range map[string]bool{
+					"a":             true,
+					"/a":            true,
+					"/a/":           true,
+					"/a/b":          true,
+					"a/b/c.txt":     true,
+					"/ab":           false,
+					"/ab/c":         false,
+					"/b":            false,
+					"/":             false,
+					"":              false,
+					"/a/..":         false,
+					"/a/../b":       false,
+					"/a/b/../../ab": false,
+					"../a":          true,
+					"/../../a/b":    true,
+					"/a/b/../c":     true,
+				} {
+					g.Assert(p.AllowsPath(name)).Equal(want, root+" "+name)
+				}
+			}
+		})
+
+		g.It("only allows paths within a nested root", func() {
+			p := &ScopePayload{Root: "/plugins/config"}
+			g.Assert(p.AllowsPath("/plugins/config/a.yml")).IsTrue()
+			g.Assert(p.AllowsPath("/plugins")).IsFalse()
+			g.Assert(p.AllowsPath("/plugins/configs")).IsFalse()
+			g.Assert(p.AllowsPath("/plugins/config/../other")).IsFalse()
+		})
+	})
+}
@@ -0,0 +1,69 @@
+package websocket
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/kristiangarcia/wings/config"
+)
+
+// MarkActive records that a message was just received from the client.
+func (h *Handler) MarkActive() {
+	h.activity.Store(time.Now().UnixNano())
+}
+
+// Keepalive pings the client on the configured interval and closes the connection
+// if the client stops responding, or if it has not sent any messages within the
+// configured idle timeout. This must be called before the connection starts reading
+// messages, the pings are then sent in the background until the context is canceled.
+func (h *Handler) Keepalive(ctx context.Context) {
+	cfg := config.Get().Api.Websocket
+	interval := time.Duration(cfg.PingInterval) * time.Second
+	idle := time.Duration(cfg.IdleTimeout) * time.Second
+	if interval <= 0 && idle <= 0 {
+		return
+	}
+
+	h.MarkActive()
+	tick := interval
+	if tick <= 0 || (idle > 0 && idle < tick) {
+		tick = idle
+	}
+
+	if interval > 0 {
+		// Give the client two full intervals to respond to a ping before the read
+		// deadline is hit, at which point the pending read in the connection loop
+		// will fail and the connection will be closed.
+		wait := interval * 2
+		_ = h.Connection.SetReadDeadline(time.Now().Add(wait))
+		h.Connection.SetPongHandler(func(string) error {
+			return h.Connection.SetReadDeadline(time.Now().Add(wait))
+		})
+	}
+
+	go h.keepalive(ctx, tick, interval, idle)
+}
+
+func (h *Handler) keepalive(ctx context.Context, tick, interval, idle time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if idle > 0 && time.Since(time.Unix(0, h.activity.Load())) >= idle {
+				h.Logger().Debug("closing idle websocket connection")
+				_ = h.Connection.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "idle timeout"), time.Now().Add(time.Second*5))
+				return
+			}
+			if interval > 0 {
+				if err := h.Connection.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second*5)); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
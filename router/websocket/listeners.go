@@ -75,9 +75,17 @@ var e = []string{
 	server.InstallCompletedEvent,
 	server.DaemonMessageEvent,
 	server.BackupCompletedEvent,
+	server.BackupProgressEvent,
+	server.BackupQueuedEvent,
 	server.BackupRestoreCompletedEvent,
+	server.DeleteProgressEvent,
+	server.DeleteCompletedEvent,
+	server.PermissionsProgressEvent,
+	server.PermissionsCompletedEvent,
 	server.TransferLogsEvent,
 	server.TransferStatusEvent,
+	server.RestartDecisionEvent,
+	server.FileChangeEvent,
 }
 
 // ListenForServerEvents will listen for different events happening on a server
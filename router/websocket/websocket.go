@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kristiangarcia/wings/internal/models"
@@ -37,6 +38,8 @@ const (
 	PermissionReceiveInstall   = "admin.websocket.install"
 	PermissionReceiveTransfer  = "admin.websocket.transfer"
 	PermissionReceiveBackups   = "backup.read"
+	PermissionReceiveDeletes   = "file.delete"
+	PermissionReceiveFiles     = "file.read"
 )
 
 type Handler struct {
@@ -46,6 +49,9 @@ type Handler struct {
 	server       *server.Server
 	ra           server.RequestActivity
 	uuid         uuid.UUID
+	// activity is the unix timestamp, in nanoseconds, of the last message received
+	// from the client.
+	activity atomic.Int64
 }
 
 var (
@@ -126,7 +132,8 @@ func (h *Handler) Uuid() uuid.UUID {
 func (h *Handler) Logger() *log.Entry {
 	return log.WithField("subsystem", "websocket").
 		WithField("connection", h.Uuid().String()).
-		WithField("server", h.server.ID())
+		WithField("server", h.server.ID()).
+		WithField("client_ip", h.ra.IP())
 }
 
 func (h *Handler) SendJson(v Message) error {
@@ -150,12 +157,30 @@ func (h *Handler) SendJson(v Message) error {
 
 		// If the user does not have permission to see backup events, do not emit
 		// them over the socket.
-		if strings.HasPrefix(v.Event, server.BackupCompletedEvent) {
+		if strings.HasPrefix(v.Event, server.BackupCompletedEvent) || strings.HasPrefix(v.Event, server.BackupProgressEvent) || strings.HasPrefix(v.Event, server.BackupQueuedEvent) {
 			if !j.HasPermission(PermissionReceiveBackups) {
 				return nil
 			}
 		}
 
+		if strings.HasPrefix(v.Event, server.DeleteProgressEvent) || strings.HasPrefix(v.Event, server.DeleteCompletedEvent) {
+			if !j.HasPermission(PermissionReceiveDeletes) {
+				return nil
+			}
+		}
+
+		if strings.HasPrefix(v.Event, server.PermissionsProgressEvent) || strings.HasPrefix(v.Event, server.PermissionsCompletedEvent) {
+			if !j.HasPermission(PermissionReceiveFiles) {
+				return nil
+			}
+		}
+
+		if v.Event == server.FileChangeEvent {
+			if !j.HasPermission(PermissionReceiveFiles) {
+				return nil
+			}
+		}
+
 		// If we are sending transfer output, only send it to the user if they have the required permissions.
 		if v.Event == server.TransferLogsEvent {
 			if !j.HasPermission(PermissionReceiveTransfer) {
@@ -386,7 +411,19 @@ func (h *Handler) HandleInbound(ctx context.Context, m Message) error {
 				return nil
 			}
 
-			logs, err := h.server.Environment.Readlog(config.Get().System.WebsocketLogCount)
+			// A client reconnecting can pass the time of the last line it received so
+			// that only the lines it missed are sent, rather than repeating the end of
+			// the log that it has already seen.
+			var since time.Time
+			if len(m.Args) > 0 && m.Args[0] != "" {
+				t, err := time.Parse(time.RFC3339Nano, m.Args[0])
+				if err != nil {
+					return errors.New("websocket: invalid timestamp passed to send logs")
+				}
+				since = t
+			}
+
+			logs, _, err := h.server.Environment.ReadlogSince(since, config.Get().System.WebsocketLogCount)
 			if err != nil {
 				return err
 			}
@@ -394,7 +431,7 @@ func (h *Handler) HandleInbound(ctx context.Context, m Message) error {
 			for _, line := range logs {
 				_ = h.SendJson(Message{
 					Event: server.ConsoleOutputEvent,
-					Args:  []string{line},
+					Args:  []string{line.Line},
 				})
 			}
 
@@ -47,6 +47,11 @@ func (ra RequestActivity) SetUser(u string) RequestActivity {
 	return c
 }
 
+// IP returns the IP address of the client that made the request.
+func (ra RequestActivity) IP() string {
+	return ra.ip
+}
+
 func (s *Server) NewRequestActivity(user string, ip string) RequestActivity {
 	return RequestActivity{server: s.ID(), user: user, ip: ip}
 }
@@ -1,9 +1,11 @@
 package server
 
 import (
+	"context"
 	"io"
 	"io/fs"
 	"os"
+	"strings"
 	"time"
 
 	"emperror.dev/errors"
@@ -11,10 +13,16 @@ import (
 	"github.com/docker/docker/client"
 
 	"github.com/kristiangarcia/wings/environment"
+	"github.com/kristiangarcia/wings/internal/metrics"
+	"github.com/kristiangarcia/wings/internal/progress"
 	"github.com/kristiangarcia/wings/remote"
 	"github.com/kristiangarcia/wings/server/backup"
 )
 
+// backupProgressInterval is how often the progress of a backup being generated
+// is emitted over the server websocket.
+const backupProgressInterval = time.Second * 2
+
 // Notifies the panel of a backup's state and returns an error if one is encountered
 // while performing this action.
 func (s *Server) notifyPanelOfBackup(uuid string, ad *backup.ArchiveDetails, successful bool) error {
@@ -67,8 +75,24 @@ func (s *Server) Backup(b backup.BackupInterface) error {
 		}
 	}
 
-	ad, err := b.Generate(s.Context(), s.Filesystem(), ignored)
+	// Only a limited number of backups are generated at once on the node, so this
+	// may wait for the others to finish first.
+	var ad *backup.ArchiveDetails
+	release, err := s.waitForBackupSlot(b.Identifier())
+	start := time.Now()
+	if err == nil {
+		defer release()
+		p := b.Progress()
+		p.SetTotal(uint64(s.Filesystem().CachedUsage()))
+		ctx, cancel := context.WithCancel(s.Context())
+		go s.publishBackupProgress(ctx, b.Identifier(), p, start)
+		b.SetWriteLimit(s.BackupWriteLimit())
+		ad, err = b.Generate(s.Context(), s.Filesystem(), ignored)
+		cancel()
+	}
 	if err != nil {
+		metrics.Backups.WithLabelValues("failed").Inc()
+		metrics.BackupDuration.WithLabelValues("failed").Observe(time.Since(start).Seconds())
 		if err := s.notifyPanelOfBackup(b.Identifier(), &backup.ArchiveDetails{}, false); err != nil {
 			s.Log().WithFields(log.Fields{
 				"backup": b.Identifier(),
@@ -88,6 +112,8 @@ func (s *Server) Backup(b backup.BackupInterface) error {
 
 		return errors.WrapIf(err, "backup: error while generating server backup")
 	}
+	metrics.Backups.WithLabelValues("successful").Inc()
+	metrics.BackupDuration.WithLabelValues("successful").Observe(time.Since(start).Seconds())
 
 	// Try to notify the panel about the status of this backup. If for some reason this request
 	// fails, delete the archive from the daemon and return that error up the chain to the caller.
@@ -113,13 +139,50 @@ func (s *Server) Backup(b backup.BackupInterface) error {
 	return nil
 }
 
+// publishBackupProgress periodically emits the progress of a backup being
+// generated over the server websocket until the context is canceled. The total
+// size is based on the disk usage of the server, so the estimated time remaining
+// is only ever a rough guide.
+func (s *Server) publishBackupProgress(ctx context.Context, uuid string, p *progress.Progress, start time.Time) {
+	t := time.NewTicker(backupProgressInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			written, total := p.Written(), p.Total()
+			var throughput, eta float64
+			if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+				throughput = float64(written) / elapsed
+			}
+			if throughput > 0 && total > written {
+				eta = float64(total-written) / throughput
+			}
+			s.Events().Publish(BackupProgressEvent+":"+uuid, map[string]interface{}{
+				"uuid":       uuid,
+				"files":      p.Files(),
+				"bytes":      written,
+				"total":      total,
+				"current":    p.Current(),
+				"throughput": int64(throughput),
+				"eta":        int64(eta),
+			})
+		}
+	}
+}
+
 // RestoreBackup calls the Restore function on the provided backup. Once this
 // restoration is completed an event is emitted to the websocket to notify the
 // Panel that is has been completed.
 //
 // In addition to the websocket event an API call is triggered to notify the
 // Panel of the new state.
-func (s *Server) RestoreBackup(b backup.BackupInterface, reader io.ReadCloser) (err error) {
+//
+// If source is the ID of a different server, the backup is being cloned from
+// that server into this one, and any path elements in the archive matching the
+// source server's ID are renamed to match this server.
+func (s *Server) RestoreBackup(b backup.BackupInterface, reader io.ReadCloser, source string) (err error) {
 	s.Config().SetSuspended(true)
 	// Local backups will not pass a reader through to this function, so check first
 	// to make sure it is a valid reader before trying to close it.
@@ -151,8 +214,12 @@ func (s *Server) RestoreBackup(b backup.BackupInterface, reader io.ReadCloser) (
 	// Attempt to restore the backup to the server by running through each entry
 	// in the file one at a time and writing them to the disk.
 	s.Log().Debug("starting file writing process for backup restoration")
+	b.SetWriteLimit(s.BackupWriteLimit())
 	err = b.Restore(s.Context(), reader, func(file string, info fs.FileInfo, r io.ReadCloser) error {
 		defer r.Close()
+		if source != "" && source != s.ID() {
+			file = remapServerPath(file, source, s.ID())
+		}
 		s.Events().Publish(DaemonMessageEvent, "(restoring): "+file)
 		// TODO: since this will be called a lot, it may be worth adding an optimized
 		// Write with Chtimes method to the UnixFS that is able to re-use the
@@ -166,3 +233,15 @@ func (s *Server) RestoreBackup(b backup.BackupInterface, reader io.ReadCloser) (
 
 	return errors.WithStackIf(err)
 }
+
+// remapServerPath replaces any element of the path that matches the ID of the
+// source server with the ID of the target server.
+func remapServerPath(p, source, target string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if part == source {
+			parts[i] = target
+		}
+	}
+	return strings.Join(parts, "/")
+}
@@ -11,10 +11,12 @@ import (
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
+	"github.com/juju/ratelimit"
 	"github.com/mholt/archives"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/internal/progress"
 	"github.com/kristiangarcia/wings/remote"
 	"github.com/kristiangarcia/wings/server/filesystem"
 )
@@ -40,6 +42,9 @@ type RestoreCallback func(file string, info fs.FileInfo, r io.ReadCloser) error
 type BackupInterface interface {
 	// SetClient sets the API request client on the backup interface.
 	SetClient(remote.Client)
+	// SetWriteLimit sets the Disk I/O write limit, in bytes per second, used when
+	// generating and restoring this backup. If it is 0 the speed is unlimited.
+	SetWriteLimit(int64)
 	// Identifier returns the UUID of this backup as tracked by the panel
 	// instance.
 	Identifier() string
@@ -51,6 +56,8 @@ type BackupInterface interface {
 	Generate(context.Context, *filesystem.Filesystem, string) (*ArchiveDetails, error)
 	// Ignored returns the ignored files for this backup instance.
 	Ignored() string
+	// Progress returns the tracker for the progress of generating this backup.
+	Progress() *progress.Progress
 	// Checksum returns a SHA1 checksum for the generated backup.
 	Checksum() ([]byte, error)
 	// Size returns the size of the generated backup.
@@ -81,16 +88,36 @@ type Backup struct {
 	client     remote.Client
 	adapter    AdapterType
 	logContext map[string]interface{}
+	progress   progress.Progress
+	writeLimit int64
 }
 
 func (b *Backup) SetClient(c remote.Client) {
 	b.client = c
 }
 
+func (b *Backup) SetWriteLimit(limit int64) {
+	b.writeLimit = limit
+}
+
+// limitReader wraps the reader with the write limit of the backup, the reads
+// from a backup that is being restored turn into writes to the disk.
+func (b *Backup) limitReader(r io.Reader) io.Reader {
+	if b.writeLimit > 0 {
+		return ratelimit.Reader(r, ratelimit.NewBucketWithRate(float64(b.writeLimit), b.writeLimit))
+	}
+	return r
+}
+
 func (b *Backup) Identifier() string {
 	return b.Uuid
 }
 
+// Progress returns the tracker for the progress of generating this backup.
+func (b *Backup) Progress() *progress.Progress {
+	return &b.progress
+}
+
 // Path returns the path for this specific backup.
 func (b *Backup) Path() string {
 	return path.Join(config.Get().System.BackupDirectory, b.Identifier()+".tar.gz")
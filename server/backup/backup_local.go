@@ -6,10 +6,8 @@ import (
 	"os"
 
 	"emperror.dev/errors"
-	"github.com/juju/ratelimit"
 	"github.com/mholt/archives"
 
-	"github.com/kristiangarcia/wings/config"
 	"github.com/kristiangarcia/wings/remote"
 	"github.com/kristiangarcia/wings/server/filesystem"
 )
@@ -63,6 +61,8 @@ func (b *LocalBackup) Generate(ctx context.Context, fsys *filesystem.Filesystem,
 	a := &filesystem.Archive{
 		Filesystem: fsys,
 		Ignore:     ignore,
+		Progress:   b.Progress(),
+		WriteLimit: b.writeLimit,
 	}
 
 	b.log().WithField("path", b.Path()).Info("creating backup for server")
@@ -87,12 +87,9 @@ func (b *LocalBackup) Restore(ctx context.Context, _ io.Reader, callback Restore
 	}
 	defer f.Close()
 
-	var reader io.Reader = f
 	// Steal the logic we use for making backups which will be applied when restoring
 	// this specific backup. This allows us to prevent overloading the disk unintentionally.
-	if writeLimit := int64(config.Get().System.Backups.WriteLimit * 1024 * 1024); writeLimit > 0 {
-		reader = ratelimit.Reader(f, ratelimit.NewBucketWithRate(float64(writeLimit), writeLimit))
-	}
+	reader := b.limitReader(f)
 	if err := format.Extract(ctx, reader, func(ctx context.Context, f archives.FileInfo) error {
 		r, err := f.Open()
 		if err != nil {
@@ -11,10 +11,8 @@ import (
 
 	"emperror.dev/errors"
 	"github.com/cenkalti/backoff/v4"
-	"github.com/juju/ratelimit"
 	"github.com/mholt/archives"
 
-	"github.com/kristiangarcia/wings/config"
 	"github.com/kristiangarcia/wings/remote"
 	"github.com/kristiangarcia/wings/server/filesystem"
 )
@@ -54,6 +52,8 @@ func (s *S3Backup) Generate(ctx context.Context, fsys *filesystem.Filesystem, ig
 	a := &filesystem.Archive{
 		Filesystem: fsys,
 		Ignore:     ignore,
+		Progress:   s.Progress(),
+		WriteLimit: s.writeLimit,
 	}
 
 	s.log().WithField("path", s.Path()).Info("creating backup for server")
@@ -87,12 +87,9 @@ func (s *S3Backup) Generate(ctx context.Context, fsys *filesystem.Filesystem, ig
 // This restoration uses a workerpool to use up to the number of CPUs available
 // on the machine when writing files to the disk.
 func (s *S3Backup) Restore(ctx context.Context, r io.Reader, callback RestoreCallback) error {
-	reader := r
 	// Steal the logic we use for making backups which will be applied when restoring
 	// this specific backup. This allows us to prevent overloading the disk unintentionally.
-	if writeLimit := int64(config.Get().System.Backups.WriteLimit * 1024 * 1024); writeLimit > 0 {
-		reader = ratelimit.Reader(r, ratelimit.NewBucketWithRate(float64(writeLimit), writeLimit))
-	}
+	reader := s.limitReader(r)
 	if err := format.Extract(ctx, reader, func(ctx context.Context, f archives.FileInfo) error {
 		r, err := f.Open()
 		if err != nil {
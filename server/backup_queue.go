@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/kristiangarcia/wings/config"
+)
+
+// backupSlots limits how many backups are generated at once across every server
+// on the node.
+var backupSlots = &backupQueue{}
+
+// backupQueue hands out a limited number of slots for generating backups, any
+// backups started once every slot is taken wait for one in the order that they
+// were started.
+type backupQueue struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	waiting []*queuedBackup
+}
+
+// queuedBackup is a backup waiting for a slot.
+type queuedBackup struct {
+	ready bool
+	// Receives whenever the backup is given a slot or moves up the queue.
+	changed chan struct{}
+}
+
+// acquire waits for a slot to generate a backup in, returning a function that
+// must be called to release it once the backup has finished. If more than limit
+// backups are running the backup is queued, and onQueued is called with its
+// position in the queue, starting from 1, each time that it changes. A limit
+// that is not greater than zero means backups are never queued.
+func (q *backupQueue) acquire(ctx context.Context, limit int, onQueued func(position int)) (func(), error) {
+	q.mu.Lock()
+	q.limit = limit
+	if limit <= 0 || (q.running < limit && len(q.waiting) == 0) {
+		q.running++
+		q.mu.Unlock()
+		return q.releaseFunc(), nil
+	}
+	w := &queuedBackup{changed: make(chan struct{}, 1)}
+	q.waiting = append(q.waiting, w)
+	position := len(q.waiting)
+	q.mu.Unlock()
+
+	for {
+		onQueued(position)
+		select {
+		case <-ctx.Done():
+			q.mu.Lock()
+			if w.ready {
+				q.mu.Unlock()
+				q.releaseFunc()()
+			} else {
+				q.remove(w)
+				q.mu.Unlock()
+			}
+			return nil, ctx.Err()
+		case <-w.changed:
+			q.mu.Lock()
+			if w.ready {
+				q.mu.Unlock()
+				return q.releaseFunc(), nil
+			}
+			position = slices.Index(q.waiting, w) + 1
+			q.mu.Unlock()
+		}
+	}
+}
+
+// releaseFunc returns a function that releases a slot, which does nothing if it
+// is called more than once.
+func (q *backupQueue) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			q.running--
+			q.promote()
+		})
+	}
+}
+
+// remove takes a backup out of the queue when it stops waiting. The mutex must
+// be held by the caller.
+func (q *backupQueue) remove(w *queuedBackup) {
+	if i := slices.Index(q.waiting, w); i >= 0 {
+		q.waiting = slices.Delete(q.waiting, i, i+1)
+		q.notifyWaiting()
+	}
+}
+
+// promote gives any free slots to the backups at the front of the queue. The
+// mutex must be held by the caller.
+func (q *backupQueue) promote() {
+	var moved bool
+	for len(q.waiting) > 0 && (q.limit <= 0 || q.running < q.limit) {
+		w := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		w.ready = true
+		q.running++
+		w.notify()
+		moved = true
+	}
+	if moved {
+		q.notifyWaiting()
+	}
+}
+
+// notifyWaiting tells every queued backup that its position has changed. The
+// mutex must be held by the caller.
+func (q *backupQueue) notifyWaiting() {
+	for _, w := range q.waiting {
+		w.notify()
+	}
+}
+
+// notify wakes the queued backup without blocking if it has already been woken.
+func (w *queuedBackup) notify() {
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+// waitForBackupSlot waits until there is a free slot on the node to generate the
+// given backup in, publishing its position over the websocket while it is queued.
+func (s *Server) waitForBackupSlot(uuid string) (func(), error) {
+	return backupSlots.acquire(s.Context(), config.Get().System.Backups.MaxConcurrent, func(position int) {
+		s.Log().WithField("backup", uuid).WithField("position", position).Debug("backup is queued waiting for a free slot")
+		s.Events().Publish(BackupQueuedEvent+":"+uuid, map[string]interface{}{
+			"uuid":     uuid,
+			"status":   "queued",
+			"position": position,
+		})
+	})
+}
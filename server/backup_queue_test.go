@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+)
+
+func TestBackupQueue(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("backupQueue#acquire", func() {
+		g.It("never queues backups without a limit", func() {
+			q := &backupQueue{}
+			for i := 0; i < 5; i++ {
+				_, err := q.acquire(context.Background(), 0, func(int) { g.Fail("backup was queued") })
+				g.Assert(err).IsNil()
+			}
+			g.Assert(q.running).Equal(5)
+		})
+
+		g.It("queues backups in order once the limit is reached", func() {
+			q := &backupQueue{}
+			release, err := q.acquire(context.Background(), 1, nil)
+			g.Assert(err).IsNil()
+
+			positions := make(chan int, 10)
+			acquired := make(chan func(), 2)
+			for i := 0; i < 2; i++ {
+				go func() {
+					r, _ := q.acquire(context.Background(), 1, func(p int) { positions <- p })
+					acquired <- r
+				}()
+				g.Assert(<-positions).Equal(i + 1)
+			}
+
+			release()
+			second := <-acquired
+			g.Assert(<-positions).Equal(1)
+			g.Assert(q.running).Equal(1)
+
+			second()
+			(<-acquired)()
+			g.Assert(q.running).Equal(0)
+			g.Assert(len(q.waiting)).Equal(0)
+		})
+
+		g.It("removes a backup from the queue when it is canceled", func() {
+			q := &backupQueue{}
+			release, _ := q.acquire(context.Background(), 1, nil)
+			defer release()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			_, err := q.acquire(ctx, 1, func(int) {})
+			g.Assert(err).Equal(context.DeadlineExceeded)
+			g.Assert(len(q.waiting)).Equal(0)
+		})
+	})
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+
+	"github.com/kristiangarcia/wings/server/filesystem"
+)
+
+// maxConfigBundleFileSize is the largest configuration file that can be
+// included in a bundle.
+const maxConfigBundleFileSize = 4 * 1024 * 1024
+
+// ConfigBundleFile is a single configuration file within a bundle. A bundle is
+// a JSON Lines document with one of these on each line.
+type ConfigBundleFile struct {
+	Path string `json:"path"`
+	// The contents of the file, encoded as base64 so that the bundle can hold any
+	// file regardless of its encoding.
+	Contents []byte `json:"contents"`
+}
+
+// ConfigBundleError is returned when a bundle being imported contains a line
+// that cannot be imported. Nothing is written if any line of the bundle is
+// invalid.
+type ConfigBundleError struct {
+	// The line of the bundle that is invalid, starting from 1.
+	Line int
+	// The path of the file on that line, if it could be read.
+	Path string
+	// Why the line cannot be imported.
+	Reason string
+}
+
+func (e *ConfigBundleError) Error() string {
+	return fmt.Sprintf("config bundle: line %d: %s", e.Line, e.Reason)
+}
+
+// configBundlePaths returns the configuration files declared by the egg of the
+// server, relative to the server root.
+func (s *Server) configBundlePaths() []string {
+	var out []string
+	for _, cf := range s.ProcessConfiguration().ConfigurationFiles {
+		if p := strings.TrimPrefix(path.Clean("/"+cf.FileName), "/"); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// ExportConfigBundle writes every configuration file declared by the egg of the
+// server to the writer as a bundle that can be imported with ImportConfigBundle,
+// returning the paths of the files that were included. Declared files that do
+// not exist, or are not regular files, are left out.
+func (s *Server) ExportConfigBundle(w io.Writer) ([]string, error) {
+	files := []string{}
+	enc := json.NewEncoder(w)
+	for _, p := range s.configBundlePaths() {
+		f, st, err := s.Filesystem().File(p)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		if !st.Mode().IsRegular() {
+			f.Close()
+			continue
+		}
+		if st.Size() > maxConfigBundleFileSize {
+			f.Close()
+			return nil, errors.Errorf("config bundle: %s is larger than the maximum size of %d bytes", p, maxConfigBundleFileSize)
+		}
+		b, err := io.ReadAll(io.LimitReader(f, maxConfigBundleFileSize))
+		f.Close()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := enc.Encode(ConfigBundleFile{Path: p, Contents: b}); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		files = append(files, p)
+	}
+	return files, nil
+}
+
+// ImportConfigBundle writes the files in a bundle created by ExportConfigBundle
+// to the server directory, returning their paths. Only files declared as
+// configuration files by the egg of the server can be imported, and none of them
+// can be on the file denylist of the egg. Every line of the bundle is checked
+// before anything is written, returning a ConfigBundleError for the first one
+// that is invalid. Either every file in the bundle is written or, if writing any
+// of them fails, none of them are.
+func (s *Server) ImportConfigBundle(r io.Reader) ([]string, error) {
+	allowed := s.configBundlePaths()
+	var files []ConfigBundleFile
+
+	sc := bufio.NewScanner(r)
+	// Each line holds the base64 encoded contents of a file, along with a little
+	// room for the rest of the JSON.
+	sc.Buffer(make([]byte, 0, 64*1024), maxConfigBundleFileSize/3*4+64*1024)
+	seen := make(map[string]bool)
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var f ConfigBundleFile
+		if err := json.Unmarshal(sc.Bytes(), &f); err != nil {
+			return nil, &ConfigBundleError{Line: line, Reason: "line is not a valid bundle file"}
+		}
+		f.Path = strings.TrimPrefix(path.Clean("/"+f.Path), "/")
+		e := &ConfigBundleError{Line: line, Path: f.Path}
+		switch {
+		case !slices.Contains(allowed, f.Path):
+			e.Reason = f.Path + " is not a configuration file for this server"
+		case seen[f.Path]:
+			e.Reason = f.Path + " is included more than once"
+		case len(f.Contents) > maxConfigBundleFileSize:
+			e.Reason = f.Path + " is too large"
+		case s.Filesystem().IsIgnored(f.Path) != nil:
+			e.Reason = f.Path + " cannot be modified"
+		}
+		if e.Reason != "" {
+			return nil, e
+		}
+		seen[f.Path] = true
+		files = append(files, f)
+	}
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, &ConfigBundleError{Line: line + 1, Reason: "line is too long"}
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	writes := make([]filesystem.FileWrite, 0, len(files))
+	out := make([]string, 0, len(files))
+	for _, f := range files {
+		writes = append(writes, filesystem.FileWrite{Path: f.Path, Contents: bytes.NewReader(f.Contents), Size: int64(len(f.Contents)), Mode: 0o644})
+		out = append(out, f.Path)
+	}
+	if err := s.Filesystem().WriteAll(writes); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
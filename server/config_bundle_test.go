@@ -0,0 +1,109 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/parser"
+	"github.com/kristiangarcia/wings/remote"
+	"github.com/kristiangarcia/wings/server/filesystem"
+)
+
+// newTestServer returns a server with an empty directory and the given
+// configuration files declared by its egg.
+func newTestServer(files ...string) (*Server, string) {
+	config.Set(&config.Configuration{
+		AuthenticationToken: "abc",
+		System: config.SystemConfiguration{
+			RootDirectory:     "/server",
+			DiskCheckInterval: 150,
+		},
+	})
+	root, err := os.MkdirTemp(os.TempDir(), "pterodactyl")
+	if err != nil {
+		panic(err)
+	}
+	fs, err := filesystem.New(root, 0, []string{})
+	if err != nil {
+		panic(err)
+	}
+	pc := &remote.ProcessConfiguration{}
+	for _, f := range files {
+		pc.ConfigurationFiles = append(pc.ConfigurationFiles, parser.ConfigurationFile{FileName: f})
+	}
+	return &Server{ctx: context.Background(), fs: fs, procConfig: pc}, root
+}
+
+func TestConfigBundle(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Server#ImportConfigBundle", func() {
+		g.It("imports a bundle exported from another server", func() {
+			src, srcRoot := newTestServer("server.properties", "config/ops.json", "missing.yml")
+			defer os.RemoveAll(srcRoot)
+			dst, dstRoot := newTestServer("server.properties", "config/ops.json", "missing.yml")
+			defer os.RemoveAll(dstRoot)
+
+			g.Assert(os.MkdirAll(filepath.Join(srcRoot, "config"), 0o755)).IsNil()
+			g.Assert(os.WriteFile(filepath.Join(srcRoot, "server.properties"), []byte("motd=hello\n"), 0o644)).IsNil()
+			g.Assert(os.WriteFile(filepath.Join(srcRoot, "config/ops.json"), []byte("[]\n"), 0o644)).IsNil()
+
+			var buf bytes.Buffer
+			exported, err := src.ExportConfigBundle(&buf)
+			g.Assert(err).IsNil()
+			g.Assert(exported).Equal([]string{"server.properties", "config/ops.json"})
+
+			imported, err := dst.ImportConfigBundle(&buf)
+			g.Assert(err).IsNil()
+			g.Assert(imported).Equal(exported)
+
+			b, err := os.ReadFile(filepath.Join(dstRoot, "server.properties"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal("motd=hello\n")
+			b, err = os.ReadFile(filepath.Join(dstRoot, "config/ops.json"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal("[]\n")
+		})
+
+		g.It("rejects a file that is not a configuration file", func() {
+			s, root := newTestServer("server.properties")
+			defer os.RemoveAll(root)
+
+			_, err := s.ImportConfigBundle(strings.NewReader(`{"path":"start.sh","contents":"ZWNobw=="}` + "\n"))
+			var be *ConfigBundleError
+			g.Assert(errors.As(err, &be)).IsTrue()
+			g.Assert(be.Line).Equal(1)
+			_, err = os.Lstat(filepath.Join(root, "start.sh"))
+			g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+		})
+
+		g.It("writes none of the files if one of them cannot be written", func() {
+			s, root := newTestServer("server.properties", "config")
+			defer os.RemoveAll(root)
+
+			g.Assert(os.WriteFile(filepath.Join(root, "server.properties"), []byte("motd=hello\n"), 0o644)).IsNil()
+			g.Assert(os.Mkdir(filepath.Join(root, "config"), 0o755)).IsNil()
+
+			bundle := `{"path":"server.properties","contents":"bW90ZD1jaGFuZ2VkCg=="}` + "\n" +
+				`{"path":"config","contents":"e30K"}` + "\n"
+			_, err := s.ImportConfigBundle(strings.NewReader(bundle))
+			g.Assert(filesystem.IsErrorCode(err, filesystem.ErrCodeIsDirectory)).IsTrue()
+
+			b, err := os.ReadFile(filepath.Join(root, "server.properties"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal("motd=hello\n")
+
+			entries, err := os.ReadDir(root)
+			g.Assert(err).IsNil()
+			g.Assert(len(entries)).Equal(2)
+		})
+	})
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"sync"
 
+	"github.com/kristiangarcia/wings/config"
 	"github.com/kristiangarcia/wings/environment"
 )
 
@@ -14,6 +15,26 @@ type EggConfiguration struct {
 	// or basically any type of access on the server by any user. This is NOT the same
 	// as a per-user denylist, this is defined at the Egg level.
 	FileDenylist []string `json:"file_denylist"`
+
+	// Glob patterns for files and directories that are skipped when searching the
+	// server files unless the search overrides them, such as "libraries/" or
+	// "cache/" for eggs with known large dependency directories.
+	SearchExcludes []string `json:"search_excludes"`
+
+	// Glob patterns for files managed by the Panel, such as a configuration file
+	// containing secrets. They still appear in search results, but flagged as
+	// protected and without any of their contents.
+	ProtectedFiles []string `json:"protected_files"`
+}
+
+// IoLimits are the Disk I/O rate limits, in MiB/s, applied to heavy operations
+// on a server. A limit that is not set uses the limit configured for the node,
+// and a limit less than 1 means that the operation is not limited at all.
+type IoLimits struct {
+	// The write limit for generating and restoring backups.
+	BackupWrite *int `json:"backup_write"`
+	// The read limit for searching the contents of files.
+	SearchRead *int `json:"search_read"`
 }
 
 type ConfigurationMeta struct {
@@ -55,6 +76,22 @@ type Configuration struct {
 	Mounts                []Mount                 `json:"mounts"`
 	Egg                   EggConfiguration        `json:"egg,omitempty"`
 
+	// What to do when the server process exits unexpectedly, if not set the crash
+	// detection settings are used.
+	RestartPolicy RestartPolicy `json:"restart_policy"`
+
+	// Glob patterns for files and directories that are skipped when searching the
+	// server files, in addition to those set for the egg.
+	SearchExcludes []string `json:"search_excludes"`
+
+	// Glob patterns for files that are protected in search results, in addition
+	// to those set for the egg.
+	ProtectedFiles []string `json:"protected_files"`
+
+	// Disk I/O limits for heavy operations on this server, overriding those set
+	// for the node.
+	IoLimits IoLimits `json:"io_limits"`
+
 	Container struct {
 		// Defines the Docker image that will be used for this server
 		Image string `json:"image,omitempty"`
@@ -69,6 +106,55 @@ func (s *Server) Config() *Configuration {
 	return &s.cfg
 }
 
+// SearchExcludes returns the glob patterns that are excluded from searches of
+// the server files by default, combining those set for the egg and the server.
+func (s *Server) SearchExcludes() []string {
+	s.cfg.mu.RLock()
+	defer s.cfg.mu.RUnlock()
+	out := make([]string, 0, len(s.cfg.Egg.SearchExcludes)+len(s.cfg.SearchExcludes))
+	out = append(out, s.cfg.Egg.SearchExcludes...)
+	return append(out, s.cfg.SearchExcludes...)
+}
+
+// ProtectedFiles returns the glob patterns for files whose contents are never
+// included in search results, combining those set for the egg and the server.
+func (s *Server) ProtectedFiles() []string {
+	s.cfg.mu.RLock()
+	defer s.cfg.mu.RUnlock()
+	out := make([]string, 0, len(s.cfg.Egg.ProtectedFiles)+len(s.cfg.ProtectedFiles))
+	out = append(out, s.cfg.Egg.ProtectedFiles...)
+	return append(out, s.cfg.ProtectedFiles...)
+}
+
+// BackupWriteLimit returns the Disk I/O write limit in bytes per second for the
+// backups of the server, or 0 if they are not limited.
+func (s *Server) BackupWriteLimit() int64 {
+	s.cfg.mu.RLock()
+	defer s.cfg.mu.RUnlock()
+	return resolveIoLimit(s.cfg.IoLimits.BackupWrite, config.Get().System.Backups.WriteLimit)
+}
+
+// SearchReadLimit returns the Disk I/O read limit in bytes per second for
+// searching the contents of the server files, or 0 if they are not limited.
+func (s *Server) SearchReadLimit() int64 {
+	s.cfg.mu.RLock()
+	defer s.cfg.mu.RUnlock()
+	return resolveIoLimit(s.cfg.IoLimits.SearchRead, config.Get().Filesystem.SearchReadLimit)
+}
+
+// resolveIoLimit returns the server limit if one is set, otherwise the node
+// limit, converted from MiB/s to bytes per second.
+func resolveIoLimit(server *int, node int) int64 {
+	limit := node
+	if server != nil {
+		limit = *server
+	}
+	if limit < 1 {
+		return 0
+	}
+	return int64(limit) * 1024 * 1024
+}
+
 // DiskSpace returns the amount of disk space available to a server in bytes.
 func (s *Server) DiskSpace() int64 {
 	s.cfg.mu.RLock()
@@ -12,11 +12,91 @@ import (
 	"github.com/kristiangarcia/wings/environment"
 )
 
+// The restart policies that can be configured for a server, controlling what
+// happens when the server process exits without being asked to.
+const (
+	// RestartPolicyAlways restarts the process whenever it exits, even if it
+	// exited cleanly.
+	RestartPolicyAlways = "always"
+	// RestartPolicyOnFailure restarts the process only if it exited with an error
+	// or was killed for running out of memory, waiting longer before each restart.
+	RestartPolicyOnFailure = "on-failure"
+	// RestartPolicyNever never restarts the process.
+	RestartPolicyNever = "never"
+)
+
+// The decisions published in a RestartDecisionEvent.
+const (
+	RestartDecisionRestart = "restart"
+	RestartDecisionSkip    = "skip"
+	RestartDecisionAbort   = "abort"
+)
+
+const (
+	// defaultRestartWindow is the window that restarts are counted in when a
+	// restart policy does not set one.
+	defaultRestartWindow = 10 * time.Minute
+	// maxRestartBackoff is the longest that will be waited before restarting a
+	// process, no matter how many times it has been restarted.
+	maxRestartBackoff = 5 * time.Minute
+)
+
+// RestartPolicy controls what Wings does when the server process exits
+// unexpectedly. If no mode is set the node wide crash detection settings are
+// used instead.
+type RestartPolicy struct {
+	// One of "always", "on-failure", or "never".
+	Mode string `json:"mode"`
+	// The most times that the process will be restarted within the window, once
+	// reached the process is left offline. If 0 there is no limit.
+	MaxRestarts int `json:"max_restarts"`
+	// The number of seconds that restarts are counted over, defaults to ten
+	// minutes.
+	Window int `json:"window"`
+	// The number of seconds to wait before the first restart when using the
+	// on-failure mode, doubling with each restart within the window.
+	Backoff int `json:"backoff"`
+}
+
+// window returns the period that restarts are counted over.
+func (p RestartPolicy) window() time.Duration {
+	if p.Window <= 0 {
+		return defaultRestartWindow
+	}
+	return time.Duration(p.Window) * time.Second
+}
+
+// ExitState describes how the server process last exited.
+type ExitState struct {
+	Code      int       `json:"code"`
+	OomKilled bool      `json:"oom_killed"`
+	Time      time.Time `json:"time"`
+	// Why the process exited, one of "oom_killed", "error", or "clean".
+	Reason string `json:"reason"`
+}
+
+// CrashStatus is the current state of the crash handling for a server.
+type CrashStatus struct {
+	Policy RestartPolicy `json:"policy"`
+	// The number of times the process has been restarted after a crash since
+	// Wings was started, and the number of those within the policy window.
+	RestartCount   int        `json:"restart_count"`
+	RecentRestarts int        `json:"recent_restarts"`
+	LastRestart    *time.Time `json:"last_restart"`
+	LastExit       *ExitState `json:"last_exit"`
+}
+
 type CrashHandler struct {
 	mu sync.RWMutex
 
 	// Tracks the time of the last server crash event.
 	lastCrash time.Time
+
+	// The times that the process was restarted after a crash, oldest first. Only
+	// those within the window of the restart policy are kept.
+	restarts     []time.Time
+	restartCount int
+	lastExit     *ExitState
 }
 
 // Returns the time of the last crash for this server instance.
@@ -34,6 +114,69 @@ func (cd *CrashHandler) SetLastCrash(t time.Time) {
 	cd.mu.Unlock()
 }
 
+// recordExit stores how the process last exited.
+func (cd *CrashHandler) recordExit(e ExitState) {
+	cd.mu.Lock()
+	cd.lastExit = &e
+	cd.mu.Unlock()
+}
+
+// recordRestart counts a restart of the process at the given time.
+func (cd *CrashHandler) recordRestart(t time.Time) {
+	cd.mu.Lock()
+	cd.restarts = append(cd.restarts, t)
+	cd.restartCount++
+	cd.mu.Unlock()
+}
+
+// recentRestarts returns the number of restarts within the given window,
+// forgetting any that happened before it.
+func (cd *CrashHandler) recentRestarts(window time.Duration) int {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	cutoff := time.Now().Add(-window)
+	i := 0
+	for i < len(cd.restarts) && cd.restarts[i].Before(cutoff) {
+		i++
+	}
+	cd.restarts = cd.restarts[i:]
+	return len(cd.restarts)
+}
+
+// CrashStatus returns the restart policy for the server along with how many
+// times it has been restarted and how it last exited.
+func (s *Server) CrashStatus() CrashStatus {
+	policy := s.Config().RestartPolicy
+	out := CrashStatus{Policy: policy, RecentRestarts: s.crasher.recentRestarts(policy.window())}
+
+	s.crasher.mu.RLock()
+	defer s.crasher.mu.RUnlock()
+	out.RestartCount = s.crasher.restartCount
+	if !s.crasher.lastCrash.IsZero() {
+		t := s.crasher.lastCrash
+		out.LastRestart = &t
+	}
+	if s.crasher.lastExit != nil {
+		e := *s.crasher.lastExit
+		out.LastExit = &e
+	}
+	return out
+}
+
+// publishRestartDecision emits an event describing what was decided after the
+// process exited unexpectedly, and why.
+func (s *Server) publishRestartDecision(decision, reason string, exit ExitState, delay time.Duration) {
+	s.Events().Publish(RestartDecisionEvent, map[string]interface{}{
+		"decision":   decision,
+		"reason":     reason,
+		"exit_code":  exit.Code,
+		"oom_killed": exit.OomKilled,
+		"restarts":   s.crasher.recentRestarts(s.Config().RestartPolicy.window()),
+		"delay":      delay.Seconds(),
+	})
+}
+
 // Looks at the environment exit state to determine if the process exited cleanly or
 // if it was the result of an event that we should try to recover from.
 //
@@ -43,17 +186,21 @@ func (cd *CrashHandler) SetLastCrash(t time.Time) {
 // by Wings.
 //
 // If the server is determined to have crashed, the process will be restarted and the
-// counter for the server will be incremented.
+// counter for the server will be incremented. If the server has a restart policy it
+// decides whether the process is restarted, see applyRestartPolicy.
 func (s *Server) handleServerCrash() error {
+	if s.Environment.State() != environment.ProcessOfflineState {
+		return nil
+	}
+	policy := s.Config().RestartPolicy
+
 	// No point in doing anything here if the server isn't currently offline, there
 	// is no reason to do a crash detection event. If the server crash detection is
 	// disabled we want to skip anything after this as well.
-	if s.Environment.State() != environment.ProcessOfflineState || !s.Config().CrashDetectionEnabled {
-		if !s.Config().CrashDetectionEnabled {
-			s.Log().Debug("server triggered crash detection but handler is disabled for server process")
-			s.PublishConsoleOutputFromDaemon("Aborting automatic restart, crash detection is disabled for this instance.")
-		}
-
+	if policy.Mode == "" && !s.Config().CrashDetectionEnabled {
+		s.Log().Debug("server triggered crash detection but handler is disabled for server process")
+		s.PublishConsoleOutputFromDaemon("Aborting automatic restart, crash detection is disabled for this instance.")
+		s.publishRestartDecision(RestartDecisionSkip, "crash detection is disabled", ExitState{}, 0)
 		return nil
 	}
 
@@ -61,11 +208,24 @@ func (s *Server) handleServerCrash() error {
 	if err != nil {
 		return errors.Wrap(err, "failed to get exit state for server process")
 	}
+	exit := ExitState{Code: int(exitCode), OomKilled: oomKilled, Time: time.Now(), Reason: "clean"}
+	switch {
+	case oomKilled:
+		exit.Reason = "oom_killed"
+	case exitCode != 0:
+		exit.Reason = "error"
+	}
+	s.crasher.recordExit(exit)
+
+	if policy.Mode != "" {
+		return s.applyRestartPolicy(policy, exit)
+	}
 
 	// If the system is not configured to detect a clean exit code as a crash, and the
 	// crash is not the result of the program running out of memory, do nothing.
 	if exitCode == 0 && !oomKilled && !config.Get().System.CrashDetection.DetectCleanExitAsCrash {
 		s.Log().Debug("server exited with successful exit code; system is configured to not detect this as a crash")
+		s.publishRestartDecision(RestartDecisionSkip, "process exited cleanly", exit, 0)
 		return nil
 	}
 
@@ -82,10 +242,71 @@ func (s *Server) handleServerCrash() error {
 	// If timeout is set to 0, always reboot the server (this is probably a terrible idea, but some people want it)
 	if timeout != 0 && !c.IsZero() && c.Add(time.Second*time.Duration(config.Get().System.CrashDetection.Timeout)).After(time.Now()) {
 		s.PublishConsoleOutputFromDaemon("Aborting automatic restart, last crash occurred less than " + strconv.Itoa(timeout) + " seconds ago.")
+		s.publishRestartDecision(RestartDecisionAbort, "crashed too soon after the last crash", exit, 0)
 		return &crashTooFrequent{}
 	}
 
 	s.crasher.SetLastCrash(time.Now())
+	s.crasher.recordRestart(time.Now())
+	s.publishRestartDecision(RestartDecisionRestart, "process crashed", exit, 0)
+
+	return errors.Wrap(s.HandlePowerAction(PowerActionStart), "failed to start server after crash detection")
+}
+
+// applyRestartPolicy restarts the server process after it exited unexpectedly
+// if the restart policy allows it. A crashTooFrequent error is returned if the
+// process has already been restarted the maximum number of times within the
+// window of the policy.
+func (s *Server) applyRestartPolicy(policy RestartPolicy, exit ExitState) error {
+	failed := exit.Code != 0 || exit.OomKilled
+	switch policy.Mode {
+	case RestartPolicyAlways:
+	case RestartPolicyOnFailure:
+		if !failed {
+			s.publishRestartDecision(RestartDecisionSkip, "process exited cleanly", exit, 0)
+			return nil
+		}
+	case RestartPolicyNever:
+		s.PublishConsoleOutputFromDaemon("Server process exited, it will not be restarted as its restart policy is set to never.")
+		s.publishRestartDecision(RestartDecisionSkip, "restart policy is never", exit, 0)
+		return nil
+	default:
+		s.publishRestartDecision(RestartDecisionSkip, "unknown restart policy", exit, 0)
+		return errors.New("unknown restart policy: " + policy.Mode)
+	}
+
+	restarts := s.crasher.recentRestarts(policy.window())
+	if policy.MaxRestarts > 0 && restarts >= policy.MaxRestarts {
+		s.PublishConsoleOutputFromDaemon(fmt.Sprintf("Aborting automatic restart, the server has already been restarted %d times within %s.", restarts, policy.window()))
+		s.publishRestartDecision(RestartDecisionAbort, "too many restarts", exit, 0)
+		return &crashTooFrequent{}
+	}
+
+	var delay time.Duration
+	if policy.Mode == RestartPolicyOnFailure && policy.Backoff > 0 {
+		delay = time.Duration(policy.Backoff) * time.Second << min(restarts, 16)
+		delay = min(delay, maxRestartBackoff)
+	}
+
+	s.PublishConsoleOutputFromDaemon(fmt.Sprintf("Server process exited (exit code: %d, out of memory: %t), restarting in %s.", exit.Code, exit.OomKilled, delay))
+	s.publishRestartDecision(RestartDecisionRestart, "restart policy is "+policy.Mode, exit, delay)
+	s.crasher.SetLastCrash(time.Now())
+	s.crasher.recordRestart(time.Now())
+
+	if delay > 0 {
+		t := time.NewTimer(delay)
+		select {
+		case <-s.Context().Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+		// Someone may have started the server while waiting, in which case there is
+		// nothing left to do.
+		if s.Environment.State() != environment.ProcessOfflineState {
+			return nil
+		}
+	}
 
 	return errors.Wrap(s.HandlePowerAction(PowerActionStart), "failed to start server after crash detection")
 }
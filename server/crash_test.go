@@ -0,0 +1,32 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+)
+
+func TestCrashHandler(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("CrashHandler#recentRestarts", func() {
+		g.It("should only count restarts within the window", func() {
+			cd := &CrashHandler{}
+			cd.recordRestart(time.Now().Add(-time.Hour))
+			cd.recordRestart(time.Now().Add(-time.Minute))
+			cd.recordRestart(time.Now())
+
+			g.Assert(cd.recentRestarts(5 * time.Minute)).Equal(2)
+			g.Assert(cd.restartCount).Equal(3)
+			g.Assert(len(cd.restarts)).Equal(2)
+		})
+	})
+
+	g.Describe("RestartPolicy#window", func() {
+		g.It("should default to ten minutes", func() {
+			g.Assert(RestartPolicy{}.window()).Equal(defaultRestartWindow)
+			g.Assert(RestartPolicy{Window: 30}.window()).Equal(30 * time.Second)
+		})
+	})
+}
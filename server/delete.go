@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/kristiangarcia/wings/server/filesystem"
+)
+
+// deleteProgressInterval is how often the progress of a recursive delete is
+// published over the websocket.
+const deleteProgressInterval = time.Second
+
+// DeleteRecursive permanently deletes the given path within the server
+// directory as part of a background operation, publishing its progress over
+// the websocket as it runs and the results once it has finished.
+func (s *Server) DeleteRecursive(ctx context.Context, op *Operation, p string, rate int) (*filesystem.DeleteResult, error) {
+	var done, total atomic.Int64
+	pctx, cancel := context.WithCancel(ctx)
+	go s.publishDeleteProgress(pctx, op.ID, p, &done, &total)
+
+	res, err := s.Filesystem().DeleteRecursive(ctx, p, filesystem.DeleteOptions{
+		Rate: rate,
+		OnProgress: func(d, t int64) {
+			done.Store(d)
+			total.Store(t)
+		},
+	})
+	cancel()
+	if err != nil {
+		s.Log().WithField("path", p).WithField("error", err).Error("failed to start recursive delete")
+		s.Events().Publish(DeleteCompletedEvent+":"+op.ID, map[string]interface{}{
+			"operation": op.ID,
+			"path":      p,
+			"error":     err.Error(),
+		})
+		return nil, err
+	}
+
+	s.Log().WithField("path", p).
+		WithField("removed", res.Removed).
+		WithField("failed", res.Failed).
+		WithField("canceled", res.Canceled).
+		Info("finished recursive delete")
+	s.Filesystem().RecordOp(filesystem.RecentOpDelete, p)
+	s.Events().Publish(DeleteCompletedEvent+":"+op.ID, map[string]interface{}{
+		"operation": op.ID,
+		"path":      p,
+		"result":    res,
+	})
+	return res, nil
+}
+
+// publishDeleteProgress periodically emits the progress of a recursive delete
+// over the server websocket until the context is canceled.
+func (s *Server) publishDeleteProgress(ctx context.Context, id, p string, done, total *atomic.Int64) {
+	t := time.NewTicker(deleteProgressInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.Events().Publish(DeleteProgressEvent+":"+id, map[string]interface{}{
+				"operation": id,
+				"path":      p,
+				"done":      done.Load(),
+				"total":     total.Load(),
+			})
+		}
+	}
+}
@@ -16,9 +16,17 @@ const (
 	StatsEvent                  = "stats"
 	BackupRestoreCompletedEvent = "backup restore completed"
 	BackupCompletedEvent        = "backup completed"
+	BackupProgressEvent         = "backup progress"
+	BackupQueuedEvent           = "backup queued"
+	DeleteProgressEvent         = "delete progress"
+	DeleteCompletedEvent        = "delete completed"
+	PermissionsProgressEvent    = "permissions progress"
+	PermissionsCompletedEvent   = "permissions completed"
 	TransferLogsEvent           = "transfer logs"
 	TransferStatusEvent         = "transfer status"
 	DeletedEvent                = "deleted"
+	RestartDecisionEvent        = "restart decision"
+	FileChangeEvent             = "file change"
 )
 
 // Events returns the server's emitter instance.
@@ -74,6 +74,10 @@ type Archive struct {
 	// Progress wraps the writer of the archive to pass through the progress tracker.
 	Progress *progress.Progress
 
+	// WriteLimit is the Disk I/O write limit, in bytes per second, applied when the
+	// archive is created on the disk. If it is 0 the write speed is unlimited.
+	WriteLimit int64
+
 	w *TarProgress
 }
 
@@ -91,13 +95,13 @@ func (a *Archive) Create(ctx context.Context, dst string) error {
 	}
 	defer f.Close()
 
-	// Select a writer based off of the WriteLimit configuration option. If there is no
-	// write limit, use the file as the writer.
+	// Select a writer based off of the WriteLimit option. If there is no write limit,
+	// use the file as the writer.
 	var writer io.Writer
-	if writeLimit := int64(config.Get().System.Backups.WriteLimit * 1024 * 1024); writeLimit > 0 {
-		// Token bucket with a capacity of "writeLimit" MiB, adding "writeLimit" MiB/s
+	if a.WriteLimit > 0 {
+		// Token bucket with a capacity of "WriteLimit" bytes, adding "WriteLimit" bytes/s
 		// and then wrap the file writer with the token bucket limiter.
-		writer = ratelimit.Writer(f, ratelimit.NewBucketWithRate(float64(writeLimit), writeLimit))
+		writer = ratelimit.Writer(f, ratelimit.NewBucketWithRate(float64(a.WriteLimit), a.WriteLimit))
 	} else {
 		writer = f
 	}
@@ -275,6 +279,12 @@ func (a *Archive) addToArchive(dirfd int, name, relative string, entry ufs.DirEn
 		return nil
 	}
 
+	// Skip symlinks entirely if the filesystem is treating them as if they do not
+	// exist, otherwise they are stored as links in the archive.
+	if s.Mode()&fs.ModeSymlink != 0 && a.Filesystem.symlinks == SymlinkPolicyIgnore {
+		return nil
+	}
+
 	// Resolve the symlink target if the file is a symlink.
 	var target string
 	if s.Mode()&fs.ModeSymlink != 0 {
@@ -307,6 +317,9 @@ func (a *Archive) addToArchive(dirfd int, name, relative string, entry ufs.DirEn
 	if err := a.w.WriteHeader(header); err != nil {
 		return errors.WrapIff(err, "failed to write tar#FileInfoHeader for '%s'", name)
 	}
+	if a.Progress != nil {
+		a.Progress.AddFile(relative)
+	}
 
 	// If the size of the file is less than 1 (most likely for symlinks), skip writing the file.
 	if header.Size < 1 {
@@ -327,7 +340,7 @@ func (a *Archive) addToArchive(dirfd int, name, relative string, entry ufs.DirEn
 	}
 
 	// Open the file.
-	f, err := a.Filesystem.unixFS.OpenFileat(dirfd, name, ufs.O_RDONLY, 0)
+	f, err := a.Filesystem.openFileat(dirfd, name, ufs.O_RDONLY, 0)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
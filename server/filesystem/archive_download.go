@@ -0,0 +1,140 @@
+package filesystem
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/klauspost/compress/zip"
+
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+// The formats that a selection of files can be streamed as.
+const (
+	ArchiveFormatTarGz = "tar.gz"
+	ArchiveFormatZip   = "zip"
+)
+
+// ErrArchiveTooLarge is returned when a selection of files is larger than the
+// limits allowed for streaming it as an archive.
+var ErrArchiveTooLarge = errors.Sentinel("filesystem: selection is too large to archive")
+
+// ArchiveSize walks the selected files within the given directory and returns
+// the number of files and their total size. If either of the limits are greater
+// than zero and are exceeded, ErrArchiveTooLarge is returned as soon as that is
+// known.
+func (fs *Filesystem) ArchiveSize(ctx context.Context, dir string, files []string, maxEntries int, maxSize int64) (int, int64, error) {
+	var entries int
+	var size int64
+	err := fs.walkSelection(dir, files, func(p string, d ufs.DirEntry) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			return nil
+		}
+		entries++
+		if maxEntries > 0 && entries > maxEntries {
+			return ErrArchiveTooLarge
+		}
+		if d.Type().IsRegular() {
+			if info, err := fs.unixFS.Lstat(p); err == nil {
+				size += info.Size()
+			}
+		}
+		if maxSize > 0 && size > maxSize {
+			return ErrArchiveTooLarge
+		}
+		return nil
+	})
+	return entries, size, err
+}
+
+// StreamArchive writes an archive of the selected files within the given
+// directory to the writer in the given format, without writing anything to the
+// disk. Paths within the archive are relative to the directory.
+func (fs *Filesystem) StreamArchive(ctx context.Context, w io.Writer, dir string, files []string, format string) error {
+	switch format {
+	case ArchiveFormatTarGz, "":
+		a := &Archive{Filesystem: fs, BaseDirectory: dir, Files: files}
+		return a.Stream(ctx, w)
+	case ArchiveFormatZip:
+		return fs.streamZip(ctx, w, dir, files)
+	default:
+		return errors.New("filesystem: unsupported archive format: " + format)
+	}
+}
+
+// streamZip writes a zip archive of the selected files to the writer. Symlinks
+// are not included since they cannot be reliably restored from a zip archive.
+func (fs *Filesystem) streamZip(ctx context.Context, w io.Writer, dir string, files []string) error {
+	zw := zip.NewWriter(w)
+	base := strings.Trim(path.Clean("/"+dir), "/")
+	err := fs.walkSelection(dir, files, func(p string, d ufs.DirEntry) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := fs.unixFS.Lstat(p)
+		if err != nil {
+			return nil
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return errors.WrapIff(err, "failed to get zip#FileInfoHeader for '%s'", p)
+		}
+		header.Name = strings.TrimPrefix(strings.TrimPrefix(strings.Trim(p, "/"), base), "/")
+		if d.IsDir() {
+			if header.Name == "" {
+				return nil
+			}
+			header.Name += "/"
+			_, err := zw.CreateHeader(header)
+			return err
+		}
+		header.Method = zip.Deflate
+
+		f, err := fs.openFile(p)
+		if err != nil {
+			if errors.Is(err, ufs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		defer f.Close()
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(fw, f)
+		return err
+	})
+	if err != nil {
+		_ = zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// walkSelection calls fn for every file and directory in the selection of files
+// within the given directory, including everything within selected directories.
+// Symlinked directories are never descended into.
+func (fs *Filesystem) walkSelection(dir string, files []string, fn func(p string, d ufs.DirEntry) error) error {
+	for _, f := range files {
+		err := fs.unixFS.WalkDir(path.Join(dir, f), func(p string, d ufs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			return fn(p, d)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package filesystem
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	iofs "io/fs"
 	"os"
 	"path/filepath"
@@ -10,6 +12,7 @@ import (
 	"testing"
 
 	. "github.com/franela/goblin"
+	"github.com/klauspost/compress/zip"
 	"github.com/mholt/archives"
 )
 
@@ -120,3 +123,51 @@ func getFiles(f iofs.ReadDirFS, name string) ([]string, error) {
 
 	return v, nil
 }
+
+func TestFilesystem_StreamArchive(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("StreamArchive", func() {
+		g.BeforeEach(func() {
+			_ = fs.CreateDirectory("world", "/")
+			_ = rfs.CreateServerFileFromString("world/level.dat", "level data")
+			_ = rfs.CreateServerFileFromString("server.properties", "motd=hello")
+			_ = rfs.CreateServerFileFromString("other.txt", "not selected")
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("streams the selected files as a zip", func() {
+			var b bytes.Buffer
+			err := fs.StreamArchive(context.Background(), &b, "/", []string{"world", "server.properties"}, ArchiveFormatZip)
+			g.Assert(err).IsNil()
+
+			zr, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+			g.Assert(err).IsNil()
+			var names []string
+			for _, f := range zr.File {
+				names = append(names, f.Name)
+			}
+			sort.Strings(names)
+			g.Assert(names).Equal([]string{"server.properties", "world/", "world/level.dat"})
+		})
+
+		g.It("returns the size of the selection", func() {
+			entries, size, err := fs.ArchiveSize(context.Background(), "/", []string{"world", "server.properties"}, 0, 0)
+			g.Assert(err).IsNil()
+			g.Assert(entries).Equal(2)
+			g.Assert(size).Equal(int64(len("level data") + len("motd=hello")))
+		})
+
+		g.It("rejects selections over the limits", func() {
+			_, _, err := fs.ArchiveSize(context.Background(), "/", []string{"world", "server.properties"}, 1, 0)
+			g.Assert(errors.Is(err, ErrArchiveTooLarge)).IsTrue()
+
+			_, _, err = fs.ArchiveSize(context.Background(), "/", []string{"world", "server.properties"}, 0, 5)
+			g.Assert(errors.Is(err, ErrArchiveTooLarge)).IsTrue()
+		})
+	})
+}
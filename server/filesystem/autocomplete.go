@@ -0,0 +1,76 @@
+package filesystem
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+// PathSuggestion is a single entry suggested when completing a partial path.
+type PathSuggestion struct {
+	Name string `json:"name"`
+	// The full path of the entry relative to the server root, with a trailing
+	// slash for directories so that it can be completed further.
+	Path      string `json:"path"`
+	Directory bool   `json:"directory"`
+	Symlink   bool   `json:"symlink"`
+}
+
+// Autocomplete returns the entries in the directory containing the given partial
+// path whose names start with the last element of the path, ignoring case. A
+// path ending in a slash lists the entries of that directory. Only the directory
+// listing is read, nothing is opened or stat'd, so this is cheap enough to call
+// on every keystroke.
+//
+// At most limit suggestions are returned, sorted alphabetically with directories
+// first. If the containing directory does not exist no suggestions are returned.
+func (fs *Filesystem) Autocomplete(partial string, limit int) ([]PathSuggestion, error) {
+	dir, prefix := path.Split(path.Join("/", partial))
+	if strings.HasSuffix(partial, "/") && partial != "/" {
+		dir, prefix = path.Join("/", partial), ""
+	}
+	prefix = strings.ToLower(prefix)
+
+	entries, err := fs.unixFS.ReadDir(path.Clean(dir))
+	if err != nil {
+		if errors.Is(err, ufs.ErrNotExist) || errors.Is(err, ufs.ErrNotDirectory) {
+			return []PathSuggestion{}, nil
+		}
+		return nil, err
+	}
+
+	out := []PathSuggestion{}
+	for _, e := range entries {
+		if !strings.HasPrefix(strings.ToLower(e.Name()), prefix) {
+			continue
+		}
+		s := PathSuggestion{
+			Name:      e.Name(),
+			Path:      strings.TrimPrefix(path.Join(dir, e.Name()), "/"),
+			Directory: e.IsDir(),
+			Symlink:   e.Type()&ufs.ModeSymlink != 0,
+		}
+		if s.Directory {
+			s.Path += "/"
+		}
+		out = append(out, s)
+	}
+
+	slices.SortFunc(out, func(a, b PathSuggestion) int {
+		if a.Directory != b.Directory {
+			if a.Directory {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
@@ -0,0 +1,74 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func suggestionPaths(s []PathSuggestion) []string {
+	out := make([]string, len(s))
+	for i, v := range s {
+		out[i] = v.Path
+	}
+	return out
+}
+
+func TestFilesystem_Autocomplete(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("Autocomplete", func() {
+		g.BeforeEach(func() {
+			_ = fs.CreateDirectory("plugins", "/")
+			_ = fs.CreateDirectory("Essentials", "/plugins")
+			_ = rfs.CreateServerFileFromString("plugins/EssentialsX.jar", "")
+			_ = rfs.CreateServerFileFromString("plugins/LuckPerms.jar", "")
+			_ = rfs.CreateServerFileFromString("server.properties", "")
+		})
+
+		g.It("returns entries matching the prefix with directories first", func() {
+			s, err := fs.Autocomplete("plugins/ess", 10)
+			g.Assert(err).IsNil()
+			g.Assert(suggestionPaths(s)).Equal([]string{"plugins/Essentials/", "plugins/EssentialsX.jar"})
+			g.Assert(s[0].Directory).IsTrue()
+		})
+
+		g.It("lists a directory when the path ends in a slash", func() {
+			s, err := fs.Autocomplete("/plugins/", 10)
+			g.Assert(err).IsNil()
+			g.Assert(suggestionPaths(s)).Equal([]string{"plugins/Essentials/", "plugins/EssentialsX.jar", "plugins/LuckPerms.jar"})
+		})
+
+		g.It("lists the root directory for an empty path", func() {
+			s, err := fs.Autocomplete("", 10)
+			g.Assert(err).IsNil()
+			g.Assert(suggestionPaths(s)).Equal([]string{"plugins/", "server.properties"})
+		})
+
+		g.It("limits the number of suggestions", func() {
+			s, err := fs.Autocomplete("plugins/", 2)
+			g.Assert(err).IsNil()
+			g.Assert(len(s)).Equal(2)
+		})
+
+		g.It("returns nothing for a directory that does not exist", func() {
+			s, err := fs.Autocomplete("missing/pl", 10)
+			g.Assert(err).IsNil()
+			g.Assert(len(s)).Equal(0)
+		})
+
+		g.It("does not complete paths outside of the server root", func() {
+			_ = os.WriteFile(filepath.Join(rfs.root, "secret.txt"), []byte(""), 0o644)
+			s, err := fs.Autocomplete("../sec", 10)
+			g.Assert(err).IsNil()
+			g.Assert(len(s)).Equal(0)
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
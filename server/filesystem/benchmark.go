@@ -0,0 +1,144 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"time"
+
+	"emperror.dev/errors"
+	"golang.org/x/sys/unix"
+)
+
+// benchmarkBlockSize is the size of each write and read made while benchmarking
+// the disk, the latency of each one is measured.
+const benchmarkBlockSize = 1024 * 1024
+
+// BenchmarkStats are the measurements taken while either writing or reading
+// the file used to benchmark the disk.
+type BenchmarkStats struct {
+	Bytes      int64   `json:"bytes"`
+	DurationMs float64 `json:"duration_ms"`
+	// The number of bytes written or read each second.
+	Throughput float64 `json:"throughput"`
+	// The average and worst time taken to write or read a single block.
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs float64 `json:"max_latency_ms"`
+}
+
+// BenchmarkResult is the outcome of benchmarking the disk of a server.
+type BenchmarkResult struct {
+	BlockSize int            `json:"block_size"`
+	Write     BenchmarkStats `json:"write"`
+	Read      BenchmarkStats `json:"read"`
+}
+
+// Benchmark measures how quickly the disk that the server directory is on can be
+// written to and read from, by writing a temporary file of the given size in
+// the root of the server directory and reading it back. The file is synced to
+// the disk after writing, which is included in the write time, and dropped from
+// the page cache before reading so that the read measures the disk rather than
+// memory. The server must have enough free space for the file, which is always
+// removed before returning.
+func (fs *Filesystem) Benchmark(ctx context.Context, size int64) (*BenchmarkResult, error) {
+	if size <= 0 {
+		return nil, errors.New("server/filesystem: benchmark: size must be greater than zero")
+	}
+	if err := fs.HasSpaceFor(size); err != nil {
+		return nil, err
+	}
+
+	// The file is never committed, so it is always removed.
+	f, err := fs.createTempFile("/", 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.cleanup()
+
+	block := make([]byte, benchmarkBlockSize)
+	// Random data keeps filesystems that compress or deduplicate blocks from
+	// making the disk look faster than it is.
+	if _, err := rand.Read(block); err != nil {
+		return nil, errors.Wrap(err, "server/filesystem: benchmark: failed to generate data")
+	}
+
+	out := &BenchmarkResult{BlockSize: benchmarkBlockSize}
+	var w benchmarkTimer
+	for written := int64(0); written < size; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		b := block[:min(int64(len(block)), size-written)]
+		n, err := w.time(func() (int, error) { return f.Write(b) })
+		written += int64(n)
+		if err != nil {
+			return nil, errors.Wrap(err, "server/filesystem: benchmark: failed to write file")
+		}
+	}
+	// Syncing is part of the time taken to write, but not of any one block.
+	start := time.Now()
+	if err := unix.Fsync(int(f.Fd())); err != nil {
+		return nil, errors.Wrap(err, "server/filesystem: benchmark: failed to sync file")
+	}
+	w.total += time.Since(start)
+	out.Write = w.stats()
+
+	// This is only advice to the kernel, if it is ignored the read is still
+	// measured but will most likely be served from memory.
+	_ = unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_DONTNEED)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.Wrap(err, "server/filesystem: benchmark: failed to seek file")
+	}
+
+	var r benchmarkTimer
+	for read := int64(0); read < size; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		b := block[:min(int64(len(block)), size-read)]
+		n, err := r.time(func() (int, error) { return io.ReadFull(f, b) })
+		read += int64(n)
+		if err != nil {
+			return nil, errors.Wrap(err, "server/filesystem: benchmark: failed to read file")
+		}
+	}
+	out.Read = r.stats()
+
+	return out, nil
+}
+
+// benchmarkTimer measures each operation made while benchmarking the disk.
+type benchmarkTimer struct {
+	bytes int64
+	ops   int
+	total time.Duration
+	worst time.Duration
+}
+
+// time runs and measures a single operation, counting the bytes that it wrote or
+// read.
+func (t *benchmarkTimer) time(fn func() (int, error)) (int, error) {
+	start := time.Now()
+	n, err := fn()
+	d := time.Since(start)
+	t.bytes += int64(n)
+	t.ops++
+	t.total += d
+	t.worst = max(t.worst, d)
+	return n, err
+}
+
+func (t *benchmarkTimer) stats() BenchmarkStats {
+	s := BenchmarkStats{
+		Bytes:        t.bytes,
+		DurationMs:   float64(t.total) / float64(time.Millisecond),
+		MaxLatencyMs: float64(t.worst) / float64(time.Millisecond),
+	}
+	if t.ops > 0 {
+		s.AvgLatencyMs = s.DurationMs / float64(t.ops)
+	}
+	if t.total > 0 {
+		s.Throughput = float64(t.bytes) / t.total.Seconds()
+	}
+	return s
+}
@@ -0,0 +1,46 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_Benchmark(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("Benchmark", func() {
+		g.AfterEach(func() {
+			fs.SetDiskLimit(0)
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("writes and reads back the whole file", func() {
+			size := int64(2*benchmarkBlockSize + 10)
+			res, err := fs.Benchmark(context.Background(), size)
+			g.Assert(err).IsNil()
+			g.Assert(res.Write.Bytes).Equal(size)
+			g.Assert(res.Read.Bytes).Equal(size)
+			g.Assert(res.Write.Throughput > 0).IsTrue()
+			g.Assert(res.Read.MaxLatencyMs >= res.Read.AvgLatencyMs).IsTrue()
+		})
+
+		g.It("removes the file afterwards", func() {
+			_, err := fs.Benchmark(context.Background(), 1024)
+			g.Assert(err).IsNil()
+			entries, err := os.ReadDir(filepath.Join(rfs.root, "server"))
+			g.Assert(err).IsNil()
+			g.Assert(len(entries)).Equal(0)
+		})
+
+		g.It("fails if the server does not have enough space", func() {
+			fs.SetDiskLimit(1024)
+			_, err := fs.Benchmark(context.Background(), 2048)
+			g.Assert(IsErrorCode(err, ErrCodeDiskSpace)).IsTrue()
+		})
+	})
+}
@@ -0,0 +1,153 @@
+package filesystem
+
+import (
+	"io"
+	"path"
+	"strings"
+)
+
+// commentStyle is the kind of comments used by a file format.
+type commentStyle int
+
+const (
+	commentStyleNone commentStyle = iota
+	// Comments start with a "#" at the start of a line or after whitespace, and
+	// run until the end of the line.
+	commentStyleHash
+	// Comments start with "//" and run until the end of the line, or are wrapped
+	// in "/*" and "*/".
+	commentStyleSlash
+)
+
+// commentStyles are the file extensions that have their comments recognized
+// when searching with comments ignored. Files with any other extension are
+// searched as normal.
+var commentStyles = map[string]commentStyle{
+	".yml":        commentStyleHash,
+	".yaml":       commentStyleHash,
+	".properties": commentStyleHash,
+	".toml":       commentStyleHash,
+	".conf":       commentStyleHash,
+	".cfg":        commentStyleHash,
+	".sh":         commentStyleHash,
+	".py":         commentStyleHash,
+	".js":         commentStyleSlash,
+	".ts":         commentStyleSlash,
+	".json5":      commentStyleSlash,
+	".jsonc":      commentStyleSlash,
+	".java":       commentStyleSlash,
+	".kt":         commentStyleSlash,
+	".cs":         commentStyleSlash,
+	".go":         commentStyleSlash,
+	".c":          commentStyleSlash,
+	".h":          commentStyleSlash,
+	".cpp":        commentStyleSlash,
+}
+
+// commentStyleFor returns the comment style for the file with the given name.
+// The extension of gzip compressed files is ignored so that the style of the
+// file within it is used.
+func commentStyleFor(name string) commentStyle {
+	name = strings.TrimSuffix(strings.ToLower(name), ".gz")
+	return commentStyles[path.Ext(name)]
+}
+
+// commentStripper removes comments from the contents of the underlying reader.
+// This is a best-effort heuristic rather than a parser for each format, so the
+// comment markers are removed even when they appear within a quoted string,
+// such as the "//" in a URL. Line breaks are always kept.
+type commentStripper struct {
+	r     io.Reader
+	style commentStyle
+	buf   []byte
+	out   []byte
+	err   error
+
+	inLine  bool
+	inBlock bool
+	// A single "/" has been read which has not been written yet, since it may be
+	// the start of a comment.
+	slash bool
+	// The last byte read within a block comment was a "*".
+	star bool
+	prev byte
+}
+
+func newCommentStripper(r io.Reader, style commentStyle) io.Reader {
+	if style == commentStyleNone {
+		return r
+	}
+	return &commentStripper{r: r, style: style, buf: make([]byte, 4096), prev: '\n'}
+}
+
+func (c *commentStripper) Read(p []byte) (int, error) {
+	for len(c.out) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		n, err := c.r.Read(c.buf)
+		c.out = c.strip(c.out[:0], c.buf[:n])
+		if err != nil {
+			if c.slash {
+				c.out = append(c.out, '/')
+				c.slash = false
+			}
+			c.err = err
+		}
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+// strip appends the bytes from b that are not part of a comment to dst.
+func (c *commentStripper) strip(dst, b []byte) []byte {
+	for _, ch := range b {
+		if c.inBlock {
+			if c.star && ch == '/' {
+				c.inBlock = false
+			} else if ch == '\n' {
+				dst = append(dst, ch)
+			}
+			c.star = ch == '*'
+			continue
+		}
+		if c.inLine {
+			if ch == '\n' {
+				c.inLine = false
+				c.prev = ch
+				dst = append(dst, ch)
+			}
+			continue
+		}
+
+		switch c.style {
+		case commentStyleSlash:
+			if c.slash {
+				c.slash = false
+				if ch == '/' {
+					c.inLine = true
+					continue
+				}
+				if ch == '*' {
+					c.inBlock = true
+					c.star = false
+					continue
+				}
+				dst = append(dst, '/')
+			}
+			if ch == '/' {
+				c.slash = true
+				continue
+			}
+		case commentStyleHash:
+			if ch == '#' && (c.prev == '\n' || c.prev == ' ' || c.prev == '\t') {
+				c.inLine = true
+				continue
+			}
+		}
+		dst = append(dst, ch)
+		c.prev = ch
+	}
+	return dst
+}
@@ -0,0 +1,43 @@
+package filesystem
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	. "github.com/franela/goblin"
+)
+
+func TestCommentStripper(t *testing.T) {
+	g := Goblin(t)
+
+	strip := func(s string, style commentStyle) string {
+		// Read a single byte at a time to make sure comment markers split across
+		// reads are still recognized.
+		b, err := io.ReadAll(newCommentStripper(iotest.OneByteReader(strings.NewReader(s)), style))
+		g.Assert(err).IsNil()
+		return string(b)
+	}
+
+	g.Describe("commentStripper", func() {
+		g.It("removes hash comments", func() {
+			g.Assert(strip("# header\nkey: value # note\nurl: a#b\n", commentStyleHash)).Equal("\nkey: value \nurl: a#b\n")
+		})
+
+		g.It("removes line and block comments", func() {
+			g.Assert(strip("a // line\nb /* block\nstill */ c\nd / e", commentStyleSlash)).Equal("a \nb \n c\nd / e")
+		})
+
+		g.It("keeps a trailing slash", func() {
+			g.Assert(strip("a/", commentStyleSlash)).Equal("a/")
+		})
+
+		g.It("picks the style from the file extension", func() {
+			g.Assert(commentStyleFor("config.yml")).Equal(commentStyleHash)
+			g.Assert(commentStyleFor("Plugin.JAVA")).Equal(commentStyleSlash)
+			g.Assert(commentStyleFor("config.yml.gz")).Equal(commentStyleHash)
+			g.Assert(commentStyleFor("notes.txt")).Equal(commentStyleNone)
+		})
+	})
+}
@@ -34,21 +34,23 @@ func (fs *Filesystem) CompressFiles(dir string, paths []string) (ufs.FileInfo, e
 		dir,
 		fmt.Sprintf("archive-%s.tar.gz", strings.ReplaceAll(time.Now().Format(time.RFC3339), ":", "")),
 	)
-	f, err := fs.unixFS.OpenFile(d, ufs.O_WRONLY|ufs.O_CREATE, 0o644)
+	t, err := fs.createTempFile(dir, 0o644)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	cw := ufs.NewCountedWriter(f)
+	defer t.cleanup()
+	cw := ufs.NewCountedWriter(t)
 	if err := a.Stream(context.Background(), cw); err != nil {
 		return nil, err
 	}
 	if !fs.unixFS.CanFit(cw.BytesWritten()) {
-		_ = fs.unixFS.Remove(d)
 		return nil, newFilesystemError(ErrCodeDiskSpace, nil)
 	}
+	if err := t.commit(d); err != nil {
+		return nil, err
+	}
 	fs.unixFS.Add(cw.BytesWritten())
-	return f.Stat()
+	return fs.unixFS.Stat(d)
 }
 
 func (fs *Filesystem) archiverFileSystem(ctx context.Context, p string) (iofs.FS, error) {
@@ -134,15 +136,147 @@ func (fs *Filesystem) SpaceAvailableForDecompression(ctx context.Context, dir st
 	})
 }
 
+// ArchiveEntry is a single entry within an archive, as returned by ListArchive.
+type ArchiveEntry struct {
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	Directory bool   `json:"directory"`
+	// LinkTarget is the target of the entry if it is a link.
+	LinkTarget string `json:"link_target,omitempty"`
+	// Suspicious is true if the entry would be written, or links to a location,
+	// outside the directory the archive is being extracted into.
+	Suspicious bool `json:"suspicious"`
+}
+
+// ArchiveListing describes the contents of an archive without extracting it.
+type ArchiveListing struct {
+	Entries []ArchiveEntry `json:"entries"`
+	// TotalSize is the total uncompressed size of every file in the archive.
+	TotalSize int64 `json:"total_size"`
+	// Fits is true if the archive can be extracted without exceeding the disk
+	// space available to the server.
+	Fits bool `json:"fits"`
+}
+
+// ListArchive reads through an archive in the given directory and returns the
+// entries it contains along with their total uncompressed size, without writing
+// anything to the disk. Entries that would escape the directory when extracted
+// are marked as suspicious rather than causing an error, so the caller is able
+// to decide what to do with them.
+func (fs *Filesystem) ListArchive(ctx context.Context, dir string, file string) (*ArchiveListing, error) {
+	f, err := fs.unixFS.Open(filepath.Join(dir, file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	format, input, err := archives.Identify(ctx, filepath.Base(file), f)
+	if err != nil {
+		if errors.Is(err, archives.NoMatch) {
+			return nil, newFilesystemError(ErrCodeUnknownArchive, err)
+		}
+		return nil, err
+	}
+
+	listing := &ArchiveListing{Entries: []ArchiveEntry{}}
+	ex, ok := format.(archives.Extractor)
+	if !ok {
+		de, ok := format.(archives.Decompressor)
+		if !ok {
+			return listing, nil
+		}
+		// Single file compression formats do not store the size of the file, so the
+		// only way to find it is to decompress the whole thing.
+		r, err := de.OpenReader(input)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		n, err := io.Copy(io.Discard, r)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(file, format.Extension())
+		listing.Entries = append(listing.Entries, ArchiveEntry{
+			Name:       name,
+			Size:       n,
+			Suspicious: isSuspiciousArchivePath(name),
+		})
+		listing.TotalSize = n
+		listing.Fits = fs.unixFS.CanFit(n)
+		return listing, nil
+	}
+
+	err = ex.Extract(ctx, input, func(ctx context.Context, f archives.FileInfo) error {
+		entry := ArchiveEntry{
+			Name:       f.NameInArchive,
+			Directory:  f.IsDir(),
+			LinkTarget: f.LinkTarget,
+			Suspicious: isSuspiciousArchivePath(f.NameInArchive),
+		}
+		if !f.IsDir() {
+			entry.Size = f.Size()
+			listing.TotalSize += entry.Size
+		}
+		if f.LinkTarget != "" && (path.IsAbs(f.LinkTarget) || isSuspiciousArchivePath(path.Join(path.Dir(f.NameInArchive), f.LinkTarget))) {
+			entry.Suspicious = true
+		}
+		listing.Entries = append(listing.Entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	listing.Fits = fs.unixFS.CanFit(listing.TotalSize)
+	return listing, nil
+}
+
+// isSuspiciousArchivePath returns true if the given archive entry name would
+// resolve to a location outside the directory it is being extracted into.
+func isSuspiciousArchivePath(name string) bool {
+	name = filepath.ToSlash(name)
+	if path.IsAbs(name) || strings.HasPrefix(name, "\\") {
+		return true
+	}
+	p := path.Clean(name)
+	return p == ".." || strings.HasPrefix(p, "../")
+}
+
 // DecompressFile will decompress a file in a given directory by using the
 // archiver tool to infer the file type and go from there. This will walk over
 // all the files within the given archive and ensure that there is not a
 // zip-slip attack being attempted by validating that the final path is within
 // the server data directory.
 func (fs *Filesystem) DecompressFile(ctx context.Context, dir string, file string) error {
+	_, err := fs.DecompressFileWithOptions(ctx, dir, file, DecompressOptions{})
+	return err
+}
+
+// DecompressOptions controls how an archive is extracted.
+type DecompressOptions struct {
+	// If true, files that already exist with the same size and modification time
+	// as their entry in the archive are left alone rather than extracted again,
+	// so that extracting an archive that previously failed part way through only
+	// writes the files that were not finished. The modification time of a file
+	// is only set once it has been fully written, so a partially written file is
+	// never mistaken for a finished one.
+	Resume bool
+}
+
+// DecompressResult is the number of files in an archive that were written when
+// extracting it, and the number that were skipped because they already existed
+// when resuming.
+type DecompressResult struct {
+	Written int `json:"written"`
+	Skipped int `json:"skipped"`
+}
+
+// DecompressFileWithOptions decompresses a file in the same way as DecompressFile,
+// returning the number of files that were written and skipped.
+func (fs *Filesystem) DecompressFileWithOptions(ctx context.Context, dir string, file string, opts DecompressOptions) (*DecompressResult, error) {
 	f, err := fs.unixFS.Open(filepath.Join(dir, file))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer f.Close()
 
@@ -150,17 +284,22 @@ func (fs *Filesystem) DecompressFile(ctx context.Context, dir string, file strin
 	format, input, err := archives.Identify(ctx, filepath.Base(file), f)
 	if err != nil {
 		if errors.Is(err, archives.NoMatch) {
-			return newFilesystemError(ErrCodeUnknownArchive, err)
+			return nil, newFilesystemError(ErrCodeUnknownArchive, err)
 		}
-		return err
+		return nil, err
 	}
 
-	return fs.extractStream(ctx, extractStreamOptions{
+	out := &DecompressResult{}
+	err = fs.extractStream(ctx, extractStreamOptions{
 		FileName:  file,
 		Directory: dir,
 		Format:    format,
 		Reader:    input,
+		RecordOps: true,
+		Resume:    opts.Resume,
+		Result:    out,
 	})
+	return out, err
 }
 
 // ExtractStreamUnsafe .
@@ -188,6 +327,12 @@ type extractStreamOptions struct {
 	Format archives.Format
 	// Reader for the archive.
 	Reader io.Reader
+	// Whether the extracted files are added to the recent operations log.
+	RecordOps bool
+	// Whether files that were already extracted are skipped, see DecompressOptions.
+	Resume bool
+	// If set, the number of files written and skipped are counted in this.
+	Result *DecompressResult
 }
 
 func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptions) error {
@@ -215,21 +360,23 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 		}
 		defer reader.Close()
 
-		// Open the file for creation/writing
-		f, err := fs.unixFS.OpenFile(p, ufs.O_WRONLY|ufs.O_CREATE, 0o644)
+		// Write to a temporary file so a partially decompressed file is never left
+		// behind if this fails.
+		f, err := fs.createTempFile(filepath.Dir(p), 0o644)
 		if err != nil {
 			return err
 		}
-		defer f.Close()
+		defer f.cleanup()
 
 		// Read in 4 KB chunks
+		var written int64
 		buf := make([]byte, 4096)
 		for {
 			n, err := reader.Read(buf)
 			if n > 0 {
 
 				// Check quota before writing the chunk
-				if quotaErr := fs.HasSpaceFor(int64(n)); quotaErr != nil {
+				if quotaErr := fs.HasSpaceFor(written + int64(n)); quotaErr != nil {
 					return quotaErr
 				}
 
@@ -237,9 +384,7 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 				if _, writeErr := f.Write(buf[:n]); writeErr != nil {
 					return writeErr
 				}
-
-				// Add to quota
-				fs.addDisk(int64(n))
+				written += int64(n)
 			}
 
 			if err != nil {
@@ -252,7 +397,18 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 				return err
 			}
 		}
+		if err := f.commit(p); err != nil {
+			return err
+		}
+		// Add to quota
+		fs.addDisk(written)
 
+		if opts.RecordOps {
+			fs.RecordOp(RecentOpExtract, p)
+		}
+		if opts.Result != nil {
+			opts.Result.Written++
+		}
 		return nil
 	}
 
@@ -266,6 +422,12 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 		if err := fs.IsIgnored(p); err != nil {
 			return nil
 		}
+		if opts.Resume && fs.extracted(p, f) {
+			if opts.Result != nil {
+				opts.Result.Skipped++
+			}
+			return nil
+		}
 		r, err := f.Open()
 		if err != nil {
 			return err
@@ -278,6 +440,23 @@ func (fs *Filesystem) extractStream(ctx context.Context, opts extractStreamOptio
 		if err := fs.Chtimes(p, f.ModTime(), f.ModTime()); err != nil {
 			return wrapError(err, opts.FileName)
 		}
+		if opts.RecordOps {
+			fs.RecordOp(RecentOpExtract, p)
+		}
+		if opts.Result != nil {
+			opts.Result.Written++
+		}
 		return nil
 	})
 }
+
+// extracted returns true if the file at the given path is a regular file with
+// the same size and modification time as the archive entry, meaning it was
+// already fully extracted from the archive.
+func (fs *Filesystem) extracted(p string, f archives.FileInfo) bool {
+	st, err := fs.unixFS.Lstat(p)
+	if err != nil || !st.Mode().IsRegular() {
+		return false
+	}
+	return st.Size() == f.Size() && st.ModTime().Unix() == f.ModTime().Unix()
+}
@@ -2,7 +2,11 @@ package filesystem
 
 import (
 	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	. "github.com/franela/goblin"
@@ -47,6 +51,73 @@ func TestFilesystem_DecompressFile(t *testing.T) {
 			})
 		}
 
+		g.It("resumes a decompression that did not finish", func() {
+			c, err := os.ReadFile("./testdata/test.zip")
+			g.Assert(err).IsNil()
+			g.Assert(rfs.CreateServerFile("./test.zip", c)).IsNil()
+
+			res, err := fs.DecompressFileWithOptions(context.Background(), "/", "test.zip", DecompressOptions{Resume: true})
+			g.Assert(err).IsNil()
+			g.Assert(res.Skipped).Equal(0)
+			total := res.Written
+
+			// One file is missing and another was only partially written, so neither of
+			// them has the modification time from the archive.
+			g.Assert(os.Remove(filepath.Join(rfs.root, "server", "test", "outside.txt"))).IsNil()
+			g.Assert(os.WriteFile(filepath.Join(rfs.root, "server", "test", "inside", "finside.txt"), nil, 0o644)).IsNil()
+
+			res, err = fs.DecompressFileWithOptions(context.Background(), "/", "test.zip", DecompressOptions{Resume: true})
+			g.Assert(err).IsNil()
+			g.Assert(res.Written).Equal(2)
+			g.Assert(res.Skipped).Equal(total - 2)
+			_, err = rfs.StatServerFile("test/outside.txt")
+			g.Assert(err).IsNil()
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
+
+func TestFilesystem_ListArchive(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("ListArchive", func() {
+		for _, ext := range []string{"zip", "tar", "tar.gz"} {
+			g.It("lists the contents of a "+ext+" without extracting it", func() {
+				c, err := os.ReadFile("./testdata/test." + ext)
+				g.Assert(err).IsNil()
+				err = rfs.CreateServerFile("./test."+ext, c)
+				g.Assert(err).IsNil()
+
+				listing, err := fs.ListArchive(context.Background(), "/", "test."+ext)
+				g.Assert(err).IsNil()
+				g.Assert(listing.Fits).IsTrue()
+
+				var names []string
+				for _, e := range listing.Entries {
+					g.Assert(e.Suspicious).IsFalse()
+					if !e.Directory {
+						names = append(names, strings.TrimSuffix(e.Name, "/"))
+					}
+				}
+				g.Assert(slices.Contains(names, "test/outside.txt")).IsTrue()
+				g.Assert(slices.Contains(names, "test/inside/finside.txt")).IsTrue()
+
+				_, err = rfs.StatServerFile("test/outside.txt")
+				g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+			})
+		}
+
+		g.It("flags entries that escape the directory", func() {
+			g.Assert(isSuspiciousArchivePath("../etc/passwd")).IsTrue()
+			g.Assert(isSuspiciousArchivePath("/etc/passwd")).IsTrue()
+			g.Assert(isSuspiciousArchivePath("test/../../x")).IsTrue()
+			g.Assert(isSuspiciousArchivePath("test/../x")).IsFalse()
+		})
+
 		g.AfterEach(func() {
 			_ = fs.TruncateRootDirectory()
 		})
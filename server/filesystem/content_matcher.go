@@ -0,0 +1,328 @@
+package filesystem
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultContentChunkSize is the number of bytes read from a file at a time when
+// searching its contents.
+const defaultContentChunkSize = 8192
+
+// contentMatcher searches a stream of content for any of a set of queries,
+// reading it in fixed size chunks so that the whole file never needs to be held
+// in memory. The end of each chunk is carried over and searched again with the
+// next one, so that a query split across two chunks is still matched.
+//
+// A contentMatcher is not safe for concurrent use, but can be reused for any
+// number of files one after another.
+type contentMatcher struct {
+	// The lowercase queries to match, matching is case-insensitive.
+	queries []string
+	// The number of bytes to read at a time.
+	chunkSize int
+	// The number of bytes from the end of the content searched so far that are
+	// searched again along with the next chunk. To reliably match queries split
+	// across chunks this must be at least one less than the length of the longest
+	// query in bytes, which is what newContentMatcher uses.
+	overlap int
+
+	buf    []byte
+	window []byte
+	// The position of the start of the window within the content, the number of
+	// line breaks before it, and the position of the start of the line that the
+	// window begins partway through.
+	base      int64
+	lines     int64
+	lineStart int64
+	// The index within the window of the last match, its length in bytes, and the
+	// index of the query that was matched.
+	at     int
+	length int
+	query  int
+	// The most bytes of the line before the window that are kept in tail so that
+	// they can be included in a snippet. The window alone only holds the overlap
+	// from before the current chunk.
+	lineContext int
+	tail        []byte
+	// The error that stopped the last call to Match before the end of the content
+	// was reached, if there was one.
+	err error
+}
+
+// newContentMatcher returns a matcher for the given lowercase queries that reads
+// chunkSize bytes at a time. If chunkSize is not greater than zero the default
+// size is used.
+func newContentMatcher(queries []string, chunkSize int) *contentMatcher {
+	if chunkSize <= 0 {
+		chunkSize = defaultContentChunkSize
+	}
+	var overlap int
+	for _, q := range queries {
+		overlap = max(overlap, len(q)-1)
+	}
+	return &contentMatcher{queries: queries, chunkSize: chunkSize, overlap: overlap}
+}
+
+// Match reads from r until one of the queries is found or the end of the content
+// is reached, returning the index of the query that matched and the number of
+// bytes that were read. A leading UTF-8 byte order mark is ignored.
+func (m *contentMatcher) Match(r io.Reader) (int, int64, bool) {
+	if m.buf == nil {
+		m.buf = make([]byte, m.chunkSize)
+	}
+	// The carried over content is always copied into the window rather than
+	// referencing the read buffer, since the buffer is overwritten by each read.
+	m.window = m.window[:0]
+	m.base, m.lines, m.lineStart = 0, 0, 0
+	m.tail = m.tail[:0]
+	m.err = nil
+
+	var read int64
+	for bom := true; ; {
+		n, err := r.Read(m.buf)
+		read += int64(n)
+		if n > 0 {
+			m.window = append(m.window, m.buf[:n]...)
+			if bom {
+				// Files edited on Windows are often saved with a leading byte order mark,
+				// strip it so that it does not become part of the first line of the file.
+				// If the content so far could still be the start of one, keep reading
+				// until it is known either way.
+				if len(m.window) < len(utf8BOM) && bytes.HasPrefix(utf8BOM, m.window) && err == nil {
+					continue
+				}
+				if bytes.HasPrefix(m.window, utf8BOM) {
+					m.window = m.window[len(utf8BOM):]
+					m.base, m.lineStart = int64(len(utf8BOM)), int64(len(utf8BOM))
+				}
+				bom = false
+			}
+			if i, ok := m.match(m.window); ok {
+				return i, read, true
+			}
+			if keep := min(len(m.window), m.overlap); keep < len(m.window) {
+				m.advance(len(m.window) - keep)
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				m.err = err
+			}
+			return 0, read, false
+		}
+	}
+}
+
+// advance drops the first n bytes of the window, keeping track of the position
+// and line of the content that remains.
+func (m *contentMatcher) advance(n int) {
+	dropped := m.window[:n]
+	m.lines += int64(bytes.Count(dropped, []byte{'\n'}))
+	if i := bytes.LastIndexByte(dropped, '\n'); i >= 0 {
+		m.lineStart = m.base + int64(i) + 1
+		m.tail = m.tail[:0]
+		dropped = dropped[i+1:]
+	}
+	if m.lineContext > 0 {
+		m.tail = append(m.tail, dropped[max(0, len(dropped)-m.lineContext):]...)
+		if len(m.tail) > m.lineContext {
+			m.tail = append(m.tail[:0], m.tail[len(m.tail)-m.lineContext:]...)
+		}
+	}
+	m.base += int64(n)
+	m.window = append(m.window[:0], m.window[n:]...)
+}
+
+// match returns the index of the first query contained in the given content,
+// recording where in the content it was found.
+func (m *contentMatcher) match(b []byte) (int, bool) {
+	text := strings.ToLower(string(b))
+	for i, q := range m.queries {
+		// An empty query would match at every position without ever moving forward, so
+		// it is never matched against content.
+		if q == "" {
+			continue
+		}
+		if j := strings.Index(text, q); j >= 0 {
+			m.at = foldedIndex(b, text, j)
+			m.length = foldedIndex(b, text, j+len(q)) - m.at
+			m.query = i
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// foldedIndex converts an index into text, the lowercase form of b, into the
+// matching index into b. Lowercasing changes the length of a few characters, so
+// the two are not always the same.
+func foldedIndex(b []byte, text string, j int) int {
+	if len(text) == len(b) {
+		return j
+	}
+	var i, n int
+	for i < len(b) && n < j {
+		r, w := utf8.DecodeRune(b[i:])
+		n += utf8.RuneLen(unicode.ToLower(r))
+		i += w
+	}
+	return i
+}
+
+// Snippet returns the line containing the last match found by Match, reading
+// the rest of the line from r, which must be the same reader that was matched.
+// At most maxLine bytes of the line are returned, taken from around the match,
+// so that a match within a huge single line file such as minified JavaScript is
+// returned without holding the entire line in memory. The number of bytes read
+// from r is also returned.
+//
+// Only lineContext bytes from before the current chunk are kept, so with a small
+// lineContext the snippet may start closer to the match than it otherwise would.
+func (m *contentMatcher) Snippet(r io.Reader, maxLine int) (*SearchSnippet, int64) {
+	maxLine = max(maxLine, m.length)
+	// The tail never contains a line break, it is only ever the start of the line
+	// that the window begins in.
+	w := append(append(make([]byte, 0, len(m.tail)+len(m.window)), m.tail...), m.window...)
+	base, at := m.base-int64(len(m.tail)), m.at+len(m.tail)
+
+	before := w[:at]
+	out := &SearchSnippet{
+		Line:   m.lines + int64(bytes.Count(before, []byte{'\n'})) + 1,
+		Offset: base + int64(at),
+	}
+	lineStart := m.lineStart
+	start := 0
+	if i := bytes.LastIndexByte(before, '\n'); i >= 0 {
+		lineStart = base + int64(i) + 1
+		start = i + 1
+	}
+	// Keep up to half of the line before the match, the rest is filled with the
+	// content that follows it.
+	start = max(start, at-(maxLine-m.length)/2)
+	if base+int64(start) > lineStart {
+		out.Truncated = true
+	}
+
+	text := w[start:]
+	var read int64
+	for {
+		end := at - start + m.length
+		if i := bytes.IndexByte(text[end:], '\n'); i >= 0 {
+			text = text[:end+i]
+			break
+		}
+		if len(text) >= maxLine {
+			break
+		}
+		n, err := r.Read(m.buf)
+		read += int64(n)
+		text = append(text, m.buf[:n]...)
+		// Keep the window up to date so that Count can carry on from here.
+		m.window = append(m.window, m.buf[:n]...)
+		if err != nil {
+			break
+		}
+	}
+	if len(text) > maxLine {
+		text = text[:maxLine]
+		out.Truncated = true
+	}
+	text = bytes.TrimSuffix(text, []byte{'\r'})
+
+	// Never start or end the snippet partway through a multibyte character.
+	for len(text) > 0 && out.Truncated && !utf8.RuneStart(text[0]) {
+		text = text[1:]
+		start++
+	}
+	// A partial character at the end of a complete line is a stray byte rather
+	// than one that was cut off, so it is kept.
+	if out.Truncated {
+		text = trimPartialRune(text)
+	}
+	out.Text = string(text)
+	out.Raw = text
+	out.TextOffset = base + int64(start)
+	return out, read
+}
+
+// Count carries on reading from r after a match found by Match, or after the
+// snippet for it, returning the number of times that any of the queries appear
+// in the content including the first match, and the number of bytes read. Each
+// query is counted separately and occurrences of the same query never overlap.
+// Reading stops once limit occurrences have been counted.
+func (m *contentMatcher) Count(r io.Reader, limit int) (int, int64) {
+	// The position in the content that each query is next looked for from. Every
+	// query other than the one matched may still be anywhere in the window.
+	from := make([]int64, len(m.queries))
+	for i := range from {
+		from[i] = m.base
+	}
+	from[m.query] = m.base + int64(m.at+m.length)
+
+	count := 1
+	var read int64
+	var err error
+	for {
+		count += m.countWindow(from, limit-count)
+		if count >= limit || err != nil {
+			break
+		}
+		if keep := min(len(m.window), m.overlap); keep < len(m.window) {
+			m.advance(len(m.window) - keep)
+		}
+		var n int
+		n, err = r.Read(m.buf)
+		read += int64(n)
+		m.window = append(m.window, m.buf[:n]...)
+	}
+	if err != nil && err != io.EOF {
+		m.err = err
+	}
+	return min(count, limit), read
+}
+
+// countWindow counts up to limit occurrences of the queries in the window that
+// start at or after the positions in from, which are updated to where each query
+// should next be looked for.
+func (m *contentMatcher) countWindow(from []int64, limit int) int {
+	b := m.window
+	text := strings.ToLower(string(b))
+	var n int
+	for i, q := range m.queries {
+		if q == "" {
+			continue
+		}
+		for n < limit {
+			start := lowerIndex(b, text, int(max(from[i]-m.base, 0)))
+			j := strings.Index(text[start:], q)
+			if j < 0 {
+				break
+			}
+			n++
+			from[i] = m.base + int64(foldedIndex(b, text, start+j+len(q)))
+		}
+		// Any occurrence that starts before the last len(q)-1 bytes of the window has
+		// already been found, the rest may continue into the next chunk.
+		from[i] = max(from[i], m.base+int64(foldedIndex(b, text, max(len(text)-len(q)+1, 0))))
+	}
+	return n
+}
+
+// lowerIndex converts an index into b into the matching index into text, the
+// lowercase form of b. See foldedIndex.
+func lowerIndex(b []byte, text string, i int) int {
+	if len(text) == len(b) {
+		return min(i, len(text))
+	}
+	var j, n int
+	for j < len(b) && j < i {
+		r, w := utf8.DecodeRune(b[j:])
+		n += utf8.RuneLen(unicode.ToLower(r))
+		j += w
+	}
+	return min(n, len(text))
+}
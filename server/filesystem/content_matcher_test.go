@@ -0,0 +1,233 @@
+package filesystem
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf8"
+
+	. "github.com/franela/goblin"
+)
+
+func TestContentMatcher(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("contentMatcher", func() {
+		g.It("matches a query split across every possible chunk boundary", func() {
+			content := "motd=A Minecraft Server"
+			for size := 1; size <= len(content); size++ {
+				m := newContentMatcher([]string{"minecraft"}, size)
+				i, n, ok := m.Match(strings.NewReader(content))
+				g.Assert(ok).IsTrue()
+				g.Assert(i).Equal(0)
+				g.Assert(n > 0).IsTrue()
+			}
+		})
+
+		g.It("matches when reads return fewer bytes than the chunk size", func() {
+			m := newContentMatcher([]string{"server-port"}, 4)
+			_, _, ok := m.Match(iotest.OneByteReader(strings.NewReader("level-name=world\nserver-port=25565\n")))
+			g.Assert(ok).IsTrue()
+		})
+
+		g.It("does not match empty content", func() {
+			m := newContentMatcher([]string{"a"}, 4)
+			_, n, ok := m.Match(strings.NewReader(""))
+			g.Assert(ok).IsFalse()
+			g.Assert(n).Equal(int64(0))
+		})
+
+		g.It("does not match content shorter than the query", func() {
+			m := newContentMatcher([]string{"minecraft"}, 4)
+			_, n, ok := m.Match(strings.NewReader("mine"))
+			g.Assert(ok).IsFalse()
+			g.Assert(n).Equal(int64(4))
+		})
+
+		g.It("matches a query longer than the chunk size", func() {
+			query := strings.Repeat("ab", 20) + "c"
+			m := newContentMatcher([]string{query}, 3)
+			_, _, ok := m.Match(strings.NewReader("xx" + strings.Repeat("ab", 30) + "c"))
+			g.Assert(ok).IsTrue()
+
+			_, _, ok = m.Match(strings.NewReader(strings.Repeat("ab", 30)))
+			g.Assert(ok).IsFalse()
+		})
+
+		g.It("returns the index of the query that matched", func() {
+			m := newContentMatcher([]string{"nether", "end"}, 2)
+			i, _, ok := m.Match(strings.NewReader("allow-end=true"))
+			g.Assert(ok).IsTrue()
+			g.Assert(i).Equal(1)
+		})
+
+		g.It("never matches an empty query", func() {
+			m := newContentMatcher([]string{"", "end"}, 2)
+			i, _, ok := m.Match(strings.NewReader("allow-end=true"))
+			g.Assert(ok).IsTrue()
+			g.Assert(i).Equal(1)
+
+			m = newContentMatcher([]string{""}, 2)
+			_, _, ok = m.Match(strings.NewReader("allow-end=true"))
+			g.Assert(ok).IsFalse()
+		})
+
+		g.It("matches case-insensitively", func() {
+			m := newContentMatcher([]string{"online-mode"}, 5)
+			_, _, ok := m.Match(strings.NewReader("ONLINE-MODE=false"))
+			g.Assert(ok).IsTrue()
+		})
+
+		g.It("ignores a leading byte order mark", func() {
+			for size := 1; size <= 4; size++ {
+				m := newContentMatcher([]string{"\xEF\xBB\xBFkey"}, size)
+				_, _, ok := m.Match(strings.NewReader("\xEF\xBB\xBFkey=value"))
+				g.Assert(ok).IsFalse()
+
+				m = newContentMatcher([]string{"key"}, size)
+				_, _, ok = m.Match(strings.NewReader("\xEF\xBB\xBFkey=value"))
+				g.Assert(ok).IsTrue()
+			}
+		})
+
+		g.It("does not carry content over between calls", func() {
+			m := newContentMatcher([]string{"spawn"}, 4)
+			_, _, ok := m.Match(strings.NewReader("spa"))
+			g.Assert(ok).IsFalse()
+			_, _, ok = m.Match(strings.NewReader("wn"))
+			g.Assert(ok).IsFalse()
+		})
+	})
+
+	g.Describe("contentMatcher.Snippet", func() {
+		content := "level-name=world\r\nserver-port=25565\nmotd=A Minecraft Server\n"
+
+		g.It("returns the line and position of the match at every chunk size", func() {
+			for size := 1; size <= len(content); size++ {
+				m := newContentMatcher([]string{"minecraft"}, size)
+				m.lineContext = 512
+				r := strings.NewReader(content)
+				_, _, ok := m.Match(r)
+				g.Assert(ok).IsTrue()
+
+				snippet, _ := m.Snippet(r, 1024)
+				g.Assert(snippet.Line).Equal(int64(3))
+				g.Assert(snippet.Offset).Equal(int64(strings.Index(content, "Minecraft")))
+				g.Assert(snippet.Text).Equal("motd=A Minecraft Server")
+				g.Assert(snippet.TextOffset).Equal(int64(strings.Index(content, "motd")))
+				g.Assert(snippet.Truncated).IsFalse()
+			}
+		})
+
+		g.It("removes the line ending", func() {
+			m := newContentMatcher([]string{"world"}, 4)
+			m.lineContext = 512
+			r := strings.NewReader(content)
+			_, _, _ = m.Match(r)
+			snippet, _ := m.Snippet(r, 1024)
+			g.Assert(snippet.Line).Equal(int64(1))
+			g.Assert(snippet.Text).Equal("level-name=world")
+		})
+
+		g.It("counts the byte order mark in the position", func() {
+			m := newContentMatcher([]string{"key"}, 2)
+			r := strings.NewReader("\xEF\xBB\xBFkey=value")
+			_, _, _ = m.Match(r)
+			snippet, _ := m.Snippet(r, 1024)
+			g.Assert(snippet.Offset).Equal(int64(3))
+			g.Assert(snippet.Text).Equal("key=value")
+		})
+
+		g.It("truncates a huge line to the part around the match", func() {
+			line := strings.Repeat("a", 100000) + "needle" + strings.Repeat("b", 100000)
+			m := newContentMatcher([]string{"needle"}, 4096)
+			m.lineContext = 32
+			r := strings.NewReader(line + "\nnext line")
+			_, _, ok := m.Match(r)
+			g.Assert(ok).IsTrue()
+
+			snippet, _ := m.Snippet(r, 64)
+			g.Assert(snippet.Truncated).IsTrue()
+			g.Assert(len(snippet.Text) <= 64).IsTrue()
+			g.Assert(snippet.Line).Equal(int64(1))
+			g.Assert(snippet.Offset).Equal(int64(100000))
+			rel := snippet.Offset - snippet.TextOffset
+			g.Assert(snippet.Text[rel : rel+6]).Equal("needle")
+			g.Assert(rel > 16).IsTrue()
+			g.Assert(r.Len() > 90000).IsTrue()
+		})
+
+		g.It("does not split multibyte characters", func() {
+			line := strings.Repeat("é", 100) + "needle" + strings.Repeat("é", 100)
+			m := newContentMatcher([]string{"needle"}, 8)
+			m.lineContext = 16
+			r := strings.NewReader(line)
+			_, _, _ = m.Match(r)
+			snippet, _ := m.Snippet(r, 31)
+			g.Assert(snippet.Truncated).IsTrue()
+			g.Assert(utf8.ValidString(snippet.Text)).IsTrue()
+			g.Assert(strings.Contains(snippet.Text, "needle")).IsTrue()
+			g.Assert(line[snippet.TextOffset:][:len(snippet.Text)]).Equal(snippet.Text)
+		})
+
+		g.It("finds the position of matches after characters that change length when lowercased", func() {
+			// The Kelvin sign is three bytes but lowercases to a single byte "k".
+			m := newContentMatcher([]string{"port"}, 64)
+			r := strings.NewReader("\u212A=1\nport=2")
+			_, _, _ = m.Match(r)
+			snippet, _ := m.Snippet(r, 1024)
+			g.Assert(snippet.Offset).Equal(int64(6))
+			g.Assert(snippet.Text).Equal("port=2")
+		})
+	})
+
+	g.Describe("contentMatcher.Count", func() {
+		g.It("counts every occurrence across every possible chunk boundary", func() {
+			content := "Hello world, hello again. HELLO!\nhellohello"
+			for size := 1; size <= len(content); size++ {
+				m := newContentMatcher([]string{"hello"}, size)
+				r := strings.NewReader(content)
+				_, _, ok := m.Match(r)
+				g.Assert(ok).IsTrue()
+				count, _ := m.Count(r, 100)
+				g.Assert(count).Equal(5)
+			}
+		})
+
+		g.It("counts each query separately without overlapping matches", func() {
+			m := newContentMatcher([]string{"aa", "b"}, 3)
+			r := strings.NewReader("aaaab b aaa")
+			_, _, _ = m.Match(r)
+			count, _ := m.Count(r, 100)
+			g.Assert(count).Equal(5)
+		})
+
+		g.It("does not count an empty query", func() {
+			m := newContentMatcher([]string{"b", ""}, 3)
+			r := strings.NewReader("abcabc")
+			_, _, _ = m.Match(r)
+			count, _ := m.Count(r, 100)
+			g.Assert(count).Equal(2)
+		})
+
+		g.It("stops counting at the limit", func() {
+			m := newContentMatcher([]string{"x"}, 4)
+			r := strings.NewReader(strings.Repeat("x", 1000))
+			_, _, _ = m.Match(r)
+			count, _ := m.Count(r, 10)
+			g.Assert(count).Equal(10)
+			g.Assert(r.Len() > 900).IsTrue()
+		})
+
+		g.It("counts the content read for the snippet", func() {
+			m := newContentMatcher([]string{"port"}, 4)
+			m.lineContext = 8
+			r := strings.NewReader("\u212A port=1 port=2\nport=3")
+			_, _, _ = m.Match(r)
+			snippet, _ := m.Snippet(r, 1024)
+			g.Assert(snippet.Text).Equal("\u212A port=1 port=2")
+			count, _ := m.Count(r, 100)
+			g.Assert(count).Equal(3)
+		})
+	})
+}
@@ -0,0 +1,136 @@
+package filesystem
+
+import (
+	"context"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+// maxDeleteFailures is the number of individual failures that are kept in the
+// results of a recursive delete, any further failures are only counted.
+const maxDeleteFailures = 100
+
+// ErrDeleteRoot is returned when attempting to recursively delete the root of
+// the server directory.
+var ErrDeleteRoot = errors.Sentinel("filesystem: cannot delete the server root directory")
+
+// DeleteOptions controls how a recursive delete is performed.
+type DeleteOptions struct {
+	// The maximum number of files and directories removed each second, if set to 0
+	// there is no limit. This keeps deleting a large directory from saturating the
+	// disk for everything else running on the node.
+	Rate int
+	// Called after each file or directory is removed, or fails to be removed, with
+	// the number of entries that have been processed so far and the total.
+	OnProgress func(done, total int64)
+}
+
+// DeleteFailure is a single file or directory that could not be removed.
+type DeleteFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// DeleteResult is the outcome of a recursive delete.
+type DeleteResult struct {
+	// The number of files and directories that were removed, out of the total that
+	// were found.
+	Removed int64 `json:"removed"`
+	Total   int64 `json:"total"`
+	// The number of entries that could not be removed, and the details of the first
+	// of them.
+	Failed   int64           `json:"failed"`
+	Failures []DeleteFailure `json:"failures"`
+	// Whether the delete was stopped before everything was processed.
+	Canceled bool `json:"canceled"`
+}
+
+// DeleteRecursive removes the given path and everything within it, one entry at
+// a time, so that progress can be reported and the delete can be stopped part
+// way through by canceling the context. Unlike Delete, a file that cannot be
+// removed does not stop the delete, every failure is collected and returned in
+// the results instead. Symlinks are removed, never followed.
+//
+// The returned error is only set if the delete could not be started at all.
+func (fs *Filesystem) DeleteRecursive(ctx context.Context, p string, opts DeleteOptions) (*DeleteResult, error) {
+	if strings.Trim(path.Clean("/"+p), "/") == "" {
+		return nil, errors.WithStack(ErrDeleteRoot)
+	}
+	if _, err := fs.resolve(p); err != nil {
+		return nil, err
+	}
+	if _, err := fs.unixFS.Lstat(p); err != nil {
+		return nil, err
+	}
+
+	out := &DeleteResult{Failures: []DeleteFailure{}}
+	// Directories are collected in the order they are walked, which puts every
+	// directory before its contents, so they are removed in reverse once all of
+	// the files are gone.
+	var files, dirs []string
+	err := fs.unixFS.WalkDir(p, func(p string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			out.fail(p, err)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			dirs = append(dirs, p)
+		} else {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		out.Canceled = true
+		return out, nil
+	}
+	slices.Reverse(dirs)
+	out.Total = int64(len(files) + len(dirs))
+
+	var tick <-chan time.Time
+	if opts.Rate > 0 {
+		t := time.NewTicker(max(time.Second/time.Duration(opts.Rate), time.Microsecond))
+		defer t.Stop()
+		tick = t.C
+	}
+
+	var done int64
+	for _, p := range append(files, dirs...) {
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+			case <-tick:
+			}
+		}
+		if ctx.Err() != nil {
+			out.Canceled = true
+			break
+		}
+		if err := fs.unixFS.Remove(p); err != nil {
+			out.fail(p, err)
+		} else {
+			out.Removed++
+		}
+		done++
+		if opts.OnProgress != nil {
+			opts.OnProgress(done, out.Total)
+		}
+	}
+	return out, nil
+}
+
+func (r *DeleteResult) fail(p string, err error) {
+	r.Failed++
+	if len(r.Failures) < maxDeleteFailures {
+		r.Failures = append(r.Failures, DeleteFailure{Path: p, Error: err.Error()})
+	}
+}
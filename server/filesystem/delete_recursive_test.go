@@ -0,0 +1,91 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_DeleteRecursive(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("DeleteRecursive", func() {
+		g.BeforeEach(func() {
+			_ = fs.CreateDirectory("region", "/world")
+			_ = rfs.CreateServerFileFromString("world/level.dat", "level")
+			_ = rfs.CreateServerFileFromString("world/region/r.0.0.mca", "region")
+			_ = rfs.CreateServerFileFromString("world/region/r.0.1.mca", "region")
+			_ = rfs.CreateServerFileFromString("server.properties", "motd=hello")
+		})
+
+		g.It("removes a directory and everything within it", func() {
+			var calls, last int64
+			res, err := fs.DeleteRecursive(context.Background(), "/world", DeleteOptions{
+				OnProgress: func(done, total int64) {
+					calls++
+					last = done
+					g.Assert(total).Equal(int64(5))
+				},
+			})
+			g.Assert(err).IsNil()
+			g.Assert(res.Removed).Equal(int64(5))
+			g.Assert(res.Total).Equal(int64(5))
+			g.Assert(res.Failed).Equal(int64(0))
+			g.Assert(res.Canceled).IsFalse()
+			g.Assert(calls).Equal(int64(5))
+			g.Assert(last).Equal(int64(5))
+
+			_, err = rfs.StatServerFile("world")
+			g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+			_, err = rfs.StatServerFile("server.properties")
+			g.Assert(err).IsNil()
+		})
+
+		g.It("stops when the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			res, err := fs.DeleteRecursive(ctx, "/world", DeleteOptions{
+				Rate: 1000,
+				OnProgress: func(done, total int64) {
+					if done == 2 {
+						cancel()
+					}
+				},
+			})
+			g.Assert(err).IsNil()
+			g.Assert(res.Canceled).IsTrue()
+			g.Assert(res.Removed).Equal(int64(2))
+
+			_, err = rfs.StatServerFile("world")
+			g.Assert(err).IsNil()
+		})
+
+		g.It("does not follow symlinks", func() {
+			_ = os.Symlink(filepath.Join(rfs.root, "server/server.properties"), filepath.Join(rfs.root, "server/world/link"))
+			res, err := fs.DeleteRecursive(context.Background(), "/world", DeleteOptions{})
+			g.Assert(err).IsNil()
+			g.Assert(res.Removed).Equal(int64(6))
+
+			_, err = rfs.StatServerFile("server.properties")
+			g.Assert(err).IsNil()
+		})
+
+		g.It("refuses to delete the root directory", func() {
+			_, err := fs.DeleteRecursive(context.Background(), "/", DeleteOptions{})
+			g.Assert(errors.Is(err, ErrDeleteRoot)).IsTrue()
+		})
+
+		g.It("returns an error for a path that does not exist", func() {
+			_, err := fs.DeleteRecursive(context.Background(), "/missing", DeleteOptions{})
+			g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
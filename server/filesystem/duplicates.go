@@ -0,0 +1,204 @@
+package filesystem
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"emperror.dev/errors"
+
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+// DuplicateOptions controls which files are considered when looking for
+// duplicate files.
+type DuplicateOptions struct {
+	// The directory to look for duplicates within.
+	Root string
+	// Files smaller than this size are not considered, empty files are never
+	// considered regardless of this value.
+	MinSize int64
+	// Files larger than this size are not considered, if set to 0 there is no
+	// limit.
+	MaxSize int64
+	// The maximum number of files that will be scanned before stopping. If set to
+	// 0 there is no limit.
+	MaxFiles int
+}
+
+// DuplicateCluster is a set of files that all have identical contents.
+type DuplicateCluster struct {
+	Hash  string   `json:"hash"`
+	Size  int64    `json:"size"`
+	Files []string `json:"files"`
+}
+
+// DuplicateResults are the results of looking for duplicate files.
+type DuplicateResults struct {
+	Clusters []DuplicateCluster `json:"clusters"`
+	// Complete is false if the file limit was reached before every file could be
+	// scanned.
+	Complete bool `json:"complete"`
+	// The number of files that were scanned.
+	Files int `json:"files"`
+	// The total size of every file that is a copy of another file, which is the
+	// amount of space that would be reclaimed by removing them.
+	Wasted int64 `json:"wasted"`
+}
+
+// FindDuplicates walks the given root directory and returns clusters of files
+// with identical contents. Files are grouped by size first, and only files that
+// share a size with another file are hashed.
+//
+// Clusters are sorted by the amount of space they waste, largest first.
+func (fs *Filesystem) FindDuplicates(ctx context.Context, opts DuplicateOptions) (*DuplicateResults, error) {
+	root, err := fs.resolve(opts.Root)
+	if err != nil {
+		return nil, err
+	}
+	opts.MinSize = max(opts.MinSize, 1)
+
+	out := &DuplicateResults{Clusters: []DuplicateCluster{}, Complete: true}
+	sizes := make(map[int64][]string)
+	err = fs.unixFS.WalkDir(root, func(p string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if opts.MaxFiles > 0 && out.Files >= opts.MaxFiles {
+			out.Complete = false
+			return io.EOF
+		}
+		info, err := fs.unixFS.Lstat(p)
+		if err != nil {
+			return nil
+		}
+		out.Files++
+		if info.Size() < opts.MinSize || (opts.MaxSize > 0 && info.Size() > opts.MaxSize) {
+			return nil
+		}
+		sizes[info.Size()] = append(sizes[info.Size()], p)
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	type candidate struct {
+		path string
+		size int64
+	}
+	var candidates []candidate
+	for size, paths := range sizes {
+		if len(paths) < 2 {
+			continue
+		}
+		for _, p := range paths {
+			candidates = append(candidates, candidate{path: p, size: size})
+		}
+	}
+
+	workers, release, err := acquireSearchWorkers(ctx, 4)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	hashes := make(map[string]*DuplicateCluster)
+	pending := make(chan candidate)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range pending {
+				sum, err := fs.hashFile(ctx, c.path)
+				if err != nil {
+					continue
+				}
+				// The size is part of the key so that the clusters stay grouped by size
+				// even in the unlikely event of a collision.
+				key := sum + ":" + strconv.FormatInt(c.size, 10)
+				mu.Lock()
+				if cl, ok := hashes[key]; ok {
+					cl.Files = append(cl.Files, c.path)
+				} else {
+					hashes[key] = &DuplicateCluster{Hash: sum, Size: c.size, Files: []string{c.path}}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, c := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
+		pending <- c
+	}
+	close(pending)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	for _, cl := range hashes {
+		if len(cl.Files) < 2 {
+			continue
+		}
+		slices.Sort(cl.Files)
+		out.Wasted += cl.Size * int64(len(cl.Files)-1)
+		out.Clusters = append(out.Clusters, *cl)
+	}
+	slices.SortFunc(out.Clusters, func(a, b DuplicateCluster) int {
+		wa, wb := a.Size*int64(len(a.Files)-1), b.Size*int64(len(b.Files)-1)
+		if wa != wb {
+			return cmp.Compare(wb, wa)
+		}
+		return strings.Compare(a.Files[0], b.Files[0])
+	})
+	return out, nil
+}
+
+// hashFile returns the hex encoded SHA-256 hash of the contents of a file.
+func (fs *Filesystem) hashFile(ctx context.Context, p string) (string, error) {
+	f, err := fs.openFile(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return hashContents(ctx, f)
+}
+
+// hashContents returns the hex encoded SHA-256 hash of everything read from r,
+// stopping early if the context is canceled.
+func hashContents(ctx context.Context, r io.Reader) (string, error) {
+	h := sha256.New()
+	buf := make([]byte, 32*1024)
+	for {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		n, err := r.Read(buf)
+		h.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "filesystem: failed to hash file")
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
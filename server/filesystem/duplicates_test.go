@@ -0,0 +1,44 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_FindDuplicates(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("FindDuplicates", func() {
+		g.BeforeEach(func() {
+			_ = fs.CreateDirectory("world_copy", "/")
+			_ = rfs.CreateServerFileFromString("level.dat", "level data")
+			_ = rfs.CreateServerFileFromString("world_copy/level.dat", "level data")
+			_ = rfs.CreateServerFileFromString("other.dat", "other data")
+			_ = rfs.CreateServerFileFromString("empty.txt", "")
+			_ = rfs.CreateServerFileFromString("empty2.txt", "")
+		})
+
+		g.It("groups files with identical contents", func() {
+			res, err := fs.FindDuplicates(context.Background(), DuplicateOptions{Root: "/"})
+			g.Assert(err).IsNil()
+			g.Assert(res.Complete).IsTrue()
+			g.Assert(len(res.Clusters)).Equal(1)
+			g.Assert(res.Clusters[0].Files).Equal([]string{"/level.dat", "/world_copy/level.dat"})
+			g.Assert(res.Wasted).Equal(int64(len("level data")))
+		})
+
+		g.It("stops once the file limit is reached", func() {
+			res, err := fs.FindDuplicates(context.Background(), DuplicateOptions{Root: "/", MaxFiles: 2})
+			g.Assert(err).IsNil()
+			g.Assert(res.Complete).IsFalse()
+			g.Assert(res.Files).Equal(2)
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
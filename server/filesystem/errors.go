@@ -6,6 +6,7 @@ import (
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
+	"golang.org/x/sys/unix"
 
 	"github.com/kristiangarcia/wings/internal/ufs"
 )
@@ -18,8 +19,15 @@ const (
 	ErrCodeUnknownArchive ErrorCode = "E_UNKNFMT"
 	ErrCodePathResolution ErrorCode = "E_BADPATH"
 	ErrCodeDenylistFile   ErrorCode = "E_DENYLIST"
+	ErrCodeSymlink        ErrorCode = "E_SYMLINK"
 	ErrCodeUnknownError   ErrorCode = "E_UNKNOWN"
 	ErrNotExist           ErrorCode = "E_NOTEXIST"
+	ErrCodeExist          ErrorCode = "E_EXIST"
+	ErrCodePermission     ErrorCode = "E_PERM"
+	ErrCodeTooLarge       ErrorCode = "E_TOOLARGE"
+	ErrCodeNameTooLong    ErrorCode = "E_NAMETOOLONG"
+	ErrCodePathTooDeep    ErrorCode = "E_TOODEEP"
+	ErrCodeNotDirectory   ErrorCode = "E_NOTDIR"
 )
 
 type Error struct {
@@ -64,6 +72,8 @@ func (e *Error) Error() string {
 			r = "<empty>"
 		}
 		return fmt.Sprintf("filesystem: file access prohibited: [%s] is on the denylist", r)
+	case ErrCodeSymlink:
+		return fmt.Sprintf("filesystem: cannot perform action: [%s] is a symlink", e.resolved)
 	case ErrCodePathResolution:
 		r := e.resolved
 		if r == "" {
@@ -72,6 +82,18 @@ func (e *Error) Error() string {
 		return fmt.Sprintf("filesystem: server path [%s] resolves to a location outside the server root: %s", e.path, r)
 	case ErrNotExist:
 		return "filesystem: does not exist"
+	case ErrCodeExist:
+		return "filesystem: already exists"
+	case ErrCodePermission:
+		return "filesystem: permission denied"
+	case ErrCodeTooLarge:
+		return "filesystem: file is too large"
+	case ErrCodeNameTooLong:
+		return "filesystem: file name too long"
+	case ErrCodePathTooDeep:
+		return "filesystem: path is nested too deeply"
+	case ErrCodeNotDirectory:
+		return "filesystem: not a directory"
 	case ErrCodeUnknownError:
 		fallthrough
 	default:
@@ -125,6 +147,44 @@ func IsErrorCode(err error, code ErrorCode) bool {
 	return false
 }
 
+// ErrorCodeOf returns the ErrorCode that best describes the given error, so
+// that callers can handle classes of errors without matching on messages. A
+// filesystem Error returns its own code unless it is an unknown error, in which
+// case, as with any other error, the underlying cause is checked against the
+// common system errors. An empty code is returned if nothing matches.
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	var fserr *Error
+	if errors.As(err, &fserr) && fserr.code != ErrCodeUnknownError {
+		return fserr.code
+	}
+	switch {
+	case errors.Is(err, ufs.ErrNotExist):
+		return ErrNotExist
+	case errors.Is(err, ufs.ErrExist):
+		return ErrCodeExist
+	case errors.Is(err, ufs.ErrPermission):
+		return ErrCodePermission
+	case errors.Is(err, ufs.ErrIsDirectory), errors.Is(err, unix.EISDIR):
+		return ErrCodeIsDirectory
+	case errors.Is(err, ufs.ErrNotDirectory), errors.Is(err, unix.ENOTDIR):
+		return ErrCodeNotDirectory
+	case errors.Is(err, ufs.ErrBadPathResolution):
+		return ErrCodePathResolution
+	case errors.Is(err, ufs.ErrPathTooDeep):
+		return ErrCodePathTooDeep
+	case errors.Is(err, unix.ENOSPC), errors.Is(err, unix.EDQUOT):
+		return ErrCodeDiskSpace
+	case errors.Is(err, unix.ENAMETOOLONG):
+		return ErrCodeNameTooLong
+	case errors.Is(err, ErrArchiveTooLarge):
+		return ErrCodeTooLarge
+	}
+	return ""
+}
+
 // NewBadPathResolution returns a new BadPathResolution error.
 func NewBadPathResolution(path string, resolved string) error {
 	return errors.WithStackDepth(&Error{code: ErrCodePathResolution, path: path, resolved: resolved}, 1)
@@ -2,10 +2,12 @@ package filesystem
 
 import (
 	"io"
+	"os"
 	"testing"
 
 	"emperror.dev/errors"
 	. "github.com/franela/goblin"
+	"golang.org/x/sys/unix"
 )
 
 type stackTracer interface {
@@ -54,4 +56,23 @@ func TestFilesystem_PathResolutionError(t *testing.T) {
 			g.Assert(err.Error()).Equal("filesystem: server path [foo] resolves to a location outside the server root: <empty>")
 		})
 	})
+	g.Describe("ErrorCodeOf", func() {
+		g.It("returns the code of a filesystem error", func() {
+			g.Assert(ErrorCodeOf(newFilesystemError(ErrCodeDiskSpace, nil))).Equal(ErrCodeDiskSpace)
+			g.Assert(ErrorCodeOf(NewBadPathResolution("foo", "bar"))).Equal(ErrCodePathResolution)
+		})
+
+		g.It("classifies the cause of unknown and system errors", func() {
+			g.Assert(ErrorCodeOf(wrapError(&os.PathError{Op: "open", Path: "foo", Err: unix.ENOENT}, "foo"))).Equal(ErrNotExist)
+			g.Assert(ErrorCodeOf(errors.Wrap(&os.PathError{Op: "open", Path: "foo", Err: unix.EACCES}, "failed"))).Equal(ErrCodePermission)
+			g.Assert(ErrorCodeOf(&os.PathError{Op: "write", Path: "foo", Err: unix.EDQUOT})).Equal(ErrCodeDiskSpace)
+			g.Assert(ErrorCodeOf(errors.WithStack(ErrArchiveTooLarge))).Equal(ErrCodeTooLarge)
+		})
+
+		g.It("returns an empty code for other errors", func() {
+			g.Assert(ErrorCodeOf(nil)).Equal(ErrorCode(""))
+			g.Assert(ErrorCodeOf(io.EOF)).Equal(ErrorCode(""))
+			g.Assert(ErrorCodeOf(newFilesystemError(ErrCodeUnknownError, io.EOF))).Equal(ErrorCode(""))
+		})
+	})
 }
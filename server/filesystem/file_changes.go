@@ -0,0 +1,130 @@
+package filesystem
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kristiangarcia/wings/config"
+)
+
+// The types of change reported for files in the server directory.
+const (
+	FileChangeCreate = "create"
+	FileChangeModify = "modify"
+	FileChangeRemove = "remove"
+)
+
+// FileChange is a change to a single file or directory in the server directory,
+// seen by the watcher used to keep the file index up to date.
+type FileChange struct {
+	Path string `json:"path"`
+	Op   string `json:"op"`
+	// The number of events from the watcher that were coalesced into this change.
+	Events int `json:"events"`
+}
+
+// fileChanges collects the changes seen by the watcher so that every change to
+// the same path within the debounce window is reported as a single change,
+// rather than the dozens of events that editors and build tools can cause by
+// saving a single file.
+type fileChanges struct {
+	mu      sync.Mutex
+	fn      func([]FileChange)
+	pending map[string]*FileChange
+	order   []string
+	timer   *time.Timer
+}
+
+// OnFileChange sets the function called with the changes made to files within
+// the server directory. Changes are only seen while the file index is enabled
+// and has been built, since it is the index that watches for them. Changes to
+// the same path are coalesced over the debounce window in the configuration.
+func (fs *Filesystem) OnFileChange(fn func([]FileChange)) {
+	c := &fs.fileChanges
+	c.mu.Lock()
+	c.fn = fn
+	c.mu.Unlock()
+}
+
+// notifyChange records a change to the given path, reporting it once the
+// debounce window has passed.
+func (fs *Filesystem) notifyChange(p, op string) {
+	c := &fs.fileChanges
+	c.mu.Lock()
+	if c.fn == nil {
+		c.mu.Unlock()
+		return
+	}
+	window := time.Duration(config.Get().Filesystem.FileChangeDebounce) * time.Millisecond
+	if window <= 0 {
+		fn := c.fn
+		c.mu.Unlock()
+		fn([]FileChange{{Path: p, Op: op, Events: 1}})
+		return
+	}
+	defer c.mu.Unlock()
+
+	if c.pending == nil {
+		c.pending = make(map[string]*FileChange)
+	}
+	if cur, ok := c.pending[p]; ok {
+		cur.Op = coalesceFileChange(cur.Op, op)
+		cur.Events++
+	} else {
+		c.pending[p] = &FileChange{Path: p, Op: op, Events: 1}
+		c.order = append(c.order, p)
+	}
+	if c.timer == nil {
+		c.timer = time.AfterFunc(window, c.flush)
+	}
+}
+
+// flush reports every pending change, in the order that the paths were first
+// changed.
+func (c *fileChanges) flush() {
+	c.mu.Lock()
+	out := make([]FileChange, 0, len(c.order))
+	for _, p := range c.order {
+		// A file that was created and removed again within the window was never
+		// there as far as anyone watching is concerned.
+		if ch := c.pending[p]; ch.Op != "" {
+			out = append(out, *ch)
+		}
+	}
+	c.pending = nil
+	c.order = nil
+	c.timer = nil
+	fn := c.fn
+	c.mu.Unlock()
+
+	if fn != nil && len(out) > 0 {
+		fn(out)
+	}
+}
+
+// stop discards any pending changes without reporting them.
+func (c *fileChanges) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.pending = nil
+	c.order = nil
+	c.timer = nil
+}
+
+// coalesceFileChange returns the single change that a change to a path followed
+// by another change to it amounts to, or an empty string if there is no change.
+func coalesceFileChange(prev, next string) string {
+	switch {
+	case prev == FileChangeCreate && next == FileChangeModify:
+		return FileChangeModify
+	case prev == FileChangeCreate && next == FileChangeRemove:
+		return ""
+	case prev == FileChangeRemove && next == FileChangeCreate:
+		// Editors commonly save by replacing the file with a new one.
+		return FileChangeModify
+	}
+	return next
+}
@@ -0,0 +1,68 @@
+package filesystem
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+
+	"github.com/kristiangarcia/wings/config"
+)
+
+func TestFilesystem_FileChanges(t *testing.T) {
+	g := Goblin(t)
+	fs, _ := NewFs()
+
+	g.Describe("File changes", func() {
+		var changes chan []FileChange
+
+		g.BeforeEach(func() {
+			changes = make(chan []FileChange, 10)
+			fs.OnFileChange(func(c []FileChange) { changes <- c })
+			config.Update(func(c *config.Configuration) {
+				c.Filesystem.FileChangeDebounce = 20
+			})
+		})
+
+		g.AfterEach(func() {
+			fs.OnFileChange(nil)
+			fs.fileChanges.stop()
+			config.Update(func(c *config.Configuration) {
+				c.Filesystem.FileChangeDebounce = 0
+			})
+		})
+
+		g.It("coalesces changes to the same path", func() {
+			fs.notifyChange("config.yml", FileChangeCreate)
+			fs.notifyChange("config.yml", FileChangeModify)
+			fs.notifyChange("config.yml", FileChangeModify)
+			fs.notifyChange("server.log", FileChangeModify)
+			fs.notifyChange("tmp.swp", FileChangeCreate)
+			fs.notifyChange("tmp.swp", FileChangeRemove)
+			fs.notifyChange("saved.txt", FileChangeRemove)
+			fs.notifyChange("saved.txt", FileChangeCreate)
+
+			select {
+			case c := <-changes:
+				g.Assert(c).Equal([]FileChange{
+					{Path: "config.yml", Op: FileChangeModify, Events: 3},
+					{Path: "server.log", Op: FileChangeModify, Events: 1},
+					{Path: "saved.txt", Op: FileChangeModify, Events: 2},
+				})
+			case <-time.After(time.Second):
+				g.Fail("changes were not reported")
+			}
+		})
+
+		g.It("reports changes immediately without a debounce window", func() {
+			config.Update(func(c *config.Configuration) {
+				c.Filesystem.FileChangeDebounce = 0
+			})
+			fs.notifyChange("config.yml", FileChangeCreate)
+			fs.notifyChange("config.yml", FileChangeModify)
+
+			g.Assert(<-changes).Equal([]FileChange{{Path: "config.yml", Op: FileChangeCreate, Events: 1}})
+			g.Assert(<-changes).Equal([]FileChange{{Path: "config.yml", Op: FileChangeModify, Events: 1}})
+		})
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
 	"strconv"
@@ -29,28 +30,52 @@ type Filesystem struct {
 	lookupInProgress  atomic.Bool
 	diskCheckInterval time.Duration
 	denylist          *ignore.GitIgnore
+	symlinks          string
+
+	indexOnce sync.Once
+	fileIndex *fileIndex
+
+	recentOps   recentOps
+	fileLocks   fileLocks
+	fileChanges fileChanges
+	thumbnails  thumbnailCache
+	treeHashes  treeHashCache
 
 	isTest bool
 }
 
-// New creates a new Filesystem instance for a given server.
+// New creates a new Filesystem instance for a given server. Any temporary files
+// left behind by writes that never finished are removed.
 func New(root string, size int64, denylist []string) (*Filesystem, error) {
 	if err := os.MkdirAll(root, 0o755); err != nil {
 		return nil, err
 	}
-	unixFS, err := ufs.NewUnixFS(root, config.UseOpenat2())
+	quota, err := newQuotaFS(root, size)
 	if err != nil {
 		return nil, err
 	}
-	quota := ufs.NewQuota(unixFS, size)
 
-	return &Filesystem{
+	fs := &Filesystem{
 		unixFS: quota,
 
 		diskCheckInterval: time.Duration(config.Get().System.DiskCheckInterval),
 		lastLookupTime:    &usageLookupTime{},
 		denylist:          ignore.CompileIgnoreLines(denylist...),
-	}, nil
+		symlinks:          symlinkPolicy(),
+	}
+	fs.removeTempFiles()
+	return fs, nil
+}
+
+// newQuotaFS opens the given server directory with the configured limits
+// applied, tracking disk usage against the given size.
+func newQuotaFS(root string, size int64) (*ufs.Quota, error) {
+	unixFS, err := ufs.NewUnixFS(root, config.UseOpenat2())
+	if err != nil {
+		return nil, err
+	}
+	unixFS.SetMaxDepth(config.Get().Filesystem.MaxPathDepth)
+	return ufs.NewQuota(unixFS, size), nil
 }
 
 // Path returns the root path for the Filesystem instance.
@@ -73,6 +98,10 @@ func (fs *Filesystem) ReadDirStat(path string) ([]ufs.FileInfo, error) {
 
 // File returns a reader for a file instance as well as the stat information.
 func (fs *Filesystem) File(p string) (ufs.File, Stat, error) {
+	p, err := fs.resolve(p)
+	if err != nil {
+		return nil, Stat{}, err
+	}
 	f, err := fs.unixFS.Open(p)
 	if err != nil {
 		return nil, Stat{}, err
@@ -137,53 +166,133 @@ func (fs *Filesystem) Writefile(p string, r io.Reader) error {
 	return err
 }
 
+// Write writes the contents of r, up to newSize bytes, to the file at the given
+// path, creating it and any missing parent directories if it does not exist.
+// The contents are written to a temporary file that is then renamed over the
+// file, so if writing fails or is canceled part way through the existing file
+// is left untouched and nothing is left behind. An existing file keeps its
+// mode, otherwise the new file is created with the given mode. Writing to a
+// symlink writes to the file it points to, as long as that is within the server
+// directory.
 func (fs *Filesystem) Write(p string, r io.Reader, newSize int64, mode ufs.FileMode) error {
+	w, err := fs.prepareWrite(p, r, newSize, mode)
+	if err != nil {
+		return err
+	}
+	defer w.cleanup()
+	return w.commit()
+}
+
+// FileWrite is a single file written by WriteAll.
+type FileWrite struct {
+	Path     string
+	Contents io.Reader
+	Size     int64
+	Mode     ufs.FileMode
+}
+
+// WriteAll writes every one of the given files in the same way as Write, except
+// that the contents of every file are written to temporary files before any of
+// them are moved into place. If writing any of the files fails none of them are
+// changed.
+func (fs *Filesystem) WriteAll(files []FileWrite) error {
+	writes := make([]*pendingWrite, 0, len(files))
+	defer func() {
+		for _, w := range writes {
+			w.cleanup()
+		}
+	}()
+	for _, f := range files {
+		w, err := fs.prepareWrite(f.Path, f.Contents, f.Size, f.Mode)
+		if err != nil {
+			return err
+		}
+		writes = append(writes, w)
+	}
+	// Each file was checked to fit on its own, but they must also fit together.
+	var size int64
+	for _, w := range writes {
+		size += w.size - w.current
+	}
+	if err := fs.HasSpaceFor(size); err != nil {
+		return err
+	}
+	for _, w := range writes {
+		if err := w.commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pendingWrite is the new contents of a file that have been written to a
+// temporary file, waiting to be moved into place.
+type pendingWrite struct {
+	*tempFile
+	path    string
+	size    int64
+	current int64
+}
+
+// prepareWrite writes the contents of r, up to newSize bytes, to a temporary
+// file next to the file at the given path. Nothing is changed until commit is
+// called on the returned write, and cleanup must always be called once it is
+// no longer needed.
+func (fs *Filesystem) prepareWrite(p string, r io.Reader, newSize int64, mode ufs.FileMode) (*pendingWrite, error) {
 	var currentSize int64
-	st, err := fs.unixFS.Stat(p)
+	st, err := fs.unixFS.Lstat(p)
+	if err == nil && st.Mode()&ufs.ModeSymlink != 0 {
+		// Renaming over a symlink would replace the link rather than writing to
+		// the file it points to, so the new contents are written next to the file
+		// it points to instead. The link is only followed if the symlink policy
+		// allows it, and only if it stays within the server directory.
+		if p, err = fs.resolve(p); err != nil {
+			return nil, err
+		}
+		st, err = fs.unixFS.Lstat(p)
+	}
 	if err != nil && !errors.Is(err, ufs.ErrNotExist) {
-		return errors.Wrap(err, "server/filesystem: writefile: failed to stat file")
+		return nil, errors.Wrap(err, "server/filesystem: writefile: failed to stat file")
 	} else if err == nil {
 		if st.IsDir() {
 			// TODO: resolved
-			return errors.WithStack(&Error{code: ErrCodeIsDirectory, resolved: ""})
+			return nil, errors.WithStack(&Error{code: ErrCodeIsDirectory, resolved: ""})
 		}
 		currentSize = st.Size()
+		mode = st.Mode().Perm()
 	}
 
 	// Check that the new size we're writing to the disk can fit. If there is currently
 	// a file we'll subtract that current file size from the size of the buffer to determine
 	// the amount of new data we're writing (or amount we're removing if smaller).
 	if err := fs.HasSpaceFor(newSize - currentSize); err != nil {
-		return err
+		return nil, err
 	}
 
-	// Touch the file and return the handle to it at this point. This will
-	// create or truncate the file, and create any necessary parent directories
-	// if they are missing.
-	file, err := fs.unixFS.Touch(p, ufs.O_RDWR|ufs.O_TRUNC, mode)
+	t, err := fs.createTempFile(path.Dir(path.Clean("/"+p)), mode)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer file.Close()
-
-	if newSize == 0 {
-		// Subtract the previous size of the file if the new size is 0.
-		fs.unixFS.Add(-currentSize)
-	} else {
+	w := &pendingWrite{tempFile: t, path: p, current: currentSize}
+	if newSize > 0 {
 		// Do not use CopyBuffer here, it is wasteful as the file implements
 		// io.ReaderFrom, which causes it to not use the buffer anyways.
-		var n int64
-		n, err = io.Copy(file, io.LimitReader(r, newSize))
-
-		// Adjust the disk usage to account for the old size and the new size of the file.
-		fs.unixFS.Add(n - currentSize)
+		if w.size, err = io.Copy(t, io.LimitReader(r, newSize)); err != nil {
+			t.cleanup()
+			return nil, err
+		}
 	}
+	return w, nil
+}
 
-	if err := fs.chownFile(p); err != nil {
+// commit moves the new contents into place over the file.
+func (w *pendingWrite) commit() error {
+	if err := w.tempFile.commit(w.path); err != nil {
 		return err
 	}
-	// Return any remaining error.
-	return err
+	// Adjust the disk usage to account for the old size and the new size of the file.
+	w.fs.unixFS.Add(w.size - w.current)
+	return nil
 }
 
 // CreateDirectory creates a new directory (name) at a specified path (p) for
@@ -196,6 +305,79 @@ func (fs *Filesystem) Rename(oldpath, newpath string) error {
 	return fs.unixFS.Rename(oldpath, newpath)
 }
 
+// The ways that a rename can be handled when something already exists at the
+// destination path.
+const (
+	// RenameConflictError returns an error without renaming anything.
+	RenameConflictError = "error"
+	// RenameConflictOverwrite replaces the existing file. Directories are never
+	// replaced.
+	RenameConflictOverwrite = "overwrite"
+	// RenameConflictRename picks a new destination by adding a " (1)" style suffix
+	// to the name.
+	RenameConflictRename = "rename"
+)
+
+// RenameWithConflict renames a file in the same way as Rename, but resolves an
+// existing file at the destination using the given conflict strategy. The path
+// that the file was finally moved to is returned.
+func (fs *Filesystem) RenameWithConflict(oldpath, newpath, conflict string) (string, error) {
+	switch conflict {
+	case "", RenameConflictError:
+		return newpath, fs.Rename(oldpath, newpath)
+	case RenameConflictOverwrite:
+		if path.Clean(oldpath) == path.Clean(newpath) {
+			return newpath, nil
+		}
+		// The source is checked before anything else so that a missing source never
+		// costs the file at the destination.
+		src, err := fs.unixFS.Lstat(oldpath)
+		if err != nil {
+			return "", err
+		}
+		st, err := fs.unixFS.Lstat(newpath)
+		if errors.Is(err, ufs.ErrNotExist) {
+			return newpath, fs.Rename(oldpath, newpath)
+		}
+		if err != nil {
+			return "", err
+		}
+		if st.IsDir() || src.IsDir() {
+			return "", &ufs.PathError{Op: "rename", Path: newpath, Err: ufs.ErrExist}
+		}
+		// Renaming over the existing file replaces it in a single step, so if the
+		// rename fails it is left as it was.
+		if err := fs.replaceFile(oldpath, newpath); err != nil {
+			return "", err
+		}
+		fs.unixFS.Add(-st.Size())
+		return newpath, nil
+	case RenameConflictRename:
+		st, err := fs.unixFS.Lstat(oldpath)
+		if err != nil {
+			return "", err
+		}
+		dir, base := path.Split(newpath)
+		var extension string
+		if !st.IsDir() {
+			extension = filepath.Ext(base)
+		}
+		name := strings.TrimSuffix(base, extension)
+
+		p := newpath
+		for i := 1; i <= 50; i++ {
+			err := fs.Rename(oldpath, p)
+			if !errors.Is(err, ufs.ErrExist) {
+				return p, err
+			}
+			p = path.Join(dir, name+" ("+strconv.Itoa(i)+")"+extension)
+		}
+		return "", &ufs.PathError{Op: "rename", Path: newpath, Err: ufs.ErrExist}
+	default:
+		return "", errors.New("filesystem: unknown rename conflict strategy: " + conflict)
+	}
+}
+
 func (fs *Filesystem) Symlink(oldpath, newpath string) error {
 	return fs.unixFS.Symlink(oldpath, newpath)
 }
@@ -305,7 +487,7 @@ func (fs *Filesystem) Copy(p string) error {
 	if err != nil {
 		return err
 	}
-	source, err := fs.unixFS.OpenFileat(dirfd, name, ufs.O_RDONLY, 0)
+	source, err := fs.openFileat(dirfd, name, ufs.O_RDONLY, 0)
 	if err != nil {
 		return err
 	}
@@ -342,10 +524,11 @@ func (fs *Filesystem) Copy(p string) error {
 	if err != nil {
 		return err
 	}
-	dst, err := fs.unixFS.OpenFileat(dirfd, newName, ufs.O_WRONLY|ufs.O_CREATE, info.Mode())
+	dst, err := fs.openFileat(dirfd, newName, ufs.O_WRONLY|ufs.O_CREATE, info.Mode())
 	if err != nil {
 		return err
 	}
+	defer dst.Close()
 
 	// Do not use CopyBuffer here, it is wasteful as the file implements
 	// io.ReaderFrom, which causes it to not use the buffer anyways.
@@ -371,20 +554,30 @@ func (fs *Filesystem) TruncateRootDirectory() error {
 		return err
 	}
 	_ = fs.unixFS.Close()
-	unixFS, err := ufs.NewUnixFS(fs.Path(), config.UseOpenat2())
-	if err != nil {
-		return err
-	}
 	var limit int64
 	if fs.isTest {
 		limit = 0
 	} else {
 		limit = fs.unixFS.Limit()
 	}
-	fs.unixFS = ufs.NewQuota(unixFS, limit)
+	quota, err := newQuotaFS(fs.Path(), limit)
+	if err != nil {
+		return err
+	}
+	fs.unixFS = quota
 	return nil
 }
 
+// IsEmpty returns true if the server's data directory does not contain any
+// files or directories.
+func (fs *Filesystem) IsEmpty() (bool, error) {
+	entries, err := fs.unixFS.ReadDir(".")
+	if err != nil {
+		return false, err
+	}
+	return len(entries) == 0, nil
+}
+
 // Delete removes a file or folder from the system. Prevents the user from
 // accidentally (or maliciously) removing their root server data directory.
 func (fs *Filesystem) Delete(p string) error {
@@ -433,9 +626,10 @@ func (fs *Filesystem) ListDirectory(p string) ([]Stat, error) {
 		if e.Type().IsDir() {
 			d = "inode/directory"
 		} else {
-			d = "application/octet-stream"
+			d = genericMimetype
 		}
 		var m *mimetype.MIME
+		var bt time.Time
 		if e.Type().IsRegular() {
 			// TODO: I should probably find a better way to do this.
 			eO := e.(interface {
@@ -449,12 +643,13 @@ func (fs *Filesystem) ListDirectory(p string) ([]Stat, error) {
 			if err != nil {
 				log.Error(err.Error())
 			}
+			bt = birthtime(f.Fd())
 			_ = f.Close()
 		}
 
-		st := Stat{FileInfo: info, Mimetype: d}
+		st := Stat{FileInfo: info, Mimetype: d, birthtime: bt}
 		if m != nil {
-			st.Mimetype = m.String()
+			st.Mimetype = mimetypeFor(e.Name(), m)
 		}
 		return st, nil
 	})
@@ -495,3 +690,20 @@ func (fs *Filesystem) Chtimes(path string, atime, mtime time.Time) error {
 	}
 	return fs.unixFS.Chtimes(path, atime, mtime)
 }
+
+// Utimes sets the access and modification times of the file or directory at the
+// given path to the ones provided, returning its updated stat. A zero time
+// leaves that time unchanged. Unlike Chtimes this is used when a user sets the
+// times explicitly, so it is always applied.
+func (fs *Filesystem) Utimes(p string, atime, mtime time.Time) (Stat, error) {
+	p, err := fs.resolve(p)
+	if err != nil {
+		return Stat{}, err
+	}
+	// The path has already been resolved according to the symlink policy, so a
+	// symlink left in it must not be followed.
+	if err := fs.unixFS.Lchtimes(p, atime, mtime); err != nil {
+		return Stat{}, err
+	}
+	return fs.Stat(p)
+}
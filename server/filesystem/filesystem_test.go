@@ -4,10 +4,13 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
+	"io"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"testing"
+	"testing/iotest"
+	"time"
 	"unicode/utf8"
 
 	. "github.com/franela/goblin"
@@ -113,7 +116,7 @@ func TestFilesystem_Openfile(t *testing.T) {
 
 func TestFilesystem_Writefile(t *testing.T) {
 	g := Goblin(t)
-	fs, _ := NewFs()
+	fs, rfs := NewFs()
 
 	g.Describe("Open and WriteFile", func() {
 		buf := &bytes.Buffer{}
@@ -196,6 +199,88 @@ func TestFilesystem_Writefile(t *testing.T) {
 			g.Assert(getFileContent(f)).Equal("new data")
 		})
 
+		g.It("leaves the existing file untouched when writing fails", func() {
+			r := bytes.NewReader([]byte("original data"))
+			err := fs.Write("test.txt", r, r.Size(), 0o644)
+			g.Assert(err).IsNil()
+			usage := fs.CachedUsage()
+
+			err = fs.Write("test.txt", io.MultiReader(bytes.NewReader([]byte("new")), iotest.ErrReader(errors.New("read failed"))), 8, 0o644)
+			g.Assert(err).IsNotNil()
+
+			f, _, err := fs.File("test.txt")
+			g.Assert(err).IsNil()
+			defer f.Close()
+			g.Assert(getFileContent(f)).Equal("original data")
+			g.Assert(fs.CachedUsage()).Equal(usage)
+
+			entries, err := os.ReadDir(filepath.Join(rfs.root, "server"))
+			g.Assert(err).IsNil()
+			g.Assert(len(entries)).Equal(1)
+		})
+
+		g.It("writes to the target of a symlink", func() {
+			fs.symlinks = SymlinkPolicyFollow
+			defer func() { fs.symlinks = SymlinkPolicyReject }()
+			r := bytes.NewReader([]byte("original data"))
+			g.Assert(fs.Write("target.txt", r, r.Size(), 0o644)).IsNil()
+			g.Assert(os.Symlink("target.txt", filepath.Join(rfs.root, "server/link.txt"))).IsNil()
+			usage := fs.CachedUsage()
+
+			r = bytes.NewReader([]byte("new"))
+			g.Assert(fs.Write("link.txt", r, r.Size(), 0o644)).IsNil()
+
+			st, err := os.Lstat(filepath.Join(rfs.root, "server/link.txt"))
+			g.Assert(err).IsNil()
+			g.Assert(st.Mode()&os.ModeSymlink != 0).IsTrue()
+			b, err := os.ReadFile(filepath.Join(rfs.root, "server/target.txt"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal("new")
+			g.Assert(fs.CachedUsage()).Equal(usage - int64(len("original data")-len("new")))
+		})
+
+		g.It("cannot write through a symlink if the symlink policy rejects them", func() {
+			r := bytes.NewReader([]byte("original data"))
+			g.Assert(fs.Write("target.txt", r, r.Size(), 0o644)).IsNil()
+			g.Assert(os.Symlink("target.txt", filepath.Join(rfs.root, "server/link.txt"))).IsNil()
+
+			r = bytes.NewReader([]byte("new"))
+			err := fs.Write("link.txt", r, r.Size(), 0o644)
+			g.Assert(IsErrorCode(err, ErrCodeSymlink)).IsTrue()
+			b, err := os.ReadFile(filepath.Join(rfs.root, "server/target.txt"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal("original data")
+		})
+
+		g.It("cannot write through a symlink to outside the root directory", func() {
+			fs.symlinks = SymlinkPolicyFollow
+			defer func() { fs.symlinks = SymlinkPolicyReject }()
+			g.Assert(os.Symlink(filepath.Join(rfs.root, "outside.txt"), filepath.Join(rfs.root, "server/link.txt"))).IsNil()
+
+			r := bytes.NewReader([]byte("new"))
+			err := fs.Write("link.txt", r, r.Size(), 0o644)
+			g.Assert(IsErrorCode(err, ErrCodePathResolution)).IsTrue()
+			_, err = os.Lstat(filepath.Join(rfs.root, "outside.txt"))
+			g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+		})
+
+		g.It("removes leftover temporary files when created", func() {
+			g.Assert(fs.CreateDirectory("plugins", "/")).IsNil()
+			g.Assert(rfs.CreateServerFileFromString(tempFilePrefix+"abc", "partial")).IsNil()
+			g.Assert(rfs.CreateServerFileFromString("plugins/"+tempFilePrefix+"def", "partial")).IsNil()
+			g.Assert(rfs.CreateServerFileFromString("plugins/config.yml", "greeting: hello")).IsNil()
+
+			_, err := New(filepath.Join(rfs.root, "server"), 0, []string{})
+			g.Assert(err).IsNil()
+
+			for _, p := range []string{tempFilePrefix + "abc", "plugins/" + tempFilePrefix + "def"} {
+				_, err := os.Lstat(filepath.Join(rfs.root, "server", p))
+				g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue(p)
+			}
+			_, err = os.Lstat(filepath.Join(rfs.root, "server/plugins/config.yml"))
+			g.Assert(err).IsNil()
+		})
+
 		g.AfterEach(func() {
 			buf.Truncate(0)
 			_ = fs.TruncateRootDirectory()
@@ -240,6 +325,19 @@ func TestFilesystem_CreateDirectory(t *testing.T) {
 			g.Assert(fs.CachedUsage()).Equal(int64(0))
 		})
 
+		g.It("keeps the path depth limit after the root directory is truncated", func() {
+			config.Update(func(c *config.Configuration) {
+				c.Filesystem.MaxPathDepth = 2
+			})
+			defer config.Update(func(c *config.Configuration) {
+				c.Filesystem.MaxPathDepth = 0
+			})
+
+			g.Assert(fs.TruncateRootDirectory()).IsNil()
+			err := fs.CreateDirectory("c/d", "a/b")
+			g.Assert(errors.Is(err, ufs.ErrPathTooDeep)).IsTrue("err is not ErrPathTooDeep")
+		})
+
 		g.AfterEach(func() {
 			_ = fs.TruncateRootDirectory()
 		})
@@ -337,6 +435,51 @@ func TestFilesystem_Rename(t *testing.T) {
 			g.Assert(st.Name()).Equal("target.txt")
 		})
 
+		g.It("overwrites an existing file when requested", func() {
+			err := rfs.CreateServerFileFromString("target.txt", "taget content")
+			g.Assert(err).IsNil()
+
+			p, err := fs.RenameWithConflict("source.txt", "target.txt", RenameConflictOverwrite)
+			g.Assert(err).IsNil()
+			g.Assert(p).Equal("target.txt")
+
+			b, err := os.ReadFile(filepath.Join(rfs.root, "/server/target.txt"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal("text content")
+		})
+
+		g.It("keeps the existing file when overwriting with a missing source", func() {
+			err := rfs.CreateServerFileFromString("target.txt", "taget content")
+			g.Assert(err).IsNil()
+
+			_, err = fs.RenameWithConflict("missing.txt", "target.txt", RenameConflictOverwrite)
+			g.Assert(errors.Is(err, ufs.ErrNotExist)).IsTrue("err is not ErrNotExist")
+
+			b, err := os.ReadFile(filepath.Join(rfs.root, "/server/target.txt"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal("taget content")
+		})
+
+		g.It("does not overwrite an existing directory", func() {
+			err := os.Mkdir(filepath.Join(rfs.root, "/server/target.txt"), 0o755)
+			g.Assert(err).IsNil()
+
+			_, err = fs.RenameWithConflict("source.txt", "target.txt", RenameConflictOverwrite)
+			g.Assert(errors.Is(err, ufs.ErrExist)).IsTrue("err is not ErrExist")
+		})
+
+		g.It("picks a new name for the file when requested", func() {
+			_ = rfs.CreateServerFileFromString("target.txt", "taget content")
+			_ = rfs.CreateServerFileFromString("target (1).txt", "taget content")
+
+			p, err := fs.RenameWithConflict("source.txt", "target.txt", RenameConflictRename)
+			g.Assert(err).IsNil()
+			g.Assert(p).Equal("target (2).txt")
+
+			_, err = rfs.StatServerFile("target (2).txt")
+			g.Assert(err).IsNil()
+		})
+
 		g.AfterEach(func() {
 			_ = fs.TruncateRootDirectory()
 		})
@@ -454,6 +597,32 @@ func TestFilesystem_Copy(t *testing.T) {
 	})
 }
 
+func TestFilesystem_IsEmpty(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("IsEmpty", func() {
+		g.It("returns true for an empty directory", func() {
+			empty, err := fs.IsEmpty()
+			g.Assert(err).IsNil()
+			g.Assert(empty).IsTrue()
+		})
+
+		g.It("returns false when a file exists", func() {
+			err := rfs.CreateServerFileFromString("test.txt", "test content")
+			g.Assert(err).IsNil()
+
+			empty, err := fs.IsEmpty()
+			g.Assert(err).IsNil()
+			g.Assert(empty).IsFalse()
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
+
 func TestFilesystem_Delete(t *testing.T) {
 	g := Goblin(t)
 	fs, rfs := NewFs()
@@ -608,3 +777,71 @@ func TestFilesystem_Delete(t *testing.T) {
 		})
 	})
 }
+
+func TestFilesystem_Utimes(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("Utimes", func() {
+		g.BeforeEach(func() {
+			_ = rfs.CreateServerFileFromString("server.properties", "motd=hello")
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("sets the modification time and returns the updated stat", func() {
+			mtime := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+			st, err := fs.Utimes("server.properties", time.Time{}, mtime)
+			g.Assert(err).IsNil()
+			g.Assert(st.ModTime().Equal(mtime)).IsTrue()
+
+			info, err := os.Stat(filepath.Join(rfs.root, "server/server.properties"))
+			g.Assert(err).IsNil()
+			g.Assert(info.ModTime().Equal(mtime)).IsTrue()
+		})
+
+		g.It("returns an error for a file that does not exist", func() {
+			_, err := fs.Utimes("missing.txt", time.Now(), time.Now())
+			g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+		})
+	})
+}
+
+func TestFilesystem_FileType(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("FileType", func() {
+		g.BeforeEach(func() {
+			_ = fs.CreateDirectory("config", "/")
+			_ = rfs.CreateServerFileFromString("server.properties", "motd=hello")
+			_ = os.Symlink("server.properties", filepath.Join(rfs.root, "server/link"))
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("returns the type of each file without following symlinks", func() {
+			for p, want := range map[string]string{"server.properties": "file", "config": "directory", "link": "symlink"} {
+				got, err := fs.FileType(p)
+				g.Assert(err).IsNil()
+				g.Assert(got).Equal(want)
+			}
+		})
+
+		g.It("returns nothing for a path that does not exist", func() {
+			got, err := fs.FileType("missing.txt")
+			g.Assert(err).IsNil()
+			g.Assert(got).Equal("")
+		})
+
+		g.It("returns nothing for a path beneath a file", func() {
+			got, err := fs.FileType("server.properties/missing.txt")
+			g.Assert(err).IsNil()
+			g.Assert(got).Equal("")
+		})
+	})
+}
@@ -0,0 +1,170 @@
+package filesystem
+
+import (
+	"context"
+	"path"
+	"sync"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+// maxPermissionFailures is the number of individual failures that are kept in
+// the results of fixing permissions, any further failures are only counted.
+const maxPermissionFailures = 100
+
+// fixPermissionsWorkers is the most workers used at once to fix the ownership
+// of files, taken from the pool shared with searches.
+const fixPermissionsWorkers = 8
+
+// FixPermissionsOptions controls how the ownership of files is fixed.
+type FixPermissionsOptions struct {
+	// If set, only files and directories currently owned by this user or group
+	// are changed, everything else is left alone even if it is not owned by the
+	// configured user. When both are set a file must match both of them.
+	OnlyUid *int
+	OnlyGid *int
+	// Called after each file or directory is checked with the number of entries
+	// that have been checked so far. It is never called concurrently.
+	OnProgress func(checked int64)
+}
+
+// PermissionFailure is a single file or directory whose owner could not be
+// fixed.
+type PermissionFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// FixPermissionsResult is the outcome of fixing the ownership of files.
+type FixPermissionsResult struct {
+	// The configured owner that every file was changed to.
+	Uid int `json:"uid"`
+	Gid int `json:"gid"`
+	// The number of files and directories that were checked, and that had their
+	// owner changed because it was wrong.
+	Checked int64 `json:"checked"`
+	Changed int64 `json:"changed"`
+	// The number of entries that could not be fixed, and the details of the first
+	// of them.
+	Failed   int64               `json:"failed"`
+	Failures []PermissionFailure `json:"failures"`
+	// Whether fixing was stopped before everything was checked.
+	Canceled bool `json:"canceled"`
+}
+
+// FixPermissions walks the given path and everything within it, changing the
+// owner of any file or directory that is not owned by the configured user back
+// to that user. This undoes any drift caused by restoring files or uploading
+// them by other means, which would otherwise stop the server from being able
+// to use them. Like Chown symlinks are changed themselves, never followed.
+// Unlike Chown a file that cannot be changed does not stop the walk, every
+// failure is collected and returned in the results instead, and the walk can
+// be stopped part way through by canceling the context. Files are checked by
+// several workers at once, shared with searches so that fixing a large server
+// cannot starve them.
+//
+// The returned error is only set if fixing could not be started at all.
+func (fs *Filesystem) FixPermissions(ctx context.Context, p string, opts FixPermissionsOptions) (*FixPermissionsResult, error) {
+	uid := config.Get().System.User.Uid
+	gid := config.Get().System.User.Gid
+
+	dirfd, name, closeFd, err := fs.unixFS.SafePath(p)
+	defer closeFd()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fs.unixFS.Lstatat(dirfd, name); err != nil {
+		return nil, err
+	}
+
+	workers, release, err := acquireSearchWorkers(ctx, fixPermissionsWorkers)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	root := path.Clean("/" + p)
+	out := &FixPermissionsResult{Uid: uid, Gid: gid, Failures: []PermissionFailure{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	pending := make(chan string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range pending {
+				changed, err := fs.fixOwner(p, uid, gid, opts)
+				mu.Lock()
+				out.Checked++
+				if err != nil {
+					out.fail(p, err)
+				} else if changed {
+					out.Changed++
+				}
+				if opts.OnProgress != nil {
+					opts.OnProgress(out.Checked)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	err = fs.unixFS.WalkDirat(dirfd, name, func(_ int, _, relative string, _ ufs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		p := path.Join(root, relative)
+		if err != nil {
+			mu.Lock()
+			out.fail(p, err)
+			mu.Unlock()
+			return nil
+		}
+		pending <- p
+		return nil
+	})
+	close(pending)
+	wg.Wait()
+	if err != nil {
+		if ctx.Err() != nil {
+			out.Canceled = true
+		} else {
+			// The walk only stops early on its own if a directory could not be opened.
+			out.fail(root, err)
+		}
+	}
+	return out, nil
+}
+
+// fixOwner changes the owner of the file or directory at the given path to the
+// given user if it is not already owned by them, returning true if it was
+// changed.
+func (fs *Filesystem) fixOwner(p string, uid, gid int, opts FixPermissionsOptions) (bool, error) {
+	st, err := fs.unixFS.Lstat(p)
+	if err != nil {
+		return false, err
+	}
+	sys, ok := st.Sys().(*unix.Stat_t)
+	if !ok {
+		return false, nil
+	}
+	if int(sys.Uid) == uid && int(sys.Gid) == gid {
+		return false, nil
+	}
+	if (opts.OnlyUid != nil && int(sys.Uid) != *opts.OnlyUid) || (opts.OnlyGid != nil && int(sys.Gid) != *opts.OnlyGid) {
+		return false, nil
+	}
+	if err := fs.unixFS.Lchown(p, uid, gid); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *FixPermissionsResult) fail(p string, err error) {
+	r.Failed++
+	if len(r.Failures) < maxPermissionFailures {
+		r.Failures = append(r.Failures, PermissionFailure{Path: p, Error: err.Error()})
+	}
+}
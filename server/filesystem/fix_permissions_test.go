@@ -0,0 +1,94 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/kristiangarcia/wings/config"
+)
+
+func TestFilesystem_FixPermissions(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	// Changing the owner of a file to another user needs root.
+	if os.Geteuid() != 0 {
+		t.Skip("fixing permissions requires running as root")
+	}
+
+	owner := func(p string) (uint32, uint32) {
+		st, err := os.Lstat(filepath.Join(rfs.root, "server", p))
+		g.Assert(err).IsNil()
+		sys := st.Sys().(*syscall.Stat_t)
+		return sys.Uid, sys.Gid
+	}
+
+	g.Describe("FixPermissions", func() {
+		g.BeforeEach(func() {
+			config.Update(func(c *config.Configuration) {
+				c.System.User.Uid = 1234
+				c.System.User.Gid = 1234
+			})
+			_ = fs.CreateDirectory("plugins", "/")
+			_ = rfs.CreateServerFileFromString("plugins/config.yml", "greeting: hello")
+			_ = rfs.CreateServerFileFromString("server.properties", "motd=hello")
+			_ = os.Lchown(filepath.Join(rfs.root, "server/server.properties"), 1234, 1234)
+		})
+
+		g.AfterEach(func() {
+			config.Update(func(c *config.Configuration) {
+				c.System.User.Uid = 0
+				c.System.User.Gid = 0
+			})
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("changes the owner of files back to the configured user", func() {
+			res, err := fs.FixPermissions(context.Background(), "/", FixPermissionsOptions{})
+			g.Assert(err).IsNil()
+			g.Assert(res.Failed).Equal(int64(0))
+			g.Assert(res.Checked).Equal(int64(4))
+			// The server properties were already owned by the user.
+			g.Assert(res.Changed).Equal(int64(3))
+
+			uid, gid := owner("plugins/config.yml")
+			g.Assert(uid).Equal(uint32(1234))
+			g.Assert(gid).Equal(uint32(1234))
+		})
+
+		g.It("only changes files within the given path", func() {
+			res, err := fs.FixPermissions(context.Background(), "plugins", FixPermissionsOptions{})
+			g.Assert(err).IsNil()
+			g.Assert(res.Checked).Equal(int64(2))
+			uid, _ := owner("plugins/config.yml")
+			g.Assert(uid).Equal(uint32(1234))
+		})
+
+		g.It("only changes files owned by the given user", func() {
+			_ = os.Lchown(filepath.Join(rfs.root, "server/plugins/config.yml"), 4321, 4321)
+			only := 4321
+			res, err := fs.FixPermissions(context.Background(), "/", FixPermissionsOptions{OnlyUid: &only})
+			g.Assert(err).IsNil()
+			g.Assert(res.Changed).Equal(int64(1))
+
+			uid, gid := owner("plugins/config.yml")
+			g.Assert(uid).Equal(uint32(1234))
+			g.Assert(gid).Equal(uint32(1234))
+			uid, _ = owner("plugins")
+			g.Assert(uid).Equal(uint32(0))
+		})
+
+		g.It("stops when the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			res, err := fs.FixPermissions(ctx, "/", FixPermissionsOptions{})
+			g.Assert(err).IsNil()
+			g.Assert(res.Canceled).IsTrue()
+		})
+	})
+}
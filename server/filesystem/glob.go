@@ -0,0 +1,67 @@
+package filesystem
+
+import (
+	"path"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// ErrBadGlob is returned when a glob pattern used for a search is malformed.
+var ErrBadGlob = errors.Sentinel("filesystem: malformed glob pattern")
+
+// ValidateGlob returns ErrBadGlob if the given pattern cannot be used with
+// matchGlob.
+func ValidateGlob(pattern string) error {
+	for _, seg := range strings.Split(pattern, "/") {
+		if seg == "**" {
+			continue
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return errors.WithStack(ErrBadGlob)
+		}
+	}
+	return nil
+}
+
+// matchGlob reports whether the slash separated path matches the pattern. Each
+// element of the pattern uses the same syntax as path.Match, and an element that
+// is just "**" matches any number of directories, including none. A pattern with
+// no slash in it, such as "*.properties", is matched against the name of the file
+// in any directory, since that is what is nearly always meant by one.
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchGlobSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(strings.Trim(name, "/"), "/"))
+}
+
+func matchGlobSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse repeated "**" elements, then try every possible number of
+			// directories for this one to match.
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range name {
+				if matchGlobSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
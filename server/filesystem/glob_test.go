@@ -0,0 +1,38 @@
+package filesystem
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestMatchGlob(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("matchGlob", func() {
+		g.It("matches patterns without a slash against the file name", func() {
+			g.Assert(matchGlob("*.properties", "server.properties")).IsTrue()
+			g.Assert(matchGlob("*.properties", "plugins/x/messages.properties")).IsTrue()
+			g.Assert(matchGlob("*.properties", "server.properties.bak")).IsFalse()
+		})
+
+		g.It("matches each element of a pattern with a slash", func() {
+			g.Assert(matchGlob("plugins/*.yml", "plugins/config.yml")).IsTrue()
+			g.Assert(matchGlob("plugins/*.yml", "plugins/essentials/config.yml")).IsFalse()
+			g.Assert(matchGlob("plugins/?ssentials/config.yml", "plugins/essentials/config.yml")).IsTrue()
+		})
+
+		g.It("matches any number of directories with a double star", func() {
+			g.Assert(matchGlob("plugins/**/config.yml", "plugins/config.yml")).IsTrue()
+			g.Assert(matchGlob("plugins/**/config.yml", "plugins/a/b/config.yml")).IsTrue()
+			g.Assert(matchGlob("**/*.log", "logs/latest.log")).IsTrue()
+			g.Assert(matchGlob("logs/**", "logs/2024/01.log.gz")).IsTrue()
+			g.Assert(matchGlob("plugins/**/config.yml", "world/config.yml")).IsFalse()
+		})
+
+		g.It("rejects malformed patterns", func() {
+			g.Assert(ValidateGlob("plugins/**/[a-")).IsNotNil()
+			g.Assert(ValidateGlob("plugins/**/[a-z]*.yml")).IsNil()
+		})
+	})
+}
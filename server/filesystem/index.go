@@ -0,0 +1,308 @@
+package filesystem
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+// indexRetryInterval is how long to wait before trying to build the index again
+// after the server grew too large to be indexed.
+const indexRetryInterval = time.Minute * 5
+
+var errIndexTooLarge = errors.Sentinel("filesystem: index: too many files to index")
+
+// indexEntry is the information stored about each file in the index.
+type indexEntry struct {
+	size    int64
+	modTime time.Time
+}
+
+// fileIndex is an in-memory listing of every file within the server directory
+// that is kept up to date by watching the filesystem for changes. It is used to
+// avoid walking the disk for every search on servers that are searched often.
+//
+// The index is built the first time it is needed, and is thrown away whenever it
+// may have drifted from the disk, such as when the watcher falls behind. It will
+// then be rebuilt the next time it is used.
+type fileIndex struct {
+	fs    *Filesystem
+	limit int
+
+	mu       sync.RWMutex
+	entries  map[string]indexEntry
+	watcher  *fsnotify.Watcher
+	retryAt  time.Time
+	building bool
+}
+
+// index returns the file index for this filesystem, or nil if indexing has not
+// been enabled.
+func (fs *Filesystem) index() *fileIndex {
+	fs.indexOnce.Do(func() {
+		if n := config.Get().Filesystem.SearchIndexMaxEntries; n > 0 {
+			fs.fileIndex = &fileIndex{fs: fs, limit: n}
+		}
+	})
+	return fs.fileIndex
+}
+
+// Files returns the paths of every file in the index within the given directory,
+// relative to that directory. If the index is not available, false is returned
+// and the caller should walk the disk instead.
+func (idx *fileIndex) Files(dir string) ([]string, bool) {
+	if !idx.ready() {
+		return nil, false
+	}
+
+	prefix := strings.Trim(path.Clean("/"+dir), "/")
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if idx.entries == nil {
+		return nil, false
+	}
+	var out []string
+	for p := range idx.entries {
+		if prefix == "" {
+			out = append(out, p)
+		} else if strings.HasPrefix(p, prefix+"/") {
+			out = append(out, p[len(prefix)+1:])
+		}
+	}
+	return out, true
+}
+
+// ready builds the index if it does not currently exist, returning false if the
+// index cannot be used right now.
+func (idx *fileIndex) ready() bool {
+	idx.mu.Lock()
+	if idx.entries != nil {
+		idx.mu.Unlock()
+		return true
+	}
+	if idx.building || time.Now().Before(idx.retryAt) {
+		idx.mu.Unlock()
+		return false
+	}
+	idx.building = true
+	idx.mu.Unlock()
+
+	err := idx.build()
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.building = false
+	if err != nil {
+		if !errors.Is(err, errIndexTooLarge) {
+			log.WithField("path", idx.fs.Path()).WithField("error", err).Warn("failed to build filesystem index")
+		}
+		idx.retryAt = time.Now().Add(indexRetryInterval)
+		return false
+	}
+	return true
+}
+
+// build walks the server directory to populate the index and starts watching
+// every directory for changes.
+func (idx *fileIndex) build() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "filesystem: index: failed to create watcher")
+	}
+
+	// The watcher is started before walking so that any changes made while the walk
+	// is in progress are not missed.
+	entries := make(map[string]indexEntry)
+	base := idx.fs.Path()
+	err = idx.fs.unixFS.WalkDir(".", func(p string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return w.Add(filepath.Join(base, p))
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(entries) >= idx.limit {
+			return errIndexTooLarge
+		}
+		info, err := idx.fs.unixFS.Lstat(p)
+		if err != nil {
+			return nil
+		}
+		entries[p] = indexEntry{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		_ = w.Close()
+		return err
+	}
+
+	idx.mu.Lock()
+	idx.entries = entries
+	idx.watcher = w
+	idx.mu.Unlock()
+
+	go idx.watch(w)
+	return nil
+}
+
+// watch applies changes from the watcher to the index until it is closed. If the
+// watcher reports an error, including falling behind on events, the index is
+// dropped since it can no longer be trusted.
+func (idx *fileIndex) watch(w *fsnotify.Watcher) {
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if err := idx.apply(w, ev); err != nil {
+				idx.reset(w)
+				return
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			log.WithField("path", idx.fs.Path()).WithField("error", err).Debug("filesystem index drifted, it will be rebuilt")
+			idx.reset(w)
+			return
+		}
+	}
+}
+
+// apply updates the index for a single change reported by the watcher.
+func (idx *fileIndex) apply(w *fsnotify.Watcher, ev fsnotify.Event) error {
+	rel, err := filepath.Rel(idx.fs.Path(), ev.Name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	rel = filepath.ToSlash(rel)
+
+	switch {
+	case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+		idx.fs.notifyChange(rel, FileChangeRemove)
+	case ev.Has(fsnotify.Create):
+		idx.fs.notifyChange(rel, FileChangeCreate)
+	case ev.Has(fsnotify.Write):
+		idx.fs.notifyChange(rel, FileChangeModify)
+	}
+
+	if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+		idx.mu.Lock()
+		delete(idx.entries, rel)
+		for p := range idx.entries {
+			if strings.HasPrefix(p, rel+"/") {
+				delete(idx.entries, p)
+			}
+		}
+		idx.mu.Unlock()
+		return nil
+	}
+
+	// Symlinks are never followed, the same as when the index is first built.
+	info, err := os.Lstat(ev.Name)
+	if err != nil {
+		return nil
+	}
+	if info.IsDir() {
+		if ev.Has(fsnotify.Create) {
+			return idx.addDirectory(w, rel)
+		}
+		return nil
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if _, ok := idx.entries[rel]; !ok && len(idx.entries) >= idx.limit {
+		return errIndexTooLarge
+	}
+	idx.entries[rel] = indexEntry{size: info.Size(), modTime: info.ModTime()}
+	return nil
+}
+
+// addDirectory starts watching a newly created directory and adds any files that
+// were created within it before the watch was in place.
+func (idx *fileIndex) addDirectory(w *fsnotify.Watcher, dir string) error {
+	base := idx.fs.Path()
+	entries := make(map[string]indexEntry)
+	err := idx.fs.unixFS.WalkDir(dir, func(p string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			// The directory may have been removed again before it could be walked,
+			// the watcher will report that as well.
+			if errors.Is(err, ufs.ErrNotExist) {
+				return ufs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return w.Add(filepath.Join(base, p))
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := idx.fs.unixFS.Lstat(p); err == nil {
+			entries[p] = indexEntry{size: info.Size(), modTime: info.ModTime()}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if len(idx.entries)+len(entries) > idx.limit {
+		return errIndexTooLarge
+	}
+	for p, e := range entries {
+		idx.entries[p] = e
+	}
+	return nil
+}
+
+// reset drops the index and stops the watcher so that the index is rebuilt the
+// next time it is used.
+func (idx *fileIndex) reset(w *fsnotify.Watcher) {
+	idx.mu.Lock()
+	if idx.watcher == w {
+		idx.entries = nil
+		idx.watcher = nil
+	}
+	idx.mu.Unlock()
+	_ = w.Close()
+}
+
+// CloseIndex stops watching the filesystem for changes and discards the file
+// index, if one has been built. This should be called when the server is being
+// removed from the node.
+func (fs *Filesystem) CloseIndex() {
+	idx := fs.index()
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	w := idx.watcher
+	// Prevent the index from being rebuilt by any searches still running.
+	idx.retryAt = time.Now().Add(time.Hour * 24 * 365)
+	idx.mu.Unlock()
+	if w != nil {
+		idx.reset(w)
+	}
+	fs.fileChanges.stop()
+}
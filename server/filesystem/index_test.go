@@ -0,0 +1,66 @@
+package filesystem
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_Index(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("Index", func() {
+		var idx *fileIndex
+
+		g.BeforeEach(func() {
+			_ = fs.CreateDirectory("plugins", "/")
+			_ = rfs.CreateServerFileFromString("server.properties", "motd=hello world")
+			_ = rfs.CreateServerFileFromString("plugins/config.yml", "greeting: hello")
+			idx = &fileIndex{fs: fs, limit: 10}
+		})
+
+		// waitForFile polls the index until the file shows up, since changes are
+		// applied in the background.
+		waitForFile := func(dir, name string) bool {
+			for i := 0; i < 50; i++ {
+				files, _ := idx.Files(dir)
+				if slices.Contains(files, name) {
+					return true
+				}
+				time.Sleep(time.Millisecond * 20)
+			}
+			return false
+		}
+
+		g.It("lists files within a directory", func() {
+			files, ok := idx.Files("/plugins")
+			g.Assert(ok).IsTrue()
+			g.Assert(files).Equal([]string{"config.yml"})
+		})
+
+		g.It("picks up files created after it was built", func() {
+			_, ok := idx.Files("/")
+			g.Assert(ok).IsTrue()
+
+			_ = fs.CreateDirectory("world", "/")
+			_ = rfs.CreateServerFileFromString("world/level.dat", "")
+			g.Assert(waitForFile("/", "world/level.dat")).IsTrue()
+		})
+
+		g.It("is not used when there are too many files", func() {
+			idx.limit = 1
+			_, ok := idx.Files("/")
+			g.Assert(ok).IsFalse()
+		})
+
+		g.AfterEach(func() {
+			if idx.watcher != nil {
+				idx.reset(idx.watcher)
+			}
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
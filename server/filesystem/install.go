@@ -0,0 +1,125 @@
+package filesystem
+
+import (
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/gabriel-vasile/mimetype"
+	"golang.org/x/sys/unix"
+
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+// ErrInstallNotAllowed is returned when a file being installed does not have
+// one of the allowed extensions or mimetypes.
+var ErrInstallNotAllowed = errors.Sentinel("filesystem: file type is not allowed to be installed")
+
+// InstallOptions controls how a file is installed into the server directory.
+type InstallOptions struct {
+	// The mode of the installed file, if zero 0644 is used.
+	Mode ufs.FileMode
+	// If set, the file must have one of these extensions, such as ".jar".
+	AllowedExtensions []string
+	// If set, the detected mimetype of the file must be one of these, such as
+	// "application/jar". Parameters such as the charset are ignored.
+	AllowedMimetypes []string
+	// If true, an existing file at the destination is replaced, otherwise an error
+	// is returned if there is one.
+	Overwrite bool
+}
+
+// InstallFile writes the contents of r, which must be exactly size bytes long,
+// to the given path in a way that is never visible partially written. The
+// contents are copied to a hidden file in the destination directory which is
+// then renamed into place, so the destination is either untouched or holds the
+// entire file. The type of the file is checked against the allowed extensions
+// and mimetypes before anything is written.
+func (fs *Filesystem) InstallFile(p string, r io.ReadSeeker, size int64, opts InstallOptions) (Stat, error) {
+	if len(opts.AllowedExtensions) > 0 {
+		ext := strings.ToLower(path.Ext(p))
+		if !slices.ContainsFunc(opts.AllowedExtensions, func(e string) bool {
+			return strings.ToLower("."+strings.TrimPrefix(e, ".")) == ext
+		}) {
+			return Stat{}, errors.WithStack(ErrInstallNotAllowed)
+		}
+	}
+	if len(opts.AllowedMimetypes) > 0 {
+		m, err := mimetype.DetectReader(r)
+		if err != nil {
+			return Stat{}, errors.Wrap(err, "server/filesystem: install: failed to detect mimetype")
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return Stat{}, errors.Wrap(err, "server/filesystem: install: failed to seek file")
+		}
+		mt := mimetypeFor(p, m)
+		if !slices.ContainsFunc(opts.AllowedMimetypes, func(a string) bool {
+			return m.Is(a) || strings.EqualFold(strings.TrimSpace(strings.Split(mt, ";")[0]), a)
+		}) {
+			return Stat{}, errors.WithStack(ErrInstallNotAllowed)
+		}
+	}
+
+	var currentSize int64
+	st, err := fs.unixFS.Lstat(p)
+	if err != nil && !errors.Is(err, ufs.ErrNotExist) {
+		return Stat{}, errors.Wrap(err, "server/filesystem: install: failed to stat file")
+	} else if err == nil {
+		if st.IsDir() {
+			return Stat{}, errors.WithStack(&Error{code: ErrCodeIsDirectory, resolved: p})
+		}
+		if !opts.Overwrite {
+			return Stat{}, &ufs.PathError{Op: "install", Path: p, Err: ufs.ErrExist}
+		}
+		currentSize = st.Size()
+	}
+	// Both the existing file and the new one are on the disk until the rename, so
+	// there must be space for the entire new file.
+	if err := fs.HasSpaceFor(size); err != nil {
+		return Stat{}, err
+	}
+
+	mode := opts.Mode
+	if mode == 0 {
+		mode = 0o644
+	}
+	t, err := fs.createTempFile(path.Dir(p), mode)
+	if err != nil {
+		return Stat{}, err
+	}
+	defer t.cleanup()
+	n, err := io.Copy(t, io.LimitReader(r, size))
+	if err != nil {
+		return Stat{}, errors.Wrap(err, "server/filesystem: install: failed to write file")
+	}
+	if n != size {
+		return Stat{}, errors.Errorf("server/filesystem: install: expected %d bytes but only %d were written", size, n)
+	}
+	if err := t.commit(p); err != nil {
+		return Stat{}, err
+	}
+	fs.unixFS.Add(size - currentSize)
+
+	return fs.Stat(p)
+}
+
+// replaceFile renames oldpath to newpath, atomically replacing anything that is
+// already at newpath. Rename cannot be used since it never replaces a file.
+func (fs *Filesystem) replaceFile(oldpath, newpath string) error {
+	olddirfd, oldname, closeOld, err := fs.unixFS.SafePath(oldpath)
+	defer closeOld()
+	if err != nil {
+		return err
+	}
+	newdirfd, newname, closeNew, err := fs.unixFS.SafePath(newpath)
+	defer closeNew()
+	if err != nil {
+		return err
+	}
+	if err := unix.Renameat(olddirfd, oldname, newdirfd, newname); err != nil {
+		return &ufs.PathError{Op: "rename", Path: newpath, Err: err}
+	}
+	return nil
+}
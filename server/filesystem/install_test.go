@@ -0,0 +1,75 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+func TestFilesystem_InstallFile(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("InstallFile", func() {
+		g.BeforeEach(func() {
+			_ = fs.CreateDirectory("plugins", "/")
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("installs the file with the requested mode", func() {
+			st, err := fs.InstallFile("plugins/config.yml", strings.NewReader("key: value"), 10, InstallOptions{Mode: 0o600})
+			g.Assert(err).IsNil()
+			g.Assert(st.Name()).Equal("config.yml")
+			g.Assert(st.Mode().Perm()).Equal(ufs.FileMode(0o600))
+
+			b, err := os.ReadFile(filepath.Join(rfs.root, "server/plugins/config.yml"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal("key: value")
+		})
+
+		g.It("rejects files without an allowed extension", func() {
+			_, err := fs.InstallFile("plugins/plugin.exe", strings.NewReader("data"), 4, InstallOptions{AllowedExtensions: []string{"jar"}})
+			g.Assert(errors.Is(err, ErrInstallNotAllowed)).IsTrue()
+
+			_, err = fs.InstallFile("plugins/plugin.JAR", strings.NewReader("data"), 4, InstallOptions{AllowedExtensions: []string{".jar"}})
+			g.Assert(err).IsNil()
+		})
+
+		g.It("rejects files without an allowed mimetype", func() {
+			_, err := fs.InstallFile("plugins/plugin.jar", strings.NewReader("just some text"), 14, InstallOptions{AllowedMimetypes: []string{"application/zip"}})
+			g.Assert(errors.Is(err, ErrInstallNotAllowed)).IsTrue()
+
+			_, err = fs.InstallFile("plugins/notes.txt", strings.NewReader("just some text"), 14, InstallOptions{AllowedMimetypes: []string{"text/plain"}})
+			g.Assert(err).IsNil()
+		})
+
+		g.It("does not replace an existing file unless asked to", func() {
+			_ = rfs.CreateServerFileFromString("plugins/config.yml", "old")
+
+			_, err := fs.InstallFile("plugins/config.yml", strings.NewReader("new"), 3, InstallOptions{})
+			g.Assert(errors.Is(err, ufs.ErrExist)).IsTrue()
+
+			_, err = fs.InstallFile("plugins/config.yml", strings.NewReader("new"), 3, InstallOptions{Overwrite: true})
+			g.Assert(err).IsNil()
+			b, _ := os.ReadFile(filepath.Join(rfs.root, "server/plugins/config.yml"))
+			g.Assert(string(b)).Equal("new")
+		})
+
+		g.It("leaves nothing behind when the contents are incomplete", func() {
+			_, err := fs.InstallFile("plugins/plugin.jar", strings.NewReader("short"), 100, InstallOptions{})
+			g.Assert(err).IsNotNil()
+
+			entries, _ := os.ReadDir(filepath.Join(rfs.root, "server/plugins"))
+			g.Assert(len(entries)).Equal(0)
+		})
+	})
+}
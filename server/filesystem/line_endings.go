@@ -0,0 +1,51 @@
+package filesystem
+
+import (
+	"bytes"
+
+	"emperror.dev/errors"
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// ErrBinaryFile is returned when a text-only operation is performed against a
+// file that appears to be binary.
+var ErrBinaryFile = errors.Sentinel("filesystem: file is not a text file")
+
+// The line endings that files can be converted to.
+const (
+	LineEndingLF   = "lf"
+	LineEndingCRLF = "crlf"
+)
+
+// NormalizeLineEndings converts every line ending in the text file at the given
+// path to the given style, returning true if the file was changed. Files that
+// already only use that style are left alone, and binary files are never
+// changed, ErrBinaryFile is returned for them instead. The file is written in
+// the same way as ReplaceInFile, so it is never seen partially written.
+func (fs *Filesystem) NormalizeLineEndings(p string, to string) (bool, error) {
+	if to != LineEndingLF && to != LineEndingCRLF {
+		return false, errors.New("server/filesystem: line endings: unknown line ending \"" + to + "\"")
+	}
+	before, mode, err := fs.readEditableFile(p)
+	if err != nil {
+		return false, err
+	}
+	head := before[:min(len(before), sniffLen)]
+	if isBinary(mimetype.Detect(head), head) {
+		return false, errors.WithStack(ErrBinaryFile)
+	}
+
+	// Converting to LF first means a file that mixes both styles does not end up
+	// with any "\r\r\n" when converting it to CRLF.
+	after := bytes.ReplaceAll(before, []byte("\r\n"), []byte("\n"))
+	if to == LineEndingCRLF {
+		after = bytes.ReplaceAll(after, []byte("\n"), []byte("\r\n"))
+	}
+	if bytes.Equal(before, after) {
+		return false, nil
+	}
+	if err := fs.writeEditedFile(p, before, after, mode); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,56 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_NormalizeLineEndings(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	read := func(p string) string {
+		b, err := os.ReadFile(filepath.Join(rfs.root, "server", p))
+		g.Assert(err).IsNil()
+		return string(b)
+	}
+
+	g.Describe("NormalizeLineEndings", func() {
+		g.BeforeEach(func() {
+			_ = rfs.CreateServerFileFromString("server.properties", "pvp=true\r\nmotd=hello\nallow-nether=true\r\n")
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("converts line endings to LF", func() {
+			changed, err := fs.NormalizeLineEndings("server.properties", LineEndingLF)
+			g.Assert(err).IsNil()
+			g.Assert(changed).IsTrue()
+			g.Assert(read("server.properties")).Equal("pvp=true\nmotd=hello\nallow-nether=true\n")
+
+			changed, err = fs.NormalizeLineEndings("server.properties", LineEndingLF)
+			g.Assert(err).IsNil()
+			g.Assert(changed).IsFalse()
+		})
+
+		g.It("converts mixed line endings to CRLF", func() {
+			changed, err := fs.NormalizeLineEndings("server.properties", LineEndingCRLF)
+			g.Assert(err).IsNil()
+			g.Assert(changed).IsTrue()
+			g.Assert(read("server.properties")).Equal("pvp=true\r\nmotd=hello\r\nallow-nether=true\r\n")
+		})
+
+		g.It("never changes binary files", func() {
+			_ = rfs.CreateServerFileFromString("world.dat", "\x00\x01\r\n\x02")
+			_, err := fs.NormalizeLineEndings("world.dat", LineEndingLF)
+			g.Assert(errors.Is(err, ErrBinaryFile)).IsTrue()
+			g.Assert(read("world.dat")).Equal("\x00\x01\r\n\x02")
+		})
+	})
+}
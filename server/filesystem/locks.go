@@ -0,0 +1,122 @@
+package filesystem
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+)
+
+// ErrFileLocked is returned when a file is locked by someone other than the one
+// trying to lock or unlock it.
+var ErrFileLocked = errors.Sentinel("filesystem: file is locked by another user")
+
+// FileLock is an advisory lock held on a file while it is being edited, so that
+// others editing the same file can be warned before overwriting the changes.
+// Locks are only enforced by the endpoints that check them, not the filesystem
+// itself, and are lost when Wings restarts.
+type FileLock struct {
+	Path    string    `json:"path"`
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// fileLocks are the advisory locks held on the files of a server.
+type fileLocks struct {
+	mu    sync.Mutex
+	locks map[string]FileLock
+}
+
+// lockPath returns the key used for locks on the given path.
+func lockPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// LockFile locks the file at the given path for the owner until the duration
+// has passed. If the owner already holds the lock it is extended, and if anyone
+// else holds it ErrFileLocked is returned along with their lock.
+func (fs *Filesystem) LockFile(p, owner string, d time.Duration) (FileLock, error) {
+	l := &fs.fileLocks
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p = lockPath(p)
+	if cur, ok := l.get(p); ok && cur.Owner != owner {
+		return cur, errors.WithStack(ErrFileLocked)
+	}
+	if l.locks == nil {
+		l.locks = make(map[string]FileLock)
+	}
+	lock := FileLock{Path: p, Owner: owner, Expires: time.Now().Add(d)}
+	l.locks[p] = lock
+	return lock, nil
+}
+
+// UnlockFile releases the lock on the file at the given path. Only the owner can
+// release a lock unless force is set. Unlocking a file that is not locked does
+// nothing.
+func (fs *Filesystem) UnlockFile(p, owner string, force bool) error {
+	l := &fs.fileLocks
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p = lockPath(p)
+	if cur, ok := l.get(p); ok && cur.Owner != owner && !force {
+		return errors.WithStack(ErrFileLocked)
+	}
+	delete(l.locks, p)
+	return nil
+}
+
+// FileLock returns the lock held on the file at the given path, if there is one
+// that has not yet expired.
+func (fs *Filesystem) FileLock(p string) (FileLock, bool) {
+	l := &fs.fileLocks
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.get(lockPath(p))
+}
+
+// FileLocks returns every unexpired lock held on the files of the server, sorted
+// by path.
+func (fs *Filesystem) FileLocks() []FileLock {
+	l := &fs.fileLocks
+	l.mu.Lock()
+	out := make([]FileLock, 0, len(l.locks))
+	for p := range l.locks {
+		if lock, ok := l.get(p); ok {
+			out = append(out, lock)
+		}
+	}
+	l.mu.Unlock()
+	slices.SortFunc(out, func(a, b FileLock) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return out
+}
+
+// CheckFileLock returns ErrFileLocked if the file at the given path is locked by
+// anyone other than the given owner, along with the lock that is held.
+func (fs *Filesystem) CheckFileLock(p, owner string) (FileLock, error) {
+	if lock, ok := fs.FileLock(p); ok && lock.Owner != owner {
+		return lock, errors.WithStack(ErrFileLocked)
+	}
+	return FileLock{}, nil
+}
+
+// get returns the lock on the given path, removing it if it has expired. The
+// mutex must be held by the caller.
+func (l *fileLocks) get(p string) (FileLock, bool) {
+	lock, ok := l.locks[p]
+	if !ok {
+		return FileLock{}, false
+	}
+	if time.Now().After(lock.Expires) {
+		delete(l.locks, p)
+		return FileLock{}, false
+	}
+	return lock, true
+}
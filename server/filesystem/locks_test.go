@@ -0,0 +1,82 @@
+package filesystem
+
+import (
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_FileLocks(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("LockFile", func() {
+		g.It("locks a file for its owner", func() {
+			fs, _ := NewFs()
+			_, err := fs.LockFile("/server.properties", "alice", time.Minute)
+			g.Assert(err).IsNil()
+
+			lock, ok := fs.FileLock("server.properties")
+			g.Assert(ok).IsTrue()
+			g.Assert(lock.Owner).Equal("alice")
+			g.Assert(lock.Path).Equal("server.properties")
+		})
+
+		g.It("rejects a lock held by another owner", func() {
+			fs, _ := NewFs()
+			_, _ = fs.LockFile("server.properties", "alice", time.Minute)
+
+			lock, err := fs.LockFile("server.properties", "bob", time.Minute)
+			g.Assert(errors.Is(err, ErrFileLocked)).IsTrue()
+			g.Assert(lock.Owner).Equal("alice")
+
+			_, err = fs.CheckFileLock("server.properties", "bob")
+			g.Assert(errors.Is(err, ErrFileLocked)).IsTrue()
+			_, err = fs.CheckFileLock("server.properties", "alice")
+			g.Assert(err).IsNil()
+		})
+
+		g.It("lets the owner extend their lock", func() {
+			fs, _ := NewFs()
+			first, _ := fs.LockFile("server.properties", "alice", time.Second)
+			second, err := fs.LockFile("server.properties", "alice", time.Minute)
+			g.Assert(err).IsNil()
+			g.Assert(second.Expires.After(first.Expires)).IsTrue()
+		})
+
+		g.It("expires locks", func() {
+			fs, _ := NewFs()
+			_, _ = fs.LockFile("server.properties", "alice", -time.Second)
+
+			_, ok := fs.FileLock("server.properties")
+			g.Assert(ok).IsFalse()
+			g.Assert(len(fs.FileLocks())).Equal(0)
+
+			_, err := fs.LockFile("server.properties", "bob", time.Minute)
+			g.Assert(err).IsNil()
+		})
+	})
+
+	g.Describe("UnlockFile", func() {
+		g.It("only lets the owner unlock a file", func() {
+			fs, _ := NewFs()
+			_, _ = fs.LockFile("server.properties", "alice", time.Minute)
+
+			err := fs.UnlockFile("server.properties", "bob", false)
+			g.Assert(errors.Is(err, ErrFileLocked)).IsTrue()
+
+			g.Assert(fs.UnlockFile("/server.properties", "alice", false)).IsNil()
+			_, ok := fs.FileLock("server.properties")
+			g.Assert(ok).IsFalse()
+		})
+
+		g.It("allows forcing a file to be unlocked", func() {
+			fs, _ := NewFs()
+			_, _ = fs.LockFile("server.properties", "alice", time.Minute)
+
+			g.Assert(fs.UnlockFile("server.properties", "bob", true)).IsNil()
+			g.Assert(len(fs.FileLocks())).Equal(0)
+		})
+	})
+}
@@ -0,0 +1,75 @@
+package filesystem
+
+import (
+	"path"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+
+	"github.com/kristiangarcia/wings/config"
+)
+
+// genericMimetype is the mimetype reported for files whose contents could not
+// be classified.
+const genericMimetype = "application/octet-stream"
+
+// defaultMimeOverrides are the mimetypes used for files with these extensions
+// when their contents cannot be classified, covering the configuration formats
+// commonly used by game servers.
+var defaultMimeOverrides = map[string]string{
+	".yml":        "text/yaml",
+	".yaml":       "text/yaml",
+	".toml":       "application/toml",
+	".json":       "application/json",
+	".json5":      "application/json5",
+	".jsonc":      "application/json",
+	".mcmeta":     "application/json",
+	".properties": "text/x-java-properties",
+	".ini":        "text/plain",
+	".cfg":        "text/plain",
+	".conf":       "text/plain",
+	".env":        "text/plain",
+	".lang":       "text/plain",
+	".log":        "text/plain",
+	".txt":        "text/plain",
+	".vdf":        "text/plain",
+	".sk":         "text/plain",
+	".lua":        "text/x-lua",
+	".sh":         "text/x-shellscript",
+	".xml":        "text/xml",
+	".csv":        "text/csv",
+	".md":         "text/markdown",
+}
+
+// mimeOverride returns the mimetype configured for the extension of the given
+// file name, if there is one.
+func mimeOverride(name string) (string, bool) {
+	ext := strings.ToLower(path.Ext(name))
+	if ext == "" {
+		return "", false
+	}
+	for k, v := range config.Get().Filesystem.MimeOverrides {
+		if strings.ToLower("."+strings.TrimPrefix(k, ".")) == ext {
+			return v, v != ""
+		}
+	}
+	v, ok := defaultMimeOverrides[ext]
+	return v, ok
+}
+
+// mimetypeFor returns the mimetype to report for the file with the given name
+// and detected type. If the contents could not be classified the extension of
+// the file is used instead, when it has an override.
+func mimetypeFor(name string, m *mimetype.MIME) string {
+	mt := genericMimetype
+	if m != nil {
+		mt = m.String()
+	}
+	if mt != genericMimetype {
+		return mt
+	}
+	if v, ok := mimeOverride(name); ok {
+		return v
+	}
+	return mt
+}
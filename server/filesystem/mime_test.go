@@ -0,0 +1,66 @@
+package filesystem
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/kristiangarcia/wings/config"
+)
+
+func TestFilesystem_MimeOverrides(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("Mimetype overrides", func() {
+		g.BeforeEach(func() {
+			_ = rfs.CreateServerFileFromString("config.yml", "key: value\x00\x01")
+			_ = rfs.CreateServerFileFromString("data.bin", "\x00\x01\x02")
+			_ = rfs.CreateServerFileFromString("plain.yml", "key: value")
+		})
+
+		g.AfterEach(func() {
+			config.Update(func(c *config.Configuration) {
+				c.Filesystem.MimeOverrides = nil
+			})
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("uses the extension for files that cannot be classified", func() {
+			st, err := fs.Stat("config.yml")
+			g.Assert(err).IsNil()
+			g.Assert(st.Mimetype).Equal("text/yaml")
+
+			st, err = fs.Stat("data.bin")
+			g.Assert(err).IsNil()
+			g.Assert(st.Mimetype).Equal("application/octet-stream")
+		})
+
+		g.It("keeps the detected mimetype when there is one", func() {
+			st, err := fs.Stat("plain.yml")
+			g.Assert(err).IsNil()
+			g.Assert(st.Mimetype).Equal("text/plain; charset=utf-8")
+		})
+
+		g.It("applies configured overrides over the defaults", func() {
+			config.Update(func(c *config.Configuration) {
+				c.Filesystem.MimeOverrides = map[string]string{"BIN": "application/x-custom", ".yml": ""}
+			})
+
+			st, _ := fs.Stat("data.bin")
+			g.Assert(st.Mimetype).Equal("application/x-custom")
+			st, _ = fs.Stat("config.yml")
+			g.Assert(st.Mimetype).Equal("application/octet-stream")
+		})
+
+		g.It("applies to directory listings", func() {
+			list, err := fs.ListDirectory("/")
+			g.Assert(err).IsNil()
+			for _, st := range list {
+				if st.Name() == "config.yml" {
+					g.Assert(st.Mimetype).Equal("text/yaml")
+				}
+			}
+		})
+	})
+}
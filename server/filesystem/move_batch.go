@@ -0,0 +1,109 @@
+package filesystem
+
+import (
+	"path"
+	"strings"
+
+	"emperror.dev/errors"
+
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+// ErrInvalidMove is returned when one of the moves in a batch cannot be
+// performed, in which case nothing in the batch is moved.
+var ErrInvalidMove = errors.Sentinel("filesystem: invalid move")
+
+// Move is a single file or directory to move as part of a batch.
+type Move struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// The reasons that a single move in a batch can fail.
+const (
+	MoveFailedNotFound = "not_found"
+	MoveFailedExists   = "exists"
+	MoveFailedError    = "error"
+)
+
+// MoveResult is the outcome of a single move in a batch. If the move failed
+// Error is set to one of the MoveFailed reasons and the file was left where it
+// was.
+type MoveResult struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Moved bool   `json:"moved"`
+	Error string `json:"error,omitempty"`
+}
+
+// MoveBatch moves each of the given files or directories to its destination,
+// resolving anything that already exists there with the conflict strategy, see
+// RenameWithConflict. Every move is checked before anything is moved, so that a
+// move that could never work, such as one onto the denylist, out of the server
+// directory, or of a directory into itself, fails the whole batch with
+// ErrInvalidMove rather than leaving it half done. After that each move is
+// performed on its own, and one failing does not stop the rest. Every move is a
+// single rename so a file is never left half moved.
+func (fs *Filesystem) MoveBatch(moves []Move, conflict string) ([]MoveResult, error) {
+	targets := make(map[string]struct{}, len(moves))
+	for _, m := range moves {
+		from, to := path.Clean("/"+m.From), path.Clean("/"+m.To)
+		if err := fs.validateMove(from, to); err != nil {
+			return nil, errors.WithMessage(err, "move "+from+" to "+to)
+		}
+		// Moving two files to the same place would always overwrite or rename one of
+		// them, which is never what was meant.
+		if _, ok := targets[to]; ok && conflict != RenameConflictRename {
+			return nil, errors.WithMessage(errors.WithStack(ErrInvalidMove), "more than one file is being moved to "+to)
+		}
+		targets[to] = struct{}{}
+	}
+
+	out := make([]MoveResult, len(moves))
+	for i, m := range moves {
+		from, to := path.Clean("/"+m.From), path.Clean("/"+m.To)
+		out[i] = MoveResult{From: m.From, To: m.To}
+		final, err := fs.RenameWithConflict(from, to, conflict)
+		if err != nil {
+			switch {
+			case errors.Is(err, ufs.ErrNotExist):
+				out[i].Error = MoveFailedNotFound
+			case errors.Is(err, ufs.ErrExist):
+				out[i].Error = MoveFailedExists
+			default:
+				fs.error(err).WithField("from", from).WithField("to", to).Warn("failed to move file in batch")
+				out[i].Error = MoveFailedError
+			}
+			continue
+		}
+		out[i].To = path.Join(path.Dir(m.To), path.Base(final))
+		out[i].Moved = true
+		fs.RecordOp(RecentOpRename, final)
+	}
+	return out, nil
+}
+
+// validateMove checks that a file can be moved between the given clean paths.
+func (fs *Filesystem) validateMove(from, to string) error {
+	switch {
+	case from == "/" || to == "/":
+		return errors.WithStack(ErrInvalidMove)
+	case from == to:
+		return nil
+	case strings.HasPrefix(to, from+"/"):
+		// A directory cannot be moved into itself.
+		return errors.WithStack(ErrInvalidMove)
+	}
+	if err := fs.IsIgnored(from, to); err != nil {
+		return err
+	}
+	// Resolving checks that neither path leaves the server directory through a
+	// symlink.
+	if _, err := fs.resolve(from); err != nil {
+		return err
+	}
+	if _, err := fs.resolve(path.Dir(to)); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_MoveBatch(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("MoveBatch", func() {
+		g.BeforeEach(func() {
+			_ = fs.CreateDirectory("plugins", "/")
+			_ = fs.CreateDirectory("archive", "/")
+			_ = rfs.CreateServerFileFromString("plugins/config.yml", "greeting: hello")
+			_ = rfs.CreateServerFileFromString("plugins/other.yml", "nothing here")
+			_ = rfs.CreateServerFileFromString("archive/other.yml", "old")
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("moves every file and reports the ones that failed", func() {
+			results, err := fs.MoveBatch([]Move{
+				{From: "plugins/config.yml", To: "archive/config.yml"},
+				{From: "plugins/other.yml", To: "archive/other.yml"},
+				{From: "plugins/missing.yml", To: "archive/missing.yml"},
+			}, "")
+			g.Assert(err).IsNil()
+			g.Assert(results[0].Moved).IsTrue()
+			g.Assert(results[1].Error).Equal(MoveFailedExists)
+			g.Assert(results[2].Error).Equal(MoveFailedNotFound)
+
+			_, err = rfs.StatServerFile("archive/config.yml")
+			g.Assert(err).IsNil()
+			_, err = rfs.StatServerFile("plugins/other.yml")
+			g.Assert(err).IsNil()
+		})
+
+		g.It("keeps the destination when overwriting with a missing source", func() {
+			results, err := fs.MoveBatch([]Move{
+				{From: "plugins/other.yml", To: "archive/moved.yml"},
+				{From: "plugins/other.yml", To: "archive/other.yml"},
+			}, RenameConflictOverwrite)
+			g.Assert(err).IsNil()
+			g.Assert(results[0].Moved).IsTrue()
+			g.Assert(results[1].Error).Equal(MoveFailedNotFound)
+
+			b, err := os.ReadFile(filepath.Join(rfs.root, "server/archive/other.yml"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal("old")
+		})
+
+		g.It("returns the name picked when renaming on conflict", func() {
+			results, err := fs.MoveBatch([]Move{{From: "plugins/other.yml", To: "archive/other.yml"}}, RenameConflictRename)
+			g.Assert(err).IsNil()
+			g.Assert(results[0].Moved).IsTrue()
+			g.Assert(results[0].To).Equal("archive/other (1).yml")
+		})
+
+		g.It("does not move anything if one of the moves is invalid", func() {
+			_, err := fs.MoveBatch([]Move{
+				{From: "plugins/config.yml", To: "archive/config.yml"},
+				{From: "plugins", To: "plugins/nested"},
+			}, "")
+			g.Assert(errors.Is(err, ErrInvalidMove)).IsTrue()
+
+			_, err = fs.MoveBatch([]Move{
+				{From: "plugins/config.yml", To: "archive/same.yml"},
+				{From: "plugins/other.yml", To: "archive/same.yml"},
+			}, "")
+			g.Assert(errors.Is(err, ErrInvalidMove)).IsTrue()
+
+			_, err = rfs.StatServerFile("plugins/config.yml")
+			g.Assert(err).IsNil()
+		})
+	})
+}
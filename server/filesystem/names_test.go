@@ -0,0 +1,108 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+// trickyNames are file names that are easy to mangle by trimming, splitting on
+// whitespace, or encoding them incorrectly.
+var trickyNames = []string{
+	" leading.txt",
+	"trailing.txt ",
+	"  ",
+	"new\nline.txt",
+	"tab\there.txt",
+	"party 🎉.txt",
+	"\u202etxt.exe",
+	"quote\"and\\slash.txt",
+	"percent%20encoded.txt",
+}
+
+func TestFilesystem_TrickyNames(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("Tricky file names", func() {
+		g.BeforeEach(func() {
+			_ = fs.CreateDirectory("names", "/")
+			for _, n := range trickyNames {
+				_ = rfs.CreateServerFileFromString(filepath.Join("names", n), "hello")
+			}
+		})
+
+		g.AfterEach(func() {
+			_ = os.RemoveAll(filepath.Join(rfs.root, "/server"))
+			_ = os.Mkdir(filepath.Join(rfs.root, "/server"), 0o755)
+		})
+
+		g.It("lists every name exactly", func() {
+			st, err := fs.ListDirectory("/names")
+			g.Assert(err).IsNil()
+
+			var names []string
+			for _, s := range st {
+				names = append(names, s.Name())
+			}
+			slices.Sort(names)
+			expected := slices.Clone(trickyNames)
+			slices.Sort(expected)
+			g.Assert(names).Equal(expected)
+		})
+
+		g.It("round-trips names through JSON", func() {
+			for _, n := range trickyNames {
+				f, st, err := fs.File(filepath.Join("names", n))
+				g.Assert(err).IsNil()
+				_ = f.Close()
+
+				b, err := json.Marshal(&st)
+				g.Assert(err).IsNil()
+				var out struct {
+					Name string `json:"name"`
+				}
+				g.Assert(json.Unmarshal(b, &out)).IsNil()
+				g.Assert(out.Name).Equal(n)
+			}
+		})
+
+		g.It("finds every name when searching", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/names", Queries: []string{"hello"}, IncludeContent: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+
+			names := searchNames(results.Results)
+			slices.Sort(names)
+			expected := slices.Clone(trickyNames)
+			slices.Sort(expected)
+			g.Assert(names).Equal(expected)
+
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"🎉"}, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"names/party 🎉.txt"})
+		})
+
+		g.It("renames and deletes files without trimming their names", func() {
+			for _, n := range trickyNames {
+				p := filepath.Join("names", n)
+				g.Assert(fs.Rename(p, p+".old")).IsNil()
+				_, err := rfs.StatServerFile(p + ".old")
+				g.Assert(err).IsNil()
+
+				g.Assert(fs.Delete(p + ".old")).IsNil()
+				_, err = rfs.StatServerFile(p + ".old")
+				g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+			}
+
+			st, err := fs.ListDirectory("/names")
+			g.Assert(err).IsNil()
+			g.Assert(len(st)).Equal(0)
+		})
+	})
+}
@@ -0,0 +1,86 @@
+package filesystem
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/sys/unix"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+var (
+	openFilesOnce sync.Once
+	openFiles     *semaphore.Weighted
+)
+
+// openFilePool returns the pool that limits how many files can be held open at
+// once by operations that open many files concurrently, or nil if there is no
+// limit.
+func openFilePool() *semaphore.Weighted {
+	openFilesOnce.Do(func() {
+		n := int64(config.Get().Filesystem.MaxOpenFiles)
+		if n == 0 {
+			// Leave half of the process limit for everything else that Wings has open,
+			// such as sockets and log files.
+			var rlimit unix.Rlimit
+			if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rlimit); err == nil && rlimit.Cur != unix.RLIM_INFINITY {
+				n = max(int64(rlimit.Cur/2), 64)
+			}
+		}
+		if n > 0 {
+			openFiles = semaphore.NewWeighted(n)
+		}
+	})
+	return openFiles
+}
+
+// limitedFile releases its slot in the open file pool when it is closed.
+type limitedFile struct {
+	ufs.File
+	once    sync.Once
+	release func()
+}
+
+func (f *limitedFile) Close() error {
+	err := f.File.Close()
+	f.once.Do(f.release)
+	return err
+}
+
+// openLimited calls open once a slot in the open file pool is available, waiting
+// for one if needed. The slot is released when the returned file is closed, or
+// immediately if the file could not be opened.
+func openLimited(open func() (ufs.File, error)) (ufs.File, error) {
+	pool := openFilePool()
+	if pool == nil {
+		return open()
+	}
+	// Acquiring with a background context only fails if the pool is smaller than
+	// the request, which it never is.
+	_ = pool.Acquire(context.Background(), 1)
+	f, err := open()
+	if err != nil {
+		pool.Release(1)
+		return nil, err
+	}
+	return &limitedFile{File: f, release: func() { pool.Release(1) }}, nil
+}
+
+// openFile opens the file at the given path for reading, counting it against
+// the open file pool.
+func (fs *Filesystem) openFile(p string) (ufs.File, error) {
+	return openLimited(func() (ufs.File, error) {
+		return fs.unixFS.Open(p)
+	})
+}
+
+// openFileat is like openFile but opens the file relative to a directory file
+// descriptor.
+func (fs *Filesystem) openFileat(dirfd int, name string, flag int, mode ufs.FileMode) (ufs.File, error) {
+	return openLimited(func() (ufs.File, error) {
+		return fs.unixFS.OpenFileat(dirfd, name, flag, mode)
+	})
+}
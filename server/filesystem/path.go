@@ -23,6 +23,29 @@ func (fs *Filesystem) IsIgnored(paths ...string) error {
 	return nil
 }
 
+// The operations that can be checked with IsPermitted.
+const (
+	OperationRead   = "read"
+	OperationWrite  = "write"
+	OperationRename = "rename"
+	OperationCopy   = "copy"
+	OperationDelete = "delete"
+)
+
+// IsPermitted reports whether the given operation is allowed to be performed on
+// the path by the server's file denylist. The denylist only prevents files from
+// being created or modified, so reading and deleting files is always permitted.
+func (fs *Filesystem) IsPermitted(operation string, p string) (bool, error) {
+	switch operation {
+	case OperationRead, OperationDelete:
+		return true, nil
+	case OperationWrite, OperationRename, OperationCopy:
+		return fs.IsIgnored(p) == nil, nil
+	default:
+		return false, errors.New("filesystem: unknown operation: " + operation)
+	}
+}
+
 // Generate a path to the file by cleaning it up and appending the root server path to it. This
 // DOES NOT guarantee that the file resolves within the server data directory. You'll want to use
 // the fs.unsafeIsInDataDirectory(p) function to confirm.
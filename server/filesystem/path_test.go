@@ -8,6 +8,7 @@ import (
 
 	"emperror.dev/errors"
 	. "github.com/franela/goblin"
+	ignore "github.com/sabhiram/go-gitignore"
 
 	"github.com/kristiangarcia/wings/internal/ufs"
 )
@@ -23,6 +24,90 @@ func TestFilesystem_Path(t *testing.T) {
 	})
 }
 
+func TestFilesystem_IsPermitted(t *testing.T) {
+	g := Goblin(t)
+	fs, _ := NewFs()
+	fs.denylist = ignore.CompileIgnoreLines("server.properties")
+
+	g.Describe("IsPermitted", func() {
+		g.It("denies modifying a file on the denylist", func() {
+			for _, op := range []string{OperationWrite, OperationRename, OperationCopy} {
+				ok, err := fs.IsPermitted(op, "/server.properties")
+				g.Assert(err).IsNil()
+				g.Assert(ok).IsFalse(op)
+			}
+		})
+
+		g.It("allows reading and deleting a file on the denylist", func() {
+			for _, op := range []string{OperationRead, OperationDelete} {
+				ok, err := fs.IsPermitted(op, "/server.properties")
+				g.Assert(err).IsNil()
+				g.Assert(ok).IsTrue(op)
+			}
+		})
+
+		g.It("allows modifying other files", func() {
+			ok, err := fs.IsPermitted(OperationWrite, "/config.yml")
+			g.Assert(err).IsNil()
+			g.Assert(ok).IsTrue()
+		})
+
+		g.It("returns an error for an unknown operation", func() {
+			_, err := fs.IsPermitted("execute", "/config.yml")
+			g.Assert(err).IsNotNil()
+		})
+	})
+}
+
+func TestFilesystem_SymlinkPolicy(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("SymlinkPolicy", func() {
+		g.BeforeEach(func() {
+			_ = fs.CreateDirectory("target", "/")
+			_ = rfs.CreateServerFileFromString("target/file.txt", "content")
+			_ = os.Symlink("target", filepath.Join(rfs.root, "/server/link"))
+			_ = os.Symlink("../../outside", filepath.Join(rfs.root, "/server/target/escape"))
+		})
+
+		g.It("returns an error when rejecting symlinks", func() {
+			fs.symlinks = SymlinkPolicyReject
+			_, _, err := fs.File("link/file.txt")
+			g.Assert(IsErrorCode(err, ErrCodeSymlink)).IsTrue()
+
+			f, _, err := fs.File("target/file.txt")
+			g.Assert(err).IsNil()
+			_ = f.Close()
+		})
+
+		g.It("follows symlinks within the root", func() {
+			fs.symlinks = SymlinkPolicyFollow
+			f, st, err := fs.File("link/file.txt")
+			g.Assert(err).IsNil()
+			g.Assert(st.Name()).Equal("file.txt")
+			_ = f.Close()
+		})
+
+		g.It("does not follow symlinks outside the root", func() {
+			fs.symlinks = SymlinkPolicyFollow
+			_, _, err := fs.File("link/escape")
+			g.Assert(IsErrorCode(err, ErrCodePathResolution)).IsTrue()
+		})
+
+		g.It("treats symlinks as missing when ignoring them", func() {
+			fs.symlinks = SymlinkPolicyIgnore
+			_, _, err := fs.File("link/file.txt")
+			g.Assert(IsErrorCode(err, ErrNotExist)).IsTrue()
+		})
+
+		g.AfterEach(func() {
+			fs.symlinks = SymlinkPolicyReject
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
+
 // We test against accessing files outside the root directory in the tests, however it
 // is still possible for someone to mess up and not properly use this safe path call. In
 // order to truly confirm this, we'll try to pass in a symlinked malicious file to all of
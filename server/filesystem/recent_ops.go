@@ -0,0 +1,88 @@
+package filesystem
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recentOpsLimit is the number of paths kept in the recent operations log of
+// each server, once reached the oldest entries are dropped.
+const recentOpsLimit = 250
+
+// The operations recorded in the recent operations log.
+const (
+	RecentOpWrite           = "write"
+	RecentOpUpload          = "upload"
+	RecentOpPull            = "pull"
+	RecentOpExtract         = "extract"
+	RecentOpDelete          = "delete"
+	RecentOpRename          = "rename"
+	RecentOpCreateDirectory = "create_directory"
+)
+
+// RecentOp is a single change made to a file through Wings.
+type RecentOp struct {
+	Op   string    `json:"op"`
+	Path string    `json:"path"`
+	Time time.Time `json:"time"`
+}
+
+// recentOps is a bounded, in-memory log of the files most recently changed
+// through Wings. Only the latest operation on each path is kept so that a file
+// that is saved repeatedly does not push everything else out of the log. The
+// log is not persisted and starts out empty whenever Wings is restarted.
+type recentOps struct {
+	mu      sync.Mutex
+	entries []RecentOp
+}
+
+// RecordOp adds the given paths to the recent operations log of the server.
+func (fs *Filesystem) RecordOp(op string, paths ...string) {
+	now := time.Now()
+	r := &fs.recentOps
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range paths {
+		p = strings.TrimPrefix(path.Clean("/"+p), "/")
+		if p == "" {
+			continue
+		}
+		r.entries = slices.DeleteFunc(r.entries, func(e RecentOp) bool { return e.Path == p })
+		r.entries = append(r.entries, RecentOp{Op: op, Path: p, Time: now})
+	}
+	if n := len(r.entries) - recentOpsLimit; n > 0 {
+		r.entries = slices.Delete(r.entries, 0, n)
+	}
+}
+
+// RecentOps returns the recent operations log of the server, most recent first.
+func (fs *Filesystem) RecentOps() []RecentOp {
+	r := &fs.recentOps
+	r.mu.Lock()
+	out := slices.Clone(r.entries)
+	r.mu.Unlock()
+	slices.Reverse(out)
+	return out
+}
+
+// recentPaths returns the paths within the given directory that were recently
+// changed and still exist, relative to that directory, in lexical order.
+func (fs *Filesystem) recentPaths(dir string) []string {
+	prefix := strings.Trim(path.Clean("/"+dir), "/")
+	out := []string{}
+	for _, e := range fs.RecentOps() {
+		if e.Op == RecentOpDelete {
+			continue
+		}
+		if prefix == "" {
+			out = append(out, e.Path)
+		} else if strings.HasPrefix(e.Path, prefix+"/") {
+			out = append(out, e.Path[len(prefix)+1:])
+		}
+	}
+	slices.Sort(out)
+	return out
+}
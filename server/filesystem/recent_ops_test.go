@@ -0,0 +1,57 @@
+package filesystem
+
+import (
+	"strconv"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_RecentOps(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("RecentOps", func() {
+		g.It("returns the most recent operations first", func() {
+			fs, _ := NewFs()
+			fs.RecordOp(RecentOpWrite, "/server.properties")
+			fs.RecordOp(RecentOpUpload, "plugins/a.jar", "plugins/b.jar")
+
+			ops := fs.RecentOps()
+			g.Assert(len(ops)).Equal(3)
+			g.Assert(ops[0].Path).Equal("plugins/b.jar")
+			g.Assert(ops[2].Path).Equal("server.properties")
+			g.Assert(ops[2].Op).Equal(RecentOpWrite)
+		})
+
+		g.It("only keeps the latest operation on each path", func() {
+			fs, _ := NewFs()
+			fs.RecordOp(RecentOpWrite, "world/level.dat")
+			fs.RecordOp(RecentOpWrite, "server.properties")
+			fs.RecordOp(RecentOpDelete, "/world/level.dat")
+
+			ops := fs.RecentOps()
+			g.Assert(len(ops)).Equal(2)
+			g.Assert(ops[0]).Equal(RecentOp{Op: RecentOpDelete, Path: "world/level.dat", Time: ops[0].Time})
+		})
+
+		g.It("drops the oldest entries once full", func() {
+			fs, _ := NewFs()
+			for i := 0; i < recentOpsLimit+10; i++ {
+				fs.RecordOp(RecentOpWrite, "file-"+strconv.Itoa(i))
+			}
+
+			ops := fs.RecentOps()
+			g.Assert(len(ops)).Equal(recentOpsLimit)
+			g.Assert(ops[len(ops)-1].Path).Equal("file-10")
+		})
+
+		g.It("returns the paths within a directory that were not deleted", func() {
+			fs, _ := NewFs()
+			fs.RecordOp(RecentOpExtract, "plugins/a.jar", "plugins/b.jar", "server.properties")
+			fs.RecordOp(RecentOpDelete, "plugins/b.jar")
+
+			g.Assert(fs.recentPaths("/plugins")).Equal([]string{"a.jar"})
+			g.Assert(fs.recentPaths("/")).Equal([]string{"plugins/a.jar", "server.properties"})
+		})
+	})
+}
@@ -0,0 +1,188 @@
+package filesystem
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+// ErrReplaceMismatch is returned when the file being edited does not contain the
+// number of matches that the caller expected it to, which usually means that it
+// was changed since the caller last read it.
+var ErrReplaceMismatch = errors.Sentinel("filesystem: file does not contain the expected number of matches")
+
+// maxReplaceFileSize is the largest file that can be edited with ReplaceInFile,
+// the whole file is held in memory while it is edited.
+const maxReplaceFileSize = 8 * 1024 * 1024
+
+// ReplaceOptions controls what is replaced in a file by ReplaceInFile.
+type ReplaceOptions struct {
+	// The text to look for. If Regex is set this is a regular expression, and the
+	// replacement may refer to its groups, such as "$1".
+	Search  string
+	Replace string
+	Regex   bool
+	// Which match to replace, starting from 1. If 0 every match is replaced.
+	Occurrence int
+	// If set, nothing is replaced unless the file contains exactly this many
+	// matches, otherwise ErrReplaceMismatch is returned.
+	ExpectedMatches *int
+}
+
+// ReplaceResult is the outcome of replacing text within a file.
+type ReplaceResult struct {
+	// The number of matches found in the file.
+	Matches int `json:"matches"`
+	// The number of matches that were replaced.
+	Replacements int `json:"replacements"`
+	// A unified diff of the changes made to the file, empty if nothing changed.
+	Diff string `json:"diff"`
+}
+
+// ReplaceInFile replaces one or every match of the search in the file at the
+// given path. The edited contents are written to a hidden file in the same
+// directory which is then renamed over the original, so the file is never seen
+// partially written. The mode of the file is kept.
+func (fs *Filesystem) ReplaceInFile(p string, opts ReplaceOptions) (*ReplaceResult, error) {
+	if opts.Search == "" {
+		return nil, errors.New("server/filesystem: replace: search must not be empty")
+	}
+	var re *regexp.Regexp
+	if opts.Regex {
+		var err error
+		if re, err = regexp.Compile(opts.Search); err != nil {
+			return nil, errors.Wrap(err, "server/filesystem: replace: invalid regular expression")
+		}
+	}
+
+	before, mode, err := fs.readEditableFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches [][]int
+	if re != nil {
+		matches = re.FindAllSubmatchIndex(before, -1)
+	} else {
+		q := []byte(opts.Search)
+		for i := 0; ; {
+			j := bytes.Index(before[i:], q)
+			if j < 0 {
+				break
+			}
+			matches = append(matches, []int{i + j, i + j + len(q)})
+			i += j + len(q)
+		}
+	}
+
+	out := &ReplaceResult{Matches: len(matches)}
+	if opts.ExpectedMatches != nil && *opts.ExpectedMatches != len(matches) {
+		return out, errors.WithStack(ErrReplaceMismatch)
+	}
+	if opts.Occurrence > len(matches) {
+		return out, nil
+	}
+	if opts.Occurrence > 0 {
+		matches = matches[opts.Occurrence-1 : opts.Occurrence]
+	}
+	if len(matches) == 0 {
+		return out, nil
+	}
+
+	after := make([]byte, 0, len(before))
+	var last int
+	for _, m := range matches {
+		after = append(after, before[last:m[0]]...)
+		if re != nil {
+			after = re.Expand(after, []byte(opts.Replace), before, m)
+		} else {
+			after = append(after, opts.Replace...)
+		}
+		last = m[1]
+	}
+	after = append(after, before[last:]...)
+	out.Replacements = len(matches)
+
+	if bytes.Equal(before, after) {
+		return out, nil
+	}
+	if err := fs.writeEditedFile(p, before, after, mode); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	out.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(before)),
+		B:        difflib.SplitLines(string(after)),
+		FromFile: "a/" + name,
+		ToFile:   "b/" + name,
+		Context:  3,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "server/filesystem: replace: failed to generate diff")
+	}
+	return out, nil
+}
+
+// readEditableFile reads the whole of the regular file at the given path so that
+// it can be edited, returning its contents and permissions. Files larger than
+// maxReplaceFileSize cannot be edited.
+func (fs *Filesystem) readEditableFile(p string) ([]byte, ufs.FileMode, error) {
+	st, err := fs.unixFS.Lstat(p)
+	if err != nil {
+		return nil, 0, err
+	}
+	if st.IsDir() {
+		return nil, 0, errors.WithStack(&Error{code: ErrCodeIsDirectory, resolved: p})
+	}
+	if !st.Mode().IsRegular() {
+		return nil, 0, errors.WithStack(&Error{code: ErrCodeSymlink, resolved: p})
+	}
+	if st.Size() > maxReplaceFileSize {
+		return nil, 0, errors.WithStack(&Error{code: ErrCodeTooLarge, resolved: p})
+	}
+
+	f, err := fs.unixFS.Open(p)
+	if err != nil {
+		return nil, 0, err
+	}
+	b, err := io.ReadAll(io.LimitReader(f, maxReplaceFileSize+1))
+	f.Close()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "server/filesystem: replace: failed to read file")
+	}
+	if len(b) > maxReplaceFileSize {
+		return nil, 0, errors.WithStack(&Error{code: ErrCodeTooLarge, resolved: p})
+	}
+	return b, st.Mode().Perm(), nil
+}
+
+// writeEditedFile replaces the contents of the file at the given path, which
+// were before, with after. The new contents are written to a hidden file in the
+// same directory which is then renamed over the original.
+func (fs *Filesystem) writeEditedFile(p string, before, after []byte, mode ufs.FileMode) error {
+	// Both the existing file and the edited one are on the disk until the rename.
+	if err := fs.HasSpaceFor(int64(len(after))); err != nil {
+		return err
+	}
+	t, err := fs.createTempFile(path.Dir(p), mode)
+	if err != nil {
+		return err
+	}
+	defer t.cleanup()
+	if _, err := t.Write(after); err != nil {
+		return errors.Wrap(err, "server/filesystem: replace: failed to write file")
+	}
+	if err := t.commit(p); err != nil {
+		return err
+	}
+	fs.unixFS.Add(int64(len(after) - len(before)))
+	return nil
+}
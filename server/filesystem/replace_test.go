@@ -0,0 +1,81 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_ReplaceInFile(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	read := func(p string) string {
+		b, err := os.ReadFile(filepath.Join(rfs.root, "server", p))
+		g.Assert(err).IsNil()
+		return string(b)
+	}
+
+	g.Describe("ReplaceInFile", func() {
+		g.BeforeEach(func() {
+			_ = rfs.CreateServerFileFromString("server.properties", "pvp=true\nmotd=hello\nallow-nether=true\n")
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("replaces every match", func() {
+			res, err := fs.ReplaceInFile("server.properties", ReplaceOptions{Search: "true", Replace: "false"})
+			g.Assert(err).IsNil()
+			g.Assert(res.Matches).Equal(2)
+			g.Assert(res.Replacements).Equal(2)
+			g.Assert(read("server.properties")).Equal("pvp=false\nmotd=hello\nallow-nether=false\n")
+			g.Assert(strings.Contains(res.Diff, "-pvp=true\n+pvp=false\n")).IsTrue()
+		})
+
+		g.It("replaces only the requested occurrence", func() {
+			res, err := fs.ReplaceInFile("server.properties", ReplaceOptions{Search: "true", Replace: "false", Occurrence: 2})
+			g.Assert(err).IsNil()
+			g.Assert(res.Replacements).Equal(1)
+			g.Assert(read("server.properties")).Equal("pvp=true\nmotd=hello\nallow-nether=false\n")
+		})
+
+		g.It("expands groups when using a regular expression", func() {
+			res, err := fs.ReplaceInFile("server.properties", ReplaceOptions{Search: `motd=(\w+)`, Replace: "motd=${1} world", Regex: true})
+			g.Assert(err).IsNil()
+			g.Assert(res.Replacements).Equal(1)
+			g.Assert(read("server.properties")).Equal("pvp=true\nmotd=hello world\nallow-nether=true\n")
+		})
+
+		g.It("does not change the file if the match count is not what was expected", func() {
+			expected := 1
+			res, err := fs.ReplaceInFile("server.properties", ReplaceOptions{Search: "true", Replace: "false", ExpectedMatches: &expected})
+			g.Assert(errors.Is(err, ErrReplaceMismatch)).IsTrue()
+			g.Assert(res.Matches).Equal(2)
+			g.Assert(read("server.properties")).Equal("pvp=true\nmotd=hello\nallow-nether=true\n")
+		})
+
+		g.It("keeps the mode of the file", func() {
+			g.Assert(os.Chmod(filepath.Join(rfs.root, "server/server.properties"), 0o600)).IsNil()
+
+			_, err := fs.ReplaceInFile("server.properties", ReplaceOptions{Search: "hello", Replace: "hi"})
+			g.Assert(err).IsNil()
+			st, err := os.Stat(filepath.Join(rfs.root, "server/server.properties"))
+			g.Assert(err).IsNil()
+			g.Assert(st.Mode().Perm()).Equal(os.FileMode(0o600))
+		})
+
+		g.It("does not leave a temporary file behind", func() {
+			_, err := fs.ReplaceInFile("server.properties", ReplaceOptions{Search: "hello", Replace: "hi"})
+			g.Assert(err).IsNil()
+			entries, err := os.ReadDir(filepath.Join(rfs.root, "server"))
+			g.Assert(err).IsNil()
+			g.Assert(len(entries)).Equal(1)
+		})
+	})
+}
@@ -0,0 +1,49 @@
+package filesystem
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/kristiangarcia/wings/config"
+)
+
+// retryBackoff is how long to wait before the first retry of an operation that
+// failed with a transient error, each further retry waits twice as long.
+const retryBackoff = 25 * time.Millisecond
+
+// isTransient returns true if the error is one that network backed storage,
+// such as NFS or Ceph, can return temporarily, so the same operation may succeed
+// if it is tried again. Errors such as a file not existing are permanent.
+func isTransient(err error) bool {
+	for _, errno := range []unix.Errno{unix.EIO, unix.EAGAIN, unix.EINTR, unix.ETIMEDOUT, unix.ESTALE, unix.EBUSY} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryTransient calls fn until it succeeds, fails with an error that is not
+// transient, or the configured number of retries has been used up, waiting
+// longer between each attempt. Waiting stops early if the context is canceled.
+func retryTransient[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	retries := config.Get().Filesystem.TransientRetries
+	wait := retryBackoff
+	for attempt := 0; ; attempt++ {
+		v, err := fn()
+		if err == nil || attempt >= retries || !isTransient(err) {
+			return v, err
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return v, err
+		case <-t.C:
+		}
+		wait *= 2
+	}
+}
@@ -0,0 +1,72 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+	"golang.org/x/sys/unix"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+func TestRetryTransient(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("retryTransient", func() {
+		g.BeforeEach(func() {
+			NewFs()
+			config.Update(func(c *config.Configuration) {
+				c.Filesystem.TransientRetries = 2
+			})
+		})
+
+		g.It("retries transient errors until the operation succeeds", func() {
+			var calls int
+			v, err := retryTransient(context.Background(), func() (int, error) {
+				calls++
+				if calls < 3 {
+					return 0, &ufs.PathError{Op: "stat", Path: "file", Err: unix.EIO}
+				}
+				return 42, nil
+			})
+			g.Assert(err).IsNil()
+			g.Assert(v).Equal(42)
+			g.Assert(calls).Equal(3)
+		})
+
+		g.It("gives up once the retries are used up", func() {
+			var calls int
+			_, err := retryTransient(context.Background(), func() (int, error) {
+				calls++
+				return 0, errors.WithStack(unix.EAGAIN)
+			})
+			g.Assert(errors.Is(err, unix.EAGAIN)).IsTrue()
+			g.Assert(calls).Equal(3)
+		})
+
+		g.It("does not retry permanent errors", func() {
+			var calls int
+			_, err := retryTransient(context.Background(), func() (int, error) {
+				calls++
+				return 0, ufs.ErrNotExist
+			})
+			g.Assert(errors.Is(err, ufs.ErrNotExist)).IsTrue()
+			g.Assert(calls).Equal(1)
+		})
+
+		g.It("stops waiting when the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			var calls int
+			_, err := retryTransient(ctx, func() (int, error) {
+				calls++
+				return 0, unix.EIO
+			})
+			g.Assert(err).IsNotNil()
+			g.Assert(calls).Equal(1)
+		})
+	})
+}
@@ -0,0 +1,1575 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/juju/ratelimit"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/internal/redact"
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+// ErrSearchDisallowed is returned when the root of a search is within one of the
+// directories that the configuration does not allow to be searched.
+var ErrSearchDisallowed = errors.Sentinel("filesystem: searching this directory is not allowed")
+
+// SearchOptions defines the parameters for a search against the server
+// filesystem.
+type SearchOptions struct {
+	// The directory to search within, relative to the server root.
+	Root string
+	// The text to look for in file names, and optionally file contents. A file is
+	// matched if it contains any one of the queries.
+	Queries []string
+	// An optional list of paths, relative to Root, to search instead of walking
+	// the entire directory tree.
+	Paths []string
+	// If true, the contents of text files will also be searched for the query.
+	IncludeContent bool
+	// The maximum number of results to return.
+	Limit int
+	// Files larger than this size (in bytes) will only have their names matched
+	// against the query, their contents will not be searched.
+	MaxSize int64
+	// If greater than zero, up to this many bytes from the start of each matched
+	// text file will be included in the result.
+	PreviewBytes int
+	// The maximum number of results across the entire search that can include
+	// content from the file, once reached any further results are returned
+	// without it. A value of 0 means there is no limit.
+	MaxMatches int
+	// If true, at most one file is matched in each directory and the remaining
+	// files in a directory are skipped once one of them has matched.
+	FirstPerDir bool
+	// The fields to include in each result, using their JSON names. The name of
+	// the file is always included. If empty, every field is included. Fields that
+	// require the file to be opened, such as the mimetype, are only computed when
+	// they are requested.
+	Fields []string
+	// If true, comments are removed from the contents of files before they are
+	// searched. This is a best-effort heuristic that only applies to files with an
+	// extension listed in commentStyles, other files are searched as normal.
+	IgnoreComments bool
+	// If true, the directory tree is walked breadth-first so that files closer to
+	// the root are searched before those nested deeper, rather than finishing each
+	// directory before moving on to the next.
+	BreadthFirst bool
+	// If true, only files that were recently changed through Wings are searched,
+	// see Filesystem.RecentOps. If Paths is also set only the paths found in both
+	// are searched.
+	RecentOpsOnly bool
+	// If true, the queries are glob patterns matched against the path of each file
+	// relative to Root, see matchGlob. File contents are never searched in this
+	// mode.
+	Glob bool
+	// If true, the queries are hex encoded SHA-256 hashes and files are matched if
+	// the hash of their contents is equal to one of them, see ValidateHash. Names
+	// are not matched in this mode, and files larger than MaxSize are never hashed
+	// as their contents would not be searched either.
+	Hash bool
+	// If set in hash mode, only files of exactly this size are hashed.
+	Size int64
+	// If true, results matched by their contents include a snippet of the line
+	// that the first match was found on.
+	Snippets bool
+	// The most bytes of a line that are included in a snippet. Longer lines, such
+	// as those in minified files or single line JSON logs, are cut down to the part
+	// around the match and marked as truncated. If not greater than zero
+	// defaultMaxLineBytes is used.
+	MaxLineBytes int
+	// If true, snippets also include the exact bytes of the line, since any
+	// invalid UTF-8 in the text is replaced when it is encoded as JSON.
+	RawSnippets bool
+	// If true, only symlinks whose target does not exist, or is outside of the
+	// server directory, are matched. The queries are optional in this mode, if any
+	// are given only links with a name containing one of them are matched. Links
+	// are never followed to check their target regardless of the symlink policy.
+	BrokenSymlinks bool
+	// If true, symlinks are matched by their target as well as their name, so
+	// that searching for a path finds the links pointing to it. The target is the
+	// text of the link as it was created, and the link is returned itself with
+	// its target in the results. Links are never followed in this mode regardless
+	// of the symlink policy, so the other filters are checked against the link
+	// itself and no links are matched if Executable or Xattrs are set.
+	SymlinkTargets bool
+	// If true, like find -xdev the search does not descend into directories that
+	// are on a different filesystem to the root, such as a mounted backup volume
+	// or bind mount, and files on a different filesystem are never matched.
+	OneFilesystem bool
+	// Glob patterns for files and directories that are skipped, see matchGlob.
+	// Unlike the queries in glob mode these are matched against the path of each
+	// file relative to the server root, not the search root, and a directory that
+	// is matched is not entered at all.
+	Excludes []string
+	// If true, files matched by their contents are read to the end, up to MaxSize,
+	// to count how many times the queries appear in them, rather than stopping at
+	// the first match. Counting stops once MaxCount is reached.
+	CountMatches bool
+	// The most occurrences counted in each file, if not greater than zero
+	// defaultMaxCount is used.
+	MaxCount int
+	// The order that results are returned in, one of the SearchSort values. The
+	// results of an export are never sorted.
+	Sort string
+	// The Disk I/O read limit, in bytes per second, for reading the contents of
+	// files to match or preview them. The limit is shared by every worker of the
+	// search, if it is 0 there is no limit.
+	ReadLimit int64
+	// If set, only files with extended attributes matching every one of these
+	// filters are matched. Checking them costs extra system calls for every file,
+	// so they are only read when a filter is given.
+	Xattrs []XattrFilter
+	// If true, only regular files with at least one of the owner, group or other
+	// execute bits set in their mode are matched. Symlinks are matched by the mode
+	// of their target.
+	Executable bool
+	// If true, the search uses fewer workers and smaller buffers so that it can
+	// safely run on nodes with very little memory, at the cost of being slower.
+	// Callers should also leave out previews and snippets, and stream the results
+	// with SearchStream rather than collecting them with Search.
+	LowMemory bool
+	// How the name of each result is written, one of the SearchPath values.
+	PathStyle string
+	// If true, the queries and the names of files are converted to the NFC
+	// Unicode normal form before they are matched, so that a name with accented
+	// characters written in decomposed form (as macOS does) still matches a query
+	// typed in composed form. The names of results are also returned in NFC, so
+	// they may not be byte for byte the same as the name on the disk. File
+	// contents are never normalized, so they are matched against the queries
+	// as they were given.
+	NormalizeUnicode bool
+	// If true, results for files with exactly the same contents are collapsed into
+	// the first of them, with the names of the others listed in its Copies. This
+	// hashes every matched file that is the same size as another, and is applied
+	// after the limit, so fewer results than the limit may be returned. Only
+	// supported by Search, since every result needs to be known first.
+	Dedupe bool
+	// Glob patterns for protected files, matched in the same way as Excludes.
+	// Protected files can be matched by name and are flagged as protected in the
+	// results, but their contents are never searched or included in a preview or
+	// snippet. Searching them would let their contents be guessed one query at a
+	// time even without ever returning them.
+	// For the same reason they are never matched by hash or collapsed as
+	// duplicates of other files.
+	Protected []string
+	// If set, files with these IDs are never matched, see Server.OpenFiles. This
+	// is used to leave out the files that the server process currently has open.
+	SkipFiles map[FileID]struct{}
+}
+
+// The orders that search results can be sorted in.
+const (
+	// SearchSortName sorts results alphabetically with directories first, this
+	// is the default.
+	SearchSortName = ""
+	// SearchSortMatches sorts results by the number of times their contents
+	// matched, most first, and then by name. Only useful when counting matches.
+	SearchSortMatches = "matches"
+	// SearchSortNone returns results in the order they were found, which is not
+	// the same between searches since files are searched by many workers at once.
+	// Nothing needs to be collected to sort it, so the results can be streamed
+	// as they are found.
+	SearchSortNone = "none"
+)
+
+// The styles that the names of search results can be written in.
+const (
+	// SearchPathRelative names results by their path relative to the root of the
+	// search, this is the default.
+	SearchPathRelative = ""
+	// SearchPathRooted names results by their path relative to the root of the
+	// server directory, including the root of the search.
+	SearchPathRooted = "rooted"
+)
+
+// defaultMaxCount is the most occurrences of the queries counted in each file
+// when no other limit is given.
+const defaultMaxCount = 1000
+
+// defaultMaxLineBytes is the most bytes of a line included in a search snippet
+// when no other limit is given.
+const defaultMaxLineBytes = 1024
+
+// SearchFields are the fields of a SearchResult that can be requested.
+var SearchFields = []string{
+	"name", "created", "birthtime", "changed", "accessed", "modified", "mode",
+	"mode_bits", "size", "directory", "file", "symlink", "mime", "query",
+	"writable", "preview", "locked_by", "snippet", "matches", "link_target",
+}
+
+// SearchResult is a single file matched by a search.
+type SearchResult struct {
+	Name      string     `json:"name"`
+	Created   time.Time  `json:"created"`
+	Birthtime *time.Time `json:"birthtime,omitempty"`
+	Changed   time.Time  `json:"changed"`
+	Accessed  time.Time  `json:"accessed"`
+	Modified  time.Time  `json:"modified"`
+	Mode      string     `json:"mode"`
+	ModeBits  string     `json:"mode_bits"`
+	Size      int64      `json:"size"`
+	Directory bool       `json:"directory"`
+	File      bool       `json:"file"`
+	Symlink   bool       `json:"symlink"`
+	Mime      string     `json:"mime"`
+	// Where the symlink points, only included for links that were not followed.
+	LinkTarget string `json:"link_target,omitempty"`
+	// The query that this file was matched by.
+	Query string `json:"query"`
+	// Whether the file can be modified, based on the server's file denylist.
+	Writable bool `json:"writable"`
+	// Whether the file is protected, in which case none of its contents are
+	// included.
+	Protected bool `json:"protected,omitempty"`
+	// The start of the file contents, only included if a preview was requested.
+	Preview *string `json:"preview,omitempty"`
+	// The owner of the advisory lock held on the file, if it is locked.
+	LockedBy string `json:"locked_by,omitempty"`
+	// The line that the contents of the file were matched on, only included if
+	// snippets were requested.
+	Snippet *SearchSnippet `json:"snippet,omitempty"`
+	// The number of times the queries appear in the contents of the file, only
+	// included if matches were counted and the contents were matched.
+	Matches int `json:"matches,omitempty"`
+	// The names of the other matched files with exactly the same contents as this
+	// one, only included if duplicates were collapsed.
+	Copies []string `json:"copies,omitempty"`
+
+	// The fields to include when encoding the result, if nil every field is
+	// included.
+	fields []string
+	// The path of the file on the disk that the result is for.
+	path string
+}
+
+// SearchSnippet is the part of a file around the first place its contents were
+// matched. Positions are byte offsets from the start of the file, or of the
+// decompressed contents for a compressed file. When comments are ignored the
+// offsets only count the content that was searched, the line is still correct.
+type SearchSnippet struct {
+	// The line number that the match was found on, starting from 1.
+	Line int64 `json:"line"`
+	// The position of the match.
+	Offset int64 `json:"offset"`
+	// The line containing the match, without its line ending.
+	Text string `json:"text"`
+	// The bytes of Text exactly as they are in the file, encoded as base64. Only
+	// included if raw snippets were requested.
+	Raw []byte `json:"raw,omitempty"`
+	// The position that Text starts at.
+	TextOffset int64 `json:"text_offset"`
+	// Whether Text is only part of the line because the line is longer than the
+	// maximum that can be returned.
+	Truncated bool `json:"truncated"`
+}
+
+// MarshalJSON encodes the result, leaving out any fields that were not
+// requested by the search.
+func (r SearchResult) MarshalJSON() ([]byte, error) {
+	type result SearchResult
+	b, err := json.Marshal(result(r))
+	if err != nil || r.fields == nil {
+		return b, err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for k := range m {
+		if k != "name" && k != "copies" && !slices.Contains(r.fields, k) {
+			delete(m, k)
+		}
+	}
+	return json.Marshal(m)
+}
+
+// The reasons a search may be reported as incomplete.
+const (
+	SearchReasonLimit    = "limit"
+	SearchReasonTimeout  = "timeout"
+	SearchReasonCanceled = "canceled"
+	SearchReasonError    = "error"
+)
+
+// SearchStats contains details about the work that was performed by a search.
+type SearchStats struct {
+	// The number of files that were checked against the query.
+	FilesVisited int64
+	// The number of bytes read from files while searching their contents.
+	BytesRead int64
+	Duration  time.Duration
+	// The number of workers that were used to search files.
+	Workers int
+	// Whether the files to search were taken from the filesystem index rather
+	// than walking the disk.
+	Indexed bool
+}
+
+// SearchResults is the outcome of a search. If the search stopped before every
+// candidate file was checked Complete will be false and Reason will explain why.
+type SearchResults struct {
+	Results  []SearchResult `json:"results"`
+	Complete bool           `json:"complete"`
+	Reason   string         `json:"reason,omitempty"`
+	// Describes why the search stopped early if it was because of an error, in
+	// which case only the results found before the error are included.
+	Warning string `json:"warning,omitempty"`
+	// Whether the maximum number of matches was reached, meaning that some results
+	// do not include the content from their file.
+	MatchesCapped bool        `json:"matches_capped"`
+	Stats         SearchStats `json:"-"`
+}
+
+// searcher holds the state shared between the workers of a single search.
+type searcher struct {
+	fs   *Filesystem
+	opts SearchOptions
+	// The lowercase queries matched against the contents of files.
+	queries []string
+	// The lowercase queries matched against names, which are also normalized if
+	// the search normalizes names.
+	nameQueries []string
+	// The lowercase search root without any leading or trailing slashes, used to
+	// make paths relative to it when matching globs.
+	root string
+	// The device that the search root is on, only set when the search stays on one
+	// filesystem.
+	dev uint64
+	// The directories that cannot be searched, without any leading or trailing
+	// slashes.
+	disallowed []string
+	// The lowercase exclude and protected file patterns.
+	excludes  []string
+	protected []string
+	// The lowercase file extensions whose contents are the only ones searched,
+	// and those whose contents are never searched.
+	contentExts     []string
+	skipContentExts []string
+	// The token bucket that limits how quickly files are read, nil if there is no
+	// limit.
+	bucket *ratelimit.Bucket
+	// Removes secrets from any file contents included in the results.
+	redactor *redact.Redactor
+	// Stats the files that are searched, following any symlinks.
+	stat func(p string) (ufs.FileInfo, error)
+
+	mu      sync.Mutex
+	results []SearchResult
+	// When exporting, results are encoded directly to the output rather than
+	// being collected in memory.
+	out     *bufio.Writer
+	outErr  error
+	exclude string
+	// If set, results are passed to this as they are found rather than being
+	// collected in memory.
+	onResult func(SearchResult)
+
+	count     atomic.Int32
+	truncated atomic.Bool
+	visited   atomic.Int64
+	bytesRead atomic.Int64
+	// The number of results that have included content from their file.
+	matches atomic.Int32
+	capped  atomic.Bool
+	// The directories that already contain a match, used when only the first
+	// match in each directory is wanted.
+	dirs sync.Map
+}
+
+var (
+	searchWorkersOnce sync.Once
+	searchWorkers     *semaphore.Weighted
+)
+
+// searchWorkerPool returns the pool of search workers shared by every server on
+// the node, or nil if no limit has been configured.
+func searchWorkerPool() *semaphore.Weighted {
+	searchWorkersOnce.Do(func() {
+		if n := config.Get().Filesystem.MaxSearchWorkers; n > 0 {
+			searchWorkers = semaphore.NewWeighted(int64(n))
+		}
+	})
+	return searchWorkers
+}
+
+// acquireSearchWorkers reserves up to n workers from the shared pool, waiting
+// until at least one of them is available. The returned function must be called
+// to release the workers once the search has finished.
+func acquireSearchWorkers(ctx context.Context, n int) (int, func(), error) {
+	pool := searchWorkerPool()
+	if pool == nil {
+		return n, func() {}, nil
+	}
+	if err := pool.Acquire(ctx, 1); err != nil {
+		return 0, func() {}, err
+	}
+	acquired := 1
+	for acquired < n && pool.TryAcquire(1) {
+		acquired++
+	}
+	return acquired, func() { pool.Release(int64(acquired)) }, nil
+}
+
+// Search walks the server filesystem starting at the root defined in the
+// options and returns any files whose name (or contents, if enabled) contain
+// any of the queries. If a list of paths is provided those files are searched directly
+// and no walk is performed.
+//
+// Results are sorted alphabetically with directories first.
+func (fs *Filesystem) Search(ctx context.Context, opts SearchOptions) (*SearchResults, error) {
+	s := fs.newSearcher(opts)
+	s.results = make([]SearchResult, 0, min(50, opts.Limit))
+
+	out, err := s.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := s.results
+	if opts.Sort != SearchSortNone {
+		sortSearchResults(results)
+	}
+
+	// Duplicates are collapsed after sorting by name so that the same one of them
+	// is always the one that is kept.
+	if opts.Dedupe {
+		var read int64
+		results, read = s.dedupe(ctx, results)
+		out.Stats.BytesRead += read
+	}
+
+	if opts.Sort == SearchSortMatches {
+		slices.SortStableFunc(results, func(a, b SearchResult) int {
+			return b.Matches - a.Matches
+		})
+	}
+
+	out.Results = results
+	return out, nil
+}
+
+// sortSearchResults sorts results alphabetically with directories first.
+func sortSearchResults(results []SearchResult) {
+	slices.SortStableFunc(results, func(a, b SearchResult) int {
+		switch {
+		case a.Name == b.Name:
+			return 0
+		case a.Name > b.Name:
+			return 1
+		default:
+			return -1
+		}
+	})
+
+	slices.SortStableFunc(results, func(a, b SearchResult) int {
+		switch {
+		case a.Directory && b.Directory:
+			return 0
+		case a.Directory:
+			return -1
+		default:
+			return 1
+		}
+	})
+}
+
+// SearchStream performs the same search as Search but rather than returning the
+// results they are written to w as a JSON array. Results are written as they are
+// found so that memory use stays bounded no matter how many files are matched,
+// which means they are not sorted. The returned results will not contain any
+// entries, only the number of results that were written is returned. Nothing is
+// written to w if the search cannot be started, and if it fails once results
+// have been written the array is still closed so that w holds valid JSON.
+func (fs *Filesystem) SearchStream(ctx context.Context, opts SearchOptions, w io.Writer) (*SearchResults, int, error) {
+	return fs.searchTo(ctx, opts, w, "")
+}
+
+// SearchFunc performs the same search as Search but passes each result to fn as
+// it is found, in the order they are found, rather than returning them. Calls
+// to fn are never made concurrently. The returned results will not contain any
+// entries, only the number of results that were found is returned.
+func (fs *Filesystem) SearchFunc(ctx context.Context, opts SearchOptions, fn func(SearchResult)) (*SearchResults, int, error) {
+	s := fs.newSearcher(opts)
+	s.onResult = fn
+	out, err := s.run(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, int(s.count.Load()), nil
+}
+
+// searchTo streams the results of a search to w, never matching the file at the
+// exclude path since it is the one being written to.
+func (fs *Filesystem) searchTo(ctx context.Context, opts SearchOptions, w io.Writer, exclude string) (*SearchResults, int, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	s := fs.newSearcher(opts)
+	s.out = bw
+	s.exclude = exclude
+
+	_, _ = bw.WriteString("[\n")
+	out, err := s.run(ctx)
+	if err == nil {
+		_, _ = bw.WriteString("\n]\n")
+		err = bw.Flush()
+	} else if cw.n > 0 {
+		_, _ = bw.WriteString("\n]\n")
+		_ = bw.Flush()
+	}
+	if err == nil {
+		err = s.outErr
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, int(s.count.Load()), nil
+}
+
+// SearchExport performs the same search as SearchStream but writes the results
+// to the file at the given path within the server directory. The file is only
+// replaced once the search has finished, so a search that fails or is canceled
+// leaves any existing file as it was. The export counts toward the disk limit of
+// the server, and fails if writing it would go over the limit.
+func (fs *Filesystem) SearchExport(ctx context.Context, opts SearchOptions, p string) (*SearchResults, int, error) {
+	if err := fs.IsIgnored(p); err != nil {
+		return nil, 0, err
+	}
+	var currentSize int64
+	st, err := fs.unixFS.Lstat(p)
+	if err != nil && !errors.Is(err, ufs.ErrNotExist) {
+		return nil, 0, errors.Wrap(err, "server/filesystem: search: failed to stat export file")
+	} else if err == nil {
+		if st.IsDir() {
+			return nil, 0, errors.WithStack(&Error{code: ErrCodeIsDirectory, resolved: p})
+		}
+		if st.Mode()&ufs.ModeSymlink != 0 {
+			return nil, 0, errors.WithStack(&Error{code: ErrCodeSymlink, resolved: p})
+		}
+		currentSize = st.Size()
+	}
+
+	t, err := fs.createTempFile(path.Dir(path.Clean("/"+p)), 0o644)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer t.cleanup()
+
+	w := &quotaWriter{fs: fs, w: t, current: currentSize}
+	out, count, err := fs.searchTo(ctx, opts, w, strings.TrimPrefix(path.Clean(p), "/"))
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := t.commit(p); err != nil {
+		return nil, 0, err
+	}
+
+	// Adjust the disk usage to account for the old size and the new size of the file.
+	fs.unixFS.Add(w.n - currentSize)
+	return out, count, nil
+}
+
+// countingWriter tracks the number of bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// quotaWriter tracks the number of bytes written to a file that will replace
+// one of the given size, and fails before writing anything that would take the
+// server over its disk limit.
+type quotaWriter struct {
+	fs      *Filesystem
+	w       io.Writer
+	n       int64
+	current int64
+}
+
+func (q *quotaWriter) Write(p []byte) (int, error) {
+	if err := q.fs.HasSpaceFor(q.n + int64(len(p)) - q.current); err != nil {
+		return 0, err
+	}
+	n, err := q.w.Write(p)
+	q.n += int64(n)
+	return n, err
+}
+
+func (fs *Filesystem) newSearcher(opts SearchOptions) *searcher {
+	s := &searcher{fs: fs, opts: opts}
+	s.root = s.normalize(strings.ToLower(strings.Trim(path.Clean("/"+opts.Root), "/")))
+	s.stat = fs.unixFS.Stat
+	for _, q := range opts.Queries {
+		s.queries = append(s.queries, strings.ToLower(q))
+		s.nameQueries = append(s.nameQueries, s.normalize(strings.ToLower(q)))
+	}
+	for _, e := range opts.Excludes {
+		if e = strings.ToLower(strings.Trim(e, "/")); e != "" {
+			s.excludes = append(s.excludes, e)
+		}
+	}
+	for _, e := range opts.Protected {
+		if e = strings.ToLower(strings.Trim(e, "/")); e != "" {
+			s.protected = append(s.protected, e)
+		}
+	}
+	if opts.ReadLimit > 0 {
+		s.bucket = ratelimit.NewBucketWithRate(float64(opts.ReadLimit), opts.ReadLimit)
+	}
+	r, err := redact.New(config.Get().System.RedactPatterns)
+	if err != nil {
+		log.WithField("error", err).Warn("ignoring invalid redaction patterns in configuration")
+	}
+	s.redactor = r
+	s.contentExts = normalizeExtensions(config.Get().Filesystem.SearchContentExtensions)
+	s.skipContentExts = normalizeExtensions(config.Get().Filesystem.SearchSkipContentExtensions)
+	for _, p := range config.Get().Filesystem.SearchDisallowedPaths {
+		if p = strings.Trim(path.Clean("/"+p), "/"); p != "" {
+			s.disallowed = append(s.disallowed, p)
+		}
+	}
+	return s
+}
+
+// isExcluded returns true if the given path, or any directory that it is within,
+// matches one of the exclude patterns of the search. The directories need to be
+// checked for paths that do not come from walking the disk, such as when using
+// the index.
+func (s *searcher) isExcluded(p string) bool {
+	return matchesPatterns(s.excludes, p)
+}
+
+// isProtected returns true if the given path, or any directory that it is
+// within, matches one of the protected file patterns of the search. The target
+// of a followed symlink is checked as well, so that linking to a protected file
+// does not make its contents searchable.
+func (s *searcher) isProtected(p, target string) bool {
+	return matchesPatterns(s.protected, p) || (target != "" && target != p && matchesPatterns(s.protected, target))
+}
+
+func matchesPatterns(patterns []string, p string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	p = strings.ToLower(strings.Trim(path.Clean("/"+p), "/"))
+	for ; p != "." && p != ""; p = path.Dir(p) {
+		for _, e := range patterns {
+			if matchGlob(e, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isDisallowed returns true if the given path is within one of the directories
+// that cannot be searched.
+func (s *searcher) isDisallowed(p string) bool {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	for _, d := range s.disallowed {
+		if p == d || strings.HasPrefix(p, d+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// run walks the filesystem and matches files using the configured workers. The
+// returned results only have their completeness and stats populated.
+func (s *searcher) run(ctx context.Context) (*SearchResults, error) {
+	start := time.Now()
+	fs, opts := s.fs, s.opts
+	if _, err := fs.resolve(opts.Root); err != nil {
+		return nil, err
+	}
+	if s.isDisallowed(opts.Root) {
+		return nil, errors.WithStack(ErrSearchDisallowed)
+	}
+	if opts.OneFilesystem {
+		st, err := fs.unixFS.Stat(opts.Root)
+		if err != nil {
+			return nil, err
+		}
+		s.dev, _ = deviceID(st)
+	}
+
+	workers, release, err := acquireSearchWorkers(ctx, s.workers())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var indexed bool
+	pending := make(chan string, s.pendingSize())
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.worker(ctx, pending)
+		}()
+	}
+
+	paths := opts.Paths
+	if opts.RecentOpsOnly {
+		recent := fs.recentPaths(opts.Root)
+		if paths != nil {
+			recent = slices.DeleteFunc(recent, func(p string) bool {
+				return !slices.Contains(paths, p)
+			})
+		}
+		paths = recent
+	}
+
+	if paths != nil {
+		for _, p := range paths {
+			if ctx.Err() != nil || s.full() {
+				s.truncated.Store(true)
+				break
+			}
+			p = path.Join(opts.Root, p)
+			if _, err := fs.resolve(path.Dir(p)); err != nil {
+				continue
+			}
+			pending <- p
+		}
+	} else if files, ok := s.indexed(); ok {
+		indexed = true
+		for _, p := range files {
+			if ctx.Err() != nil || s.full() {
+				s.truncated.Store(true)
+				break
+			}
+			p = path.Join(opts.Root, p)
+			// The name can be checked against the index directly, there is no need to
+			// stat files that cannot be matched.
+			if _, ok := s.match(strings.ToLower(p)); ok || opts.Hash || (opts.IncludeContent && !opts.Glob) {
+				pending <- p
+			}
+		}
+	} else {
+		walk := fs.unixFS.WalkDir
+		if opts.BreadthFirst {
+			walk = fs.walkBreadthFirst
+		}
+		err = walk(opts.Root, func(path string, d ufs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if s.isDisallowed(path) || s.isExcluded(path) || (opts.OneFilesystem && !s.sameDevice(path)) {
+					return ufs.SkipDir
+				}
+				return nil
+			}
+			// Returning io.EOF stops the walk early, there is still at least one
+			// file left that will not be searched.
+			if ctx.Err() != nil || s.full() {
+				s.truncated.Store(true)
+				return io.EOF
+			}
+			if s.dirMatched(path) {
+				return nil
+			}
+			pending <- path
+			return nil
+		})
+	}
+
+	close(pending)
+	wg.Wait()
+
+	out := &SearchResults{Complete: !s.truncated.Load(), MatchesCapped: s.capped.Load()}
+	if err != nil && err != io.EOF {
+		// Once something has been matched it is more useful to return it than to fail
+		// the entire search, so the error only fails the search if nothing was found.
+		if s.count.Load() == 0 {
+			return nil, err
+		}
+		fs.error(err).WithField("search_root", opts.Root).Warn("search stopped early after failing to walk directory")
+		out.Complete = false
+		out.Reason = SearchReasonError
+		out.Warning = "The search stopped early because part of the directory could not be read, only the results found before then are included."
+	} else if !out.Complete {
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			out.Reason = SearchReasonTimeout
+		case ctx.Err() != nil:
+			out.Reason = SearchReasonCanceled
+		default:
+			out.Reason = SearchReasonLimit
+		}
+	}
+
+	out.Stats = SearchStats{
+		FilesVisited: s.visited.Load(),
+		BytesRead:    s.bytesRead.Load(),
+		Duration:     time.Since(start),
+		Workers:      workers,
+		Indexed:      indexed,
+	}
+	return out, nil
+}
+
+// walkBreadthFirst is like WalkDir except that every entry in a directory is
+// visited before any of the directories nested within it. Returning SkipDir for
+// a directory prevents it from being walked.
+func (fs *Filesystem) walkBreadthFirst(root string, fn ufs.WalkDirFunc) error {
+	queue := []string{root}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		entries, err := fs.unixFS.ReadDir(dir)
+		if err != nil {
+			if err := fn(dir, nil, err); err != nil && err != ufs.SkipDir {
+				return err
+			}
+			continue
+		}
+		// Keep the same lexical order within each directory that WalkDir uses.
+		slices.SortFunc(entries, func(a, b ufs.DirEntry) int {
+			return strings.Compare(a.Name(), b.Name())
+		})
+		for _, e := range entries {
+			p := path.Join(dir, e.Name())
+			err := fn(p, e, nil)
+			if err == ufs.SkipDir {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if e.IsDir() {
+				queue = append(queue, p)
+			}
+		}
+	}
+	return nil
+}
+
+// sameDevice returns true if the directory at the given path is on the same
+// filesystem as the search root. A directory that cannot be checked is treated
+// as being on the same filesystem, so that the walk reports the error instead.
+func (s *searcher) sameDevice(p string) bool {
+	st, err := s.fs.unixFS.Lstat(p)
+	if err != nil {
+		return true
+	}
+	dev, ok := deviceID(st)
+	return !ok || dev == s.dev
+}
+
+// indexed returns the files within the search root from the filesystem index,
+// if the index is enabled and available.
+func (s *searcher) indexed() ([]string, bool) {
+	// The index does not record symlinks, so finding them always requires walking
+	// the disk.
+	if s.opts.BrokenSymlinks || s.opts.SymlinkTargets {
+		return nil, false
+	}
+	idx := s.fs.index()
+	if idx == nil {
+		return nil, false
+	}
+	files, ok := idx.Files(s.opts.Root)
+	if ok {
+		// Keep the same lexical order that walking the disk would produce.
+		slices.Sort(files)
+	}
+	return files, ok
+}
+
+// full returns true once the search has collected the maximum number of
+// results that were requested.
+func (s *searcher) full() bool {
+	return s.count.Load() >= int32(s.opts.Limit)
+}
+
+// wants returns true if the given field should be included in the results.
+func (s *searcher) wants(field string) bool {
+	return len(s.opts.Fields) == 0 || slices.Contains(s.opts.Fields, field)
+}
+
+// maxCount returns the most occurrences of the queries counted in each file.
+func (s *searcher) maxCount() int {
+	if s.opts.MaxCount > 0 {
+		return s.opts.MaxCount
+	}
+	return defaultMaxCount
+}
+
+// maxLineBytes returns the most bytes of a line to include in each snippet.
+func (s *searcher) maxLineBytes() int {
+	if s.opts.MaxLineBytes > 0 {
+		return s.opts.MaxLineBytes
+	}
+	return defaultMaxLineBytes
+}
+
+// dirMatched returns true if only the first match in each directory is wanted
+// and the directory containing the given path already has one.
+func (s *searcher) dirMatched(p string) bool {
+	if !s.opts.FirstPerDir {
+		return false
+	}
+	_, ok := s.dirs.Load(path.Dir(p))
+	return ok
+}
+
+// worker processes paths from the pending channel until it is closed.
+func (s *searcher) worker(ctx context.Context, pending <-chan string) {
+	m := newContentMatcher(s.queries, s.chunkSize())
+	if s.opts.Snippets {
+		m.lineContext = s.maxLineBytes() / 2
+	}
+
+	for p := range pending {
+		if s.full() {
+			s.truncated.Store(true)
+			continue
+		}
+		if !s.searchPath(ctx, p, m) {
+			// The matcher may have been left part way through a file by the panic, so
+			// a new one is used for the remaining paths.
+			m = newContentMatcher(s.queries, s.chunkSize())
+			if s.opts.Snippets {
+				m.lineContext = s.maxLineBytes() / 2
+			}
+		}
+	}
+}
+
+// searchPath checks a single path against the search, adding it to the results
+// if it matches. A panic while doing so, such as from a corrupt file triggering
+// a bug in a library, is recovered and logged so that it only skips this path
+// rather than crashing the daemon or leaving the search waiting forever on the
+// worker. Returns false if a panic was recovered.
+func (s *searcher) searchPath(ctx context.Context, p string, m *contentMatcher) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+			s.fs.error(fmt.Errorf("%v", r)).
+				WithField("path", p).
+				WithField("stack", string(debug.Stack())).
+				Error("recovered from panic while searching file")
+		}
+	}()
+
+	// Neither the file being exported to nor any file still being written is
+	// ever included.
+	if (s.exclude != "" && strings.TrimPrefix(path.Clean(p), "/") == s.exclude) || isTempFile(p) {
+		return true
+	}
+	if s.dirMatched(p) || s.isDisallowed(p) || s.isExcluded(p) {
+		return true
+	}
+
+	// Walking never descends into symlinked directories, so only the file itself
+	// needs to be checked against the symlink policy.
+	target := p
+	if st, err := retryTransient(ctx, func() (ufs.FileInfo, error) { return s.fs.unixFS.Lstat(p) }); err != nil {
+		return true
+	} else if s.opts.BrokenSymlinks {
+		if st.Mode()&ufs.ModeSymlink != 0 {
+			s.visited.Add(1)
+			s.matchBrokenSymlink(p)
+		}
+		return true
+	} else if st.Mode()&ufs.ModeSymlink != 0 {
+		if s.opts.SymlinkTargets {
+			s.matchSymlink(p, st)
+			return true
+		}
+		if s.fs.symlinks != SymlinkPolicyFollow {
+			return true
+		}
+		if target, err = s.fs.resolve(p); err != nil {
+			return true
+		}
+	}
+
+	info, err := retryTransient(ctx, func() (ufs.FileInfo, error) { return s.stat(target) })
+	if err != nil || info.IsDir() {
+		return true
+	}
+	if s.opts.OneFilesystem {
+		if dev, ok := deviceID(info); ok && dev != s.dev {
+			return true
+		}
+	}
+	s.visited.Add(1)
+
+	if len(s.opts.SkipFiles) > 0 {
+		if id, ok := FileIDOf(info); ok {
+			if _, skip := s.opts.SkipFiles[id]; skip {
+				return true
+			}
+		}
+	}
+	if s.opts.Executable && !isExecutable(info) {
+		return true
+	}
+	if len(s.opts.Xattrs) > 0 && !s.fs.matchXattrs(target, s.opts.Xattrs) {
+		return true
+	}
+
+	if s.opts.Hash {
+		if s.isProtected(p, target) {
+			return true
+		}
+		if i, ok := s.matchHash(ctx, target, info.Size()); ok {
+			s.add(p, target, i, nil)
+		}
+		return true
+	}
+
+	if i, ok := s.match(strings.ToLower(p)); ok {
+		s.add(p, target, i, nil)
+		return true
+	}
+
+	// Skip large files for content search, along with any whose extension means
+	// they are not worth opening.
+	if !s.opts.IncludeContent || s.opts.Glob || info.Size() > s.opts.MaxSize || s.skipContent(p) || s.isProtected(p, target) {
+		return true
+	}
+
+	if i, match, ok := s.matchContent(ctx, target, m); ok {
+		s.add(p, target, i, match)
+	}
+	return true
+}
+
+// normalizeExtensions returns the given file extensions in lowercase with a
+// leading dot, so that "JAR" and ".jar" are treated the same.
+func normalizeExtensions(exts []string) []string {
+	out := make([]string, 0, len(exts))
+	for _, e := range exts {
+		if e = strings.ToLower(strings.TrimLeft(strings.TrimSpace(e), ".")); e != "" {
+			out = append(out, "."+e)
+		}
+	}
+	return out
+}
+
+// skipContent returns true if the contents of the file at the given path should
+// not be searched because of its extension, in which case it is never opened.
+func (s *searcher) skipContent(p string) bool {
+	name := strings.ToLower(path.Base(p))
+	hasExt := func(exts []string) bool {
+		return slices.ContainsFunc(exts, func(e string) bool {
+			return strings.HasSuffix(name, e)
+		})
+	}
+	if len(s.contentExts) > 0 && !hasExt(s.contentExts) {
+		return true
+	}
+	return hasExt(s.skipContentExts)
+}
+
+// isExecutable returns true if the file is a regular file that any user is
+// allowed to execute.
+func isExecutable(info ufs.FileInfo) bool {
+	return info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0
+}
+
+// The number of workers, size of the queue of paths waiting for a worker, and
+// size of the chunks that file contents are read in for a search in low memory
+// mode.
+const (
+	lowMemorySearchWorkers    = 2
+	lowMemoryPendingSize      = 64
+	lowMemoryContentChunkSize = 2048
+)
+
+// workers returns the most workers that can be used by the search.
+func (s *searcher) workers() int {
+	if s.opts.LowMemory {
+		return lowMemorySearchWorkers
+	}
+	return 8
+}
+
+// pendingSize returns how many paths can be queued waiting for a worker.
+func (s *searcher) pendingSize() int {
+	if s.opts.LowMemory {
+		return lowMemoryPendingSize
+	}
+	return 1000
+}
+
+// chunkSize returns the number of bytes of a file that each worker reads at a
+// time when matching its contents.
+func (s *searcher) chunkSize() int {
+	if s.opts.LowMemory {
+		return lowMemoryContentChunkSize
+	}
+	return defaultContentChunkSize
+}
+
+// matchBrokenSymlink adds the symlink at the given path to the results if its
+// target cannot be found. The link is resolved by the underlying filesystem,
+// which never leaves the server directory, so a link pointing outside of it is
+// always treated as broken.
+func (s *searcher) matchBrokenSymlink(p string) {
+	query := -1
+	if len(s.queries) > 0 {
+		i, ok := s.match(strings.ToLower(p))
+		if !ok {
+			return
+		}
+		query = i
+	}
+	if _, err := s.fs.unixFS.Stat(p); err == nil {
+		return
+	}
+	s.add(p, "", query, nil)
+}
+
+// matchSymlink adds the symlink at the given path to the results if either its
+// name or its target matches one of the queries. The link is never followed, so
+// a link is never executable and cannot have extended attributes to filter on.
+func (s *searcher) matchSymlink(p string, info ufs.FileInfo) {
+	if s.opts.OneFilesystem {
+		if dev, ok := deviceID(info); ok && dev != s.dev {
+			return
+		}
+	}
+	s.visited.Add(1)
+
+	if len(s.opts.SkipFiles) > 0 {
+		if id, ok := FileIDOf(info); ok {
+			if _, skip := s.opts.SkipFiles[id]; skip {
+				return
+			}
+		}
+	}
+	if s.opts.Executable || len(s.opts.Xattrs) > 0 {
+		return
+	}
+
+	target, err := s.fs.readlink(p)
+	if err != nil {
+		return
+	}
+	i, ok := s.match(strings.ToLower(p))
+	if !ok {
+		if i, ok = s.match(strings.ToLower(target)); !ok {
+			return
+		}
+	}
+	s.add(p, "", i, nil)
+}
+
+// match returns the index of the first query contained in the given lowercase
+// text, or in glob mode the first query that matches it as a pattern.
+func (s *searcher) match(text string) (int, bool) {
+	text = s.normalize(text)
+	if s.opts.Glob {
+		rel := strings.TrimPrefix(text, "/")
+		if s.root != "" {
+			rel = strings.TrimPrefix(rel, s.root+"/")
+		}
+		for i, q := range s.nameQueries {
+			if matchGlob(q, rel) {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+	for i, q := range s.nameQueries {
+		if strings.Contains(text, q) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// utf8BOM is the byte order mark that may appear at the start of UTF-8 files.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// gzipMagic is the header that every gzip compressed file starts with.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// matchContent checks if the contents of the file at the given path contain
+// any of the queries, returning the index of the query that matched and, if
+// snippets were requested, the line it was found on. Binary
+// files are never matched. Gzip compressed files, such as rotated logs, are
+// decompressed and their contents are searched instead.
+//
+// Only the contents that existed when the file was opened are searched. Reading
+// stops once the size that was seen for the file is reached, or the maximum size
+// for the search, so that a file being written to while it is searched (such as
+// a live log) cannot keep the search running. For compressed files the maximum
+// size applies to the decompressed contents.
+//
+// If the file cannot be read because of a transient error the whole file is
+// searched again, see retryTransient.
+func (s *searcher) matchContent(ctx context.Context, p string, m *contentMatcher) (int, *contentMatch, bool) {
+	type match struct {
+		query int
+		match *contentMatch
+		ok    bool
+	}
+	res, _ := retryTransient(ctx, func() (match, error) {
+		i, cm, ok, err := s.matchContentOnce(p, m)
+		return match{query: i, match: cm, ok: ok}, err
+	})
+	return res.query, res.match, res.ok
+}
+
+// contentMatch is what was learned about a file while matching its contents,
+// so that adding it to the results does not need to open it again.
+type contentMatch struct {
+	mimetype  string
+	birthtime time.Time
+	snippet   *SearchSnippet
+	// The number of times the queries appear in the file, if they were counted.
+	matches int
+}
+
+// sniffLen is the number of bytes read from the start of a file to detect its
+// mimetype, the same as the limit used by mimetype.DetectReader.
+const sniffLen = 3072
+
+// matchContentOnce is matchContent without any retries, returning the error
+// that stopped the file from being read if there was one.
+func (s *searcher) matchContentOnce(p string, m *contentMatcher) (int, *contentMatch, bool, error) {
+	file, err := s.fs.openFile(p)
+	if err != nil {
+		return 0, nil, false, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, nil, false, err
+	}
+
+	// The same bytes are used to detect the mimetype reported for the file and to
+	// decide whether it is binary, so the file is only opened and read once.
+	br := bufio.NewReaderSize(io.LimitReader(s.limitReader(file), info.Size()), sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return 0, nil, false, err
+	}
+	mt := mimetype.Detect(head)
+	if bytes.HasPrefix(head, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return 0, nil, false, err
+		}
+		defer gz.Close()
+		// A compressed file is binary, it is the decompressed contents that decide
+		// whether there is anything to search.
+		br = bufio.NewReaderSize(gz, sniffLen)
+		if head, err = br.Peek(sniffLen); err != nil && err != io.EOF {
+			return 0, nil, false, err
+		}
+		if isBinary(mimetype.Detect(head), head) {
+			return 0, nil, false, nil
+		}
+	} else if isBinary(mt, head) {
+		return 0, nil, false, nil
+	}
+	r := io.LimitReader(br, s.opts.MaxSize)
+	if s.opts.IgnoreComments {
+		r = newCommentStripper(r, commentStyleFor(p))
+	}
+
+	i, n, ok := m.Match(r)
+	s.bytesRead.Add(n)
+	if !ok {
+		return i, nil, false, m.err
+	}
+	out := &contentMatch{mimetype: mimetypeFor(p, mt), birthtime: birthtime(file.Fd())}
+	if s.opts.Snippets && s.wants("snippet") && s.takeMatch() {
+		out.snippet, n = m.Snippet(r, s.maxLineBytes())
+		s.bytesRead.Add(n)
+		if !s.opts.RawSnippets {
+			out.snippet.Raw = nil
+		}
+		s.redactSnippet(out.snippet)
+	}
+	// Counting carries on from wherever the snippet finished reading the file.
+	if s.opts.CountMatches {
+		out.matches, n = m.Count(r, s.maxCount())
+		s.bytesRead.Add(n)
+	}
+	return i, out, true, m.err
+}
+
+// isBinary returns true if a file with the given mimetype and leading bytes is
+// a binary file, whose contents are never searched. Anything detected as text
+// is searched, otherwise a file is only treated as binary if it contains a null
+// byte, since many text formats used by game servers are not recognized.
+func isBinary(m *mimetype.MIME, head []byte) bool {
+	for ; m != nil; m = m.Parent() {
+		if m.Is("text/plain") {
+			return false
+		}
+	}
+	return bytes.Contains(head, []byte{0})
+}
+
+// add stats the file at the given path and appends it to the results if the
+// limit has not yet been reached. The target is the file that the path resolves
+// to if it is a symlink, or empty if it is a symlink that was not followed, such
+// as a broken one. The query is the index of the query that the file was matched
+// by, or -1 if there were none, and the match is what was found when matching
+// its contents if they were matched.
+func (s *searcher) add(p, target string, query int, match *contentMatch) {
+	// Another worker may have matched a file in the same directory while this one
+	// was being checked, only the first of them is kept.
+	if s.opts.FirstPerDir {
+		if _, loaded := s.dirs.LoadOrStore(path.Dir(p), struct{}{}); loaded {
+			return
+		}
+	}
+	// Opening the file is by far the most expensive part of adding a result, so
+	// avoid doing so unless a field that depends on it was requested.
+	wantsPreview := s.opts.PreviewBytes > 0 && s.wants("preview")
+	open := wantsPreview || s.wants("mime") || s.wants("birthtime") || s.wants("created")
+	var stat Stat
+	var err error
+	var link string
+	if target == "" {
+		// A symlink that is not followed has nothing to stat other than the link
+		// itself.
+		var info ufs.FileInfo
+		if info, err = s.fs.unixFS.Lstat(p); err == nil {
+			stat = Stat{FileInfo: info, Mimetype: "inode/symlink"}
+		}
+		if s.wants("link_target") {
+			link, _ = s.fs.readlink(p)
+		}
+	} else if match != nil {
+		// The file was already opened to match its contents, so everything that
+		// would have needed it to be opened again is known.
+		if stat, err = s.fs.statFromPath(target, false); err == nil {
+			stat.Mimetype, stat.birthtime = match.mimetype, match.birthtime
+		}
+	} else {
+		stat, err = s.fs.statFromPath(target, open)
+	}
+	if err != nil {
+		return
+	}
+
+	// Read the preview before taking the lock so that other workers are not
+	// blocked waiting on this file.
+	var preview *string
+	protected := s.isProtected(p, target)
+	if wantsPreview && !protected && strings.HasPrefix(stat.Mimetype, "text/") && stat.Size() <= s.opts.MaxSize && s.takeMatch() {
+		if v, ok := s.preview(target); ok {
+			preview = &v
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if int(s.count.Load()) >= s.opts.Limit {
+		s.truncated.Store(true)
+		return
+	}
+	result := SearchResult{
+		Name:       s.resultName(p),
+		Created:    stat.Created(),
+		Changed:    stat.CTime(),
+		Accessed:   stat.ATime(),
+		Modified:   stat.ModTime(),
+		Mode:       stat.Mode().String(),
+		ModeBits:   fmt.Sprintf("%o", stat.Mode().Perm()),
+		Size:       stat.Size(),
+		Directory:  stat.IsDir(),
+		File:       stat.Mode().IsRegular(),
+		Symlink:    p != target || stat.Mode()&ufs.ModeSymlink != 0,
+		Mime:       stat.Mimetype,
+		Writable:   s.wants("writable") && s.fs.IsIgnored(p) == nil,
+		Protected:  protected,
+		LinkTarget: link,
+	}
+	if query >= 0 {
+		result.Query = s.opts.Queries[query]
+	}
+	if len(s.opts.Fields) > 0 {
+		result.fields = s.opts.Fields
+	}
+	if bt := stat.Birthtime(); !bt.IsZero() {
+		result.Birthtime = &bt
+	}
+	result.path = target
+	result.Preview = preview
+	if match != nil {
+		result.Snippet = match.snippet
+		result.Matches = match.matches
+	}
+	if lock, ok := s.fs.FileLock(p); ok {
+		result.LockedBy = lock.Owner
+	}
+	if s.out != nil {
+		if s.outErr != nil {
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			s.outErr = err
+			return
+		}
+		if s.count.Load() > 0 {
+			_, _ = s.out.WriteString(",\n")
+		}
+		if _, err := s.out.Write(b); err != nil {
+			s.outErr = err
+			return
+		}
+	} else if s.onResult != nil {
+		s.onResult(result)
+	} else {
+		s.results = append(s.results, result)
+	}
+	s.count.Add(1)
+}
+
+// resultName returns the name of the result for the file at the given path, in
+// the path style of the search.
+func (s *searcher) resultName(p string) string {
+	p = s.normalize(strings.TrimPrefix(path.Clean("/"+p), "/"))
+	if s.opts.PathStyle == SearchPathRooted {
+		return p
+	}
+	// The root is matched as a whole directory so that a root of "/plugins" does
+	// not strip the start of a sibling such as "/plugins-old".
+	if root := s.normalize(strings.Trim(path.Clean("/"+s.opts.Root), "/")); root != "" {
+		return strings.TrimPrefix(p, root+"/")
+	}
+	return p
+}
+
+// normalize converts the given text to the NFC Unicode normal form if the
+// search normalizes names, otherwise it is returned as it is.
+func (s *searcher) normalize(text string) string {
+	if !s.opts.NormalizeUnicode {
+		return text
+	}
+	return norm.NFC.String(text)
+}
+
+// takeMatch reserves one of the matches that can include content from the file,
+// returning false once the maximum number of matches has been reached.
+func (s *searcher) takeMatch() bool {
+	if s.opts.MaxMatches <= 0 {
+		return true
+	}
+	if s.matches.Add(1) > int32(s.opts.MaxMatches) {
+		s.capped.Store(true)
+		return false
+	}
+	return true
+}
+
+// limitReader wraps the reader with the read limit of the search, if it has one.
+func (s *searcher) limitReader(r io.Reader) io.Reader {
+	if s.bucket == nil {
+		return r
+	}
+	return ratelimit.Reader(r, s.bucket)
+}
+
+// preview returns the start of the given file, truncated so that it does not end
+// in the middle of a multibyte character.
+func (s *searcher) preview(p string) (string, bool) {
+	file, err := s.fs.openFile(p)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	buf := make([]byte, min(int64(s.opts.PreviewBytes), s.opts.MaxSize))
+	n, err := io.ReadFull(s.limitReader(file), buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", false
+	}
+	s.bytesRead.Add(int64(n))
+
+	return string(s.redactor.Redact(trimPartialRune(bytes.TrimPrefix(buf[:n], utf8BOM)))), true
+}
+
+// redactSnippet replaces any secrets in the text of a snippet, such as a token
+// on the same line as the match, so that they are not shown to users searching
+// the server. The offsets still refer to the contents of the file.
+func (s *searcher) redactSnippet(v *SearchSnippet) {
+	v.Text = s.redactor.RedactString(v.Text)
+	if v.Raw != nil {
+		v.Raw = s.redactor.Redact(v.Raw)
+	}
+}
+
+// trimPartialRune drops the last character of b if it stops partway through it.
+func trimPartialRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				b = b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// statFromPath returns the stat information for the given path. Unlike Stat
+// this will only open regular files to detect their mimetype, so it is safe to
+// call on named pipes and other special files. If open is false the file is not
+// opened at all, and the mimetype and birthtime are not populated.
+func (fs *Filesystem) statFromPath(p string, open bool) (Stat, error) {
+	info, err := fs.unixFS.Stat(p)
+	if err != nil {
+		return Stat{}, err
+	}
+
+	var mt string
+	var bt time.Time
+	if info.IsDir() {
+		mt = "inode/directory"
+	} else if open {
+		mt = genericMimetype
+		if info.Mode().IsRegular() {
+			file, err := fs.openFile(p)
+			if err != nil {
+				return Stat{}, err
+			}
+			m, err := mimetype.DetectReader(file)
+			if err == nil {
+				mt = mimetypeFor(p, m)
+			}
+			bt = birthtime(file.Fd())
+			file.Close()
+		}
+	}
+
+	return Stat{FileInfo: info, Mimetype: mt, birthtime: bt}, nil
+}
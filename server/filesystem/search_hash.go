@@ -0,0 +1,139 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"emperror.dev/errors"
+)
+
+// ErrBadHash is returned when a hash used for a search is not a hex encoded
+// SHA-256 hash.
+var ErrBadHash = errors.Sentinel("filesystem: malformed sha256 hash")
+
+// ValidateHash returns ErrBadHash if the given value is not a hex encoded
+// SHA-256 hash that can be searched for.
+func ValidateHash(h string) error {
+	if len(h) != sha256.Size*2 {
+		return errors.WithStack(ErrBadHash)
+	}
+	if _, err := hex.DecodeString(h); err != nil {
+		return errors.WithStack(ErrBadHash)
+	}
+	return nil
+}
+
+// matchHash hashes the contents of the file at the given path and returns the
+// index of the query that the hash is equal to. If a size was given for the
+// search, files of any other size are never hashed since they cannot match.
+// Files larger than the max size of the search are never hashed either.
+func (s *searcher) matchHash(ctx context.Context, p string, size int64) (int, bool) {
+	if s.opts.Size > 0 && size != s.opts.Size {
+		return 0, false
+	}
+	if s.opts.MaxSize > 0 && size > s.opts.MaxSize {
+		return 0, false
+	}
+	sum, err := retryTransient(ctx, func() (string, error) { return s.hashFile(ctx, p) })
+	if err != nil {
+		return 0, false
+	}
+	s.bytesRead.Add(size)
+	if i := slices.Index(s.queries, sum); i >= 0 {
+		return i, true
+	}
+	return 0, false
+}
+
+// hashFile hashes the contents of the file at the given path, reading it through
+// the read limit of the search.
+func (s *searcher) hashFile(ctx context.Context, p string) (string, error) {
+	f, err := s.fs.openFile(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return hashContents(ctx, s.limitReader(f))
+}
+
+// dedupe collapses results for files with identical contents into a single
+// result, the first of them, with the names of the others in its Copies. Only
+// regular files that share a size with another result are hashed, since files
+// of different sizes can never be the same. Files that cannot be hashed are kept
+// as they are, as are protected files and files larger than the max size of the
+// search so that collapsing duplicates never reads more of a file than
+// searching it would.
+// The files are hashed using workers from the pool shared with searches. The
+// number of bytes that were read is returned.
+func (s *searcher) dedupe(ctx context.Context, results []SearchResult) ([]SearchResult, int64) {
+	sizes := make(map[int64][]int)
+	for i, r := range results {
+		if r.File && r.path != "" && !r.Protected && (s.opts.MaxSize <= 0 || r.Size <= s.opts.MaxSize) {
+			sizes[r.Size] = append(sizes[r.Size], i)
+		}
+	}
+	var candidates []int
+	for _, idx := range sizes {
+		if len(idx) > 1 {
+			candidates = append(candidates, idx...)
+		}
+	}
+	if len(candidates) == 0 {
+		return results, 0
+	}
+
+	workers, release, err := acquireSearchWorkers(ctx, min(s.workers(), len(candidates)))
+	if err != nil {
+		return results, 0
+	}
+	defer release()
+
+	var read atomic.Int64
+	sums := make([]string, len(results))
+	pending := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				sum, err := retryTransient(ctx, func() (string, error) { return s.hashFile(ctx, results[i].path) })
+				if err != nil {
+					continue
+				}
+				read.Add(results[i].Size)
+				// The size is part of the key in the same way as when finding duplicates.
+				sums[i] = sum + ":" + strconv.FormatInt(results[i].Size, 10)
+			}
+		}()
+	}
+	for _, i := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
+		pending <- i
+	}
+	close(pending)
+	wg.Wait()
+
+	first := make(map[string]int)
+	out := make([]SearchResult, 0, len(results))
+	for i, r := range results {
+		if sums[i] == "" {
+			out = append(out, r)
+			continue
+		}
+		if j, ok := first[sums[i]]; ok {
+			out[j].Copies = append(out[j].Copies, r.Name)
+			continue
+		}
+		first[sums[i]] = len(out)
+		out = append(out, r)
+	}
+	return out, read.Load()
+}
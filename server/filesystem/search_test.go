@@ -0,0 +1,661 @@
+package filesystem
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+	ignore "github.com/sabhiram/go-gitignore"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+func searchNames(results []SearchResult) []string {
+	out := make([]string, len(results))
+	for i, r := range results {
+		out[i] = r.Name
+	}
+	return out
+}
+
+func TestFilesystem_Search(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("Search", func() {
+		g.BeforeEach(func() {
+			_ = fs.CreateDirectory("plugins", "/")
+			_ = rfs.CreateServerFileFromString("server.properties", "motd=hello world")
+			_ = rfs.CreateServerFileFromString("plugins/config.yml", "greeting: hello")
+			_ = rfs.CreateServerFileFromString("plugins/other.yml", "nothing here")
+		})
+
+		g.It("matches file names", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"config"}, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/config.yml"})
+		})
+
+		g.It("matches file contents when enabled", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"HELLO"}, IncludeContent: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/config.yml", "server.properties"})
+		})
+
+		g.It("does not match the contents of binary files", func() {
+			_ = rfs.CreateServerFileFromString("plugins/data.bin", "hello\x00\x01\x02")
+			_ = rfs.CreateServerFileFromString("plugins/colors.log", "\x1b[32mhello\x1b[0m")
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/plugins", Queries: []string{"hello"}, IncludeContent: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"colors.log", "config.yml"})
+		})
+
+		g.It("reports the same mimetype for content matches as stat", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/plugins", Queries: []string{"hello"}, IncludeContent: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(1)
+
+			f, st, err := fs.File("plugins/config.yml")
+			g.Assert(err).IsNil()
+			_ = f.Close()
+			g.Assert(results.Results[0].Mime).Equal(st.Mimetype)
+			g.Assert(results.Results[0].Mime).IsNotZero()
+		})
+
+		g.It("does not match file contents when disabled", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"hello"}, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(0)
+		})
+
+		g.It("only searches the provided paths", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{
+				Root:           "/plugins",
+				Queries:        []string{"hello"},
+				Paths:          []string{"other.yml", "config.yml", "missing.yml"},
+				IncludeContent: true,
+				Limit:          100,
+				MaxSize:        1024,
+			})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"config.yml"})
+		})
+
+		g.It("reports the results as complete when nothing was skipped", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"yml"}, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(results.Complete).IsTrue()
+			g.Assert(results.Reason).Equal("")
+		})
+
+		g.It("reports the results as incomplete when the limit is reached", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"e"}, Limit: 1, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(1)
+			g.Assert(results.Complete).IsFalse()
+			g.Assert(results.Reason).Equal(SearchReasonLimit)
+		})
+
+		g.It("matches any of multiple queries", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"missing", "properties", "greeting"}, IncludeContent: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/config.yml", "server.properties"})
+			g.Assert(results.Results[0].Query).Equal("greeting")
+			g.Assert(results.Results[1].Query).Equal("properties")
+		})
+
+		g.It("ignores a leading byte order mark when matching contents", func() {
+			_ = rfs.CreateServerFileFromString("bom.cfg", "\xEF\xBB\xBFport=25565")
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"port="}, IncludeContent: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"bom.cfg"})
+		})
+
+		g.It("includes a preview of matched text files", func() {
+			_ = rfs.CreateServerFileFromString("motd.txt", "héllo")
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"motd.txt"}, PreviewBytes: 2, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(1)
+			g.Assert(*results.Results[0].Preview).Equal("h")
+		})
+
+		g.It("stops including previews once the maximum matches is reached", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"yml"}, PreviewBytes: 16, MaxMatches: 1, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(2)
+			g.Assert(results.MatchesCapped).IsTrue()
+
+			var previews int
+			for _, r := range results.Results {
+				if r.Preview != nil {
+					previews++
+				}
+			}
+			g.Assert(previews).Equal(1)
+		})
+
+		g.It("only matches the first file in each directory when requested", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"yml", "properties"}, FirstPerDir: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			// Either file in the plugins directory may be the one that matched first.
+			names := searchNames(results.Results)
+			g.Assert(len(names)).Equal(2)
+			g.Assert(filepath.Dir(names[0])).Equal("plugins")
+			g.Assert(names[1]).Equal("server.properties")
+		})
+
+		g.It("only includes the requested fields", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"config"}, Fields: []string{"size"}, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(1)
+			g.Assert(results.Results[0].Mime).Equal("")
+
+			b, err := json.Marshal(results.Results[0])
+			g.Assert(err).IsNil()
+			var m map[string]any
+			g.Assert(json.Unmarshal(b, &m)).IsNil()
+			g.Assert(len(m)).Equal(2)
+			g.Assert(m["name"]).Equal("plugins/config.yml")
+			g.Assert(m["size"]).Equal(float64(len("greeting: hello")))
+		})
+
+		g.It("matches queries containing special characters literally", func() {
+			_ = rfs.CreateServerFileFromString("prices.txt", `cost: $5.00 (each) "quoted" <tag>`)
+
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{`$5.00 (each) "quoted"`}, IncludeContent: true, PreviewBytes: 64, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"prices.txt"})
+
+			b, err := json.Marshal(results.Results[0])
+			g.Assert(err).IsNil()
+			var r struct {
+				Preview string `json:"preview"`
+			}
+			g.Assert(json.Unmarshal(b, &r)).IsNil()
+			g.Assert(r.Preview).Equal(`cost: $5.00 (each) "quoted" <tag>`)
+
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"$5.0."}, IncludeContent: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(0)
+		})
+
+		g.It("does not match comments when they are ignored", func() {
+			_ = rfs.CreateServerFileFromString("plugins/commented.yml", "# greeting: hello\nenabled: true")
+
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"hello"}, IncludeContent: true, IgnoreComments: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/config.yml", "server.properties"})
+		})
+
+		g.It("visits files closer to the root first when walking breadth-first", func() {
+			_ = fs.CreateDirectory("a/b", "/")
+			_ = rfs.CreateServerFileFromString("a/b/deep.yml", "")
+			_ = rfs.CreateServerFileFromString("z.yml", "")
+
+			var visited []string
+			err := fs.walkBreadthFirst("/", func(p string, d ufs.DirEntry, err error) error {
+				visited = append(visited, p)
+				return err
+			})
+			g.Assert(err).IsNil()
+			g.Assert(visited).Equal([]string{
+				"/a", "/plugins", "/server.properties", "/z.yml",
+				"/a/b", "/plugins/config.yml", "/plugins/other.yml",
+				"/a/b/deep.yml",
+			})
+		})
+
+		g.It("matches the contents of gzip compressed files", func() {
+			var b bytes.Buffer
+			w := gzip.NewWriter(&b)
+			_, _ = w.Write([]byte("[12:00:00] player joined the game"))
+			_ = w.Close()
+			_ = rfs.CreateServerFile("latest.log.gz", b.Bytes())
+
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"joined"}, IncludeContent: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"latest.log.gz"})
+
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"joined"}, IncludeContent: true, Limit: 100, MaxSize: 16})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(0)
+		})
+
+		g.It("exports results to a file", func() {
+			results, count, err := fs.SearchExport(context.Background(), SearchOptions{Root: "/", Queries: []string{"search", "yml"}, Limit: 100, MaxSize: 1024}, "/search-results.json")
+			g.Assert(err).IsNil()
+			g.Assert(count).Equal(2)
+			g.Assert(results.Complete).IsTrue()
+
+			b, err := os.ReadFile(filepath.Join(rfs.root, "/server/search-results.json"))
+			g.Assert(err).IsNil()
+			var exported []SearchResult
+			g.Assert(json.Unmarshal(b, &exported)).IsNil()
+			names := searchNames(exported)
+			sort.Strings(names)
+			g.Assert(names).Equal([]string{"plugins/config.yml", "plugins/other.yml"})
+		})
+
+		g.It("does not export results to a file on the denylist", func() {
+			denylist := fs.denylist
+			fs.denylist = ignore.CompileIgnoreLines("search-results.json")
+			defer func() { fs.denylist = denylist }()
+
+			_, _, err := fs.SearchExport(context.Background(), SearchOptions{Root: "/", Queries: []string{"yml"}, Limit: 100, MaxSize: 1024}, "/search-results.json")
+			g.Assert(IsErrorCode(err, ErrCodeDenylistFile)).IsTrue()
+			_, err = os.Lstat(filepath.Join(rfs.root, "/server/search-results.json"))
+			g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+		})
+
+		g.It("does not export results past the disk limit", func() {
+			fs.SetDiskLimit(fs.CachedUsage() + 64)
+			defer fs.SetDiskLimit(0)
+
+			_, _, err := fs.SearchExport(context.Background(), SearchOptions{Root: "/", Queries: []string{"yml"}, Limit: 100, MaxSize: 1024}, "/search-results.json")
+			g.Assert(IsErrorCode(err, ErrCodeDiskSpace)).IsTrue()
+			entries, err := os.ReadDir(filepath.Join(rfs.root, "/server"))
+			g.Assert(err).IsNil()
+			for _, e := range entries {
+				g.Assert(e.Name() != "search-results.json" && !isTempFile(e.Name())).IsTrue(e.Name())
+			}
+		})
+
+		g.It("streams results in low memory mode", func() {
+			var buf bytes.Buffer
+			results, count, err := fs.SearchStream(context.Background(), SearchOptions{Root: "/", Queries: []string{"hello"}, IncludeContent: true, LowMemory: true, Limit: 100, MaxSize: 1024}, &buf)
+			g.Assert(err).IsNil()
+			g.Assert(count).Equal(2)
+			g.Assert(results.Complete).IsTrue()
+			g.Assert(results.Stats.Workers <= lowMemorySearchWorkers).IsTrue()
+
+			var streamed []SearchResult
+			g.Assert(json.Unmarshal(buf.Bytes(), &streamed)).IsNil()
+			names := searchNames(streamed)
+			sort.Strings(names)
+			g.Assert(names).Equal([]string{"plugins/config.yml", "server.properties"})
+		})
+
+		g.It("passes each result to the callback as it is found", func() {
+			var names []string
+			results, count, err := fs.SearchFunc(context.Background(), SearchOptions{Root: "/", Queries: []string{"hello"}, IncludeContent: true, Limit: 100, MaxSize: 1024}, func(r SearchResult) {
+				names = append(names, r.Name)
+			})
+			g.Assert(err).IsNil()
+			g.Assert(count).Equal(2)
+			g.Assert(len(results.Results)).Equal(0)
+			sort.Strings(names)
+			g.Assert(names).Equal([]string{"plugins/config.yml", "server.properties"})
+		})
+
+		g.It("writes nothing when a stream cannot be started", func() {
+			config.Update(func(c *config.Configuration) {
+				c.Filesystem.SearchDisallowedPaths = []string{"/plugins/"}
+			})
+			defer config.Update(func(c *config.Configuration) {
+				c.Filesystem.SearchDisallowedPaths = nil
+			})
+
+			var buf bytes.Buffer
+			_, _, err := fs.SearchStream(context.Background(), SearchOptions{Root: "/plugins", Queries: []string{"hello"}, Limit: 100}, &buf)
+			g.Assert(errors.Is(err, ErrSearchDisallowed)).IsTrue()
+			g.Assert(buf.Len()).Equal(0)
+		})
+
+		g.It("only searches recently changed files when requested", func() {
+			fs.RecordOp(RecentOpWrite, "plugins/config.yml")
+			fs.RecordOp(RecentOpDelete, "plugins/other.yml")
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"yml"}, RecentOpsOnly: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/config.yml"})
+		})
+
+		g.It("matches glob patterns against the path relative to the root", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"*.YML"}, Glob: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/config.yml", "plugins/other.yml"})
+
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/plugins", Queries: []string{"c*.yml"}, Glob: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"config.yml"})
+		})
+
+		g.It("includes the matched line when snippets are requested", func() {
+			_ = rfs.CreateServerFileFromString("plugins/long.yml", "a: 1\n"+strings.Repeat("x", 5000)+"hello"+strings.Repeat("y", 5000))
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/plugins", Queries: []string{"hello"}, IncludeContent: true, Snippets: true, MaxLineBytes: 100, Limit: 100, MaxSize: 1 << 20})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"config.yml", "long.yml"})
+
+			g.Assert(*results.Results[0].Snippet).Equal(SearchSnippet{Line: 1, Offset: 10, Text: "greeting: hello"})
+			snippet := results.Results[1].Snippet
+			g.Assert(snippet.Line).Equal(int64(2))
+			g.Assert(snippet.Offset).Equal(int64(5005))
+			g.Assert(snippet.Truncated).IsTrue()
+			g.Assert(len(snippet.Text)).Equal(100)
+		})
+
+		g.It("includes the raw bytes of the line when requested", func() {
+			_ = rfs.CreateServerFileFromString("plugins/latin1.txt", "caf\xe9 hello\n")
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/plugins", Queries: []string{"hello"}, IncludeContent: true, Snippets: true, RawSnippets: true, Limit: 100, MaxSize: 1 << 20})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"config.yml", "latin1.txt"})
+			g.Assert(results.Results[1].Snippet.Raw).Equal([]byte("caf\xe9 hello"))
+
+			b, err := json.Marshal(results.Results[1].Snippet)
+			g.Assert(err).IsNil()
+			g.Assert(strings.Contains(string(b), `"raw":"Y2Fm6SBoZWxsbw=="`)).IsTrue()
+		})
+
+		g.It("redacts secrets from snippets and previews", func() {
+			config.Update(func(c *config.Configuration) {
+				c.System.RedactPatterns = []string{`sk_[a-z0-9]+`}
+			})
+			defer config.Update(func(c *config.Configuration) {
+				c.System.RedactPatterns = nil
+			})
+			_ = rfs.CreateServerFileFromString("plugins/secrets.txt", "hello password=hunter2 key=sk_abc123\n")
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/plugins", Queries: []string{"secrets.txt"}, PreviewBytes: 64, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(1)
+			g.Assert(*results.Results[0].Preview).Equal("hello password=**** key=****\n")
+
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/plugins", Queries: []string{"hunter"}, IncludeContent: true, Snippets: true, RawSnippets: true, Limit: 100, MaxSize: 1 << 20})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"secrets.txt"})
+			g.Assert(results.Results[0].Snippet.Text).Equal("hello password=**** key=****")
+			g.Assert(results.Results[0].Snippet.Raw).Equal([]byte("hello password=**** key=****"))
+		})
+
+		g.It("includes the root of the search in rooted names", func() {
+			_ = fs.CreateDirectory("plugins-old", "/")
+			_ = rfs.CreateServerFileFromString("plugins-old/config.yml", "greeting: hello")
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/plugins", Queries: []string{"config"}, PathStyle: SearchPathRooted, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/config.yml"})
+
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "plugins/", Queries: []string{"config"}, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"config.yml"})
+		})
+
+		g.It("only opens files with allowed extensions to search their contents", func() {
+			_ = rfs.CreateServerFileFromString("plugins/notes.txt", "hello there")
+			_ = rfs.CreateServerFileFromString("plugins/hello.jar", "hello")
+			config.Update(func(c *config.Configuration) {
+				c.Filesystem.SearchContentExtensions = []string{"YML", ".txt"}
+				c.Filesystem.SearchSkipContentExtensions = []string{".txt"}
+			})
+			defer config.Update(func(c *config.Configuration) {
+				c.Filesystem.SearchContentExtensions = nil
+				c.Filesystem.SearchSkipContentExtensions = nil
+			})
+
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"hello"}, IncludeContent: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			// Names are still matched no matter the extension.
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/config.yml", "plugins/hello.jar"})
+		})
+
+		g.It("only matches executable files when requested", func() {
+			_ = rfs.CreateServerFileFromString("plugins/start.sh", "#!/bin/sh")
+			_ = os.Chmod(filepath.Join(rfs.root, "server/plugins/start.sh"), 0o744)
+			_ = os.Chmod(filepath.Join(rfs.root, "server/plugins/other.yml"), 0o640)
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/plugins", Queries: []string{"/"}, Executable: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"start.sh"})
+		})
+
+		g.It("searches the root filesystem when staying on one filesystem", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"config"}, OneFilesystem: true, Limit: 100})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/config.yml"})
+		})
+
+		g.It("never searches disallowed directories", func() {
+			config.Update(func(c *config.Configuration) {
+				c.Filesystem.SearchDisallowedPaths = []string{"/plugins/"}
+			})
+			defer config.Update(func(c *config.Configuration) {
+				c.Filesystem.SearchDisallowedPaths = nil
+			})
+
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"hello"}, IncludeContent: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"server.properties"})
+
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"hello"}, Paths: []string{"plugins/config.yml"}, IncludeContent: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(0)
+
+			_, err = fs.Search(context.Background(), SearchOptions{Root: "/plugins", Queries: []string{"hello"}, Limit: 100, MaxSize: 1024})
+			g.Assert(errors.Is(err, ErrSearchDisallowed)).IsTrue()
+		})
+
+		g.It("skips excluded files and directories", func() {
+			_ = fs.CreateDirectory("libraries", "/")
+			_ = rfs.CreateServerFileFromString("libraries/lib.yml", "hello")
+
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"hello"}, IncludeContent: true, Excludes: []string{"Libraries/", "*.properties"}, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/config.yml"})
+
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"hello"}, Paths: []string{"libraries/lib.yml", "plugins/config.yml"}, IncludeContent: true, Excludes: []string{"libraries"}, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/config.yml"})
+		})
+
+		g.It("flags protected files without including their contents", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"hello"}, IncludeContent: true, Protected: []string{"Plugins/"}, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"server.properties"})
+
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"config.yml"}, PreviewBytes: 16, Protected: []string{"plugins"}, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(1)
+			g.Assert(results.Results[0].Protected).IsTrue()
+			g.Assert(results.Results[0].Preview == nil).IsTrue()
+		})
+
+		g.It("protects files reached through a symlink", func() {
+			fs.symlinks = SymlinkPolicyFollow
+			defer func() { fs.symlinks = SymlinkPolicyReject }()
+			link := filepath.Join(rfs.root, "server", "link.yml")
+			g.Assert(os.Symlink("plugins/config.yml", link)).IsNil()
+			defer os.Remove(link)
+
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"hello"}, IncludeContent: true, Protected: []string{"plugins"}, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"server.properties"})
+
+			sum := sha256.Sum256([]byte("greeting: hello"))
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{hex.EncodeToString(sum[:])}, Hash: true, Protected: []string{"plugins"}, Limit: 100})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(0)
+		})
+
+		g.It("counts matches and sorts by them", func() {
+			_ = rfs.CreateServerFileFromString("plugins/many.yml", "hello hello\nhello")
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"hello"}, IncludeContent: true, CountMatches: true, Sort: SearchSortMatches, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/many.yml", "plugins/config.yml", "server.properties"})
+			g.Assert(results.Results[0].Matches).Equal(3)
+			g.Assert(results.Results[1].Matches).Equal(1)
+
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"hello"}, IncludeContent: true, CountMatches: true, MaxCount: 2, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/config.yml", "plugins/many.yml", "server.properties"})
+			g.Assert(results.Results[1].Matches).Equal(2)
+		})
+
+		g.It("finds broken symlinks", func() {
+			_ = os.Symlink("config.yml", filepath.Join(rfs.root, "server/plugins/working.yml"))
+			_ = os.Symlink("missing.yml", filepath.Join(rfs.root, "server/plugins/broken.yml"))
+			_ = os.Symlink("gone", filepath.Join(rfs.root, "server/dangling"))
+
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", BrokenSymlinks: true, Limit: 100})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"dangling", "plugins/broken.yml"})
+			g.Assert(results.Results[0].Symlink).IsTrue()
+			g.Assert(results.Results[0].Query).Equal("")
+
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{".YML"}, BrokenSymlinks: true, Limit: 100})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/broken.yml"})
+		})
+
+		g.It("matches symlinks by their target", func() {
+			_ = fs.CreateDirectory("mods", "/")
+			_ = os.Symlink("../plugins/config.yml", filepath.Join(rfs.root, "server/mods/linked"))
+
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"plugins/CONFIG"}, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/config.yml"})
+
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"plugins/CONFIG"}, SymlinkTargets: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"mods/linked", "plugins/config.yml"})
+			g.Assert(results.Results[0].Symlink).IsTrue()
+			g.Assert(results.Results[0].LinkTarget).Equal("../plugins/config.yml")
+			g.Assert(results.Results[1].LinkTarget).Equal("")
+		})
+
+		g.It("checks the filters against a symlink matched by its target", func() {
+			_ = fs.CreateDirectory("mods", "/")
+			_ = os.Symlink("../plugins/config.yml", filepath.Join(rfs.root, "server/mods/linked"))
+			st, err := os.Lstat(filepath.Join(rfs.root, "server/mods/linked"))
+			g.Assert(err).IsNil()
+			id, ok := FileIDOf(st)
+			g.Assert(ok).IsTrue()
+
+			opts := SearchOptions{Root: "/mods", Queries: []string{"plugins/config"}, SymlinkTargets: true, Limit: 100}
+			results, err := fs.Search(context.Background(), opts)
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"linked"})
+
+			opts.SkipFiles = map[FileID]struct{}{id: {}}
+			results, err = fs.Search(context.Background(), opts)
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(0)
+
+			opts.SkipFiles = nil
+			opts.Executable = true
+			results, err = fs.Search(context.Background(), opts)
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(0)
+		})
+
+		g.It("matches files by the hash of their contents", func() {
+			sum := sha256.Sum256([]byte("greeting: hello"))
+			hash := hex.EncodeToString(sum[:])
+			other := strings.Repeat("0", 64)
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{other, hash}, Hash: true, Limit: 100})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/config.yml"})
+			g.Assert(results.Results[0].Query).Equal(hash)
+
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{hash}, Hash: true, Size: 3, Limit: 100})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(0)
+			g.Assert(results.Stats.BytesRead).Equal(int64(0))
+		})
+
+		g.It("does not hash files larger than the max size", func() {
+			sum := sha256.Sum256([]byte("greeting: hello"))
+			hash := hex.EncodeToString(sum[:])
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{hash}, Hash: true, Limit: 100, MaxSize: 8})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(0)
+			g.Assert(results.Stats.BytesRead).Equal(int64(0))
+		})
+
+		g.It("normalizes unicode names when enabled", func() {
+			// "café" with the accent as a separate combining character, as written by
+			// macOS, and the query with it as a single character.
+			_ = rfs.CreateServerFileFromString("plugins/cafe\u0301.yml", "menu: none")
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"caf\u00e9"}, Limit: 100})
+			g.Assert(err).IsNil()
+			g.Assert(len(results.Results)).Equal(0)
+
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/plugins", Queries: []string{"CAF\u00c9"}, NormalizeUnicode: true, Limit: 100})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"caf\u00e9.yml"})
+		})
+
+		g.It("does not normalize the queries matched against file contents", func() {
+			_ = rfs.CreateServerFileFromString("plugins/menu.yml", "menu: cafe\u0301")
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/plugins", Queries: []string{"cafe\u0301"}, IncludeContent: true, NormalizeUnicode: true, Limit: 100, MaxSize: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"menu.yml"})
+		})
+
+		g.It("does not sort results when asked not to", func() {
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"o"}, Sort: SearchSortNone, Limit: 100})
+			g.Assert(err).IsNil()
+			names := searchNames(results.Results)
+			sort.Strings(names)
+			g.Assert(names).Equal([]string{"plugins/config.yml", "plugins/other.yml", "server.properties"})
+		})
+
+		g.It("collapses files with the same contents when deduplicating", func() {
+			_ = fs.CreateDirectory("backup", "/")
+			_ = rfs.CreateServerFileFromString("backup/config.yml", "greeting: hello")
+			_ = rfs.CreateServerFileFromString("backup/other.yml", "nothing HERE")
+
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{".yml"}, Dedupe: true, Limit: 100})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"backup/config.yml", "backup/other.yml", "plugins/other.yml"})
+			g.Assert(results.Results[0].Copies).Equal([]string{"plugins/config.yml"})
+			g.Assert(results.Results[1].Copies == nil).IsTrue()
+		})
+
+		g.It("does not hash files larger than the max size when deduplicating", func() {
+			_ = fs.CreateDirectory("backup", "/")
+			_ = rfs.CreateServerFileFromString("backup/config.yml", "greeting: hello")
+
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"config.yml"}, Dedupe: true, Limit: 100, MaxSize: 4})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"backup/config.yml", "plugins/config.yml"})
+			g.Assert(results.Stats.BytesRead).Equal(int64(0))
+		})
+
+		g.It("skips the given files", func() {
+			st, err := os.Stat(filepath.Join(rfs.root, "server", "plugins", "config.yml"))
+			g.Assert(err).IsNil()
+			id, ok := FileIDOf(st)
+			g.Assert(ok).IsTrue()
+
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{"yml"}, SkipFiles: map[FileID]struct{}{id: {}}, Limit: 100})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"plugins/other.yml"})
+		})
+
+		g.It("recovers from a panic while searching a file", func() {
+			s := fs.newSearcher(SearchOptions{Root: "/", Queries: []string{"hello"}, IncludeContent: true, Limit: 100, MaxSize: 1024})
+			stat := s.stat
+			s.stat = func(p string) (ufs.FileInfo, error) {
+				if strings.HasSuffix(p, "config.yml") {
+					panic("corrupt file")
+				}
+				return stat(p)
+			}
+			results, err := s.run(context.Background())
+			g.Assert(err).IsNil()
+			g.Assert(results.Complete).IsTrue()
+			g.Assert(searchNames(s.results)).Equal([]string{"server.properties"})
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"time"
 
+	"emperror.dev/errors"
 	"github.com/gabriel-vasile/mimetype"
 
 	"github.com/kristiangarcia/wings/internal/ufs"
@@ -14,12 +15,38 @@ import (
 type Stat struct {
 	ufs.FileInfo
 	Mimetype string
+
+	// The time the file was created, only known if the file was opened while it
+	// was being stat'd and the filesystem supports it.
+	birthtime time.Time
+}
+
+// Birthtime returns the time that the file was created, or a zero time if it is
+// not known.
+func (s *Stat) Birthtime() time.Time {
+	return s.birthtime
+}
+
+// Created returns the time that the file was created if it is known, otherwise
+// the time that the file metadata was last changed is used instead.
+func (s *Stat) Created() time.Time {
+	if !s.birthtime.IsZero() {
+		return s.birthtime
+	}
+	return s.CTime()
 }
 
 func (s *Stat) MarshalJSON() ([]byte, error) {
+	var bt string
+	if !s.birthtime.IsZero() {
+		bt = s.birthtime.Format(time.RFC3339)
+	}
 	return json.Marshal(struct {
 		Name      string `json:"name"`
 		Created   string `json:"created"`
+		Birthtime string `json:"birthtime,omitempty"`
+		Changed   string `json:"changed"`
+		Accessed  string `json:"accessed"`
 		Modified  string `json:"modified"`
 		Mode      string `json:"mode"`
 		ModeBits  string `json:"mode_bits"`
@@ -29,10 +56,13 @@ func (s *Stat) MarshalJSON() ([]byte, error) {
 		Symlink   bool   `json:"symlink"`
 		Mime      string `json:"mime"`
 	}{
-		Name:     s.Name(),
-		Created:  s.CTime().Format(time.RFC3339),
-		Modified: s.ModTime().Format(time.RFC3339),
-		Mode:     s.Mode().String(),
+		Name:      s.Name(),
+		Created:   s.Created().Format(time.RFC3339),
+		Birthtime: bt,
+		Changed:   s.CTime().Format(time.RFC3339),
+		Accessed:  s.ATime().Format(time.RFC3339),
+		Modified:  s.ModTime().Format(time.RFC3339),
+		Mode:      s.Mode().String(),
 		// Using `&ModePerm` on the file's mode will cause the mode to only have the permission values, and nothing else.
 		ModeBits:  strconv.FormatUint(uint64(s.Mode()&ufs.ModePerm), 8),
 		Size:      s.Size(),
@@ -59,11 +89,12 @@ func statFromFile(f ufs.File) (Stat, error) {
 		}
 	}
 	st := Stat{
-		FileInfo: s,
-		Mimetype: "inode/directory",
+		FileInfo:  s,
+		Mimetype:  "inode/directory",
+		birthtime: birthtime(f.Fd()),
 	}
-	if m != nil {
-		st.Mimetype = m.String()
+	if !s.IsDir() {
+		st.Mimetype = mimetypeFor(s.Name(), m)
 	}
 	return st, nil
 }
@@ -71,6 +102,10 @@ func statFromFile(f ufs.File) (Stat, error) {
 // Stat stats a file or folder and returns the base stat object from go along
 // with the MIME data that can be used for editing files.
 func (fs *Filesystem) Stat(p string) (Stat, error) {
+	p, err := fs.resolve(p)
+	if err != nil {
+		return Stat{}, err
+	}
 	f, err := fs.unixFS.Open(p)
 	if err != nil {
 		return Stat{}, err
@@ -82,3 +117,28 @@ func (fs *Filesystem) Stat(p string) (Stat, error) {
 	}
 	return st, nil
 }
+
+// FileType returns the type of whatever is at the given path without following
+// symlinks, one of "file", "directory", "symlink" or "other". An empty string is
+// returned if nothing exists at the path, which includes a path with a file in
+// place of one of its parent directories and a path outside of the server
+// directory.
+func (fs *Filesystem) FileType(p string) (string, error) {
+	st, err := fs.unixFS.Lstat(p)
+	if err != nil {
+		if errors.Is(err, ufs.ErrNotExist) || errors.Is(err, ufs.ErrNotDirectory) || errors.Is(err, ufs.ErrBadPathResolution) {
+			return "", nil
+		}
+		return "", err
+	}
+	switch {
+	case st.Mode()&ufs.ModeSymlink != 0:
+		return "symlink", nil
+	case st.IsDir():
+		return "directory", nil
+	case st.Mode().IsRegular():
+		return "file", nil
+	default:
+		return "other", nil
+	}
+}
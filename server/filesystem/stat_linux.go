@@ -5,12 +5,14 @@ import (
 	"time"
 
 	"golang.org/x/sys/unix"
+
+	"github.com/kristiangarcia/wings/internal/ufs"
 )
 
-// CTime returns the time that the file/folder was created.
-//
-// TODO: remove. Ctim is not actually ever been correct and doesn't actually
-// return the creation time.
+// CTime returns the time that the file/folder metadata was last changed. On Linux
+// this is the inode change time, which is updated whenever the file is written to,
+// renamed, or has its permissions changed, it is NOT the time the file was created.
+// Use Created to get the best available creation time for a file.
 func (s *Stat) CTime() time.Time {
 	if st, ok := s.Sys().(*unix.Stat_t); ok {
 		// Do not remove these "redundant" type-casts, they are required for 32-bit builds to work.
@@ -22,3 +24,62 @@ func (s *Stat) CTime() time.Time {
 	}
 	return time.Time{}
 }
+
+// ATime returns the time that the file/folder was last accessed. Many filesystems
+// are mounted with "relatime" or "noatime", so this value may not be up to date.
+func (s *Stat) ATime() time.Time {
+	if st, ok := s.Sys().(*unix.Stat_t); ok {
+		// Do not remove these "redundant" type-casts, they are required for 32-bit builds to work.
+		return time.Unix(int64(st.Atim.Sec), int64(st.Atim.Nsec))
+	}
+	if st, ok := s.Sys().(*syscall.Stat_t); ok {
+		// Do not remove these "redundant" type-casts, they are required for 32-bit builds to work.
+		return time.Unix(int64(st.Atim.Sec), int64(st.Atim.Nsec))
+	}
+	return time.Time{}
+}
+
+// deviceID returns the ID of the device that the file is on, or false if the
+// file information does not include it.
+func deviceID(info ufs.FileInfo) (uint64, bool) {
+	if st, ok := info.Sys().(*unix.Stat_t); ok {
+		// Do not remove this "redundant" type-cast, it is required for 32-bit builds to work.
+		return uint64(st.Dev), true
+	}
+	return 0, false
+}
+
+// FileID identifies a single file on the system by the device it is on and its
+// inode number, so that the same file is recognised no matter which path it is
+// reached by, including from within a container.
+type FileID struct {
+	Dev uint64
+	Ino uint64
+}
+
+// FileIDOf returns the ID of the file that the given info was returned for,
+// either by the os package or by ufs.
+func FileIDOf(info ufs.FileInfo) (FileID, bool) {
+	// Do not remove these "redundant" type-casts, they are required for 32-bit
+	// builds to work.
+	switch st := info.Sys().(type) {
+	case *unix.Stat_t:
+		return FileID{Dev: uint64(st.Dev), Ino: uint64(st.Ino)}, true
+	case *syscall.Stat_t:
+		return FileID{Dev: uint64(st.Dev), Ino: uint64(st.Ino)}, true
+	}
+	return FileID{}, false
+}
+
+// birthtime returns the creation time of the open file using statx. A zero time
+// is returned if the kernel or filesystem does not record when files are created.
+func birthtime(fd uintptr) time.Time {
+	var stx unix.Statx_t
+	if err := unix.Statx(int(fd), "", unix.AT_EMPTY_PATH, unix.STATX_BTIME, &stx); err != nil {
+		return time.Time{}
+	}
+	if stx.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}
+	}
+	return time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec))
+}
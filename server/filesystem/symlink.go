@@ -0,0 +1,128 @@
+package filesystem
+
+import (
+	"path"
+	"strings"
+
+	"emperror.dev/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+// The policies that control how symlinks within a server directory are handled
+// by file operations.
+const (
+	// SymlinkPolicyReject returns an error when a symlink is accessed directly, and
+	// skips over them when searching files. This is the default behavior.
+	SymlinkPolicyReject = "reject"
+	// SymlinkPolicyFollow resolves symlinks when they are accessed, as long as the
+	// target is a relative path that stays within the server directory.
+	SymlinkPolicyFollow = "follow"
+	// SymlinkPolicyIgnore treats symlinks as if they do not exist.
+	SymlinkPolicyIgnore = "ignore"
+)
+
+// maxSymlinks is the number of symlinks that will be followed when resolving a
+// single path before giving up, matching the limit used by Linux.
+const maxSymlinks = 40
+
+// symlinkPolicy returns the configured policy for handling symlinks, falling back
+// to rejecting them if the configured value is not recognized.
+func symlinkPolicy() string {
+	switch p := config.Get().Filesystem.SymlinkPolicy; p {
+	case SymlinkPolicyFollow, SymlinkPolicyIgnore:
+		return p
+	default:
+		return SymlinkPolicyReject
+	}
+}
+
+// resolve applies the symlink policy to the given path, returning the path that
+// should be used to access the file.
+//
+// When following symlinks every element of the path is resolved, otherwise an
+// error is returned if any element of the path is a symlink. Deleting files does
+// not use this, removing a symlink only ever removes the link itself and never
+// the file it points to.
+func (fs *Filesystem) resolve(p string) (string, error) {
+	return fs.resolvePolicy(p, fs.symlinks)
+}
+
+// resolvePolicy is like resolve but applies the given symlink policy rather than
+// the configured one.
+func (fs *Filesystem) resolvePolicy(p string, policy string) (string, error) {
+	parts := strings.Split(strings.Trim(path.Clean("/"+p), "/"), "/")
+	var current string
+	var links int
+	for i := 0; i < len(parts); i++ {
+		if parts[i] == "" {
+			continue
+		}
+		next := path.Join(current, parts[i])
+		st, err := fs.unixFS.Lstat(next)
+		if err != nil {
+			// Anything that does not exist cannot be a symlink, the operation itself
+			// will return the appropriate error for this.
+			if errors.Is(err, ufs.ErrNotExist) {
+				return path.Join(append([]string{current}, parts[i:]...)...), nil
+			}
+			return "", err
+		}
+		if st.Mode()&ufs.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+
+		switch policy {
+		case SymlinkPolicyIgnore:
+			return "", errors.WithStack(&Error{code: ErrNotExist, path: p, resolved: next})
+		case SymlinkPolicyFollow:
+		default:
+			return "", errors.WithStack(&Error{code: ErrCodeSymlink, path: p, resolved: next})
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", errors.WithStack(&Error{code: ErrCodeSymlink, path: p, resolved: next})
+		}
+		target, err := fs.readlink(next)
+		if err != nil {
+			return "", err
+		}
+		// Absolute targets point to a location on the host system (or within the
+		// container) rather than the server directory, so they are never followed.
+		if path.IsAbs(target) {
+			return "", NewBadPathResolution(p, target)
+		}
+		resolved := path.Join(current, target)
+		if resolved == ".." || strings.HasPrefix(resolved, "../") {
+			return "", NewBadPathResolution(p, resolved)
+		}
+		// Start over from the root with the target in place of the symlink, since
+		// the target may itself contain more symlinks.
+		parts = append(strings.Split(resolved, "/"), parts[i+1:]...)
+		current = ""
+		i = -1
+	}
+	if current == "" {
+		return "/", nil
+	}
+	return current, nil
+}
+
+// readlink returns the target of the symlink at the given path.
+func (fs *Filesystem) readlink(p string) (string, error) {
+	dirfd, name, closeFd, err := fs.unixFS.SafePath(p)
+	defer closeFd()
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, unix.PathMax)
+	n, err := unix.Readlinkat(dirfd, name, buf)
+	if err != nil {
+		return "", errors.Wrap(err, "filesystem: failed to read symlink")
+	}
+	return string(buf[:n]), nil
+}
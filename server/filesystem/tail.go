@@ -0,0 +1,165 @@
+package filesystem
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// ErrNotRegularFile is returned when tailing something that is not a regular
+// file, such as a named pipe or a device.
+var ErrNotRegularFile = errors.Sentinel("filesystem: not a regular file")
+
+// maxTailBytes is the most bytes from the end of a file that are read when
+// tailing it, a file with longer lines returns fewer of them.
+const maxTailBytes = 4 * 1024 * 1024
+
+// TailOptions controls which lines are returned when tailing a file.
+type TailOptions struct {
+	// The number of lines from the end of the file that are returned.
+	Lines int
+	// The most bytes returned for each line, longer lines are cut short.
+	MaxLineBytes int
+	// If set, only lines that this returns true for are returned and counted
+	// against the number of lines. It is called with the full line, before it is
+	// cut short.
+	Match func(line []byte) bool
+}
+
+// TailResult is the end of a file returned by Tail.
+type TailResult struct {
+	Lines []string
+	// Whether the lines do not reach back to the start of the file, either because
+	// the file has more lines or because only the end of it was read.
+	Truncated bool
+}
+
+// Tail returns the last lines of the file at the given path, oldest first. Only
+// the last few megabytes of the file are read, and only the contents that
+// existed when the file was opened, so a log that is being written to while it
+// is read returns a consistent set of lines. A line still being written at the
+// end of the file is included.
+func (fs *Filesystem) Tail(p string, opts TailOptions) (*TailResult, error) {
+	if opts.Lines <= 0 || opts.MaxLineBytes <= 0 {
+		return nil, errors.New("server/filesystem: tail: lines and line size must be greater than zero")
+	}
+	// The file is checked before it is opened since opening a named pipe would
+	// block until something writes to it.
+	resolved, err := fs.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	if st, err := fs.unixFS.Stat(resolved); err != nil {
+		return nil, err
+	} else if st.IsDir() {
+		return nil, errors.WithStack(&Error{code: ErrCodeIsDirectory, resolved: p})
+	} else if !st.Mode().IsRegular() {
+		return nil, errors.WithStack(ErrNotRegularFile)
+	}
+	f, st, err := fs.File(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	start := max(0, st.Size()-maxTailBytes)
+	buf := make([]byte, st.Size()-start)
+	if _, err := f.ReadAt(buf, start); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "server/filesystem: tail: failed to read file")
+	}
+	buf = bytes.TrimSuffix(buf, []byte("\n"))
+	out := &TailResult{Lines: []string{}}
+	if len(buf) == 0 {
+		return out, nil
+	}
+	// The first line is cut short when the start of the file is not read, so it
+	// is never returned.
+	split := bytes.Split(buf, []byte("\n"))
+	if start > 0 {
+		split = split[1:]
+		out.Truncated = true
+	}
+
+	for i := len(split) - 1; i >= 0; i-- {
+		line := bytes.TrimSuffix(split[i], []byte("\r"))
+		if opts.Match != nil && !opts.Match(line) {
+			continue
+		}
+		if len(out.Lines) == opts.Lines {
+			out.Truncated = true
+			break
+		}
+		out.Lines = append(out.Lines, string(line[:min(len(line), opts.MaxLineBytes)]))
+	}
+	for i, j := 0, len(out.Lines)-1; i < j; i, j = i+1, j-1 {
+		out.Lines[i], out.Lines[j] = out.Lines[j], out.Lines[i]
+	}
+	return out, nil
+}
+
+// JSONLogLine is a single line of a file that was tailed as JSON. If the line
+// could not be parsed as a JSON object it is returned in Raw instead.
+type JSONLogLine struct {
+	Data map[string]any `json:"data"`
+	Raw  *string        `json:"raw,omitempty"`
+}
+
+// TailJSON returns the last lines of the file at the given path in the same way
+// as Tail, parsing each of them as a JSON object. Lines that are not an object,
+// or that were cut short, are returned as they are. If field is set only the
+// lines that are objects with that field set to value are returned, nested
+// fields are separated with a dot such as "log.level". Non-string values are
+// compared by their JSON form, so a value of "5" matches the number 5.
+func (fs *Filesystem) TailJSON(p string, opts TailOptions, field, value string) ([]JSONLogLine, bool, error) {
+	if field != "" {
+		opts.Match = func(line []byte) bool {
+			v, ok := jsonField(line, field)
+			return ok && v == value
+		}
+	}
+	res, err := fs.Tail(p, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	out := make([]JSONLogLine, len(res.Lines))
+	for i, line := range res.Lines {
+		if err := json.Unmarshal([]byte(line), &out[i].Data); err != nil || out[i].Data == nil {
+			out[i] = JSONLogLine{Raw: &res.Lines[i]}
+		}
+	}
+	return out, res.Truncated, nil
+}
+
+// jsonField returns the value of the given dot separated field of the JSON
+// object in line, as a string.
+func jsonField(line []byte, field string) (string, bool) {
+	var v any
+	if err := json.Unmarshal(line, &v); err != nil {
+		return "", false
+	}
+	for _, key := range strings.Split(field, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if v, ok = obj[key]; !ok {
+			return "", false
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case nil:
+		return "null", true
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v), true
+		}
+		return string(b), true
+	}
+}
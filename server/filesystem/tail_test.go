@@ -0,0 +1,83 @@
+package filesystem
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_Tail(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("Tail", func() {
+		g.BeforeEach(func() {
+			_ = fs.CreateDirectory("logs", "/")
+			_ = rfs.CreateServerFileFromString("logs/latest.log", "first\nsecond\r\nthird\nfourth\n")
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("returns the last lines of a file", func() {
+			res, err := fs.Tail("logs/latest.log", TailOptions{Lines: 2, MaxLineBytes: 1024})
+			g.Assert(err).IsNil()
+			g.Assert(res.Lines).Equal([]string{"third", "fourth"})
+			g.Assert(res.Truncated).IsTrue()
+
+			res, err = fs.Tail("logs/latest.log", TailOptions{Lines: 10, MaxLineBytes: 3})
+			g.Assert(err).IsNil()
+			g.Assert(res.Lines).Equal([]string{"fir", "sec", "thi", "fou"})
+			g.Assert(res.Truncated).IsFalse()
+		})
+
+		g.It("returns an error for files that do not exist", func() {
+			_, err := fs.Tail("logs/missing.log", TailOptions{Lines: 2, MaxLineBytes: 1024})
+			g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+		})
+	})
+
+	g.Describe("TailJSON", func() {
+		g.BeforeEach(func() {
+			_ = fs.CreateDirectory("logs", "/")
+			_ = rfs.CreateServerFileFromString("logs/latest.json", strings.Join([]string{
+				`{"level":"info","msg":"starting","log":{"code":1}}`,
+				`not json`,
+				`{"level":"error","msg":"failed","log":{"code":5}}`,
+				`{"level":"info","msg":"stopped"}`,
+			}, "\n"))
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("parses each line as JSON", func() {
+			lines, truncated, err := fs.TailJSON("logs/latest.json", TailOptions{Lines: 3, MaxLineBytes: 1024}, "", "")
+			g.Assert(err).IsNil()
+			g.Assert(truncated).IsTrue()
+			g.Assert(len(lines)).Equal(3)
+			g.Assert(*lines[0].Raw).Equal("not json")
+			g.Assert(lines[0].Data == nil).IsTrue()
+			g.Assert(lines[1].Data["msg"]).Equal("failed")
+			g.Assert(lines[2].Raw == nil).IsTrue()
+		})
+
+		g.It("filters lines by a field", func() {
+			lines, _, err := fs.TailJSON("logs/latest.json", TailOptions{Lines: 10, MaxLineBytes: 1024}, "level", "info")
+			g.Assert(err).IsNil()
+			g.Assert(len(lines)).Equal(2)
+			g.Assert(lines[0].Data["msg"]).Equal("starting")
+			g.Assert(lines[1].Data["msg"]).Equal("stopped")
+
+			lines, _, err = fs.TailJSON("logs/latest.json", TailOptions{Lines: 10, MaxLineBytes: 1024}, "log.code", "5")
+			g.Assert(err).IsNil()
+			g.Assert(len(lines)).Equal(1)
+			g.Assert(lines[0].Data["msg"]).Equal("failed")
+		})
+	})
+}
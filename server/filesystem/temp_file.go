@@ -0,0 +1,108 @@
+package filesystem
+
+import (
+	"path"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/google/uuid"
+
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+// tempFilePrefix is the prefix of the hidden files that contents are written to
+// before being moved into place.
+const tempFilePrefix = ".wings-tmp-"
+
+// tempFile is a hidden file in the server directory that contents are written
+// to before it is renamed over the file they are meant for, so that the file is
+// either untouched or holds all of the new contents and is never seen partially
+// written.
+//
+// Calling cleanup removes the file unless it was committed, so deferring it as
+// soon as the file is created means nothing is left behind when writing fails
+// or is canceled part way through.
+type tempFile struct {
+	ufs.File
+	fs   *Filesystem
+	path string
+	mode ufs.FileMode
+	done bool
+}
+
+// createTempFile creates a new temporary file in the given directory, creating
+// the directory if it does not exist. The file must be in the same directory as
+// the one it will replace so that both are on the same filesystem and it can be
+// renamed into place.
+func (fs *Filesystem) createTempFile(dir string, mode ufs.FileMode) (*tempFile, error) {
+	p := path.Join(path.Clean("/"+dir), tempFilePrefix+uuid.New().String())
+	f, err := fs.unixFS.Touch(p, ufs.O_RDWR|ufs.O_EXCL, mode)
+	if err != nil {
+		return nil, err
+	}
+	return &tempFile{File: f, fs: fs, path: p, mode: mode}, nil
+}
+
+// commit closes the file, sets its mode and owner, and then renames it to the
+// given path, replacing anything that is already there.
+func (t *tempFile) commit(p string) error {
+	if err := t.File.Close(); err != nil {
+		return errors.Wrap(err, "server/filesystem: failed to close temporary file")
+	}
+	// The mode given when creating the file is reduced by the umask, so set it
+	// explicitly to make sure it is exactly what was asked for.
+	if err := t.fs.unixFS.Chmod(t.path, t.mode); err != nil {
+		return err
+	}
+	if err := t.fs.chownFile(t.path); err != nil {
+		return err
+	}
+	if err := t.fs.replaceFile(t.path, p); err != nil {
+		return err
+	}
+	t.done = true
+	return nil
+}
+
+// cleanup closes and removes the file if it was never committed. It is safe to
+// call more than once.
+func (t *tempFile) cleanup() {
+	if t.done {
+		return
+	}
+	t.done = true
+	_ = t.File.Close()
+	// Nothing written to the file is added to the disk usage until it is
+	// committed, so it must not be subtracted when removing it either.
+	_ = t.fs.unixFS.UnixFS.Remove(t.path)
+}
+
+// isTempFile returns true if the given path is a temporary file that contents
+// are still being written to.
+func isTempFile(p string) bool {
+	return strings.HasPrefix(path.Base(p), tempFilePrefix)
+}
+
+// removeTempFiles deletes every temporary file left behind in the server
+// directory, which happens if Wings stops while a file is still being written.
+// This is only called while the filesystem is being created, before anything
+// can have started writing to it.
+func (fs *Filesystem) removeTempFiles() {
+	err := fs.unixFS.WalkDir("/", func(p string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.Type().IsRegular() || !isTempFile(p) {
+			return nil
+		}
+		// Temporary files are never added to the disk usage, so removing them
+		// must not subtract from it either.
+		if err := fs.unixFS.UnixFS.Remove(p); err != nil && !errors.Is(err, ufs.ErrNotExist) {
+			fs.error(err).WithField("path", p).Warn("failed to remove leftover temporary file")
+		}
+		return nil
+	})
+	if err != nil {
+		fs.error(err).Warn("failed to remove leftover temporary files")
+	}
+}
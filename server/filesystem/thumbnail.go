@@ -0,0 +1,192 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"sync"
+
+	"emperror.dev/errors"
+	"golang.org/x/sync/semaphore"
+)
+
+// ErrNotImage is returned when a thumbnail is requested for a file that is not
+// an image in one of the supported formats.
+var ErrNotImage = errors.Sentinel("filesystem: file is not a supported image")
+
+// ErrImageTooLarge is returned when an image has too many pixels to safely be
+// decoded in order to generate a thumbnail of it.
+var ErrImageTooLarge = errors.Sentinel("filesystem: image is too large to generate a thumbnail")
+
+// maxThumbnailSourceSize is the largest image file that a thumbnail can be
+// generated for.
+const maxThumbnailSourceSize = 32 * 1024 * 1024
+
+// maxThumbnailSourcePixels is the most pixels that an image can have for a
+// thumbnail to be generated, since the whole image is decoded into memory.
+// Compressed images can be far larger once decoded than they are on the disk.
+const maxThumbnailSourcePixels = 40_000_000
+
+// maxCachedThumbnailBytes is the total size of the thumbnails kept in memory for
+// each server, the oldest are dropped once they take up more than this.
+const maxCachedThumbnailBytes = 16 * 1024 * 1024
+
+// maxThumbnailDecodes is the most images decoded at once across every server on
+// the node, since each one can take a lot of memory while it is decoded.
+const maxThumbnailDecodes = 4
+
+// thumbnailDecodes limits the number of images being decoded at once.
+var thumbnailDecodes = semaphore.NewWeighted(maxThumbnailDecodes)
+
+// thumbnailDecoders are the image formats that thumbnails can be generated for,
+// by their mimetype.
+var thumbnailDecoders = map[string]func(io.Reader) (image.Image, error){
+	"image/png":  png.Decode,
+	"image/jpeg": jpeg.Decode,
+	"image/gif":  gif.Decode,
+}
+
+// thumbnailCache holds recently generated thumbnails, keyed by the path and
+// modification time of the image and the size of the thumbnail so that a
+// changed image never returns a stale thumbnail.
+type thumbnailCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	order   []string
+	size    int
+}
+
+func (c *thumbnailCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.entries[key]
+	return b, ok
+}
+
+func (c *thumbnailCache) put(key string, b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string][]byte)
+	}
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	// A thumbnail that could never fit would only empty the cache.
+	if len(b) > maxCachedThumbnailBytes {
+		return
+	}
+	c.entries[key] = b
+	c.order = append(c.order, key)
+	c.size += len(b)
+	for c.size > maxCachedThumbnailBytes {
+		c.size -= len(c.entries[c.order[0]])
+		delete(c.entries, c.order[0])
+		c.order = slices.Delete(c.order, 0, 1)
+	}
+}
+
+// Thumbnail returns a PNG encoded copy of the image at the given path, scaled
+// down so that neither of its sides are longer than size pixels, keeping its
+// aspect ratio. Images that are already small enough are not scaled up. Only
+// PNG, JPEG and GIF images are supported, using the detected mimetype of the
+// file rather than its extension, and ErrNotImage is returned for any other
+// file. Only a few images are decoded at once across every server, so this
+// waits until one of them has finished or the context is canceled.
+func (fs *Filesystem) Thumbnail(ctx context.Context, p string, size int) ([]byte, error) {
+	if size <= 0 {
+		return nil, errors.New("server/filesystem: thumbnail: size must be greater than zero")
+	}
+	f, st, err := fs.File(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	decode, ok := thumbnailDecoders[st.Mimetype]
+	if !ok || !st.Mode().IsRegular() {
+		return nil, errors.WithStack(ErrNotImage)
+	}
+	if st.Size() > maxThumbnailSourceSize {
+		return nil, errors.WithStack(&Error{code: ErrCodeTooLarge, resolved: p})
+	}
+
+	key := path.Clean("/"+p) + ":" + strconv.FormatInt(st.ModTime().UnixNano(), 10) + ":" + strconv.FormatInt(st.Size(), 10) + ":" + strconv.Itoa(size)
+	if b, ok := fs.thumbnails.get(key); ok {
+		return b, nil
+	}
+
+	if err := thumbnailDecodes.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer thumbnailDecodes.Release(1)
+
+	src, err := io.ReadAll(io.LimitReader(f, maxThumbnailSourceSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "server/filesystem: thumbnail: failed to read image")
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return nil, errors.WithStack(ErrNotImage)
+	}
+	if cfg.Width*cfg.Height > maxThumbnailSourcePixels {
+		return nil, errors.WithStack(ErrImageTooLarge)
+	}
+	img, err := decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, errors.WithStack(ErrNotImage)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scaleImage(img, size)); err != nil {
+		return nil, errors.Wrap(err, "server/filesystem: thumbnail: failed to encode thumbnail")
+	}
+	fs.thumbnails.put(key, buf.Bytes())
+	return buf.Bytes(), nil
+}
+
+// scaleImage shrinks the image so that neither side is longer than size,
+// averaging every source pixel that falls within each pixel of the result so
+// that fine detail such as text in a texture does not alias.
+func scaleImage(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	dw, dh := size, size
+	if w > h {
+		dh = max(1, h*size/w)
+	} else {
+		dw = max(1, w*size/h)
+	}
+
+	out := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+max((x+1)*w/dw, x*w/dw+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					// These are alpha premultiplied, so transparent pixels do not darken
+					// the edges of whatever is next to them.
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			c := color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)}
+			out.Set(x, y, c)
+		}
+	}
+	return out
+}
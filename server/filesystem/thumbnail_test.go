@@ -0,0 +1,106 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_Thumbnail(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	writeImage := func(p string, w, h int) {
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				img.Set(x, y, color.NRGBA{R: 255, A: 255})
+			}
+		}
+		var buf bytes.Buffer
+		g.Assert(png.Encode(&buf, img)).IsNil()
+		g.Assert(rfs.CreateServerFileFromString(p, buf.String())).IsNil()
+	}
+
+	decode := func(b []byte) image.Image {
+		img, err := png.Decode(bytes.NewReader(b))
+		g.Assert(err).IsNil()
+		return img
+	}
+
+	g.Describe("Thumbnail", func() {
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("scales images down keeping their aspect ratio", func() {
+			writeImage("icon.png", 400, 200)
+			b, err := fs.Thumbnail(context.Background(), "icon.png", 100)
+			g.Assert(err).IsNil()
+			img := decode(b)
+			g.Assert(img.Bounds().Dx()).Equal(100)
+			g.Assert(img.Bounds().Dy()).Equal(50)
+			r, _, _, a := img.At(10, 10).RGBA()
+			g.Assert(r>>8 == 255 && a>>8 == 255).IsTrue()
+		})
+
+		g.It("does not scale small images up", func() {
+			writeImage("icon.png", 64, 32)
+			b, err := fs.Thumbnail(context.Background(), "icon.png", 256)
+			g.Assert(err).IsNil()
+			g.Assert(decode(b).Bounds().Dx()).Equal(64)
+		})
+
+		g.It("generates a new thumbnail once the image changes", func() {
+			writeImage("icon.png", 400, 400)
+			_, err := fs.Thumbnail(context.Background(), "icon.png", 100)
+			g.Assert(err).IsNil()
+
+			writeImage("icon.png", 200, 400)
+			later := time.Now().Add(time.Minute)
+			g.Assert(os.Chtimes(filepath.Join(rfs.root, "server", "icon.png"), later, later)).IsNil()
+			b, err := fs.Thumbnail(context.Background(), "icon.png", 100)
+			g.Assert(err).IsNil()
+			g.Assert(decode(b).Bounds().Dx()).Equal(50)
+		})
+
+		g.It("rejects files that are not images", func() {
+			_ = rfs.CreateServerFileFromString("icon.png", "motd=hello world")
+			_, err := fs.Thumbnail(context.Background(), "icon.png", 100)
+			g.Assert(errors.Is(err, ErrNotImage)).IsTrue()
+		})
+
+		g.It("returns an error for files that do not exist", func() {
+			_, err := fs.Thumbnail(context.Background(), "icon.png", 100)
+			g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+		})
+
+		g.It("drops the oldest thumbnails once the cache is full", func() {
+			var c thumbnailCache
+			chunk := make([]byte, maxCachedThumbnailBytes/4)
+			for _, key := range []string{"a", "b", "c", "d", "e"} {
+				c.put(key, chunk)
+			}
+			_, ok := c.get("a")
+			g.Assert(ok).IsFalse()
+			_, ok = c.get("e")
+			g.Assert(ok).IsTrue()
+			g.Assert(c.size).Equal(maxCachedThumbnailBytes)
+
+			c.put("huge", make([]byte, maxCachedThumbnailBytes+1))
+			_, ok = c.get("huge")
+			g.Assert(ok).IsFalse()
+			_, ok = c.get("b")
+			g.Assert(ok).IsTrue()
+		})
+	})
+}
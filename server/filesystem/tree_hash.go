@@ -0,0 +1,267 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"emperror.dev/errors"
+
+	"github.com/kristiangarcia/wings/internal/ufs"
+)
+
+// ErrTreeTooLarge is returned when a directory has more files, directories and
+// symlinks within it than the limit given when hashing it.
+var ErrTreeTooLarge = errors.Sentinel("filesystem: directory has too many files to hash")
+
+// maxCachedTreeHashes is the number of directory hashes kept in memory for each
+// server, the oldest is dropped once there are more.
+const maxCachedTreeHashes = 32
+
+// TreeHash is the hash of every file and directory within a directory.
+type TreeHash struct {
+	Hash        string `json:"hash"`
+	Files       int    `json:"files"`
+	Directories int    `json:"directories"`
+	Symlinks    int    `json:"symlinks"`
+	// The total size of every file that was hashed.
+	Size int64 `json:"size"`
+	// Whether the hash was returned from the cache rather than read from the
+	// disk, because nothing in the directory has changed since it was last
+	// hashed.
+	Cached bool `json:"cached"`
+}
+
+// treeEntry is a single file, directory or symlink found while walking the
+// directory being hashed.
+type treeEntry struct {
+	name string
+	kind byte
+	hash string
+	size int64
+	// Set if the file was removed before it could be hashed, in which case it is
+	// left out as if it was never found.
+	missing bool
+}
+
+// treeHashCache holds recently computed directory hashes, keyed by the
+// directory and a fingerprint of the name, size and modification time of
+// everything within it so that a changed directory is always hashed again.
+type treeHashCache struct {
+	mu      sync.Mutex
+	entries map[string]TreeHash
+	order   []string
+}
+
+func (c *treeHashCache) get(key string) (TreeHash, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, ok := c.entries[key]
+	return h, ok
+}
+
+func (c *treeHashCache) put(key string, h TreeHash) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]TreeHash)
+	}
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	c.entries[key] = h
+	c.order = append(c.order, key)
+	if len(c.order) > maxCachedTreeHashes {
+		delete(c.entries, c.order[0])
+		c.order = slices.Delete(c.order, 0, 1)
+	}
+}
+
+// HashTree returns a single hash over everything within the given directory,
+// so that two directories can be checked for differences by comparing their
+// hashes rather than every file within them.
+//
+// The hash is built like a Merkle tree. Each file is hashed by its contents and
+// each symlink by its target, then each directory is hashed over the sorted
+// names, types and hashes of everything directly within it. Only names and
+// contents are part of the hash, so directories with identical contents on two
+// different servers always have the same hash regardless of when the files
+// were written or who owns them. Anything other than regular files,
+// directories and symlinks is skipped, as are temporary files that are still
+// being written.
+//
+// If maxFiles is greater than zero and the directory has more files,
+// directories and symlinks than that ErrTreeTooLarge is returned, since a hash
+// of only some of them could not be compared to anything. Anything removed
+// while hashing is left out, as if it was already gone.
+func (fs *Filesystem) HashTree(ctx context.Context, p string, maxFiles int) (*TreeHash, error) {
+	root, err := fs.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	root = path.Clean("/" + root)
+	st, err := fs.unixFS.Lstat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !st.IsDir() {
+		return nil, errors.WithStack(&Error{code: ErrCodeNotDirectory, resolved: p})
+	}
+
+	out := &TreeHash{}
+	children := make(map[string][]*treeEntry)
+	var files []*treeEntry
+	var paths []string
+	fingerprint := sha256.New()
+	err = fs.unixFS.WalkDir(root, func(p string, _ ufs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		p = path.Clean("/" + p)
+		if p == root {
+			return nil
+		}
+		if isTempFile(p) {
+			return nil
+		}
+		info, err := fs.unixFS.Lstat(p)
+		if err != nil {
+			// Anything removed while walking is left out, as if it was already gone.
+			if errors.Is(err, ufs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		e := &treeEntry{name: path.Base(p)}
+		switch t := info.Mode().Type(); {
+		case t.IsDir():
+			e.kind = 'd'
+			out.Directories++
+		case t&ufs.ModeSymlink != 0:
+			target, err := fs.readlink(p)
+			if err != nil {
+				if errors.Is(err, ufs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			e.kind = 'l'
+			out.Symlinks++
+			sum := sha256.Sum256([]byte(target))
+			e.hash = hex.EncodeToString(sum[:])
+		case t.IsRegular():
+			e.kind = 'f'
+			e.size = info.Size()
+			out.Files++
+			out.Size += info.Size()
+			files = append(files, e)
+			paths = append(paths, p)
+		default:
+			return nil
+		}
+		if maxFiles > 0 && out.Files+out.Directories+out.Symlinks > maxFiles {
+			return errors.WithStack(ErrTreeTooLarge)
+		}
+		_, _ = io.WriteString(fingerprint, p+"\x00"+string(e.kind)+"\x00"+strconv.FormatInt(info.Size(), 10)+"\x00"+strconv.FormatInt(info.ModTime().UnixNano(), 10)+"\n")
+		children[path.Dir(p)] = append(children[path.Dir(p)], e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	key := root + ":" + hex.EncodeToString(fingerprint.Sum(nil))
+	if h, ok := fs.treeHashes.get(key); ok {
+		h.Cached = true
+		return &h, nil
+	}
+
+	if err := fs.hashTreeFiles(ctx, files, paths); err != nil {
+		return nil, err
+	}
+	for _, e := range files {
+		if e.missing {
+			out.Files--
+			out.Size -= e.size
+		}
+	}
+	out.Hash = hashTreeDir(root, children)
+	fs.treeHashes.put(key, *out)
+	return out, nil
+}
+
+// hashTreeFiles hashes the contents of every file found while walking the
+// directory, sharing the workers used by searches.
+func (fs *Filesystem) hashTreeFiles(ctx context.Context, files []*treeEntry, paths []string) error {
+	workers, release, err := acquireSearchWorkers(ctx, 4)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var once sync.Once
+	var ferr error
+	var wg sync.WaitGroup
+	pending := make(chan int)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				sum, err := retryTransient(ctx, func() (string, error) { return fs.hashFile(ctx, paths[i]) })
+				if errors.Is(err, ufs.ErrNotExist) {
+					files[i].missing = true
+					continue
+				}
+				if err != nil {
+					once.Do(func() { ferr = err })
+					continue
+				}
+				files[i].hash = sum
+			}
+		}()
+	}
+	for i := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		pending <- i
+	}
+	close(pending)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return ferr
+}
+
+// hashTreeDir returns the hash of the given directory over everything directly
+// within it, hashing each directory within it first.
+func hashTreeDir(dir string, children map[string][]*treeEntry) string {
+	entries := children[dir]
+	slices.SortFunc(entries, func(a, b *treeEntry) int {
+		return strings.Compare(a.name, b.name)
+	})
+	h := sha256.New()
+	for _, e := range entries {
+		if e.missing {
+			continue
+		}
+		if e.kind == 'd' {
+			e.hash = hashTreeDir(path.Join(dir, e.name), children)
+		}
+		// Names cannot contain a NUL byte, so it cannot be confused for part of one.
+		_, _ = io.WriteString(h, string(e.kind)+" "+e.name+"\x00"+e.hash+"\n")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
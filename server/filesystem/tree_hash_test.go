@@ -0,0 +1,96 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestFilesystem_HashTree(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("HashTree", func() {
+		g.BeforeEach(func() {
+			_ = fs.CreateDirectory("a/plugins", "/")
+			_ = fs.CreateDirectory("b/plugins", "/")
+			for _, dir := range []string{"a", "b"} {
+				_ = rfs.CreateServerFileFromString(dir+"/server.properties", "motd=hello world")
+				_ = rfs.CreateServerFileFromString(dir+"/plugins/config.yml", "greeting: hello")
+			}
+		})
+
+		g.It("returns the same hash for directories with identical contents", func() {
+			a, err := fs.HashTree(context.Background(), "a", 0)
+			g.Assert(err).IsNil()
+			b, err := fs.HashTree(context.Background(), "b", 0)
+			g.Assert(err).IsNil()
+			g.Assert(a.Hash).Equal(b.Hash)
+			g.Assert(a.Files).Equal(2)
+			g.Assert(a.Directories).Equal(1)
+			g.Assert(a.Size).Equal(int64(len("motd=hello world") + len("greeting: hello")))
+		})
+
+		g.It("returns a different hash once anything changes", func() {
+			before, err := fs.HashTree(context.Background(), "a", 0)
+			g.Assert(err).IsNil()
+
+			_ = rfs.CreateServerFileFromString("a/plugins/config.yml", "greeting: hi")
+			after, err := fs.HashTree(context.Background(), "a", 0)
+			g.Assert(err).IsNil()
+			g.Assert(after.Cached).IsFalse()
+			g.Assert(after.Hash == before.Hash).IsFalse()
+
+			_ = rfs.CreateServerFileFromString("a/plugins/config.yml", "greeting: hello")
+			_ = fs.Rename("a/plugins/config.yml", "a/plugins/other.yml")
+			renamed, err := fs.HashTree(context.Background(), "a", 0)
+			g.Assert(err).IsNil()
+			g.Assert(renamed.Hash == before.Hash).IsFalse()
+		})
+
+		g.It("returns the cached hash when nothing has changed", func() {
+			first, err := fs.HashTree(context.Background(), "a", 0)
+			g.Assert(err).IsNil()
+			g.Assert(first.Cached).IsFalse()
+
+			second, err := fs.HashTree(context.Background(), "a", 0)
+			g.Assert(err).IsNil()
+			g.Assert(second.Cached).IsTrue()
+			g.Assert(second.Hash).Equal(first.Hash)
+		})
+
+		g.It("fails if the directory has too many files", func() {
+			_, err := fs.HashTree(context.Background(), "a", 1)
+			g.Assert(errors.Is(err, ErrTreeTooLarge)).IsTrue()
+		})
+
+		g.It("counts directories toward the limit", func() {
+			_, err := fs.HashTree(context.Background(), "a", 2)
+			g.Assert(errors.Is(err, ErrTreeTooLarge)).IsTrue()
+
+			h, err := fs.HashTree(context.Background(), "a", 3)
+			g.Assert(err).IsNil()
+			g.Assert(h.Files + h.Directories).Equal(3)
+		})
+
+		g.It("skips a file that is removed before it is hashed", func() {
+			files := []*treeEntry{{name: "server.properties", kind: 'f'}, {name: "missing.txt", kind: 'f'}}
+			err := fs.hashTreeFiles(context.Background(), files, []string{"/a/server.properties", "/a/missing.txt"})
+			g.Assert(err).IsNil()
+			g.Assert(files[0].missing).IsFalse()
+			g.Assert(files[0].hash == "").IsFalse()
+			g.Assert(files[1].missing).IsTrue()
+		})
+
+		g.It("fails if the path is not a directory", func() {
+			_, err := fs.HashTree(context.Background(), "a/server.properties", 0)
+			g.Assert(IsErrorCode(err, ErrCodeNotDirectory)).IsTrue()
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
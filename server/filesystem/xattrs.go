@@ -0,0 +1,67 @@
+package filesystem
+
+import (
+	"bytes"
+	"encoding/base64"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Xattr is a single extended attribute of a file.
+type Xattr struct {
+	Name string `json:"name"`
+	// The value of the attribute. Text values have any trailing NUL removed, and
+	// values that are not valid UTF-8 are encoded as base64.
+	Value  string `json:"value"`
+	Base64 bool   `json:"base64"`
+}
+
+// XattrFilter matches files by one of their extended attributes. If Value is
+// nil any file with the attribute is matched, otherwise its value must be
+// exactly the same.
+type XattrFilter struct {
+	Name  string  `json:"name"`
+	Value *string `json:"value,omitempty"`
+}
+
+// Xattrs returns the extended attributes of the file or directory at the given
+// path, sorted by name. Symlinks are not followed.
+func (fs *Filesystem) Xattrs(p string) ([]Xattr, error) {
+	attrs, err := fs.unixFS.Xattrs(p)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Xattr, 0, len(attrs))
+	for name, v := range attrs {
+		v = bytes.TrimRight(v, "\x00")
+		if utf8.Valid(v) {
+			out = append(out, Xattr{Name: name, Value: string(v)})
+		} else {
+			out = append(out, Xattr{Name: name, Value: base64.StdEncoding.EncodeToString(v), Base64: true})
+		}
+	}
+	slices.SortFunc(out, func(a, b Xattr) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return out, nil
+}
+
+// matchXattrs returns true if the file at the given path matches every one of
+// the filters. Files whose attributes cannot be read never match.
+func (fs *Filesystem) matchXattrs(p string, filters []XattrFilter) bool {
+	attrs, err := fs.unixFS.Xattrs(p)
+	if err != nil {
+		return false
+	}
+	for _, f := range filters {
+		v, ok := attrs[f.Name]
+		if !ok {
+			return false
+		}
+		if f.Value != nil && string(bytes.TrimRight(v, "\x00")) != *f.Value {
+			return false
+		}
+	}
+	return true
+}
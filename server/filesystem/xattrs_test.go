@@ -0,0 +1,69 @@
+package filesystem
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	. "github.com/franela/goblin"
+	"golang.org/x/sys/unix"
+)
+
+func TestFilesystem_Xattrs(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	// Not every filesystem that the tests may run on supports user attributes,
+	// in which case there is nothing to test.
+	supported := true
+	setxattr := func(p, name, value string) {
+		if err := unix.Setxattr(filepath.Join(rfs.root, "server", p), name, []byte(value), 0); err != nil {
+			supported = false
+		}
+	}
+
+	g.Describe("Xattrs", func() {
+		g.BeforeEach(func() {
+			_ = rfs.CreateServerFileFromString("premium.txt", "hello")
+			_ = rfs.CreateServerFileFromString("budget.txt", "hello")
+			_ = rfs.CreateServerFileFromString("plain.txt", "hello")
+			setxattr("premium.txt", "user.tier", "premium")
+			setxattr("budget.txt", "user.tier", "budget")
+			setxattr("budget.txt", "user.raw", "\xff\xfe")
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("returns the attributes of a file sorted by name", func() {
+			if !supported {
+				return
+			}
+			attrs, err := fs.Xattrs("budget.txt")
+			g.Assert(err).IsNil()
+			g.Assert(attrs).Equal([]Xattr{
+				{Name: "user.raw", Value: "//4=", Base64: true},
+				{Name: "user.tier", Value: "budget"},
+			})
+
+			attrs, err = fs.Xattrs("plain.txt")
+			g.Assert(err).IsNil()
+			g.Assert(len(attrs)).Equal(0)
+		})
+
+		g.It("filters search results by attribute presence and value", func() {
+			if !supported {
+				return
+			}
+			results, err := fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{".txt"}, Limit: 100, Xattrs: []XattrFilter{{Name: "user.tier"}}})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"budget.txt", "premium.txt"})
+
+			premium := "premium"
+			results, err = fs.Search(context.Background(), SearchOptions{Root: "/", Queries: []string{".txt"}, Limit: 100, Xattrs: []XattrFilter{{Name: "user.tier", Value: &premium}}})
+			g.Assert(err).IsNil()
+			g.Assert(searchNames(results.Results)).Equal([]string{"premium.txt"})
+		})
+	})
+}
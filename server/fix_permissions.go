@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/kristiangarcia/wings/server/filesystem"
+)
+
+// permissionsProgressInterval is how often the progress of fixing the owner of
+// files is published over the websocket.
+const permissionsProgressInterval = time.Second
+
+// FixPermissions changes the owner of every file within the given path back to
+// the configured user as part of a background operation, publishing its
+// progress over the websocket as it runs and the results once it has finished.
+// Only files matching the filters in the options are changed.
+func (s *Server) FixPermissions(ctx context.Context, op *Operation, p string, opts filesystem.FixPermissionsOptions) (*filesystem.FixPermissionsResult, error) {
+	var checked atomic.Int64
+	pctx, cancel := context.WithCancel(ctx)
+	go s.publishPermissionsProgress(pctx, op.ID, p, &checked)
+
+	opts.OnProgress = func(n int64) {
+		checked.Store(n)
+	}
+	res, err := s.Filesystem().FixPermissions(ctx, p, opts)
+	cancel()
+	if err != nil {
+		s.Log().WithField("path", p).WithField("error", err).Error("failed to start fixing file permissions")
+		s.Events().Publish(PermissionsCompletedEvent+":"+op.ID, map[string]interface{}{
+			"operation": op.ID,
+			"path":      p,
+			"error":     err.Error(),
+		})
+		return nil, err
+	}
+
+	s.Log().WithField("path", p).
+		WithField("checked", res.Checked).
+		WithField("changed", res.Changed).
+		WithField("failed", res.Failed).
+		WithField("canceled", res.Canceled).
+		Info("finished fixing file permissions")
+	s.Events().Publish(PermissionsCompletedEvent+":"+op.ID, map[string]interface{}{
+		"operation": op.ID,
+		"path":      p,
+		"result":    res,
+	})
+	return res, nil
+}
+
+// publishPermissionsProgress periodically emits the number of files checked
+// while fixing their owner over the server websocket until the context is
+// canceled.
+func (s *Server) publishPermissionsProgress(ctx context.Context, id, p string, checked *atomic.Int64) {
+	t := time.NewTicker(permissionsProgressInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.Events().Publish(PermissionsProgressEvent+":"+id, map[string]interface{}{
+				"operation": id,
+				"path":      p,
+				"checked":   checked.Load(),
+			})
+		}
+	}
+}
@@ -144,6 +144,12 @@ func (s *Server) StartEventListeners() {
 
 var stripAnsiRegex = regexp.MustCompile("[\u001B\u009B][[\\]()#;?]*(?:(?:(?:[a-zA-Z\\d]*(?:;[a-zA-Z\\d]*)*)?\u0007)|(?:(?:\\d{1,4}(?:;\\d{0,4})*)?[\\dA-PRZcf-ntqry=><~]))")
 
+// StripAnsi removes any ANSI escape codes, such as colors, from the given line
+// of console output.
+func StripAnsi(v []byte) []byte {
+	return stripAnsiRegex.ReplaceAll(v, []byte(""))
+}
+
 // Custom listener for console output events that will check if the given line
 // of output matches one that should mark the server as started or not.
 func (s *Server) onConsoleOutput(data []byte) {
@@ -162,7 +168,7 @@ func (s *Server) onConsoleOutput(data []byte) {
 	if s.Environment.State() == environment.ProcessStartingState {
 		// Check if we should strip ansi color codes.
 		if processConfiguration.Startup.StripAnsi {
-			v = stripAnsiRegex.ReplaceAll(v, []byte(""))
+			v = StripAnsi(v)
 		}
 
 		// Iterate over all the done lines.
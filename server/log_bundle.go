@@ -0,0 +1,160 @@
+package server
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/internal/redact"
+)
+
+// LogBundleSkipped is a file that was left out of a log bundle.
+type LogBundleSkipped struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// LogBundleManifest describes what was included in a log bundle, it is written
+// to the bundle as manifest.json.
+type LogBundleManifest struct {
+	Server    string    `json:"server"`
+	Generated time.Time `json:"generated"`
+	Files     []string  `json:"files"`
+	// The files that were cut short to keep the bundle within its maximum size.
+	// Logs keep their most recent lines, other files keep their start.
+	Truncated []string           `json:"truncated"`
+	Skipped   []LogBundleSkipped `json:"skipped"`
+}
+
+// logBundle builds the zip archive for ExportLogBundle.
+type logBundle struct {
+	zw       *zip.Writer
+	redactor *redact.Redactor
+	// The number of bytes that can still be added to the bundle.
+	budget   int64
+	manifest LogBundleManifest
+}
+
+// ExportLogBundle writes a zip archive to w that can be attached to a support
+// request, containing the recent console output of the server, the output of
+// its last installation, and the configuration files declared by its egg.
+// Secrets are redacted from every file, see config.SystemConfiguration, and the
+// files are cut short once the maximum size of the bundle is reached. Which
+// files were included is recorded in manifest.json within the archive.
+func (s *Server) ExportLogBundle(w io.Writer) (*LogBundleManifest, error) {
+	cfg := config.Get().System
+	r, err := redact.New(cfg.RedactPatterns)
+	if err != nil {
+		s.Log().WithField("error", err).Warn("ignoring invalid redaction patterns in configuration")
+	}
+	b := &logBundle{
+		zw:       zip.NewWriter(w),
+		redactor: r,
+		budget:   int64(cfg.LogBundle.MaxSize) * 1024 * 1024,
+		manifest: LogBundleManifest{
+			Server:    s.ID(),
+			Generated: time.Now().UTC(),
+			Files:     []string{},
+			Truncated: []string{},
+			Skipped:   []LogBundleSkipped{},
+		},
+	}
+
+	if lines, err := s.ReadLogfile(cfg.LogBundle.LogLines); err != nil {
+		b.skip("console.log", "the console output could not be read")
+	} else if err := b.add("console.log", []byte(strings.Join(lines, "\n")), true); err != nil {
+		return nil, err
+	}
+
+	install := filepath.Join(cfg.LogDirectory, "install", s.ID()+".log")
+	if c, err := os.ReadFile(install); err == nil {
+		if err := b.add("install.log", c, true); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		b.skip("install.log", "the installation log could not be read")
+	}
+
+	for _, p := range s.configBundlePaths() {
+		name := "config/" + p
+		if err := s.Filesystem().IsIgnored(p); err != nil {
+			b.skip(name, "the file is on the denylist")
+			continue
+		}
+		f, st, err := s.Filesystem().File(p)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				b.skip(name, "the file could not be read")
+			}
+			continue
+		}
+		if !st.Mode().IsRegular() {
+			f.Close()
+			b.skip(name, "not a regular file")
+			continue
+		}
+		c, err := io.ReadAll(io.LimitReader(f, maxConfigBundleFileSize))
+		f.Close()
+		if err != nil {
+			b.skip(name, "the file could not be read")
+			continue
+		}
+		if err := b.add(name, c, false); err != nil {
+			return nil, err
+		}
+	}
+
+	mw, err := b.zw.Create("manifest.json")
+	if err != nil {
+		return nil, errors.Wrap(err, "server/logs: failed to add manifest to bundle")
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(b.manifest); err != nil {
+		return nil, errors.Wrap(err, "server/logs: failed to write bundle manifest")
+	}
+	if err := b.zw.Close(); err != nil {
+		return nil, errors.Wrap(err, "server/logs: failed to write bundle")
+	}
+	return &b.manifest, nil
+}
+
+// add redacts and writes a file to the bundle, cutting it short if there is not
+// enough of the budget left for all of it. If tail is true the end of the file
+// is kept rather than the start.
+func (b *logBundle) add(name string, c []byte, tail bool) error {
+	if b.budget <= 0 {
+		b.skip(name, "the bundle reached its maximum size")
+		return nil
+	}
+	c = b.redactor.Redact(c)
+	if int64(len(c)) > b.budget {
+		if tail {
+			c = c[int64(len(c))-b.budget:]
+		} else {
+			c = c[:b.budget]
+		}
+		b.manifest.Truncated = append(b.manifest.Truncated, name)
+	}
+	w, err := b.zw.Create(name)
+	if err != nil {
+		return errors.Wrap(err, "server/logs: failed to add file to bundle")
+	}
+	if _, err := w.Write(c); err != nil {
+		return errors.Wrap(err, "server/logs: failed to write file to bundle")
+	}
+	b.budget -= int64(len(c))
+	b.manifest.Files = append(b.manifest.Files, name)
+	return nil
+}
+
+func (b *logBundle) skip(name, reason string) {
+	b.manifest.Skipped = append(b.manifest.Skipped, LogBundleSkipped{Path: name, Reason: reason})
+}
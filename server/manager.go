@@ -200,6 +200,9 @@ func (m *Manager) InitServer(data remote.ServerConfigurationResponse) (*Server,
 	if err != nil {
 		return nil, errors.WithStackIf(err)
 	}
+	s.fs.OnFileChange(func(changes []filesystem.FileChange) {
+		s.Events().Publish(FileChangeEvent, changes)
+	})
 
 	// Right now we only support a Docker based environment, so I'm going to hard code
 	// this logic in. When we're ready to support other environment we'll need to make
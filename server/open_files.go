@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"emperror.dev/errors"
+
+	"github.com/kristiangarcia/wings/server/filesystem"
+)
+
+// ErrProcUnavailable is returned when the files open by a server cannot be
+// found because /proc is not available on this system.
+var ErrProcUnavailable = errors.Sentinel("server: /proc is not available")
+
+// procRoot is where the process information of the host system is mounted.
+const procRoot = "/proc"
+
+// OpenFiles returns the IDs of every file that the processes of the server
+// currently have open, found by looking through /proc/<pid>/fd for each of
+// them. Files are identified by their device and inode since the paths seen
+// by the processes are the ones within the container. A server that is not
+// running has no open files. If /proc is not available ErrProcUnavailable is
+// returned, and processes that exit while they are being looked at are
+// skipped.
+func (s *Server) OpenFiles(ctx context.Context) (map[filesystem.FileID]struct{}, error) {
+	if _, err := os.Stat(filepath.Join(procRoot, "self", "fd")); err != nil {
+		return nil, errors.WithStack(ErrProcUnavailable)
+	}
+	out := make(map[filesystem.FileID]struct{})
+	if !s.IsRunning() {
+		return out, nil
+	}
+	processes, err := s.Environment.Processes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range processes {
+		dir := filepath.Join(procRoot, strconv.Itoa(p.Pid), "fd")
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			// Stat follows the descriptor to the file itself, wherever it is.
+			st, err := os.Stat(filepath.Join(dir, e.Name()))
+			if err != nil || !st.Mode().IsRegular() {
+				continue
+			}
+			if id, ok := filesystem.FileIDOf(st); ok {
+				out[id] = struct{}{}
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// operationDestroyWait is how long deleting a server waits for its background
+// operations to stop after they have been canceled.
+const operationDestroyWait = 30 * time.Second
+
+// Operation is a long running file operation for a server that runs in the
+// background after the request that started it has returned, and that can be
+// canceled by its ID while it is running.
+type Operation struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Started time.Time `json:"started"`
+
+	cancel context.CancelFunc
+	// Closed once the operation has finished.
+	done chan struct{}
+}
+
+// operationSet tracks the background operations running for a server.
+type operationSet struct {
+	mu      sync.Mutex
+	running map[string]*Operation
+}
+
+// StartOperation registers a new background operation of the given type and
+// returns it along with a context that is canceled when the operation is
+// canceled or the server is deleted. FinishOperation must be called with the
+// ID of the operation once it has finished. Unless CancelOperationsOnStop is
+// disabled the context is also canceled when the server is stopped.
+func (s *Server) StartOperation(typ string) (*Operation, context.Context) {
+	ctx, cancel := context.WithCancel(s.Context())
+	op := &Operation{ID: uuid.New().String(), Type: typ, Started: time.Now(), cancel: cancel, done: make(chan struct{})}
+
+	s.ops.mu.Lock()
+	defer s.ops.mu.Unlock()
+	if s.ops.running == nil {
+		s.ops.running = make(map[string]*Operation)
+	}
+	s.ops.running[op.ID] = op
+	return op, ctx
+}
+
+// FinishOperation removes a finished operation from the server, releasing its
+// context.
+func (s *Server) FinishOperation(id string) {
+	s.ops.mu.Lock()
+	op, ok := s.ops.running[id]
+	delete(s.ops.running, id)
+	s.ops.mu.Unlock()
+	if ok {
+		op.cancel()
+		close(op.done)
+	}
+}
+
+// CancelOperation cancels the running operation with the given ID, returning
+// false if there is no such operation. The operation will stop as soon as it
+// next checks its context.
+func (s *Server) CancelOperation(id string) bool {
+	s.ops.mu.Lock()
+	op, ok := s.ops.running[id]
+	s.ops.mu.Unlock()
+	if ok {
+		op.cancel()
+	}
+	return ok
+}
+
+// CancelOperations cancels every background operation running for the server
+// and waits up to the given duration for them to finish, so that they have
+// stopped using the server files and removed anything temporary they created
+// before the server is torn down. Returns the number of operations that had
+// still not finished once it stopped waiting.
+func (s *Server) CancelOperations(wait time.Duration) int {
+	s.ops.mu.Lock()
+	ops := make([]*Operation, 0, len(s.ops.running))
+	for _, op := range s.ops.running {
+		ops = append(ops, op)
+	}
+	s.ops.mu.Unlock()
+
+	for _, op := range ops {
+		op.cancel()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	var remaining int
+	for _, op := range ops {
+		select {
+		case <-op.done:
+		case <-ctx.Done():
+			select {
+			case <-op.done:
+			default:
+				remaining++
+			}
+		}
+	}
+	return remaining
+}
+
+// Operations returns the background operations currently running for the
+// server, oldest first.
+func (s *Server) Operations() []Operation {
+	s.ops.mu.Lock()
+	out := make([]Operation, 0, len(s.ops.running))
+	for _, op := range s.ops.running {
+		out = append(out, Operation{ID: op.ID, Type: op.Type, Started: op.Started})
+	}
+	s.ops.mu.Unlock()
+	slices.SortFunc(out, func(a, b Operation) int {
+		if c := a.Started.Compare(b.Started); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out
+}
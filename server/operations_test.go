@@ -0,0 +1,40 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+)
+
+func TestOperations(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Server#CancelOperations", func() {
+		g.It("cancels running operations and waits for them to finish", func() {
+			s := &Server{ctx: context.Background()}
+			op, ctx := s.StartOperation("search")
+			finished := make(chan struct{})
+			go func() {
+				defer close(finished)
+				defer s.FinishOperation(op.ID)
+				<-ctx.Done()
+			}()
+
+			g.Assert(s.CancelOperations(time.Second)).Equal(0)
+			g.Assert(ctx.Err() != nil).IsTrue()
+			<-finished
+			g.Assert(len(s.Operations())).Equal(0)
+		})
+
+		g.It("returns the operations that did not finish in time", func() {
+			s := &Server{ctx: context.Background()}
+			op, ctx := s.StartOperation("delete")
+			defer s.FinishOperation(op.ID)
+
+			g.Assert(s.CancelOperations(10 * time.Millisecond)).Equal(1)
+			g.Assert(ctx.Err() != nil).IsTrue()
+		})
+	})
+}
@@ -150,6 +150,7 @@ func (s *Server) HandlePowerAction(action PowerAction, waitSeconds ...int) error
 		}
 
 		if action == PowerActionStop {
+			s.cancelOperationsOnStop()
 			return nil
 		}
 
@@ -160,12 +161,29 @@ func (s *Server) HandlePowerAction(action PowerAction, waitSeconds ...int) error
 
 		return s.Environment.Start(s.Context())
 	case PowerActionTerminate:
-		return s.Environment.Terminate(s.Context(), "SIGKILL")
+		if err := s.Environment.Terminate(s.Context(), "SIGKILL"); err != nil {
+			return err
+		}
+		s.cancelOperationsOnStop()
+		return nil
 	}
 
 	return errors.New("attempting to handle unknown power action")
 }
 
+// cancelOperationsOnStop cancels the background operations of the server once
+// it has been stopped, if the node is configured to. They are not waited for
+// since the server files are left in place.
+func (s *Server) cancelOperationsOnStop() {
+	if !config.Get().Filesystem.CancelOperationsOnStop {
+		return
+	}
+	if n := len(s.Operations()); n > 0 {
+		s.Log().WithField("operations", n).Info("canceling background operations for stopped server")
+		s.CancelOperations(0)
+	}
+}
+
 // Execute a few functions before actually calling the environment start commands. This ensures
 // that everything is ready to go for environment booting, and that the server can even be started.
 func (s *Server) onBeforeStart() error {
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/server/filesystem"
+)
+
+// ErrTooManyAsyncSearches is returned when a background search is started for a
+// server that is already running the most that it is allowed to.
+var ErrTooManyAsyncSearches = errors.Sentinel("server: too many background searches running")
+
+// maxFinishedAsyncSearches is the number of finished background searches whose
+// results are kept for each server, the oldest is removed once there are more
+// even if it has not yet expired.
+const maxFinishedAsyncSearches = 16
+
+// The states that a background search can be in.
+const (
+	AsyncSearchRunning   = "running"
+	AsyncSearchCompleted = "completed"
+	AsyncSearchCanceled  = "canceled"
+	AsyncSearchFailed    = "failed"
+)
+
+// AsyncSearch is a search of the server files that runs in the background, as
+// an operation that can be canceled, so that searches taking longer than an
+// HTTP request can wait for are still possible. Results are collected as they
+// are found, in that order, so that they can be read while it is running.
+type AsyncSearch struct {
+	ID      string
+	Started time.Time
+	// The directory that was searched, so that access to the search can be
+	// checked in the same way as access to the directory.
+	Root string
+
+	mu       sync.Mutex
+	status   string
+	finished time.Time
+	results  []filesystem.SearchResult
+	outcome  *filesystem.SearchResults
+	err      error
+}
+
+// AsyncSearchSnapshot is the state of a background search at the time that it
+// was read, along with the results found since the requested offset.
+type AsyncSearchSnapshot struct {
+	ID       string                    `json:"id"`
+	Status   string                    `json:"status"`
+	Started  time.Time                 `json:"started"`
+	Finished *time.Time                `json:"finished,omitempty"`
+	Results  []filesystem.SearchResult `json:"results"`
+	// The total number of results found so far, which is also the offset to read
+	// from to only get the results found after these.
+	Count int `json:"count"`
+	// The details of how the search finished, only set once it has.
+	Complete      bool   `json:"complete"`
+	Reason        string `json:"reason,omitempty"`
+	Warning       string `json:"warning,omitempty"`
+	MatchesCapped bool   `json:"matches_capped"`
+	Error         string `json:"error,omitempty"`
+}
+
+// asyncSearchSet tracks the background searches for a server.
+type asyncSearchSet struct {
+	mu       sync.Mutex
+	searches map[string]*AsyncSearch
+}
+
+// StartAsyncSearch starts searching the server files with the given options in
+// the background, returning the search so that its results can be read as it
+// runs. The search is also an operation of the server, so it can be canceled
+// by its ID. Once it has finished its results are kept for the configured TTL
+// and then removed, or sooner if the server has too many finished searches.
+func (s *Server) StartAsyncSearch(opts filesystem.SearchOptions) (*AsyncSearch, error) {
+	cfg := config.Get().Filesystem
+
+	s.searches.mu.Lock()
+	var running int
+	for _, as := range s.searches.searches {
+		if as.Status() == AsyncSearchRunning {
+			running++
+		}
+	}
+	if running >= cfg.MaxAsyncSearches {
+		s.searches.mu.Unlock()
+		return nil, errors.WithStack(ErrTooManyAsyncSearches)
+	}
+	op, ctx := s.StartOperation("search")
+	as := &AsyncSearch{ID: op.ID, Started: op.Started, Root: opts.Root, status: AsyncSearchRunning}
+	if s.searches.searches == nil {
+		s.searches.searches = make(map[string]*AsyncSearch)
+	}
+	s.searches.searches[as.ID] = as
+	s.searches.mu.Unlock()
+
+	go func() {
+		defer s.FinishOperation(op.ID)
+		res, _, err := s.Filesystem().SearchFunc(ctx, opts, as.add)
+		if err != nil && ctx.Err() == nil {
+			s.Log().WithField("search", as.ID).WithField("error", err).Warn("background search failed")
+		}
+		as.finish(ctx, res, err)
+		s.removeFinishedSearches()
+		time.AfterFunc(time.Duration(cfg.AsyncSearchTTL)*time.Second, func() {
+			s.searches.mu.Lock()
+			delete(s.searches.searches, as.ID)
+			s.searches.mu.Unlock()
+		})
+	}()
+	return as, nil
+}
+
+// removeFinishedSearches removes the oldest finished background searches until
+// no more than maxFinishedAsyncSearches are left.
+func (s *Server) removeFinishedSearches() {
+	s.searches.mu.Lock()
+	defer s.searches.mu.Unlock()
+	var finished []*AsyncSearch
+	for _, as := range s.searches.searches {
+		if as.Status() != AsyncSearchRunning {
+			finished = append(finished, as)
+		}
+	}
+	if len(finished) <= maxFinishedAsyncSearches {
+		return
+	}
+	slices.SortFunc(finished, func(a, b *AsyncSearch) int {
+		return a.finishedAt().Compare(b.finishedAt())
+	})
+	for _, as := range finished[:len(finished)-maxFinishedAsyncSearches] {
+		delete(s.searches.searches, as.ID)
+	}
+}
+
+// AsyncSearch returns the background search with the given ID, if it is still
+// running or its results have not yet expired.
+func (s *Server) AsyncSearch(id string) (*AsyncSearch, bool) {
+	s.searches.mu.Lock()
+	defer s.searches.mu.Unlock()
+	as, ok := s.searches.searches[id]
+	return as, ok
+}
+
+// Status returns the current state of the search, one of the AsyncSearch
+// values.
+func (as *AsyncSearch) Status() string {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	return as.status
+}
+
+// Snapshot returns the current state of the search with the results found
+// after the given offset.
+func (as *AsyncSearch) Snapshot(offset int) AsyncSearchSnapshot {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	out := AsyncSearchSnapshot{
+		ID:      as.ID,
+		Status:  as.status,
+		Started: as.Started,
+		Count:   len(as.results),
+		Results: []filesystem.SearchResult{},
+	}
+	if offset >= 0 && offset < len(as.results) {
+		// Copied so that results added afterwards are never seen by the caller.
+		out.Results = append(out.Results, as.results[offset:]...)
+	}
+	if !as.finished.IsZero() {
+		finished := as.finished
+		out.Finished = &finished
+	}
+	if as.outcome != nil {
+		out.Complete = as.outcome.Complete
+		out.Reason = as.outcome.Reason
+		out.Warning = as.outcome.Warning
+		out.MatchesCapped = as.outcome.MatchesCapped
+	}
+	if as.err != nil {
+		out.Error = as.err.Error()
+	}
+	return out
+}
+
+func (as *AsyncSearch) finishedAt() time.Time {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	return as.finished
+}
+
+func (as *AsyncSearch) add(r filesystem.SearchResult) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	as.results = append(as.results, r)
+}
+
+func (as *AsyncSearch) finish(ctx context.Context, res *filesystem.SearchResults, err error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	as.finished = time.Now()
+	as.outcome = res
+	switch {
+	// Canceling can stop the search before it has started, which returns the
+	// error from the context rather than the results.
+	case ctx.Err() != nil || errors.Is(err, context.Canceled):
+		as.status = AsyncSearchCanceled
+	case err != nil:
+		as.status = AsyncSearchFailed
+		as.err = err
+	default:
+		as.status = AsyncSearchCompleted
+	}
+}
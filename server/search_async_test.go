@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+
+	"github.com/kristiangarcia/wings/config"
+	"github.com/kristiangarcia/wings/server/filesystem"
+)
+
+func TestAsyncSearch(t *testing.T) {
+	g := Goblin(t)
+
+	newServer := func() (*Server, string) {
+		s, root := newTestServer()
+		config.Update(func(c *config.Configuration) {
+			c.Filesystem.MaxAsyncSearches = 2
+			c.Filesystem.AsyncSearchTTL = 600
+		})
+		return s, root
+	}
+	wait := func(as *AsyncSearch) {
+		deadline := time.Now().Add(5 * time.Second)
+		for as.Status() == AsyncSearchRunning && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	opts := filesystem.SearchOptions{Root: "/", Queries: []string{"hello"}, Limit: 100, MaxSize: 1024}
+
+	g.Describe("Server#StartAsyncSearch", func() {
+		g.It("collects results that can be read from an offset", func() {
+			s, root := newServer()
+			defer os.RemoveAll(root)
+			g.Assert(os.WriteFile(filepath.Join(root, "hello-1.txt"), nil, 0o644)).IsNil()
+			g.Assert(os.WriteFile(filepath.Join(root, "hello-2.txt"), nil, 0o644)).IsNil()
+			g.Assert(os.WriteFile(filepath.Join(root, "other.txt"), nil, 0o644)).IsNil()
+
+			as, err := s.StartAsyncSearch(opts)
+			g.Assert(err).IsNil()
+			g.Assert(as.Root).Equal("/")
+			wait(as)
+
+			snap := as.Snapshot(0)
+			g.Assert(snap.Status).Equal(AsyncSearchCompleted)
+			g.Assert(snap.Count).Equal(2)
+			g.Assert(len(snap.Results)).Equal(2)
+			g.Assert(snap.Complete).IsTrue()
+			g.Assert(snap.Finished != nil).IsTrue()
+			g.Assert(snap.Error).Equal("")
+
+			snap = as.Snapshot(1)
+			g.Assert(snap.Count).Equal(2)
+			g.Assert(len(snap.Results)).Equal(1)
+			g.Assert(len(as.Snapshot(5).Results)).Equal(0)
+
+			found, ok := s.AsyncSearch(as.ID)
+			g.Assert(ok).IsTrue()
+			g.Assert(found == as).IsTrue()
+		})
+
+		g.It("refuses to start more than the configured number of searches", func() {
+			s, root := newServer()
+			defer os.RemoveAll(root)
+			s.searches.searches = map[string]*AsyncSearch{
+				"a": {ID: "a", status: AsyncSearchRunning},
+				"b": {ID: "b", status: AsyncSearchRunning},
+			}
+
+			_, err := s.StartAsyncSearch(opts)
+			g.Assert(errors.Is(err, ErrTooManyAsyncSearches)).IsTrue()
+		})
+
+		g.It("reports a search that is canceled before it starts as canceled", func() {
+			s, root := newServer()
+			defer os.RemoveAll(root)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			s.ctx = ctx
+
+			as, err := s.StartAsyncSearch(opts)
+			g.Assert(err).IsNil()
+			wait(as)
+
+			snap := as.Snapshot(0)
+			g.Assert(snap.Status).Equal(AsyncSearchCanceled)
+			g.Assert(snap.Error).Equal("")
+		})
+
+		g.It("only keeps the most recently finished searches", func() {
+			s, root := newServer()
+			defer os.RemoveAll(root)
+			s.searches.searches = map[string]*AsyncSearch{
+				"running": {ID: "running", status: AsyncSearchRunning},
+			}
+			start := time.Now()
+			for i := 0; i < maxFinishedAsyncSearches+2; i++ {
+				id := strconv.Itoa(i)
+				s.searches.searches[id] = &AsyncSearch{ID: id, status: AsyncSearchCompleted, finished: start.Add(time.Duration(i) * time.Second)}
+			}
+
+			s.removeFinishedSearches()
+			g.Assert(len(s.searches.searches)).Equal(maxFinishedAsyncSearches + 1)
+			for _, id := range []string{"0", "1"} {
+				_, ok := s.AsyncSearch(id)
+				g.Assert(ok).IsFalse(id)
+			}
+			for _, id := range []string{"2", "running"} {
+				_, ok := s.AsyncSearch(id)
+				g.Assert(ok).IsTrue(id)
+			}
+		})
+	})
+}
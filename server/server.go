@@ -8,6 +8,8 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
@@ -74,6 +76,14 @@ type Server struct {
 
 	logSink     *system.SinkPool
 	installSink *system.SinkPool
+
+	// The number of heavy filesystem operations currently running for the server.
+	operations atomic.Int32
+	// The background operations running for the server that can be canceled.
+	ops operationSet
+	// The searches running in the background for the server, and those that have
+	// finished but whose results have not yet expired.
+	searches asyncSearchSet
 }
 
 // New returns a new server instance with a context and all of the default
@@ -105,13 +115,35 @@ func New(client remote.Client) (*Server, error) {
 
 // CleanupForDestroy stops all running background tasks for this server that are
 // using the context on the server struct. This will cancel any running install
-// processes for the server as well.
+// processes for the server as well. Background file operations are waited for
+// so that they are no longer using the server files when they are removed.
 func (s *Server) CleanupForDestroy() {
 	s.CtxCancel()
+	if n := s.CancelOperations(operationDestroyWait); n > 0 {
+		s.Log().WithField("operations", n).Warn("background operations did not stop before the server was destroyed")
+	}
 	s.Events().Destroy()
 	s.DestroyAllSinks()
 	s.Websockets().CancelAll()
 	s.powerLock.Destroy()
+	s.Filesystem().CloseIndex()
+	s.removeStagedUploads()
+}
+
+// AcquireOperation reserves one of the slots for running a heavy filesystem
+// operation, such as a search or compressing files, returning false if the server
+// is already running the maximum number of operations allowed. The returned
+// function must be called once the operation has finished.
+func (s *Server) AcquireOperation() (func(), bool) {
+	limit := config.Get().Filesystem.MaxServerOperations
+	if limit <= 0 {
+		return func() {}, true
+	}
+	if s.operations.Add(1) > int32(limit) {
+		s.operations.Add(-1)
+		return nil, false
+	}
+	return func() { s.operations.Add(-1) }, true
 }
 
 // ID returns the UUID for the server instance.
@@ -240,6 +272,12 @@ func (s *Server) ReadLogfile(len int) ([]string, error) {
 	return s.Environment.Readlog(len)
 }
 
+// ReadLogfileSince reads up to the given number of lines from the console log
+// that were written after the given time, see ProcessEnvironment.ReadlogSince.
+func (s *Server) ReadLogfileSince(since time.Time, lines int) ([]environment.LogLine, bool, error) {
+	return s.Environment.ReadlogSince(since, lines)
+}
+
 // Initializes a server instance. This will run through and ensure that the environment
 // for the server is setup, and that all of the necessary files are created.
 func (s *Server) CreateEnvironment() error {
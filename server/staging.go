@@ -0,0 +1,168 @@
+package server
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/google/uuid"
+
+	"github.com/kristiangarcia/wings/config"
+)
+
+// stagedUploadTTL is how long a staged upload is kept before it is removed if it
+// has not been installed.
+const stagedUploadTTL = time.Hour
+
+// maxStagedUploads is the number of uploads that can be staged for a server at
+// once. Together with the upload limit this bounds how much of the temporary
+// directory a single server can use.
+const maxStagedUploads = 16
+
+// ErrStagedUploadNotFound is returned when a staged upload does not exist, or
+// has already expired.
+var ErrStagedUploadNotFound = errors.Sentinel("server: staged upload not found")
+
+// ErrTooManyStagedUploads is returned when a server already has the maximum
+// number of staged uploads waiting to be installed.
+var ErrTooManyStagedUploads = errors.Sentinel("server: too many staged uploads")
+
+// stagingDir returns the directory that uploads waiting to be installed into the
+// server directory are kept in. This is outside of the server directory so that
+// an incomplete upload never appears among the server files.
+func (s *Server) stagingDir() string {
+	return filepath.Join(config.Get().System.TmpDirectory, "staging", s.ID())
+}
+
+// stagedUploadPath returns the path of the staged upload with the given ID,
+// returning an error if the ID is not a valid staged upload ID.
+func (s *Server) stagedUploadPath(id string) (string, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return "", errors.WithStack(ErrStagedUploadNotFound)
+	}
+	return filepath.Join(s.stagingDir(), id), nil
+}
+
+// StageUpload writes the contents of r, which must be exactly size bytes, to a
+// new staged upload and returns its ID. The upload can then be installed into
+// the server directory with InstallStagedUpload. If writing the upload fails
+// nothing is left behind. Any staged uploads that are older than an hour are
+// removed at the same time.
+//
+// Staged uploads count towards the disk space of the server, so an upload is
+// rejected if it and every other staged upload would not fit in the server
+// directory once installed, or if the server already has too many uploads
+// staged.
+func (s *Server) StageUpload(r io.Reader, size int64) (string, error) {
+	s.removeExpiredUploads()
+
+	count, staged := s.stagedUploadUsage()
+	if count >= maxStagedUploads {
+		return "", errors.WithStack(ErrTooManyStagedUploads)
+	}
+	if err := s.Filesystem().HasSpaceFor(staged + size); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(s.stagingDir(), 0o700); err != nil {
+		return "", errors.Wrap(err, "server: failed to create staging directory")
+	}
+	id := uuid.New().String()
+	p := filepath.Join(s.stagingDir(), id)
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", errors.Wrap(err, "server: failed to create staged upload")
+	}
+	n, err := io.Copy(f, io.LimitReader(r, size))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n != size {
+		err = errors.Errorf("server: staged upload is %d bytes but %d were expected", n, size)
+	}
+	if err != nil {
+		_ = os.Remove(p)
+		return "", errors.WrapIf(err, "server: failed to write staged upload")
+	}
+	return id, nil
+}
+
+// OpenStagedUpload opens the staged upload with the given ID for reading,
+// returning its size along with it.
+func (s *Server) OpenStagedUpload(id string) (*os.File, int64, error) {
+	p, err := s.stagedUploadPath(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, 0, errors.WithStack(ErrStagedUploadNotFound)
+		}
+		return nil, 0, errors.Wrap(err, "server: failed to open staged upload")
+	}
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, 0, errors.Wrap(err, "server: failed to stat staged upload")
+	}
+	return f, st.Size(), nil
+}
+
+// RemoveStagedUpload deletes the staged upload with the given ID.
+func (s *Server) RemoveStagedUpload(id string) error {
+	p, err := s.stagedUploadPath(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return errors.WithStack(ErrStagedUploadNotFound)
+		}
+		return errors.Wrap(err, "server: failed to remove staged upload")
+	}
+	return nil
+}
+
+// removeExpiredUploads deletes every staged upload that has been waiting to be
+// installed for longer than stagedUploadTTL.
+func (s *Server) removeExpiredUploads() {
+	entries, err := os.ReadDir(s.stagingDir())
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < stagedUploadTTL {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.stagingDir(), e.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.Log().WithField("upload", e.Name()).WithField("error", err).Warn("failed to remove expired staged upload")
+		}
+	}
+}
+
+// stagedUploadUsage returns the number of staged uploads for the server and
+// their total size.
+func (s *Server) stagedUploadUsage() (int, int64) {
+	entries, err := os.ReadDir(s.stagingDir())
+	if err != nil {
+		return 0, 0
+	}
+	var size int64
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil {
+			size += info.Size()
+		}
+	}
+	return len(entries), size
+}
+
+// removeStagedUploads deletes every staged upload for the server.
+func (s *Server) removeStagedUploads() {
+	if err := os.RemoveAll(s.stagingDir()); err != nil {
+		s.Log().WithField("error", err).Warn("failed to remove staged uploads")
+	}
+}
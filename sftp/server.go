@@ -10,7 +10,6 @@ import (
 	"os"
 	"path"
 	"regexp"
-	"strconv"
 	"strings"
 
 	"emperror.dev/errors"
@@ -43,7 +42,7 @@ func New(m *server.Manager) *SFTPServer {
 		manager:  m,
 		BasePath: cfg.Data,
 		ReadOnly: cfg.Sftp.ReadOnly,
-		Listen:   cfg.Sftp.Address + ":" + strconv.Itoa(cfg.Sftp.Port),
+		Listen:   cfg.Sftp.ListenAddress(),
 	}
 }
 